@@ -273,7 +273,7 @@ function EditorContent(props: {
           return (
             <span
               key={`invalid-${index}`}
-              title={`Unknown token ${segment.value}`}
+              title={`Unknown token or function ${segment.value}`}
               className={`inline-flex rounded-full border px-1.25 py-px align-middle text-[9px] font-semibold leading-4 ${
                 props.muted
                   ? "border-[#8A1538]/20 bg-[#FFE8E8]/75 text-[#8A1538]/45 dark:border-[#FFB6C1]/15 dark:bg-[#3B1622]/45 dark:text-[#FFB6C1]/40"
@@ -51,6 +51,18 @@ export const DOWNLOAD_PATTERN_TOKENS: DownloadPatternToken[] = [
     description: "Sanitized one-word submission type such as picture, series, video, or music.",
     example: "series",
   },
+  {
+    name: "type",
+    label: "Type (short)",
+    description: "Alias for the submission type.",
+    example: "series",
+  },
+  {
+    name: "date",
+    label: "Date",
+    description: "Submission upload date as year-month-day.",
+    example: "2026-03-09",
+  },
   {
     name: "year",
     label: "Year",
@@ -125,6 +137,12 @@ export const DOWNLOAD_PATTERN_TOKENS: DownloadPatternToken[] = [
     description: "1-based file order inside the submission.",
     example: "3",
   },
+  {
+    name: "page",
+    label: "Page",
+    description: "Alias for the file number.",
+    example: "3",
+  },
   {
     name: "ext",
     label: "Ext",
@@ -170,7 +188,100 @@ for (const token of DOWNLOAD_PATTERN_TOKENS) {
   TOKEN_MAP.set(token.name, token)
 }
 
-const TOKEN_RE = /\{([a-z0-9_]+)\}/g
+// Tokens can be piped through functions, as in {title|slug|truncate:40}. The
+// functions match those of pkg/app/downloads/template.go.
+const TOKEN_RE = /\{([a-z0-9_]+)((?:\|[^{}|/\\]+)*)\}/g
+
+const GO_DATE_RE = /January|Jan|2006|01|02|15|04|05|06/g
+const MONTH_NAMES = [
+  "January",
+  "February",
+  "March",
+  "April",
+  "May",
+  "June",
+  "July",
+  "August",
+  "September",
+  "October",
+  "November",
+  "December",
+]
+
+const PATTERN_FUNCTIONS: Record<string, (value: string, arg: string) => string | null> = {
+  lower: (value) => value.toLowerCase(),
+  upper: (value) => value.toUpperCase(),
+  slug: (value) =>
+    value
+      .toLowerCase()
+      .split(/[^\p{L}\p{N}]+/u)
+      .filter(Boolean)
+      .join("-"),
+  truncate: (value, arg) => {
+    const n = patternCount(arg)
+    return n === null ? null : [...value].slice(0, n).join("").trim()
+  },
+  pad: (value, arg) => {
+    const n = patternCount(arg)
+    return n === null ? null : value.padStart(n, "0")
+  },
+  replace: (value, arg) => {
+    const separator = arg.indexOf("=")
+    if (separator <= 0) {
+      return null
+    }
+    return value.replaceAll(arg.slice(0, separator), arg.slice(separator + 1))
+  },
+  date: (value, arg) => {
+    if (!arg) {
+      return null
+    }
+    const match = /^(\d{4})-(\d{2})-(\d{2})(?:[ T](\d{2}):(\d{2}):(\d{2}))?/.exec(value)
+    if (!match) {
+      return value
+    }
+    const [, year, month, day, hour = "00", minute = "00", second = "00"] = match
+    const monthName = MONTH_NAMES[Number(month) - 1] ?? ""
+    const parts: Record<string, string> = {
+      January: monthName,
+      Jan: monthName.slice(0, 3),
+      "2006": year,
+      "01": month,
+      "02": day,
+      "15": hour,
+      "04": minute,
+      "05": second,
+      "06": year.slice(2),
+    }
+    return arg.replaceAll(GO_DATE_RE, (part) => parts[part] ?? part)
+  },
+}
+
+function patternCount(arg: string): number | null {
+  const n = Number(arg.trim())
+  return Number.isInteger(n) && n > 0 ? n : null
+}
+
+// applyPatternFunctions pipes value through the functions of a token, or
+// returns null when one of them is unknown or given a bad argument.
+function applyPatternFunctions(value: string, pipes: string): string | null {
+  let result: string | null = value
+  for (const call of pipes.split("|").slice(1)) {
+    const separator = call.indexOf(":")
+    const name = (separator < 0 ? call : call.slice(0, separator)).trim()
+    const arg = separator < 0 ? "" : call.slice(separator + 1)
+    const fn = PATTERN_FUNCTIONS[name]
+    if (!fn || result === null) {
+      return null
+    }
+    result = fn(result, arg)
+  }
+  return result
+}
+
+function validToken(name: string, pipes: string): boolean {
+  return TOKEN_MAP.has(name) && applyPatternFunctions("", pipes) !== null
+}
 const PREVIEW_POOLS = [
   { pool_id: "7001", pool_name: "season-one" },
   { pool_id: "7002", pool_name: "favorites" },
@@ -183,6 +294,8 @@ const PREVIEW_VALUES: Record<string, string> = {
   rating: "adult",
   public: "public",
   submission_type: "series",
+  type: "series",
+  date: "2026-03-09",
   year: "2026",
   month: "03",
   day: "09",
@@ -195,6 +308,7 @@ const PREVIEW_VALUES: Record<string, string> = {
   submission_name: "Star Patrol",
   submission_name_auto_omit: "Star Patrol",
   number: "3",
+  page: "3",
   ext: "png",
   extension: "png",
   submission_id: "908172",
@@ -205,13 +319,13 @@ export function tokenizeDownloadPattern(value: string): DownloadPatternSegment[]
   const segments: DownloadPatternSegment[] = []
   let lastIndex = 0
 
-  value.replaceAll(TOKEN_RE, (match, name: string, offset: number) => {
+  value.replaceAll(TOKEN_RE, (match, name: string, pipes: string, offset: number) => {
     if (offset > lastIndex) {
       segments.push({ kind: "text", value: value.slice(lastIndex, offset) })
     }
 
     const token = TOKEN_MAP.get(name)
-    if (token) {
+    if (token && validToken(name, pipes)) {
       segments.push({ kind: "token", value: match, token })
     } else {
       segments.push({ kind: "invalid", value: match, name })
@@ -231,8 +345,8 @@ export function tokenizeDownloadPattern(value: string): DownloadPatternSegment[]
 export function collectUnknownDownloadTokens(value: string): string[] {
   const unknown = new Set<string>()
 
-  value.replaceAll(TOKEN_RE, (match, name: string) => {
-    if (!TOKEN_MAP.has(name)) {
+  value.replaceAll(TOKEN_RE, (match, name: string, pipes: string) => {
+    if (!validToken(name, pipes)) {
       unknown.add(match)
     }
     return match
@@ -244,16 +358,19 @@ export function collectUnknownDownloadTokens(value: string): string[] {
 export function renderDownloadPatternPreview(pattern: string): string[] {
   const activePattern = pattern.trim() || DEFAULT_DOWNLOAD_PATTERN
   const normalized = activePattern.replaceAll("\\", "/")
-  const usesPoolTokens = normalized.includes("{pool_id}") || normalized.includes("{pool_name}")
+  const usesPoolTokens = [...normalized.matchAll(TOKEN_RE)].some(
+    ([, name]) => name === "pool_id" || name === "pool_name",
+  )
   const contexts = usesPoolTokens ? PREVIEW_POOLS : [{}]
 
   return contexts.map((poolValues) => {
     const poolRecord = poolValues as Record<string, string>
-    const rendered = normalized.replaceAll(TOKEN_RE, (match, name: string) => {
-      if (name in poolRecord) {
-        return poolRecord[name] ?? ""
+    const rendered = normalized.replaceAll(TOKEN_RE, (match, name: string, pipes: string) => {
+      const value = name in poolRecord ? (poolRecord[name] ?? "") : PREVIEW_VALUES[name]
+      if (value === undefined) {
+        return match
       }
-      return PREVIEW_VALUES[name] ?? match
+      return applyPatternFunctions(value, pipes) ?? match
     })
     return normalizePreviewPath(rendered)
   })
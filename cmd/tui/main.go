@@ -1,6 +1,8 @@
 package main
 
 import (
+	"os"
+
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/buildinfo"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/modes"
@@ -9,10 +11,12 @@ import (
 var _ = buildinfo.Version
 
 func main() {
-	defer modes.InitLogging()()
 	config := flags.Parse()
+	closeLog := modes.InitLogging(config)
 	config.NoTUI = false
 	config.Headless = false
 	config.TUI = true
 	modes.RunTUI(config)
+	closeLog()
+	os.Exit(modes.ExitCode())
 }
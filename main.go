@@ -13,6 +13,7 @@ import (
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/buildinfo"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/remote"
 	desktopapp "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/state"
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/modes"
 )
@@ -23,17 +24,19 @@ var assets embed.FS
 func main() {
 	config := flags.Parse()
 	if forceTUI(os.Args[1:]) || config.TUI {
-		defer modes.InitLogging()()
+		closeLog := modes.InitLogging(config)
 		config.NoTUI = false
 		config.Headless = false
 		modes.RunTUI(config)
-		return
+		closeLog()
+		os.Exit(modes.ExitCode())
 	}
 	if config.Headless {
-		defer modes.InitLogging()()
+		closeLog := modes.InitLogging(config)
 		config.NoTUI = true
 		modes.RunHeadless(config)
-		return
+		closeLog()
+		os.Exit(modes.ExitCode())
 	}
 
 	app := desktopapp.NewApp()
@@ -48,8 +51,12 @@ func main() {
 		})
 	})
 
+	title := fmt.Sprintf("Inkbunny Downloader [%s]", buildinfo.DisplayVersion())
+	if library := appstorage.ActiveLibrary(); library != "" {
+		title = fmt.Sprintf("%s - %s", title, library)
+	}
 	err := wails.Run(&options.App{
-		Title:     fmt.Sprintf("Inkbunny Downloader [%s]", buildinfo.DisplayVersion()),
+		Title:     title,
 		MinWidth:  1280,
 		MinHeight: 860,
 		Width:     1440,
@@ -0,0 +1,48 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+var ErrInvalidAIFilter = errors.New("invalid AI filter, expected all, exclude or only")
+
+// AI filters, which keep every submission when empty.
+const (
+	AIExclude = "exclude"
+	AIOnly    = "only"
+)
+
+// aiKeywords are the keywords Inkbunny asks AI-generated and AI-assisted
+// submissions to be tagged with. The API marks them no other way.
+var aiKeywords = []string{"ai generated", "ai assisted", "ai art"}
+
+func ParseAIFilter(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", "all", "any":
+		return "", nil
+	case AIExclude, AIOnly:
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidAIFilter, value)
+}
+
+// AIGenerated reports whether the submission is tagged as made with AI.
+func AIGenerated(submission inkbunny.SubmissionDetails) bool {
+	return len(MatchCharacters(submission, aiKeywords)) > 0
+}
+
+// KeepsAI reports whether the submission passes filter.
+func KeepsAI(filter string, submission inkbunny.SubmissionDetails) bool {
+	switch filter {
+	case AIExclude:
+		return !AIGenerated(submission)
+	case AIOnly:
+		return AIGenerated(submission)
+	}
+	return true
+}
@@ -0,0 +1,185 @@
+package downloads
+
+import (
+	"archive/zip"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+var ErrInvalidArchiveFormat = errors.New("invalid archive format, expected cbz or zip")
+
+// Archive formats. Both are zip files, cbz being what comic readers open.
+const (
+	ArchiveCBZ = "cbz"
+	ArchiveZIP = "zip"
+)
+
+func ParseArchiveFormat(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", ArchiveCBZ, ArchiveZIP:
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidArchiveFormat, value)
+}
+
+// ArchivePage is a downloaded file of a submission and its place among the
+// files of the submission.
+type ArchivePage struct {
+	Order int
+	Path  string
+}
+
+// ArchivePath is where the archive of a submission goes: beside its first
+// page, named after the submission.
+func ArchivePath(submission inkbunny.SubmissionDetails, pages []ArchivePage, format string) string {
+	if len(pages) == 0 {
+		return ""
+	}
+	first := slices.MinFunc(pages, func(a, b ArchivePage) int { return cmp.Compare(a.Order, b.Order) })
+	folder := filepath.Dir(first.Path)
+	name := sanitizePathComponent(strings.TrimSpace(submission.SubmissionID.String() + " - " + submission.Title))
+	return filepath.Join(folder, cmp.Or(name, submission.SubmissionID.String())+"."+format)
+}
+
+// WriteArchive packages pages in the order of the submission into an archive at
+// path, named 001.png, 002.jpg and so on, with a ComicInfo.xml describing the
+// submission. The archive replaces the one written before.
+func WriteArchive(path string, submission inkbunny.SubmissionDetails, pages []ArchivePage) error {
+	pages = slices.Clone(pages)
+	slices.SortStableFunc(pages, func(a, b ArchivePage) int { return cmp.Compare(a.Order, b.Order) })
+
+	temp := path + ".archiving"
+	out, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp)
+
+	archive := zip.NewWriter(out)
+	padding := max(3, len(fmt.Sprint(len(pages))))
+	for n, page := range pages {
+		name := fmt.Sprintf("%0*d%s", padding, n+1, strings.ToLower(filepath.Ext(page.Path)))
+		if err := addArchivePage(archive, name, page.Path); err != nil {
+			out.Close()
+			return fmt.Errorf("archive %s: %w", page.Path, err)
+		}
+	}
+	info, err := xml.MarshalIndent(newComicInfo(submission, len(pages)), "", "  ")
+	if err != nil {
+		out.Close()
+		return err
+	}
+	writer, err := archive.Create("ComicInfo.xml")
+	if err == nil {
+		_, err = writer.Write(append([]byte(xml.Header), info...))
+	}
+	if err == nil {
+		err = archive.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// addArchivePage stores the file at path as name. Images are compressed
+// already, so they are stored as they are.
+func addArchivePage(archive *zip.Writer, name, path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	stat, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(stat)
+	if err != nil {
+		return err
+	}
+	header.Name, header.Method = name, zip.Store
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, in)
+	return err
+}
+
+// comicInfo is the ComicInfo.xml that comic readers take the title, artist,
+// tags and page order of an archive from.
+type comicInfo struct {
+	XMLName     xml.Name        `xml:"ComicInfo"`
+	Title       string          `xml:"Title,omitempty"`
+	Summary     string          `xml:"Summary,omitempty"`
+	Year        int             `xml:"Year,omitempty"`
+	Month       int             `xml:"Month,omitempty"`
+	Day         int             `xml:"Day,omitempty"`
+	Writer      string          `xml:"Writer,omitempty"`
+	Penciller   string          `xml:"Penciller,omitempty"`
+	Publisher   string          `xml:"Publisher,omitempty"`
+	Tags        string          `xml:"Tags,omitempty"`
+	Web         string          `xml:"Web,omitempty"`
+	PageCount   int             `xml:"PageCount"`
+	LanguageISO string          `xml:"LanguageISO,omitempty"`
+	AgeRating   string          `xml:"AgeRating,omitempty"`
+	Pages       []comicInfoPage `xml:"Pages>Page"`
+}
+
+type comicInfoPage struct {
+	Image int    `xml:"Image,attr"`
+	Type  string `xml:"Type,attr,omitempty"`
+}
+
+// comicAgeRatings maps the ratings of Inkbunny onto those of ComicInfo.xml.
+var comicAgeRatings = map[string]string{
+	"general": "Everyone",
+	"mature":  "Mature 17+",
+	"adult":   "Adults Only 18+",
+}
+
+func newComicInfo(submission inkbunny.SubmissionDetails, pages int) comicInfo {
+	keywords := make([]string, 0, len(submission.Keywords))
+	for _, keyword := range submission.Keywords {
+		keywords = append(keywords, keyword.KeywordName)
+	}
+	info := comicInfo{
+		Title:     strings.TrimSpace(submission.Title),
+		Summary:   strings.TrimSpace(BBCodeToMarkdown(submission.Description)),
+		Writer:    submission.Username,
+		Penciller: submission.Username,
+		Publisher: "Inkbunny",
+		Tags:      strings.Join(keywords, ", "),
+		Web:       fmt.Sprintf("https://inkbunny.net/s/%s", submission.SubmissionID),
+		PageCount: pages,
+		AgeRating: comicAgeRatings[strings.ToLower(strings.TrimSpace(submission.RatingName))],
+	}
+	if language := DetectLanguage(submission); language != LanguageUnknown {
+		info.LanguageISO = language
+	}
+	if uploaded := UploadTime(submission, inkbunny.File{}, TimeZoneUTC); !uploaded.IsZero() {
+		info.Year, info.Month, info.Day = uploaded.Year(), int(uploaded.Month()), uploaded.Day()
+	}
+	for n := range pages {
+		page := comicInfoPage{Image: n}
+		if n == 0 {
+			page.Type = "FrontCover"
+		}
+		info.Pages = append(info.Pages, page)
+	}
+	return info
+}
@@ -14,19 +14,24 @@ func DeleteTaskArtifacts(task Task) error {
 	if err != nil {
 		return err
 	}
+	index, _ := LibraryIndex(task.DownloadRoot)
+	if err := index.Forget(task.FileID); err != nil {
+		return err
+	}
 	for _, destination := range destinations {
-		if err := os.Remove(destination); err != nil && !os.IsNotExist(err) {
-			return err
-		}
-		if !task.SaveKeywords {
-			continue
-		}
-		metadataPath, ok := trustedArtifactPath(task.DownloadRoot, submissionMetadataPath(destination))
-		if !ok {
-			return errInvalidArtifactPath
+		for _, path := range []string{destination, PartPath(destination)} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
 		}
-		if err := os.Remove(metadataPath); err != nil && !os.IsNotExist(err) {
-			return err
+		for _, sidecar := range SidecarPaths(destination, task.Sidecars) {
+			sidecarPath, ok := trustedArtifactPath(task.DownloadRoot, sidecar)
+			if !ok {
+				return errInvalidArtifactPath
+			}
+			if err := os.Remove(sidecarPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
 		}
 	}
 	return nil
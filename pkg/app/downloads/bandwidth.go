@@ -0,0 +1,171 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidRate = errors.New("invalid rate, expected bytes per second such as 500K or 2M")
+	ErrInvalidSize = errors.New("invalid size, expected bytes such as 500K or 2G")
+)
+
+// ParseRate parses a download rate in bytes per second, such as "2M". An empty
+// rate is unlimited.
+func ParseRate(value string) (int64, error) {
+	rate, ok := parseBytes(value)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidRate, strings.TrimSpace(value))
+	}
+	return rate, nil
+}
+
+// ParseSize parses a number of bytes written like a rate, such as "1.5G".
+func ParseSize(value string) (int64, error) {
+	size, ok := parseBytes(value)
+	if !ok || size == 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSize, strings.TrimSpace(value))
+	}
+	return size, nil
+}
+
+// parseBytes reads a number of bytes with an optional K, M or G suffix in
+// powers of 1024. An empty value is 0.
+func parseBytes(value string) (int64, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, true
+	}
+	number, unit := value, int64(1)
+	switch suffix := strings.ToUpper(value[len(value)-1:]); suffix {
+	case "K", "M", "G":
+		unit = 1 << (10 * (strings.Index("KMG", suffix) + 1))
+		number = value[:len(value)-1]
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil || n <= 0 || n*float64(unit) < 1 {
+		return 0, false
+	}
+	return int64(n * float64(unit)), true
+}
+
+// FormatSize writes a number of bytes for people, such as "1.5 MiB".
+func FormatSize(size float64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%.0f B", size)
+	}
+	exponent := 0
+	for size >= unit*unit && exponent < 3 {
+		size /= unit
+		exponent++
+	}
+	return fmt.Sprintf("%.1f %ciB", size/unit, "KMGT"[exponent])
+}
+
+// RateLimiter is a token bucket shared by every download, capping how fast
+// they read together. A nil limiter reads at full speed.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	// A burst of a tenth of a second keeps reads small enough that workers
+	// take turns instead of one of them draining a second's worth at once.
+	burst := max(float64(bytesPerSecond)/10, 32<<10)
+	return &RateLimiter{rate: float64(bytesPerSecond), burst: burst, tokens: burst, last: time.Now()}
+}
+
+// take blocks until some of n bytes may be read and returns how many.
+func (l *RateLimiter) take(n int) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for {
+		now := time.Now()
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+		l.last = now
+		if l.tokens >= 1 {
+			n = min(n, int(l.tokens))
+			l.tokens -= float64(n)
+			return n
+		}
+		// Holding the lock while asleep queues the other workers behind this
+		// one, so they are served in turn.
+		time.Sleep(time.Duration((1 - l.tokens) / l.rate * float64(time.Second)))
+	}
+}
+
+// giveBack returns the tokens of bytes that were taken but not read.
+func (l *RateLimiter) giveBack(n int) {
+	l.mu.Lock()
+	l.tokens = min(l.burst, l.tokens+float64(n))
+	l.mu.Unlock()
+}
+
+// Reader returns r read no faster than the limit allows, together with every
+// other reader of l.
+func (l *RateLimiter) Reader(r io.ReadCloser) io.ReadCloser {
+	if l == nil {
+		return r
+	}
+	return limitedBody{ReadCloser: r, limit: l}
+}
+
+// Client returns an HTTP client whose response bodies are read under the
+// limit.
+func (l *RateLimiter) Client(client *http.Client) *http.Client {
+	if l == nil {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	limited := *client
+	limited.Transport = limitedTransport{base: base, limit: l}
+	return &limited
+}
+
+type limitedBody struct {
+	io.ReadCloser
+	limit *RateLimiter
+}
+
+func (b limitedBody) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return b.ReadCloser.Read(p)
+	}
+	allowed := b.limit.take(len(p))
+	n, err := b.ReadCloser.Read(p[:allowed])
+	if n < allowed {
+		b.limit.giveBack(allowed - n)
+	}
+	return n, err
+}
+
+type limitedTransport struct {
+	base  http.RoundTripper
+	limit *RateLimiter
+}
+
+func (t limitedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	resp.Body = t.limit.Reader(resp.Body)
+	return resp, nil
+}
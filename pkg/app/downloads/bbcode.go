@@ -0,0 +1,255 @@
+package downloads
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// bbcodeTag converts one Inkbunny BBCode tag, given the value after its = and
+// the already converted text between its opening and closing tag.
+type bbcodeTag struct {
+	name     string
+	markdown func(value, inner string) string
+	html     func(value, inner string) string
+}
+
+func userLink(markdown bool) func(string, string) string {
+	return func(_, inner string) string {
+		user := strings.TrimSpace(inner)
+		link := "https://inkbunny.net/" + url.PathEscape(user)
+		if markdown {
+			return fmt.Sprintf("[%s](%s)", user, link)
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, link, user)
+	}
+}
+
+func keepInner(_, inner string) string { return inner }
+
+var bbcodeTags = []bbcodeTag{
+	{"b", func(_, inner string) string { return "**" + inner + "**" }, htmlElement("strong")},
+	{"i", func(_, inner string) string { return "*" + inner + "*" }, htmlElement("em")},
+	{"u", keepInner, htmlElement("u")},
+	{"s", func(_, inner string) string { return "~~" + inner + "~~" }, htmlElement("s")},
+	{"t", func(_, inner string) string { return "\n### " + strings.TrimSpace(inner) + "\n" }, htmlElement("h3")},
+	{"q", markdownQuote, htmlElement("blockquote")},
+	{"quote", markdownQuote, htmlElement("blockquote")},
+	{"code", func(_, inner string) string { return "`" + inner + "`" }, htmlElement("code")},
+	{"left", keepInner, htmlAligned("left")},
+	{"center", keepInner, htmlAligned("center")},
+	{"right", keepInner, htmlAligned("right")},
+	{"color", keepInner, func(value, inner string) string {
+		return fmt.Sprintf(`<span style="color: %s">%s</span>`, cssColor(value), inner)
+	}},
+	{"url", func(value, inner string) string {
+		target := cmp.Or(value, strings.TrimSpace(inner))
+		if !webLink(target) {
+			return inner
+		}
+		if value == "" {
+			return "<" + target + ">"
+		}
+		return fmt.Sprintf("[%s](%s)", inner, target)
+	}, func(value, inner string) string {
+		// The text was escaped before it was converted, so the link is too.
+		target := cmp.Or(value, strings.TrimSpace(inner))
+		if !webLink(html.UnescapeString(target)) {
+			return inner
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, target, inner)
+	}},
+	{"name", userLink(true), userLink(false)},
+	{"icon", userLink(true), userLink(false)},
+	{"iconname", userLink(true), userLink(false)},
+}
+
+// bbcodeTagREs match a pair of each tag in bbcodeTags. Nested pairs of the
+// same tag are converted over several passes.
+var bbcodeTagREs = func() map[string]*regexp.Regexp {
+	res := make(map[string]*regexp.Regexp, len(bbcodeTags))
+	for _, tag := range bbcodeTags {
+		res[tag.name] = regexp.MustCompile(fmt.Sprintf(`(?is)\[%[1]s(?:=([^\]]*))?\](.*?)\[/%[1]s\]`, tag.name))
+	}
+	return res
+}()
+
+// maxBBCodeDepth bounds how deeply nested tags are converted, so that a
+// description made of nothing but tags cannot take long.
+const maxBBCodeDepth = 16
+
+// BBCodeToMarkdown converts the BBCode of an Inkbunny description or story
+// to Markdown. Tags without a Markdown form, such as colors, keep their text.
+func BBCodeToMarkdown(text string) string {
+	return convertBBCode(normalizeNewlines(text), func(tag bbcodeTag) func(string, string) string { return tag.markdown })
+}
+
+// BBCodeToHTML converts the BBCode of an Inkbunny description or story to an
+// HTML fragment, escaping the text itself.
+func BBCodeToHTML(text string) string {
+	converted := convertBBCode(html.EscapeString(normalizeNewlines(text)), func(tag bbcodeTag) func(string, string) string { return tag.html })
+	return strings.ReplaceAll(converted, "\n", "<br>\n")
+}
+
+func convertBBCode(text string, convert func(bbcodeTag) func(string, string) string) string {
+	for range maxBBCodeDepth {
+		changed := false
+		for _, tag := range bbcodeTags {
+			re, to := bbcodeTagREs[tag.name], convert(tag)
+			text = re.ReplaceAllStringFunc(text, func(match string) string {
+				changed = true
+				groups := re.FindStringSubmatch(match)
+				return to(strings.TrimSpace(groups[1]), groups[2])
+			})
+		}
+		if !changed {
+			break
+		}
+	}
+	return text
+}
+
+func normalizeNewlines(text string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(text, "\r\n", "\n"), "\r", "\n")
+}
+
+func markdownQuote(_, inner string) string {
+	lines := strings.Split(strings.Trim(inner, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = "> " + line
+	}
+	return "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+func htmlElement(name string) func(string, string) string {
+	return func(_, inner string) string {
+		return "<" + name + ">" + inner + "</" + name + ">"
+	}
+}
+
+func htmlAligned(align string) func(string, string) string {
+	return func(_, inner string) string {
+		return fmt.Sprintf(`<div style="text-align: %s">%s</div>`, align, inner)
+	}
+}
+
+var cssColorRE = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// cssColor keeps value only when it is a plain color name or hex color.
+func cssColor(value string) string {
+	if cssColorRE.MatchString(value) {
+		return value
+	}
+	return "inherit"
+}
+
+func webLink(target string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(target))
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https")
+}
+
+var ErrInvalidDescriptionFormat = errors.New("invalid description format, expected markdown or html")
+
+// Description export formats, see types.SidecarOptions.DescriptionExport.
+const (
+	DescriptionMarkdown = "markdown"
+	DescriptionHTML     = "html"
+)
+
+// descriptionExtensions are the extensions of the description export in each
+// format.
+var descriptionExtensions = map[string]string{
+	DescriptionMarkdown: ".description.md",
+	DescriptionHTML:     ".description.html",
+}
+
+func ParseDescriptionFormat(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "":
+		return "", nil
+	case "md":
+		return DescriptionMarkdown, nil
+	case DescriptionMarkdown, DescriptionHTML:
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidDescriptionFormat, value)
+}
+
+// DescriptionExportPath is where the description of the submission behind
+// destination is exported to in format.
+func DescriptionExportPath(destination, submissionID, format string) string {
+	clean := filepath.Clean(strings.TrimSpace(destination))
+	extension, ok := descriptionExtensions[format]
+	if clean == "." || clean == "" || submissionID == "" || !ok {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(clean), submissionID+extension)
+}
+
+// writeDescriptionExports writes the description and story of a submission,
+// converted from BBCode, into every folder of destinations.
+func writeDescriptionExports(destinations []string, details SubmissionFileMetadata, format string) error {
+	var payload []byte
+	if format == DescriptionHTML {
+		payload = descriptionHTML(details)
+	} else {
+		payload = descriptionMarkdown(details)
+	}
+	written := make(map[string]bool)
+	for _, destination := range uniqueNonEmptyPaths(destinations) {
+		path := DescriptionExportPath(destination, details.SubmissionID.String(), format)
+		if path == "" || written[path] {
+			continue
+		}
+		written[path] = true
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, payload, 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func descriptionMarkdown(details SubmissionFileMetadata) []byte {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# %s\n\n", strings.TrimSpace(details.Title))
+	fmt.Fprintf(&builder, "by [%[1]s](https://inkbunny.net/%[1]s) · <https://inkbunny.net/s/%[2]s>\n", strings.TrimSpace(details.Username), details.SubmissionID)
+	if description := strings.TrimSpace(details.Description); description != "" {
+		builder.WriteString("\n")
+		builder.WriteString(strings.TrimSpace(BBCodeToMarkdown(description)))
+		builder.WriteString("\n")
+	}
+	if writing := strings.TrimSpace(details.Writing); writing != "" {
+		builder.WriteString("\n---\n\n")
+		builder.WriteString(strings.TrimSpace(BBCodeToMarkdown(writing)))
+		builder.WriteString("\n")
+	}
+	return []byte(builder.String())
+}
+
+func descriptionHTML(details SubmissionFileMetadata) []byte {
+	title := html.EscapeString(strings.TrimSpace(details.Title))
+	artist := html.EscapeString(strings.TrimSpace(details.Username))
+	var builder strings.Builder
+	builder.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&builder, "<title>%s</title>\n</head>\n<body>\n", title)
+	fmt.Fprintf(&builder, "<h1>%s</h1>\n", title)
+	fmt.Fprintf(&builder, "<p>by <a href=\"https://inkbunny.net/%[1]s\">%[1]s</a> · <a href=\"https://inkbunny.net/s/%[2]s\">https://inkbunny.net/s/%[2]s</a></p>\n", artist, details.SubmissionID)
+	if description := strings.TrimSpace(details.Description); description != "" {
+		fmt.Fprintf(&builder, "<div class=\"description\">\n%s\n</div>\n", BBCodeToHTML(description))
+	}
+	if writing := strings.TrimSpace(details.Writing); writing != "" {
+		fmt.Fprintf(&builder, "<hr>\n<div class=\"writing\">\n%s\n</div>\n", BBCodeToHTML(writing))
+	}
+	builder.WriteString("</body>\n</html>\n")
+	return []byte(builder.String())
+}
@@ -0,0 +1,21 @@
+package downloads
+
+import "github.com/ellypaws/inkbunny"
+
+// ParseKeywordBlacklist splits a comma separated keyword blacklist as typed in
+// the TUI or on the command line. Keywords are written and matched the way
+// characters are, ignoring case and whether words are joined by spaces or
+// underscores.
+func ParseKeywordBlacklist(value string) []string {
+	return ParseCharacters(value)
+}
+
+// BlacklistedKeyword returns the first keyword of blacklist that the
+// submission is tagged with, which leaves the submission out of downloads.
+func BlacklistedKeyword(submission inkbunny.SubmissionDetails, blacklist []string) (string, bool) {
+	matched := MatchCharacters(submission, blacklist)
+	if len(matched) == 0 {
+		return "", false
+	}
+	return matched[0], true
+}
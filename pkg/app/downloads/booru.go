@@ -0,0 +1,209 @@
+package downloads
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+var (
+	ErrInvalidBooru = errors.New("invalid booru, expected a url and a kind of szurubooru or danbooru")
+	ErrBooruUpload  = errors.New("booru upload failed")
+)
+
+// Kinds of booru, see types.BooruSettings.Kind.
+const (
+	BooruSzurubooru = "szurubooru"
+	BooruDanbooru   = "danbooru"
+)
+
+// booruClient bounds an upload, which runs in the download worker, so that a
+// booru that stops answering does not hold the worker up for good.
+var booruClient = &http.Client{Timeout: 10 * time.Minute}
+
+// Booru uploads downloaded files to an image board. A new kind only has to
+// implement it and be named in NewBooru.
+type Booru interface {
+	Upload(ctx context.Context, path string, details SubmissionFileMetadata) error
+}
+
+// NewBooru returns the uploader for the booru in settings.
+func NewBooru(settings types.BooruSettings) (Booru, error) {
+	url := strings.TrimRight(strings.TrimSpace(settings.URL), "/")
+	if url == "" {
+		return nil, ErrInvalidBooru
+	}
+	switch kind := strings.ToLower(strings.TrimSpace(settings.Kind)); kind {
+	case "", BooruSzurubooru:
+		return SzurubooruUploader{URL: url, Username: settings.Username, Token: settings.Token}, nil
+	case BooruDanbooru:
+		return DanbooruUploader{URL: url, Login: settings.Username, APIKey: settings.Token}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidBooru, kind)
+	}
+}
+
+// booruPostTags lists the tags of the post of details in the way boorus write
+// them, see booruTags, with the artists first.
+func booruPostTags(details SubmissionFileMetadata, artist func(string) string) []string {
+	artists := details.Artists
+	if len(artists) == 0 {
+		artists = []string{details.Username}
+	}
+	var tags []string
+	for _, name := range artists {
+		if tag := booruTags([]string{name}); tag != "" {
+			tags = append(tags, artist(strings.ToLower(tag)))
+		}
+	}
+	for _, keyword := range details.Keywords {
+		if tag := booruTags([]string{keyword.KeywordName}); tag != "" {
+			tags = append(tags, strings.ToLower(tag))
+		}
+	}
+	return tags
+}
+
+func submissionSource(details SubmissionFileMetadata) string {
+	return fmt.Sprintf("https://inkbunny.net/s/%s", details.SubmissionID)
+}
+
+// SzurubooruUploader creates a post for each file through the REST API of a
+// szurubooru instance, authenticating with a login token.
+type SzurubooruUploader struct {
+	URL      string
+	Username string
+	Token    string
+}
+
+// szurubooruSafety maps the ratings of Inkbunny onto szurubooru's.
+var szurubooruSafety = map[string]string{
+	"general": "safe",
+	"mature":  "sketchy",
+	"adult":   "unsafe",
+}
+
+type szurubooruError struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func (s SzurubooruUploader) Upload(ctx context.Context, path string, details SubmissionFileMetadata) error {
+	metadata, err := json.Marshal(map[string]any{
+		"tags":   booruPostTags(details, func(tag string) string { return tag }),
+		"safety": cmp.Or(szurubooruSafety[strings.ToLower(strings.TrimSpace(details.RatingName))], "unsafe"),
+		"source": submissionSource(details),
+	})
+	if err != nil {
+		return err
+	}
+	response, err := postMultipart(ctx, s.URL+"/api/posts/", path, "content", map[string]string{"metadata": string(metadata)}, func(request *http.Request) {
+		request.Header.Set("Accept", "application/json")
+		if s.Username != "" {
+			request.Header.Set("Authorization", "Token "+base64.StdEncoding.EncodeToString([]byte(s.Username+":"+s.Token)))
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%w: szurubooru: %w", ErrBooruUpload, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	var failure szurubooruError
+	_ = json.NewDecoder(io.LimitReader(response.Body, 1<<16)).Decode(&failure)
+	if failure.Name == "PostAlreadyUploadedError" {
+		return nil
+	}
+	return fmt.Errorf("%w: szurubooru: %s: %s", ErrBooruUpload, response.Status, failure.Description)
+}
+
+// DanbooruUploader uploads each file with the single request upload of
+// Danbooru 2, which Danbooru compatible boorus keep, authenticating with an
+// API key.
+type DanbooruUploader struct {
+	URL    string
+	Login  string
+	APIKey string
+}
+
+// danbooruRatings maps the ratings of Inkbunny onto Danbooru's.
+var danbooruRatings = map[string]string{
+	"general": "g",
+	"mature":  "q",
+	"adult":   "e",
+}
+
+func (d DanbooruUploader) Upload(ctx context.Context, path string, details SubmissionFileMetadata) error {
+	fields := map[string]string{
+		"upload[tag_string]": strings.Join(booruPostTags(details, func(tag string) string { return "artist:" + tag }), " "),
+		"upload[rating]":     cmp.Or(danbooruRatings[strings.ToLower(strings.TrimSpace(details.RatingName))], "e"),
+		"upload[source]":     submissionSource(details),
+	}
+	response, err := postMultipart(ctx, d.URL+"/uploads.json", path, "upload[file]", fields, func(request *http.Request) {
+		if d.Login != "" {
+			request.SetBasicAuth(d.Login, d.APIKey)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("%w: danbooru: %w", ErrBooruUpload, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("%w: danbooru: %s", ErrBooruUpload, response.Status)
+	}
+	return nil
+}
+
+// postMultipart posts fields and the file at path, under the form field file,
+// streaming the file rather than reading it into memory.
+func postMultipart(ctx context.Context, url string, path string, file string, fields map[string]string, authorize func(*http.Request)) (*http.Response, error) {
+	content, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer content.Close()
+
+	reader, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		writer.CloseWithError(writeMultipart(form, content, file, fields))
+	}()
+	defer reader.Close()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", form.FormDataContentType())
+	authorize(request)
+	return booruClient.Do(request)
+}
+
+func writeMultipart(form *multipart.Writer, content *os.File, file string, fields map[string]string) error {
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+	part, err := form.CreateFormFile(file, filepath.Base(content.Name()))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, content); err != nil {
+		return err
+	}
+	return form.Close()
+}
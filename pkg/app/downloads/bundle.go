@@ -0,0 +1,317 @@
+package downloads
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+)
+
+var (
+	ErrInvalidBundleStrip = errors.New("invalid bundle strip, expected none or any of artist, description, keywords, links and dates")
+	ErrBundleEmpty        = errors.New("none of the submissions to bundle are in the library")
+)
+
+// BundleStrip is what a bundle leaves out of what it shares. Bundles never
+// hold the paths, download times or sessions of the library they came from.
+type BundleStrip struct {
+	// Artist names the artists Artist 1, Artist 2 and so on.
+	Artist      bool
+	Description bool
+	Keywords    bool
+	// Links leaves out the submission IDs, links and MD5s that lead back to
+	// the submissions on Inkbunny.
+	Links bool
+	// Either Artist or Links also names the files by their place in the
+	// submission, as their names carry the file ID and the artist.
+	Dates bool
+}
+
+// ParseBundleStrip parses what to strip from a bundle, written as
+// "artist,links", "none" or "all".
+func ParseBundleStrip(value string) (BundleStrip, error) {
+	var strip BundleStrip
+	for part := range strings.SplitSeq(value, ",") {
+		switch part = strings.ToLower(strings.TrimSpace(part)); part {
+		case "", "none":
+		case "all":
+			strip = BundleStrip{Artist: true, Description: true, Keywords: true, Links: true, Dates: true}
+		case "artist":
+			strip.Artist = true
+		case "description":
+			strip.Description = true
+		case "keywords":
+			strip.Keywords = true
+		case "links":
+			strip.Links = true
+		case "dates":
+			strip.Dates = true
+		default:
+			return BundleStrip{}, fmt.Errorf("%w: %q", ErrInvalidBundleStrip, part)
+		}
+	}
+	return strip, nil
+}
+
+// bundleSubmission is the metadata.json of a submission in a bundle, and what
+// its index lists.
+type bundleSubmission struct {
+	SubmissionID string       `json:"submission_id,omitempty"`
+	URL          string       `json:"url,omitempty"`
+	Title        string       `json:"title"`
+	Artist       string       `json:"artist"`
+	Type         string       `json:"type,omitempty"`
+	Rating       string       `json:"rating,omitempty"`
+	Uploaded     string       `json:"uploaded,omitempty"`
+	Keywords     []string     `json:"keywords,omitempty"`
+	Description  string       `json:"description,omitempty"`
+	Files        []bundleFile `json:"files"`
+
+	// Folder is where the files are in the bundle, and DescriptionHTML the
+	// description the index shows, see BBCodeToHTML.
+	Folder          string        `json:"-"`
+	DescriptionHTML template.HTML `json:"-"`
+}
+
+type bundleFile struct {
+	Name string `json:"name"`
+	MD5  string `json:"md5,omitempty"`
+	Size int64  `json:"size"`
+
+	// Image is whether the index shows the file, and path where it is in
+	// the library.
+	Image bool `json:"-"`
+	path  string
+}
+
+// bundleIndex lists the submissions of a bundle with their files, opened from
+// the folder the bundle is unpacked in.
+var bundleIndex = template.Must(template.New("bundle").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Inkbunny bundle</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+section { border-top: 1px solid #ccc; padding: 1em 0; }
+img { max-width: 100%; display: block; margin: .5em 0; }
+.meta, .keywords { color: #666; }
+</style>
+</head>
+<body>
+<h1>{{len .}} submissions</h1>
+{{range .}}<section>
+<h2>{{if .URL}}<a href="{{.URL}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</h2>
+<p class="meta">by {{.Artist}}{{with .Type}} · {{.}}{{end}}{{with .Rating}} · {{.}}{{end}}{{with .Uploaded}} · uploaded {{.}}{{end}}</p>
+{{$folder := .Folder}}{{range .Files}}{{if .Image}}<img src="{{$folder}}/{{.Name}}" alt="{{.Name}}">
+{{else}}<p><a href="{{$folder}}/{{.Name}}">{{.Name}}</a></p>
+{{end}}{{end}}{{with .DescriptionHTML}}<div class="description">
+{{.}}
+</div>
+{{end}}{{with .Keywords}}<p class="keywords">{{range .}}{{.}} {{end}}</p>{{end}}
+</section>
+{{end}}</body>
+</html>
+`))
+
+// WriteBundle packages the submissions of ids that are in index into a zip at
+// destination, for sharing: the files of each in a folder of its own with a
+// metadata.json, and an index.html that shows them all. What strip names is
+// left out. It returns how many submissions went in; those not in the library
+// are left out.
+func WriteBundle(index *library.Index, ids []string, destination string, strip BundleStrip) (int, error) {
+	var submissions []*bundleSubmission
+	artists := make(map[string]string)
+	padding := max(3, len(strconv.Itoa(len(ids))))
+	for _, id := range ids {
+		submission := newBundleSubmission(index, id, strip)
+		if submission == nil {
+			continue
+		}
+		if strip.Artist {
+			pseudonym, ok := artists[strings.ToLower(submission.Artist)]
+			if !ok {
+				pseudonym = fmt.Sprintf("Artist %d", len(artists)+1)
+				artists[strings.ToLower(submission.Artist)] = pseudonym
+			}
+			submission.Artist = pseudonym
+		}
+		submission.Folder = fmt.Sprintf("%0*d", padding, len(submissions)+1)
+		if title := sanitizePathComponent(submission.Title); title != "" {
+			submission.Folder += " - " + title
+		}
+		submissions = append(submissions, submission)
+	}
+	if len(submissions) == 0 {
+		return 0, ErrBundleEmpty
+	}
+
+	temp := destination + ".bundling"
+	out, err := os.Create(temp)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(temp)
+	archive := zip.NewWriter(out)
+	err = writeBundle(archive, submissions)
+	err = errors.Join(err, archive.Close(), out.Close())
+	if err != nil {
+		return 0, err
+	}
+	return len(submissions), os.Rename(temp, destination)
+}
+
+func writeBundle(archive *zip.Writer, submissions []*bundleSubmission) error {
+	for _, submission := range submissions {
+		for _, file := range submission.Files {
+			if err := addBundleFile(archive, path.Join(submission.Folder, file.Name), file.path); err != nil {
+				return fmt.Errorf("bundle %s: %w", file.path, err)
+			}
+		}
+		metadata, err := json.MarshalIndent(submission, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := addBundleData(archive, path.Join(submission.Folder, "metadata.json"), append(metadata, '\n')); err != nil {
+			return err
+		}
+	}
+	var page bytes.Buffer
+	if err := bundleIndex.Execute(&page, submissions); err != nil {
+		return err
+	}
+	return addBundleData(archive, "index.html", page.Bytes())
+}
+
+// newBundleSubmission reads what the library holds of the submission id, or
+// returns nil when none of its files are in it.
+func newBundleSubmission(index *library.Index, id string, strip BundleStrip) *bundleSubmission {
+	entries := slices.DeleteFunc(index.Submission(id), func(entry library.Entry) bool {
+		return entry.Variant == library.VariantMetadata
+	})
+	slices.SortFunc(entries, func(a, b library.Entry) int {
+		return cmp.Compare(submissionNumber(a.FileID), submissionNumber(b.FileID))
+	})
+	submission := &bundleSubmission{SubmissionID: id, URL: "https://inkbunny.net/s/" + id}
+	var details inkbunny.SubmissionDetails
+	read := false
+	for _, entry := range entries {
+		var source string
+		var info os.FileInfo
+		for _, file := range index.Files(entry) {
+			if stat, err := os.Stat(file); err == nil && stat.Mode().IsRegular() {
+				source, info = file, stat
+				break
+			}
+		}
+		if source == "" {
+			continue
+		}
+		name := filepath.Base(source)
+		if strip.Artist || strip.Links {
+			name = fmt.Sprintf("%03d%s", len(submission.Files)+1, strings.ToLower(filepath.Ext(source)))
+		}
+		file := bundleFile{Name: name, Size: info.Size(), path: source, Image: isImageName(source)}
+		if !strip.Links {
+			file.MD5 = entry.MD5
+		}
+		submission.Files = append(submission.Files, file)
+
+		submission.Title = cmp.Or(submission.Title, entry.Title)
+		submission.Artist = cmp.Or(submission.Artist, entry.Artist)
+		if submission.Uploaded == "" && !entry.Uploaded.IsZero() {
+			submission.Uploaded = entry.Uploaded.Format(time.DateOnly)
+		}
+		if len(submission.Keywords) == 0 {
+			submission.Keywords = entry.Keywords
+		}
+		if !read {
+			details, read = readSubmissionSidecar(source, id)
+		}
+	}
+	if len(submission.Files) == 0 {
+		return nil
+	}
+	submission.Title = cmp.Or(submission.Title, strings.TrimSpace(details.Title), "Untitled")
+	submission.Artist = cmp.Or(submission.Artist, strings.TrimSpace(details.Username))
+	submission.Type = strings.TrimSpace(details.TypeName)
+	submission.Rating = strings.TrimSpace(details.RatingName)
+	if description := strings.TrimSpace(details.Description); description != "" && !strip.Description {
+		submission.Description = description
+		submission.DescriptionHTML = template.HTML(BBCodeToHTML(description))
+	}
+	if strip.Links {
+		submission.SubmissionID, submission.URL = "", ""
+	}
+	if strip.Dates {
+		submission.Uploaded = ""
+	}
+	if strip.Keywords {
+		submission.Keywords = nil
+	}
+	return submission
+}
+
+// readSubmissionSidecar reads the submission sidecar written beside file, and
+// reports whether there was one.
+func readSubmissionSidecar(file string, id string) (inkbunny.SubmissionDetails, bool) {
+	var details inkbunny.SubmissionDetails
+	data, err := os.ReadFile(SubmissionSidecarPath(file, id))
+	if err != nil {
+		return details, false
+	}
+	return details, json.Unmarshal(data, &details) == nil
+}
+
+func isImageName(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".bmp":
+		return true
+	}
+	return false
+}
+
+// addBundleFile stores the file at source as name, without its modification
+// time, which would tell when it was downloaded.
+func addBundleFile(archive *zip.Writer, name, source string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(writer, in)
+	return err
+}
+
+func addBundleData(archive *zip.Writer, name string, data []byte) error {
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
+
+func submissionNumber(id string) int {
+	n, _ := strconv.Atoi(id)
+	return n
+}
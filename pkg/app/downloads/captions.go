@@ -0,0 +1,179 @@
+package downloads
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+var (
+	ErrInvalidCaptionFormat = errors.New("invalid caption format, expected tags, booru, jsonl or template")
+	ErrInvalidCaptionBOM    = errors.New("invalid caption bom, expected always, windows or never")
+)
+
+// Caption formats of the keywords sidecar, see types.SidecarOptions.CaptionFormat.
+const (
+	CaptionTags      = "tags"
+	CaptionBooru     = "booru"
+	CaptionJSONLines = "jsonl"
+	CaptionTemplate  = "template"
+)
+
+// CaptionLinesFile is the file in each folder that the "jsonl" format adds the
+// caption of every file in it to.
+const CaptionLinesFile = "metadata.jsonl"
+
+// CaptionFormats lists the caption formats in the order they are offered.
+var CaptionFormats = []string{CaptionTags, CaptionBooru, CaptionJSONLines, CaptionTemplate}
+
+func ParseCaptionFormat(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "":
+		return "", nil
+	case "comma", "commas":
+		return CaptionTags, nil
+	case "json", "jsonlines":
+		return CaptionJSONLines, nil
+	case CaptionTags, CaptionBooru, CaptionJSONLines, CaptionTemplate:
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidCaptionFormat, value)
+}
+
+// When the keywords sidecar starts with a byte order mark, see
+// types.SidecarOptions.CaptionBOM.
+const (
+	CaptionBOMNever   = "never"
+	CaptionBOMAlways  = "always"
+	CaptionBOMWindows = "windows"
+)
+
+// utf8BOM is the UTF-8 byte order mark.
+const utf8BOM = "\ufeff"
+
+func ParseCaptionBOM(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "", CaptionBOMNever, "false", "no":
+		return "", nil
+	case CaptionBOMAlways, "true", "yes":
+		return CaptionBOMAlways, nil
+	case CaptionBOMWindows:
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidCaptionBOM, value)
+}
+
+// captionBOM is what the keywords sidecar starts with on this platform.
+func captionBOM(sidecars types.SidecarOptions) string {
+	switch {
+	case sidecars.CaptionBOM == CaptionBOMAlways,
+		sidecars.CaptionBOM == CaptionBOMWindows && runtime.GOOS == "windows":
+		return utf8BOM
+	}
+	return ""
+}
+
+// booruTags joins names the way booru sites write tags, with the spaces in
+// each tag replaced by underscores.
+func booruTags(names []string) string {
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		if tag := strings.Join(strings.Fields(name), "_"); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return strings.Join(tags, " ")
+}
+
+// caption is the text of the keywords sidecar of details in the format of
+// sidecars. The template format without a template writes tags.
+func caption(details SubmissionFileMetadata, names []string, sidecars types.SidecarOptions) string {
+	switch {
+	case sidecars.CaptionFormat == CaptionBooru:
+		return booruTags(names)
+	case sidecars.CaptionFormat == CaptionTemplate && sidecars.CaptionTemplate != "":
+		return strings.NewReplacer(
+			"{tags}", strings.Join(names, ", "),
+			"{booru}", booruTags(names),
+			"{artist}", strings.TrimSpace(details.Username),
+			"{title}", strings.TrimSpace(details.Title),
+		).Replace(sidecars.CaptionTemplate)
+	}
+	return strings.Join(names, ", ")
+}
+
+// captionLine is a line of CaptionLinesFile, in the layout image folder
+// datasets read.
+type captionLine struct {
+	FileName string   `json:"file_name"`
+	Text     string   `json:"text"`
+	Tags     []string `json:"tags"`
+}
+
+// captionLinesMu keeps two files of one folder from rewriting its
+// CaptionLinesFile at once.
+var captionLinesMu sync.Mutex
+
+// writeCaptionLines records the caption of each of destinations in the
+// CaptionLinesFile of its folder, replacing the line already there for it.
+func writeCaptionLines(destinations []string, details SubmissionFileMetadata, names []string) error {
+	captionLinesMu.Lock()
+	defer captionLinesMu.Unlock()
+
+	for _, destination := range uniqueNonEmptyPaths(destinations) {
+		clean := filepath.Clean(destination)
+		line, err := json.Marshal(captionLine{
+			FileName: filepath.Base(clean),
+			Text:     strings.Join(names, ", "),
+			Tags:     names,
+		})
+		if err != nil {
+			return err
+		}
+		if err := replaceCaptionLine(filepath.Join(filepath.Dir(clean), CaptionLinesFile), filepath.Base(clean), line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func replaceCaptionLine(path, fileName string, line []byte) error {
+	var out bytes.Buffer
+	existing, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(existing))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var recorded captionLine
+		if json.Unmarshal(scanner.Bytes(), &recorded) == nil && recorded.FileName == fileName {
+			continue
+		}
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		out.Write(scanner.Bytes())
+		out.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	out.Write(line)
+	out.WriteByte('\n')
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0o600)
+}
@@ -0,0 +1,82 @@
+package downloads
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+const charactersFolderName = "characters"
+
+// ParseCharacters splits a comma separated character list as typed in the TUI or on the command line.
+func ParseCharacters(value string) []string {
+	return NormalizeCharacters(strings.Split(value, ","))
+}
+
+func NormalizeCharacters(characters []string) []string {
+	seen := make(map[string]struct{}, len(characters))
+	normalized := make([]string, 0, len(characters))
+	for _, character := range characters {
+		character = strings.TrimSpace(character)
+		key := characterKey(character)
+		if key == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		normalized = append(normalized, character)
+	}
+	return normalized
+}
+
+// MatchCharacters returns the characters from the list that appear as keywords on the submission.
+// Matching ignores case and treats spaces and underscores the same, since keywords use either.
+func MatchCharacters(submission inkbunny.SubmissionDetails, characters []string) []string {
+	if len(characters) == 0 || len(submission.Keywords) == 0 {
+		return nil
+	}
+
+	keywords := make(map[string]struct{}, len(submission.Keywords))
+	for _, keyword := range submission.Keywords {
+		keywords[characterKey(keyword.KeywordName)] = struct{}{}
+	}
+
+	var matched []string
+	for _, character := range NormalizeCharacters(characters) {
+		if _, ok := keywords[characterKey(character)]; ok {
+			matched = append(matched, character)
+		}
+	}
+	return matched
+}
+
+func characterDestinations(root string, submission inkbunny.SubmissionDetails, file inkbunny.File, characters []string) []string {
+	matched := MatchCharacters(submission, characters)
+	if len(matched) == 0 {
+		return nil
+	}
+
+	fileName := sanitizePathComponent(filepath.Base(file.FileName))
+	if fileName == "" {
+		return nil
+	}
+
+	destinations := make([]string, 0, len(matched))
+	for _, character := range matched {
+		folder := sanitizePathComponent(character)
+		if folder == "" {
+			continue
+		}
+		destinations = append(destinations, filepath.Join(root, charactersFolderName, folder, fileName))
+	}
+	return destinations
+}
+
+func characterKey(value string) string {
+	return strings.ToLower(strings.Join(strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == '_'
+	}), " "))
+}
@@ -0,0 +1,79 @@
+package downloads
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+const (
+	CollabsOff    = ""
+	CollabsFolder = "folder"
+	CollabsLinks  = "links"
+
+	collabsFolderName = "collabs"
+)
+
+var collabKeywords = map[string]struct{}{
+	"collab":        {},
+	"collaboration": {},
+	"collaborative": {},
+}
+
+var descriptionUserRE = regexp.MustCompile(`(?i)\[(?:icon|iconname|name)\]\s*([a-z0-9_-]+)\s*\[/(?:icon|iconname|name)\]|\bib!([a-z0-9_-]+)`)
+
+func NormalizeCollabsMode(mode string) string {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case CollabsFolder:
+		return CollabsFolder
+	case CollabsLinks:
+		return CollabsLinks
+	default:
+		return CollabsOff
+	}
+}
+
+// SubmissionArtists returns the owner of a submission followed by every artist
+// linked from the description when the submission is tagged as a collaboration.
+// Inkbunny has no collaborator field, so the tag is what separates collaborators
+// from commissioners or gift recipients that are also linked in descriptions.
+func SubmissionArtists(submission inkbunny.SubmissionDetails) []string {
+	owner := strings.TrimSpace(submission.Username)
+	artists := make([]string, 0, 1)
+	seen := make(map[string]struct{})
+	add := func(name string) {
+		name = strings.TrimSpace(name)
+		key := strings.ToLower(name)
+		if name == "" {
+			return
+		}
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		artists = append(artists, name)
+	}
+
+	add(owner)
+	if !isCollabSubmission(submission) {
+		return artists
+	}
+	for _, match := range descriptionUserRE.FindAllStringSubmatch(submission.Description, -1) {
+		if match[1] != "" {
+			add(match[1])
+		} else {
+			add(match[2])
+		}
+	}
+	return artists
+}
+
+func isCollabSubmission(submission inkbunny.SubmissionDetails) bool {
+	for _, keyword := range submission.Keywords {
+		if _, ok := collabKeywords[strings.ToLower(strings.TrimSpace(keyword.KeywordName))]; ok {
+			return true
+		}
+	}
+	return false
+}
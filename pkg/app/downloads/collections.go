@@ -0,0 +1,183 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+var ErrInvalidCollection = errors.New("invalid collection, expected a name, at least one tag and dates such as 2024-01-31")
+
+// CollectionsFolder is the folder of the library that collections are placed
+// in, each in a folder named after it.
+const CollectionsFolder = "Collections"
+
+// Collection is a collection of the settings file read for matching.
+type Collection struct {
+	Name string
+	Tags []string
+	// From and To bound the upload time, To being the end of its day. Either
+	// is zero when left out.
+	From time.Time
+	To   time.Time
+}
+
+// ParseCollections reads the collections of the settings file.
+func ParseCollections(settings []types.Collection) ([]Collection, error) {
+	collections := make([]Collection, 0, len(settings))
+	seen := make(map[string]bool)
+	for _, setting := range settings {
+		name := sanitizePathComponent(strings.TrimSpace(setting.Name))
+		tags := NormalizeCharacters(setting.Tags)
+		if name == "" || len(tags) == 0 || seen[strings.ToLower(name)] {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidCollection, setting.Name)
+		}
+		seen[strings.ToLower(name)] = true
+		collection := Collection{Name: name, Tags: tags}
+		var err error
+		if collection.From, err = parseCollectionDate(setting.From); err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalidCollection, setting.Name, err)
+		}
+		if collection.To, err = parseCollectionDate(setting.To); err != nil {
+			return nil, fmt.Errorf("%w: %q: %w", ErrInvalidCollection, setting.Name, err)
+		}
+		if !collection.To.IsZero() {
+			collection.To = collection.To.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		collections = append(collections, collection)
+	}
+	return collections, nil
+}
+
+func parseCollectionDate(value string) (time.Time, error) {
+	if value = strings.TrimSpace(value); value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
+
+// Matches reports whether entry carries every tag of c and was uploaded
+// within its dates. Tags match like --blacklist keywords do.
+func (c Collection) Matches(entry library.Entry) bool {
+	if entry.Variant == library.VariantMetadata {
+		return false
+	}
+	if (!c.From.IsZero() || !c.To.IsZero()) && entry.Uploaded.IsZero() {
+		return false
+	}
+	if (!c.From.IsZero() && entry.Uploaded.Before(c.From)) || (!c.To.IsZero() && entry.Uploaded.After(c.To)) {
+		return false
+	}
+	keywords := make(map[string]bool, len(entry.Keywords))
+	for _, keyword := range entry.Keywords {
+		keywords[characterKey(keyword)] = true
+	}
+	for _, tag := range c.Tags {
+		if !keywords[characterKey(tag)] {
+			return false
+		}
+	}
+	return true
+}
+
+// about says what c holds, for its index.
+func (c Collection) about() string {
+	about := "tagged " + strings.Join(c.Tags, ", ")
+	switch {
+	case !c.From.IsZero() && !c.To.IsZero():
+		about += fmt.Sprintf(" uploaded from %s to %s", c.From.Format(time.DateOnly), c.To.Format(time.DateOnly))
+	case !c.From.IsZero():
+		about += " uploaded since " + c.From.Format(time.DateOnly)
+	case !c.To.IsZero():
+		about += " uploaded until " + c.To.Format(time.DateOnly)
+	}
+	return about
+}
+
+// PlaceCollection links every recorded file that matches c into its folder
+// under root, copying where links cannot be made, and writes its index. The
+// folder belongs to the collection: files in it that no longer match, such as
+// after its tags or dates changed, are removed. It returns how many files the
+// collection holds.
+func PlaceCollection(index *library.Index, root string, c Collection) (int, error) {
+	dir := filepath.Join(root, CollectionsFolder, c.Name)
+	type matched struct {
+		entry  library.Entry
+		source string
+	}
+	var (
+		matches []matched
+		names   = make(map[string]int)
+	)
+	for _, entry := range index.Recorded() {
+		if !c.Matches(entry) {
+			continue
+		}
+		for _, file := range index.Files(entry) {
+			if _, err := os.Stat(file); err == nil {
+				matches = append(matches, matched{entry: entry, source: file})
+				names[filepath.Base(file)]++
+				break
+			}
+		}
+	}
+
+	var (
+		entries []library.Entry
+		placed  = make(map[string]bool)
+		errs    []error
+	)
+	for _, match := range matches {
+		entry := match.entry
+		// Every file sharing a name is told apart by its file ID, so that
+		// which one keeps the plain name does not depend on what else
+		// matches.
+		name := filepath.Base(match.source)
+		if names[name] > 1 {
+			name = entry.FileID + "_" + name
+		}
+		destination := filepath.Join(dir, name)
+		if err := placeCollectionFile(match.source, destination, entry.MD5); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		placed[name] = true
+		entry.Paths = []string{destination}
+		entries = append(entries, entry)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		errs = append(errs, err)
+	}
+	for _, file := range files {
+		if file.Type().IsRegular() && !placed[file.Name()] && file.Name() != library.ArtistIndexFileName {
+			errs = append(errs, os.Remove(filepath.Join(dir, file.Name())))
+		}
+	}
+	errs = append(errs, index.WriteCollectionIndex(dir, c.Name, c.about(), entries))
+	return len(entries), errors.Join(errs...)
+}
+
+// placeCollectionFile places source at destination unless it is already
+// there: linked to source, or a copy matching expectedMD5 when it is known.
+func placeCollectionFile(source, destination, expectedMD5 string) error {
+	if existing, err := os.Stat(destination); err == nil {
+		if info, err := os.Stat(source); err == nil && os.SameFile(existing, info) {
+			return nil
+		}
+		if strings.TrimSpace(expectedMD5) != "" {
+			if result, err := verifyDownloadedFile(destination, expectedMD5); err == nil && result.Matches {
+				return nil
+			}
+		}
+	}
+	return placeFile(Disk, source, destination)
+}
@@ -0,0 +1,76 @@
+package downloads
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+var ErrInvalidEmptySubmissions = errors.New("invalid empty submissions, expected skip or record")
+
+// What is done with submissions without files, such as writing whose story
+// is only in the description.
+const (
+	EmptySkip   = "skip"
+	EmptyRecord = "record"
+)
+
+func ParseEmptySubmissions(value string) (string, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "":
+		return EmptySkip, nil
+	case EmptySkip, EmptyRecord:
+		return value, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidEmptySubmissions, value)
+}
+
+// WriteEmptySubmission writes what there is of a submission without files
+// into the folder its files would have gone to: its submission sidecar, its
+// description exported as sidecars.DescriptionExport or Markdown, and its page
+// snapshot when enabled. It returns the history entry that records it, under
+// library.MetadataFileID.
+func WriteEmptySubmission(root, pattern string, details inkbunny.SubmissionDetails, layout Layout, sidecars types.SidecarOptions) (library.Entry, error) {
+	id := details.SubmissionID.String()
+	// The placeholder is named like the submission sidecar, so that it stands
+	// in for the file the sidecars are written beside.
+	placeholder := inkbunny.File{FileName: id + submissionSidecarSuffix}
+	destinations := ResolveLayoutDestinations(root, pattern, details, placeholder, layout)
+	metadata := NewSubmissionFileMetadata(details, placeholder, layout.TimeZone)
+
+	if err := writeSubmissionSidecars(destinations, metadata); err != nil {
+		return library.Entry{}, err
+	}
+	if err := writeDescriptionExports(destinations, metadata, cmp.Or(sidecars.DescriptionExport, DescriptionMarkdown)); err != nil {
+		return library.Entry{}, err
+	}
+	if sidecars.Page {
+		if err := writePageSnapshots(destinations, metadata, sidecars.PageTemplate); err != nil {
+			return library.Entry{}, err
+		}
+	}
+
+	var paths []string
+	for _, destination := range uniqueNonEmptyPaths(destinations) {
+		if path := SubmissionSidecarPath(destination, id); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return library.Entry{
+		SubmissionID: id,
+		FileID:       library.MetadataFileID(id),
+		Variant:      library.VariantMetadata,
+		Artist:       details.Username,
+		Title:        details.Title,
+		Uploaded:     UploadTime(details, placeholder, TimeZoneUTC),
+		Keywords:     library.SubmissionKeywords(details),
+		Paths:        uniqueNonEmptyPaths(paths),
+	}, nil
+}
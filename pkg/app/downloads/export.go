@@ -0,0 +1,107 @@
+package downloads
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+)
+
+var ErrInvalidExport = errors.New("invalid export, expected a path ending in .csv or .json")
+
+// Export formats, named after the extension of the file they are written to.
+const (
+	ExportCSV  = "csv"
+	ExportJSON = "json"
+)
+
+// ExportRow is a search result as --export writes it.
+type ExportRow struct {
+	SubmissionID string    `json:"submission_id"`
+	Title        string    `json:"title"`
+	Artist       string    `json:"artist"`
+	URL          string    `json:"url"`
+	Type         string    `json:"type"`
+	Rating       string    `json:"rating"`
+	Uploaded     time.Time `json:"uploaded,omitzero"`
+	Files        int       `json:"files"`
+	Keywords     []string  `json:"keywords"`
+}
+
+// exportColumns are the columns of a CSV export, in the order of ExportRow.
+var exportColumns = []string{"submission_id", "title", "artist", "url", "type", "rating", "uploaded", "files", "keywords"}
+
+// ParseExport returns the format of the export at path, from its extension.
+func ParseExport(path string) (string, error) {
+	path = strings.TrimSpace(path)
+	switch format := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), ".")); format {
+	case ExportCSV, ExportJSON:
+		return format, nil
+	}
+	return "", fmt.Errorf("%w: %q", ErrInvalidExport, path)
+}
+
+// NewExportRow is the row of the search result details.
+func NewExportRow(details inkbunny.SubmissionDetails) ExportRow {
+	return ExportRow{
+		SubmissionID: details.SubmissionID.String(),
+		Title:        strings.TrimSpace(details.Title),
+		Artist:       strings.TrimSpace(details.Username),
+		URL:          submissionSource(SubmissionFileMetadata{SubmissionDetails: details}),
+		Type:         strings.TrimSpace(details.TypeName),
+		Rating:       strings.TrimSpace(details.RatingName),
+		Uploaded:     UploadTime(details, inkbunny.File{}, TimeZoneUTC),
+		Files:        len(details.Files),
+		Keywords:     library.SubmissionKeywords(details),
+	}
+}
+
+// WriteExport writes rows to path as CSV or JSON, as its extension says. In a
+// CSV, the keywords of a row are one cell, separated by semicolons.
+func WriteExport(path string, rows []ExportRow) error {
+	format, err := ParseExport(path)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if format == ExportJSON {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if rows == nil {
+			rows = []ExportRow{}
+		}
+		return errors.Join(encoder.Encode(rows), file.Close())
+	}
+
+	writer := csv.NewWriter(file)
+	_ = writer.Write(exportColumns)
+	for _, row := range rows {
+		uploaded := ""
+		if !row.Uploaded.IsZero() {
+			uploaded = row.Uploaded.Format(time.RFC3339)
+		}
+		_ = writer.Write([]string{
+			row.SubmissionID, row.Title, row.Artist, row.URL, row.Type, row.Rating,
+			uploaded, strconv.Itoa(row.Files), strings.Join(row.Keywords, "; "),
+		})
+	}
+	writer.Flush()
+	return errors.Join(writer.Error(), file.Close())
+}
@@ -0,0 +1,121 @@
+package downloads
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// WriteBufferSize is the size of the buffer placed in front of every download
+// so that slow disks and network mounts see large sequential writes.
+const WriteBufferSize = 1 << 20
+
+var ErrInvalidSyncPolicy = errors.New("invalid fsync policy")
+
+// SyncPolicy controls how often finished downloads are fsynced.
+// Every is 0 to never sync, 1 to sync every file and N to sync in batches of N files.
+type SyncPolicy struct {
+	Every int
+}
+
+func ParseSyncPolicy(value string) (SyncPolicy, error) {
+	switch value = strings.ToLower(strings.TrimSpace(value)); value {
+	case "", "never":
+		return SyncPolicy{}, nil
+	case "file":
+		return SyncPolicy{Every: 1}, nil
+	}
+	every, err := strconv.Atoi(value)
+	if err != nil || every < 0 {
+		return SyncPolicy{}, fmt.Errorf("%w: %q", ErrInvalidSyncPolicy, value)
+	}
+	return SyncPolicy{Every: every}, nil
+}
+
+func (p SyncPolicy) String() string {
+	switch {
+	case p.Every <= 0:
+		return "never"
+	case p.Every == 1:
+		return "file"
+	default:
+		return strconv.Itoa(p.Every)
+	}
+}
+
+func NewFileWriter(file io.Writer) *bufio.Writer {
+	return bufio.NewWriterSize(file, WriteBufferSize)
+}
+
+// FileSyncer applies a SyncPolicy across concurrent downloads. A nil FileSyncer never syncs.
+type FileSyncer struct {
+	policy SyncPolicy
+
+	mu      sync.Mutex
+	pending []string
+}
+
+func NewFileSyncer(policy SyncPolicy) *FileSyncer {
+	return &FileSyncer{policy: policy}
+}
+
+// Written is called once a file has been fully written and flushed, before it is closed.
+// name is where the file is found once closed, since part files are renamed when done.
+func (s *FileSyncer) Written(file *os.File, name string) error {
+	if s == nil || s.policy.Every <= 0 {
+		return nil
+	}
+	if s.policy.Every == 1 {
+		return file.Sync()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, name)
+	if len(s.pending) < s.policy.Every {
+		return nil
+	}
+	return s.flushLocked()
+}
+
+// Flush syncs any files still waiting for their batch to fill up.
+func (s *FileSyncer) Flush() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flushLocked()
+}
+
+func (s *FileSyncer) flushLocked() error {
+	var errs []error
+	for _, path := range s.pending {
+		err := syncPath(path)
+		if errors.Is(err, os.ErrNotExist) {
+			// The batch filled up before the last file was renamed from its part file.
+			err = syncPath(PartPath(path))
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			err = nil
+		}
+		errs = append(errs, err)
+	}
+	s.pending = s.pending[:0]
+	return errors.Join(errs...)
+}
+
+// syncPath reopens path for writing since Windows refuses to flush read-only handles.
+func syncPath(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
@@ -0,0 +1,79 @@
+package downloads
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Hydrus Network import sidecars are named after the whole file name, with
+// the suffix set in the sidecar options of an import folder ("tags" and
+// "urls" here) and .txt after it, and hold one tag or URL per line.
+const (
+	hydrusTagsSidecarSuffix = ".tags.txt"
+	hydrusURLsSidecarSuffix = ".urls.txt"
+)
+
+// hydrusTags lists the tags of details in the namespaces Hydrus uses: the
+// keywords as they are, the artists under creator:, and the title, rating,
+// pools and page of the file under their own.
+func hydrusTags(details SubmissionFileMetadata, names []string) []string {
+	var tags []string
+	add := func(namespace, tag string) {
+		if tag = strings.Join(strings.Fields(tag), " "); tag == "" {
+			return
+		}
+		if namespace != "" {
+			tag = namespace + ":" + tag
+		}
+		tags = append(tags, strings.ToLower(tag))
+	}
+
+	for _, name := range names {
+		add("", name)
+	}
+	artists := details.Artists
+	if len(artists) == 0 {
+		artists = []string{details.Username}
+	}
+	for _, artist := range artists {
+		add("creator", artist)
+	}
+	add("title", details.Title)
+	add("rating", details.RatingName)
+	for _, pool := range details.Pools {
+		add("series", pool.Name)
+	}
+	if len(details.submissionFiles) > 1 {
+		add("page", fmt.Sprint(details.File.SubmissionFileOrder.Int()+1))
+	}
+	return tags
+}
+
+// hydrusURLs lists the URLs Hydrus records as the known URLs of the file of
+// details: the submission page, the page of the file within it, and the file.
+func hydrusURLs(details SubmissionFileMetadata) []string {
+	submission := fmt.Sprintf("https://inkbunny.net/s/%s", details.SubmissionID)
+	urls := []string{submission}
+	if len(details.submissionFiles) > 1 {
+		urls = append(urls, fmt.Sprintf("%s-p%d", submission, details.File.SubmissionFileOrder.Int()+1))
+	}
+	if file := strings.TrimSpace(string(details.File.FileURLFull)); file != "" {
+		urls = append(urls, file)
+	}
+	return urls
+}
+
+func hydrusSidecar(lines []string) []byte {
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+// hydrusSidecarPath is the Hydrus sidecar of destination with suffix, which
+// keeps the extension of destination unlike sidecarPath.
+func hydrusSidecarPath(destination string, suffix string) string {
+	clean := filepath.Clean(strings.TrimSpace(destination))
+	if clean == "." || clean == "" {
+		return ""
+	}
+	return clean + suffix
+}
@@ -0,0 +1,126 @@
+package downloads
+
+import (
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+)
+
+var ErrInvalidKeywordReport = errors.New("invalid keyword report, expected a path ending in .csv or .json")
+
+// KeywordStat is how often a keyword is found in the library, as the keyword
+// report writes it.
+type KeywordStat struct {
+	Keyword     string `json:"keyword"`
+	Submissions int    `json:"submissions"`
+	Files       int    `json:"files"`
+	// Share is the percentage of the downloaded submissions with keywords
+	// that carry it.
+	Share float64 `json:"share"`
+}
+
+// ParseKeywordReport returns the format of the keyword report at path, from
+// its extension, which is one of the formats of ParseExport.
+func ParseKeywordReport(path string) (string, error) {
+	format, err := ParseExport(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %q", ErrInvalidKeywordReport, strings.TrimSpace(path))
+	}
+	return format, nil
+}
+
+// KeywordStats counts the keywords of every file downloaded into index, most
+// frequent first, ignoring case. It also returns how many submissions had
+// keywords recorded, which Share is out of.
+func KeywordStats(index *library.Index) ([]KeywordStat, int) {
+	type counts struct {
+		name        string
+		submissions map[string]struct{}
+		files       int
+	}
+	keywords := make(map[string]*counts)
+	tagged := make(map[string]struct{})
+	for _, entry := range index.Recorded() {
+		if len(entry.Keywords) == 0 || entry.Variant == library.VariantMetadata {
+			continue
+		}
+		tagged[entry.SubmissionID] = struct{}{}
+		seen := make(map[string]struct{}, len(entry.Keywords))
+		for _, keyword := range entry.Keywords {
+			key := strings.ToLower(strings.TrimSpace(keyword))
+			if _, ok := seen[key]; ok || key == "" {
+				continue
+			}
+			seen[key] = struct{}{}
+			count, ok := keywords[key]
+			if !ok {
+				count = &counts{name: key, submissions: make(map[string]struct{})}
+				keywords[key] = count
+			}
+			count.submissions[entry.SubmissionID] = struct{}{}
+			count.files++
+		}
+	}
+
+	stats := make([]KeywordStat, 0, len(keywords))
+	for _, count := range keywords {
+		share := float64(len(count.submissions)) / float64(len(tagged)) * 100
+		stats = append(stats, KeywordStat{
+			Keyword:     count.name,
+			Submissions: len(count.submissions),
+			Files:       count.files,
+			Share:       math.Round(share*100) / 100,
+		})
+	}
+	slices.SortFunc(stats, func(a, b KeywordStat) int {
+		return cmp.Or(cmp.Compare(b.Submissions, a.Submissions), cmp.Compare(b.Files, a.Files), strings.Compare(a.Keyword, b.Keyword))
+	})
+	return stats, len(tagged)
+}
+
+// WriteKeywordReport writes stats to path as CSV or JSON, as its extension
+// says.
+func WriteKeywordReport(path string, stats []KeywordStat) error {
+	format, err := ParseKeywordReport(path)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if format == ExportJSON {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if stats == nil {
+			stats = []KeywordStat{}
+		}
+		return errors.Join(encoder.Encode(stats), file.Close())
+	}
+
+	writer := csv.NewWriter(file)
+	_ = writer.Write([]string{"keyword", "submissions", "files", "share"})
+	for _, stat := range stats {
+		_ = writer.Write([]string{
+			stat.Keyword, strconv.Itoa(stat.Submissions), strconv.Itoa(stat.Files),
+			strconv.FormatFloat(stat.Share, 'f', 2, 64),
+		})
+	}
+	writer.Flush()
+	return errors.Join(writer.Error(), file.Close())
+}
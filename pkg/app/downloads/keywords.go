@@ -0,0 +1,72 @@
+package downloads
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+// maxDerivedKeywords caps the terms taken from a long description.
+const maxDerivedKeywords = 30
+
+var (
+	bbcodeTagRE = regexp.MustCompile(`\[/?[a-zA-Z*]+(?:=[^\]]*)?\]`)
+	urlRE       = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+)
+
+// stopwords are left out of derived keywords since they say nothing about the
+// submission. Words shorter than three letters are dropped before this list.
+var stopwords = map[string]struct{}{
+	"about": {}, "after": {}, "again": {}, "all": {}, "also": {}, "and": {}, "any": {}, "are": {},
+	"back": {}, "because": {}, "been": {}, "before": {}, "being": {}, "but": {}, "can": {},
+	"could": {}, "did": {}, "does": {}, "done": {}, "down": {}, "each": {}, "even": {}, "every": {},
+	"for": {}, "from": {}, "get": {}, "gets": {}, "got": {}, "had": {}, "has": {}, "have": {},
+	"her": {}, "here": {}, "him": {}, "his": {}, "how": {}, "into": {}, "its": {}, "just": {},
+	"like": {}, "made": {}, "make": {}, "many": {}, "more": {}, "most": {}, "much": {}, "not": {},
+	"now": {}, "off": {}, "once": {}, "one": {}, "only": {}, "other": {}, "our": {}, "out": {},
+	"over": {}, "own": {}, "part": {}, "really": {}, "same": {}, "see": {}, "she": {}, "should": {}, "some": {},
+	"still": {}, "such": {}, "than": {}, "thanks": {}, "that": {}, "the": {}, "their": {},
+	"them": {}, "then": {}, "there": {}, "these": {}, "they": {}, "thing": {}, "this": {},
+	"those": {}, "through": {}, "too": {}, "two": {}, "very": {}, "was": {}, "way": {}, "were": {},
+	"what": {}, "when": {}, "where": {}, "which": {}, "while": {}, "who": {}, "why": {}, "will": {},
+	"with": {}, "would": {}, "yet": {}, "you": {}, "your": {},
+	// Words that come up in nearly every description on the site.
+	"art": {}, "artwork": {}, "commission": {}, "drawn": {}, "enjoy": {}, "hope": {}, "new": {},
+	"page": {}, "picture": {}, "pic": {}, "submission": {}, "thank": {},
+}
+
+// DeriveKeywords splits the title and description of a submission into terms
+// that can stand in for its keywords when it has none. Terms keep the order
+// they first appear in, title first.
+func DeriveKeywords(submission inkbunny.SubmissionDetails) []string {
+	text := submission.Title + "\n" + submission.Description
+	text = urlRE.ReplaceAllString(text, " ")
+	text = bbcodeTagRE.ReplaceAllString(text, " ")
+
+	seen := make(map[string]struct{})
+	var terms []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\''
+	}) {
+		word = strings.Trim(word, "'")
+		word = strings.TrimSuffix(word, "'s")
+		if utf8.RuneCountInString(word) < 3 || !strings.ContainsFunc(word, unicode.IsLetter) {
+			continue
+		}
+		if _, ok := stopwords[word]; ok {
+			continue
+		}
+		if _, ok := seen[word]; ok {
+			continue
+		}
+		seen[word] = struct{}{}
+		terms = append(terms, word)
+		if len(terms) == maxDerivedKeywords {
+			break
+		}
+	}
+	return terms
+}
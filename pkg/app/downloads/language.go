@@ -0,0 +1,142 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+// LanguageUnknown is the language of submissions with too little text to tell.
+const LanguageUnknown = "und"
+
+var ErrUnknownLanguage = errors.New("unknown language")
+
+// scriptLanguages are told apart by their script alone. Japanese is checked
+// before Chinese, as it is also written with Han characters.
+var scriptLanguages = []struct {
+	language string
+	tables   []*unicode.RangeTable
+}{
+	{"ja", []*unicode.RangeTable{unicode.Hiragana, unicode.Katakana}},
+	{"ko", []*unicode.RangeTable{unicode.Hangul}},
+	{"zh", []*unicode.RangeTable{unicode.Han}},
+	{"ru", []*unicode.RangeTable{unicode.Cyrillic}},
+	{"ar", []*unicode.RangeTable{unicode.Arabic}},
+	{"el", []*unicode.RangeTable{unicode.Greek}},
+	{"he", []*unicode.RangeTable{unicode.Hebrew}},
+	{"th", []*unicode.RangeTable{unicode.Thai}},
+}
+
+// languageStopwords are the most common words of the languages written in the Latin
+// script, which is how they are told apart. Ties go to the earlier language.
+var languageStopwords = []struct {
+	language string
+	words    []string
+}{
+	{"en", strings.Fields("the and of to is in it you that was for with this my her his on are be have")},
+	{"es", strings.Fields("el la los las de que y en un una es por con para del se su al lo como")},
+	{"de", strings.Fields("der die das und ist nicht ich ein eine zu mit den auf sich auch es von dem mir")},
+	{"fr", strings.Fields("le la les et est un une des du que pour dans pas je il elle avec sur ce qui")},
+	{"pt", strings.Fields("o a os as de que e do da em um uma para com não por se mais seu sua")},
+	{"it", strings.Fields("il lo la gli le di che e un una per con non sono del della si ma come")},
+	{"nl", strings.Fields("de het een en van is dat niet ik je op te zijn met voor die maar ook er")},
+	{"pl", strings.Fields("i w nie na się z do jest to że jak ale po o tak co od za jego")},
+}
+
+// ParseLanguages parses a comma separated list of language codes, such as
+// "en,ja". LanguageUnknown keeps the submissions whose language is not told.
+func ParseLanguages(value string) ([]string, error) {
+	var languages []string
+	for code := range strings.SplitSeq(value, ",") {
+		code = strings.ToLower(strings.TrimSpace(code))
+		if code == "" || slices.Contains(languages, code) {
+			continue
+		}
+		if !knownLanguage(code) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownLanguage, code)
+		}
+		languages = append(languages, code)
+	}
+	return languages, nil
+}
+
+// KeepsLanguage reports whether a submission in language passes languages,
+// which keeps every submission when empty.
+func KeepsLanguage(languages []string, language string) bool {
+	return len(languages) == 0 || slices.Contains(languages, language)
+}
+
+func knownLanguage(code string) bool {
+	if code == LanguageUnknown {
+		return true
+	}
+	for _, script := range scriptLanguages {
+		if script.language == code {
+			return true
+		}
+	}
+	for _, latin := range languageStopwords {
+		if latin.language == code {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectLanguage guesses the language of submission from its title and
+// description, returning an ISO 639-1 code or LanguageUnknown. Keywords are
+// left out, as they are mostly English whatever the description is in.
+func DetectLanguage(submission inkbunny.SubmissionDetails) string {
+	return detectLanguage(submission.Title + "\n" + submission.Description)
+}
+
+func detectLanguage(text string) string {
+	letters := 0
+	scripts := make([]int, len(scriptLanguages))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for i, script := range scriptLanguages {
+			if unicode.In(r, script.tables...) {
+				scripts[i]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return LanguageUnknown
+	}
+	// Kana is mixed with far more Han characters in Japanese, so any of it
+	// decides between the two.
+	if scripts[0] > 0 && scripts[0]+scripts[2] > letters/3 {
+		return "ja"
+	}
+	for i, count := range scripts {
+		if count > letters/3 {
+			return scriptLanguages[i].language
+		}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	best, bestHits := LanguageUnknown, 1
+	for _, latin := range languageStopwords {
+		hits := 0
+		for _, word := range words {
+			if slices.Contains(latin.words, word) {
+				hits++
+			}
+		}
+		if hits > bestHits {
+			best, bestHits = latin.language, hits
+		}
+	}
+	return best
+}
@@ -0,0 +1,122 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+)
+
+var ErrAdoptConflict = errors.New("a different file is already at the destination")
+
+// libraries caches one history index per download root so every job sharing
+// a root sees the files recorded by the others.
+var libraries = struct {
+	mu      sync.Mutex
+	indexes map[string]*library.Index
+	// upgrade is set by UpgradeLibraries.
+	upgrade bool
+}{indexes: make(map[string]*library.Index)}
+
+// UpgradeLibraries sets whether LibraryIndex upgrades an older library to
+// SchemaVersion, see MigrateLibrary. It is off until the desktop app's
+// settings turn it on, so that a library is not rewritten without being asked.
+func UpgradeLibraries(enabled bool) {
+	libraries.mu.Lock()
+	defer libraries.mu.Unlock()
+	libraries.upgrade = enabled
+}
+
+// LibraryIndex returns the history index for root, loading it on first use,
+// and upgrading an older library to SchemaVersion when UpgradeLibraries allows.
+func LibraryIndex(root string) (*library.Index, error) {
+	root = filepath.Clean(strings.TrimSpace(root))
+
+	libraries.mu.Lock()
+	defer libraries.mu.Unlock()
+	if index, ok := libraries.indexes[root]; ok {
+		return index, nil
+	}
+	index, err := library.Open(root)
+	if invalid := index.InvalidIgnores(); invalid != nil {
+		log.Warn("skipped invalid lines of the ignore file", "library", root, "err", invalid)
+	}
+	if err == nil && libraries.upgrade {
+		_, _, err = MigrateLibrary(index)
+	}
+	libraries.indexes[root] = index
+	return index, err
+}
+
+func taskEntry(task Task, destinations []string, size int64) library.Entry {
+	return library.Entry{
+		SubmissionID: task.SubmissionID,
+		FileID:       task.FileID,
+		MD5:          task.FileMD5,
+		Artist:       task.Username,
+		Title:        task.Metadata.Title,
+		Uploaded:     UploadTime(task.Metadata.SubmissionDetails, task.Metadata.File, TimeZoneUTC),
+		Keywords:     library.SubmissionKeywords(task.Metadata.SubmissionDetails),
+		Size:         size,
+		Paths:        destinations,
+	}
+}
+
+// AdoptFile moves source to the first destination and links the others to it,
+// for files that were downloaded outside the library. A source that is already
+// at a destination is left in place, and one whose copy is already there is removed.
+func AdoptFile(source string, destinations []string, expectedMD5 string) error {
+	destinations = uniqueNonEmptyPaths(destinations)
+	if len(destinations) == 0 {
+		return nil
+	}
+	source = filepath.Clean(source)
+	target := destinations[0]
+	for _, destination := range destinations {
+		if destination == source {
+			target = source
+			break
+		}
+	}
+
+	if target != source {
+		result, err := verifyDownloadedFile(target, expectedMD5)
+		if err != nil {
+			return err
+		}
+		switch {
+		case result.Matches:
+			if err := os.Remove(source); err != nil {
+				return err
+			}
+		case result.Exists:
+			return fmt.Errorf("%w: %q", ErrAdoptConflict, target)
+		default:
+			if err := moveFile(source, target); err != nil {
+				return err
+			}
+		}
+	}
+	return ensureDownloadTargetsFromSource(target, destinations, expectedMD5)
+}
+
+// moveFile renames source to destination, copying it instead when they are on different filesystems.
+func moveFile(source, destination string) error {
+	if err := os.MkdirAll(filepath.Dir(destination), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(source, destination); err == nil {
+		return nil
+	}
+	if err := copyFile(source, destination); err != nil {
+		_ = os.Remove(destination)
+		return err
+	}
+	return os.Remove(source)
+}
@@ -0,0 +1,147 @@
+package downloads
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+// MissingSidecar is a downloaded file without some of the sidecars it should have.
+type MissingSidecar struct {
+	Entry    library.Entry
+	File     string
+	Sidecars []string
+}
+
+// FindMissingSidecars checks every file recorded in index for the sidecars
+// enabled in sidecars. Files that are gone from the library are left to
+// --scan-deleted. With PerSubmission, a missing caption is only reported when
+// none of the files of its submission has one.
+func FindMissingSidecars(index *library.Index, sidecars types.SidecarOptions) []MissingSidecar {
+	var missing []MissingSidecar
+	captioned := make(map[string]bool)
+	for _, entry := range index.Recorded() {
+		if entry.Variant == library.VariantMetadata {
+			continue
+		}
+		for _, file := range index.Files(entry) {
+			if _, err := os.Stat(file); err != nil {
+				continue
+			}
+			paths := SidecarPaths(file, sidecars)
+			if sidecars.Submission {
+				paths = append(paths, SubmissionSidecarPath(file, entry.SubmissionID))
+			}
+			if sidecars.Page {
+				paths = append(paths, PageSnapshotPath(file, entry.SubmissionID))
+			}
+			var absent []string
+			for _, sidecar := range paths {
+				if sidecar == CaptionPath(file) && len(entry.Keywords) == 0 && !sidecars.DeriveKeywords {
+					// Submissions without keywords never get a caption.
+					continue
+				}
+				if _, err := os.Stat(sidecar); os.IsNotExist(err) {
+					absent = append(absent, sidecar)
+				} else if sidecars.Keywords && sidecar == CaptionPath(file) {
+					captioned[entry.SubmissionID] = true
+				}
+			}
+			if len(absent) > 0 {
+				missing = append(missing, MissingSidecar{Entry: entry, File: file, Sidecars: absent})
+			}
+		}
+	}
+	if sidecars.PerSubmission {
+		for i, file := range missing {
+			if captioned[file.Entry.SubmissionID] {
+				missing[i].Sidecars = slices.DeleteFunc(file.Sidecars, func(sidecar string) bool {
+					return sidecar == CaptionPath(file.File)
+				})
+			}
+		}
+		missing = slices.DeleteFunc(missing, func(file MissingSidecar) bool {
+			return len(file.Sidecars) == 0
+		})
+	}
+	return missing
+}
+
+// FindOrphanSidecars returns the sidecars left behind under root by files
+// recorded in index that are gone. Only the sidecars a recorded file is known to
+// have are looked for, so nothing the library does not own is ever reported.
+// The sidecars shared by a submission are only orphaned once none of its files
+// in their folder is left, and those of files uploaded to --output are kept
+// as a record of them.
+func FindOrphanSidecars(root string, index *library.Index) []string {
+	root = filepath.Clean(root)
+	recorded := make(map[string]bool)
+	// kept holds the shared sidecars that still have a file of their
+	// submission beside them.
+	kept := make(map[string]bool)
+	type goneFile struct {
+		path         string
+		submissionID string
+	}
+	var gone []goneFile
+	for _, entry := range index.Recorded() {
+		for _, file := range index.Files(entry) {
+			recorded[filepath.Clean(file)] = true
+			if entry.Variant == library.VariantMetadata {
+				continue
+			}
+			if _, err := os.Stat(file); err == nil || !os.IsNotExist(err) || entry.Offloaded != "" {
+				for _, sidecar := range sharedSidecarPaths(file, entry.SubmissionID) {
+					kept[sidecar] = true
+				}
+				continue
+			}
+			gone = append(gone, goneFile{path: file, submissionID: entry.SubmissionID})
+		}
+	}
+
+	var orphans []string
+	seen := make(map[string]bool)
+	orphaned := func(sidecar string) {
+		if sidecar == "" || seen[sidecar] || recorded[sidecar] {
+			return
+		}
+		seen[sidecar] = true
+		if rel, err := filepath.Rel(root, sidecar); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return
+		}
+		if info, err := os.Stat(sidecar); err == nil && info.Mode().IsRegular() {
+			orphans = append(orphans, sidecar)
+		}
+	}
+	for _, file := range gone {
+		for _, suffix := range []string{keywordsSidecarSuffix, metadataSidecarSuffix, descriptionSidecarSuffix, commentsSidecarSuffix} {
+			orphaned(sidecarPath(file.path, suffix))
+		}
+		for _, suffix := range []string{hydrusTagsSidecarSuffix, hydrusURLsSidecarSuffix} {
+			orphaned(hydrusSidecarPath(file.path, suffix))
+		}
+		for _, sidecar := range sharedSidecarPaths(file.path, file.submissionID) {
+			if !kept[sidecar] {
+				orphaned(sidecar)
+			}
+		}
+	}
+	slices.Sort(orphans)
+	return orphans
+}
+
+// sharedSidecarPaths lists the sidecars that the files of a submission in the
+// folder of file share.
+func sharedSidecarPaths(file string, submissionID string) []string {
+	return []string{
+		SubmissionSidecarPath(file, submissionID),
+		PageSnapshotPath(file, submissionID),
+		DescriptionExportPath(file, submissionID, DescriptionMarkdown),
+		DescriptionExportPath(file, submissionID, DescriptionHTML),
+	}
+}
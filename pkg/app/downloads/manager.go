@@ -31,9 +31,11 @@ type Task struct {
 	IsPublic     bool
 	Metadata     SubmissionFileMetadata
 	PreviewURL   string
-	SaveKeywords bool
+	Sidecars     types.SidecarOptions
 	DownloadRoot string
 	Destinations []string
+	// Convert is the format a convert route re-encodes the file in.
+	Convert string
 }
 
 type downloadJob struct {
@@ -57,6 +59,9 @@ type Manager struct {
 	active    int
 	jobs      map[string]*downloadJob
 	pending   []string
+	syncer    *FileSyncer
+	// connections is how many ranged requests a large file is split across.
+	connections int
 }
 
 func NewManager(ctx context.Context, maxActive int, limiter *apputils.RateLimiter, emit func(string, any)) *Manager {
@@ -80,6 +85,27 @@ func (m *Manager) SetMaxActive(maxActive int) {
 	m.maybeStartLocked()
 }
 
+func (m *Manager) SetSyncPolicy(policy SyncPolicy) {
+	m.mu.Lock()
+	previous := m.syncer
+	m.syncer = NewFileSyncer(policy)
+	m.mu.Unlock()
+	_ = previous.Flush()
+}
+
+func (m *Manager) SetConnectionsPerFile(connections int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connections = NormalizeConnections(connections)
+}
+
+func (m *Manager) FlushSync() error {
+	m.mu.Lock()
+	syncer := m.syncer
+	m.mu.Unlock()
+	return syncer.Flush()
+}
+
 func (m *Manager) Enqueue(tasks []Task, maxActive int) types.QueueSnapshot {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -631,23 +657,51 @@ func (m *Manager) download(ctx context.Context, jobID string) error {
 			filepath.Join(task.DownloadRoot, task.Username, filepath.Base(task.FileName)),
 		})
 	}
+
+	// A history that fails to load only loses the fast path below; files
+	// already on disk are still found by hashing them.
+	index, _ := LibraryIndex(task.DownloadRoot)
+	recorded, covered := index.Covers(task.FileID, task.FileMD5, destinations)
+	if covered {
+		m.setProgress(jobID, recorded.Size, recorded.Size)
+		return nil
+	}
+	if source, ok := index.Source(task.FileID, task.FileMD5, destinations[0]); ok {
+		if err := ensureDownloadTargetsFromSource(source, destinations, task.FileMD5); err != nil {
+			return err
+		}
+		if err := ConvertFiles(destinations, task.Convert); err != nil {
+			return err
+		}
+		if err := index.Record(taskEntry(task, destinations, recorded.Size)); err != nil {
+			return err
+		}
+		return WriteSidecars(destinations, task.Metadata, task.Sidecars)
+	}
+
 	allMatch, size, source, err := downloadTargetsMatch(destinations, task.FileMD5)
 	if err != nil {
 		return err
 	}
 	if allMatch {
 		m.setProgress(jobID, size, size)
-		return nil
+		if err := ConvertFiles(destinations, task.Convert); err != nil {
+			return err
+		}
+		return index.Record(taskEntry(task, destinations, size))
 	}
 
 	if source != "" {
 		if err := ensureDownloadTargetsFromSource(source, destinations, task.FileMD5); err != nil {
 			return err
 		}
-		if task.SaveKeywords {
-			return WriteSubmissionMetadata(destinations, task.Metadata)
+		if err := ConvertFiles(destinations, task.Convert); err != nil {
+			return err
 		}
-		return nil
+		if err := index.Record(taskEntry(task, destinations, size)); err != nil {
+			return err
+		}
+		return WriteSidecars(destinations, task.Metadata, task.Sidecars)
 	}
 
 	filename := destinations[0]
@@ -667,23 +721,36 @@ func (m *Manager) download(ctx context.Context, jobID string) error {
 
 	const maxAttempts = 5
 	var lastErr error
+	segmented := true
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		m.setAttempt(jobID, attempt)
-		err := m.downloadAttempt(ctx, jobID, attempt, task, filename, url)
+		err := m.downloadAttempt(ctx, jobID, attempt, task, filename, url, segmented)
 		if err == nil {
 			if copyErr := ensureDownloadTargetsFromSource(filename, destinations, task.FileMD5); copyErr != nil {
 				return copyErr
 			}
-			if task.SaveKeywords {
-				return WriteSubmissionMetadata(destinations, task.Metadata)
+			if convertErr := ConvertFiles(destinations, task.Convert); convertErr != nil {
+				return convertErr
+			}
+			var size int64
+			if info, statErr := os.Stat(filename); statErr == nil {
+				size = info.Size()
 			}
-			return nil
+			if recordErr := index.Record(taskEntry(task, destinations, size)); recordErr != nil {
+				return recordErr
+			}
+			return WriteSidecars(destinations, task.Metadata, task.Sidecars)
 		}
 		if errors.Is(err, errRetryWithSID) {
 			url = sidURL
 			lastErr = err
 			continue
 		}
+		if errors.Is(err, ErrSegmentsUnsupported) {
+			segmented = false
+			lastErr = err
+			continue
+		}
 		if !errors.Is(err, errRetry) {
 			return err
 		}
@@ -698,11 +765,18 @@ func (m *Manager) download(ctx context.Context, jobID string) error {
 var errRetry = errors.New("retry")
 var errRetryWithSID = errors.New("retry with sid")
 
-func (m *Manager) downloadAttempt(ctx context.Context, jobID string, attempt int, task Task, filename string, url string) error {
+func (m *Manager) downloadAttempt(ctx context.Context, jobID string, attempt int, task Task, filename string, url string, segmented bool) error {
+	file, offset, err := OpenPart(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return err
 	}
+	RequestFrom(req, offset)
 	resp, err := m.client.Do(req)
 	if err != nil {
 		return err
@@ -711,38 +785,68 @@ func (m *Manager) downloadAttempt(ctx context.Context, jobID string, attempt int
 
 	if resp.StatusCode == http.StatusTooManyRequests {
 		if m.limiter != nil {
-			m.limiter.Register("downloads", attempt)
+			if delay, ok := apputils.RetryAfter(resp); ok {
+				m.limiter.Pause("downloads", delay)
+			} else {
+				m.limiter.Register("downloads", attempt)
+			}
 			if err := m.limiter.Wait(ctx); err != nil {
 				return err
 			}
 		}
 		return errRetry
 	}
-	if resp.StatusCode != http.StatusOK {
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The part file is no shorter than the file, such as one left preallocated.
+		if err := file.Truncate(0); err != nil {
+			return err
+		}
+		return errRetry
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 		if sidURL := baseutils.AppendSID(task.URL, task.SessionID); sidURL != "" && sidURL != url {
 			return errRetryWithSID
 		}
 		return fmt.Errorf("unexpected status: %d", resp.StatusCode)
 	}
 
-	file, err := os.Create(filename)
-	if err != nil {
-		return err
+	m.mu.Lock()
+	connections := m.connections
+	syncer := m.syncer
+	m.mu.Unlock()
+	if segmented && offset == 0 && CanSegment(resp, connections) {
+		_ = resp.Body.Close()
+		return m.downloadSegmented(ctx, jobID, task, file, filename, url, resp.ContentLength, connections)
 	}
-	defer file.Close()
 
 	hasher := md5.New()
-	writer := io.MultiWriter(file, hasher)
+	written, err := ResumeFrom(file, resp, offset, hasher)
+	if err != nil {
+		return err
+	}
 	total := resp.ContentLength
-	m.setProgress(jobID, 0, total)
+	if total >= 0 {
+		total += written
+	}
+	preallocated := false
+	if written == 0 {
+		if preallocated, err = PreallocateFile(file, resp.ContentLength); err != nil {
+			_ = file.Close()
+			_ = os.Remove(PartPath(filename))
+			return err
+		}
+	}
+
+	buffered := NewFileWriter(file)
+	writer := io.MultiWriter(buffered, hasher)
+	m.setProgress(jobID, written, total)
 
-	var written int64
+	// An interrupted download keeps its part file so the next attempt resumes it.
 	buffer := make([]byte, 32*1024)
 	for {
 		select {
 		case <-ctx.Done():
-			_ = file.Close()
-			_ = os.Remove(filename)
+			_ = buffered.Flush()
 			return ctx.Err()
 		default:
 		}
@@ -751,7 +855,8 @@ func (m *Manager) downloadAttempt(ctx context.Context, jobID string, attempt int
 		if n > 0 {
 			nw, writeErr := writer.Write(buffer[:n])
 			if writeErr != nil {
-				_ = os.Remove(filename)
+				_ = file.Close()
+				_ = os.Remove(PartPath(filename))
 				return writeErr
 			}
 			written += int64(nw)
@@ -761,22 +866,75 @@ func (m *Manager) downloadAttempt(ctx context.Context, jobID string, attempt int
 			if errors.Is(readErr, io.EOF) {
 				break
 			}
-			_ = os.Remove(filename)
+			_ = buffered.Flush()
 			return readErr
 		}
 	}
 
+	if err := buffered.Flush(); err != nil {
+		_ = file.Close()
+		_ = os.Remove(PartPath(filename))
+		return err
+	}
+	if preallocated {
+		if err := TrimPreallocatedFile(file, written); err != nil {
+			_ = file.Close()
+			_ = os.Remove(PartPath(filename))
+			return err
+		}
+	}
 	if task.FileMD5 != "" {
 		hash := fmt.Sprintf("%x", hasher.Sum(nil))
 		if hash != task.FileMD5 {
-			_ = os.Remove(filename)
+			_ = file.Close()
+			_ = os.Remove(PartPath(filename))
 			return errRetry
 		}
 	}
+	if err := syncer.Written(file, filename); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := FinishPart(filename); err != nil {
+		return err
+	}
 	m.setProgress(jobID, written, max64(written, total))
 	return nil
 }
 
+func (m *Manager) downloadSegmented(ctx context.Context, jobID string, task Task, file *os.File, filename string, url string, size int64, connections int) error {
+	m.setProgress(jobID, 0, size)
+	hash, err := DownloadSegments(ctx, m.client, url, file, size, connections, func(written int64) {
+		m.setProgress(jobID, written, size)
+	})
+	if err != nil {
+		// Parts are written all over the file, so what is there cannot be resumed.
+		_ = file.Truncate(0)
+		return err
+	}
+	if task.FileMD5 != "" && hash != task.FileMD5 {
+		_ = file.Close()
+		_ = os.Remove(PartPath(filename))
+		return errRetry
+	}
+	m.mu.Lock()
+	syncer := m.syncer
+	m.mu.Unlock()
+	if err := syncer.Written(file, filename); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := FinishPart(filename); err != nil {
+		return err
+	}
+	m.setProgress(jobID, size, size)
+	return nil
+}
+
 func (m *Manager) setAttempt(jobID string, attempt int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
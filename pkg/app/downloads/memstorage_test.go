@@ -0,0 +1,132 @@
+package downloads
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// MemoryStorage is a Storage held in memory, so that the code placing files can
+// be run without touching the disk. Linked names share one file, as they would
+// on disk.
+type MemoryStorage struct {
+	mu    sync.Mutex
+	files map[string]*memoryFile
+}
+
+type memoryFile struct {
+	data     []byte
+	modified time.Time
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{files: make(map[string]*memoryFile)}
+}
+
+// WriteFile puts data at name, as Create would.
+func (m *MemoryStorage) WriteFile(name string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[filepath.Clean(name)] = &memoryFile{data: bytes.Clone(data), modified: time.Now()}
+}
+
+// ReadFile returns what is at name.
+func (m *MemoryStorage) ReadFile(name string) ([]byte, error) {
+	file, err := m.file("read", name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return bytes.Clone(file.data), nil
+}
+
+// Names lists every name in the storage.
+func (m *MemoryStorage) Names() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.files))
+	for name := range m.files {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (m *MemoryStorage) file(op, name string) (*memoryFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	file, ok := m.files[filepath.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return file, nil
+}
+
+func (m *MemoryStorage) Create(name string) (io.WriteCloser, error) {
+	return &memoryWriter{storage: m, name: filepath.Clean(name)}, nil
+}
+
+// memoryWriter puts what was written in the storage when it is closed.
+type memoryWriter struct {
+	bytes.Buffer
+	storage *MemoryStorage
+	name    string
+}
+
+func (w *memoryWriter) Close() error {
+	w.storage.WriteFile(w.name, w.Bytes())
+	return nil
+}
+
+func (m *MemoryStorage) Open(name string) (io.ReadCloser, error) {
+	data, err := m.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *MemoryStorage) Exists(name string) (bool, error) {
+	_, err := m.file("stat", name)
+	return err == nil, nil
+}
+
+func (m *MemoryStorage) Move(from, to string) error {
+	file, err := m.file("rename", from)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.files, filepath.Clean(from))
+	m.files[filepath.Clean(to)] = file
+	return nil
+}
+
+func (m *MemoryStorage) Stat(name string) (fs.FileInfo, error) {
+	file, err := m.file("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return storedFileInfo{name: filepath.Base(name), size: int64(len(file.data)), modified: file.modified}, nil
+}
+
+func (m *MemoryStorage) Link(from, to string) error {
+	file, err := m.file("link", from)
+	if err != nil {
+		return err
+	}
+	if filepath.Clean(from) == filepath.Clean(to) {
+		return fmt.Errorf("link %s: %w", to, fs.ErrExist)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[filepath.Clean(to)] = file
+	return nil
+}
@@ -1,27 +1,45 @@
 package downloads
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"strings"
+	"time"
 
 	"github.com/ellypaws/inkbunny"
 )
 
 type SubmissionFileMetadata struct {
-	File inkbunny.File `json:"file"`
+	// SchemaVersion is the SchemaVersion the sidecar was written in.
+	SchemaVersion int           `json:"schema_version"`
+	File          inkbunny.File `json:"file"`
 	inkbunny.SubmissionDetails
-	Files []inkbunny.File `json:"files,omitzero"`
+	Files   []inkbunny.File `json:"files,omitzero"`
+	Artists []string        `json:"artists,omitzero"`
+	// Uploaded is the upload time of the file in the chosen time zone, next to
+	// the strings Inkbunny sends.
+	Uploaded string `json:"upload_datetime,omitempty"`
+	// Language is what DetectLanguage tells the submission is written in.
+	Language string `json:"language,omitempty"`
+
+	// submissionFiles keeps every file of the submission for its submission
+	// sidecar, which the per file metadata leaves out.
+	submissionFiles []inkbunny.File
 }
 
-func NewSubmissionFileMetadata(submission inkbunny.SubmissionDetails, file inkbunny.File) SubmissionFileMetadata {
+func NewSubmissionFileMetadata(submission inkbunny.SubmissionDetails, file inkbunny.File, zone string) SubmissionFileMetadata {
+	files := submission.Files
 	submission.Files = nil
-	return SubmissionFileMetadata{
+	metadata := SubmissionFileMetadata{
+		SchemaVersion:     SchemaVersion,
 		File:              file,
 		SubmissionDetails: submission,
 		Files:             nil,
+		Artists:           SubmissionArtists(submission),
+		submissionFiles:   files,
+		Language:          DetectLanguage(submission),
 	}
+	if uploaded := UploadTime(submission, file, zone); !uploaded.IsZero() {
+		metadata.Uploaded = uploaded.Format(time.RFC3339)
+	}
+	return metadata
 }
 
 func MetadataSubmissionDetailsRequest() inkbunny.SubmissionDetailsRequest {
@@ -33,38 +51,3 @@ func MetadataSubmissionDetailsRequest() inkbunny.SubmissionDetailsRequest {
 		ShowPools:                   inkbunny.Yes,
 	}
 }
-
-func WriteSubmissionMetadata(destinations []string, details SubmissionFileMetadata) error {
-	payload, err := json.MarshalIndent(details, "", "  ")
-	if err != nil {
-		return err
-	}
-	payload = append(payload, '\n')
-
-	for _, destination := range uniqueNonEmptyPaths(destinations) {
-		metadataPath := submissionMetadataPath(destination)
-		if metadataPath == "" {
-			continue
-		}
-		if err := os.MkdirAll(filepath.Dir(metadataPath), 0o755); err != nil {
-			return err
-		}
-		if err := os.WriteFile(metadataPath, payload, 0o600); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
-func submissionMetadataPath(destination string) string {
-	clean := filepath.Clean(strings.TrimSpace(destination))
-	if clean == "." || clean == "" {
-		return ""
-	}
-
-	ext := filepath.Ext(clean)
-	if ext == "" {
-		return clean + ".json"
-	}
-	return strings.TrimSuffix(clean, ext) + ".json"
-}
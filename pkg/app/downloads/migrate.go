@@ -0,0 +1,226 @@
+package downloads
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/buildinfo"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+)
+
+// SchemaFileName records the version of the sidecar and history formats a
+// library was written in, so that older libraries are upgraded in place.
+const SchemaFileName = ".inkbunny-schema.json"
+
+// SchemaVersion is the version of the formats written by this build. A format
+// change bumps it and adds the step that upgrades older libraries to migrations.
+const SchemaVersion = 1
+
+// BackupsFolder is the folder in the library root that the files an upgrade
+// rewrites are copied to first, in a folder per upgrade.
+const BackupsFolder = ".inkbunny-backups"
+
+var ErrNewerSchema = errors.New("library was written by a newer version of the downloader")
+
+// libraryMigration upgrades a library by one version.
+type libraryMigration struct {
+	// Description says what the upgrade does, for asking before it runs.
+	Description string
+	// Files lists the files of the library the upgrade rewrites, which are
+	// backed up before any upgrade runs.
+	Files   func(index *library.Index) []string
+	Migrate func(index *library.Index) error
+}
+
+// migrations upgrade a library from the version at their index to the next one.
+var migrations = [SchemaVersion]libraryMigration{
+	{
+		Description: "Add the artists, upload date and schema version to the metadata sidecars",
+		Files:       metadataSidecarPaths,
+		Migrate:     migrateMetadataSidecars,
+	},
+}
+
+type librarySchema struct {
+	Version int `json:"version"`
+	// WrittenBy is the version of the downloader that last upgraded the
+	// library, for telling which one to use when it is newer than this one.
+	WrittenBy string `json:"writtenBy,omitempty"`
+}
+
+// LibraryUpgrade is what MigrateLibrary has to do to a library.
+type LibraryUpgrade struct {
+	From, To int
+	// WrittenBy is the downloader that last upgraded the library, if known.
+	WrittenBy string
+	// Steps describes each upgrade that runs, in order.
+	Steps []string
+}
+
+// Pending reports whether the library is older than this build.
+func (u LibraryUpgrade) Pending() bool {
+	return u.From < u.To
+}
+
+// PendingUpgrade returns what MigrateLibrary would do to the library behind
+// index, and an error wrapping ErrNewerSchema when a newer downloader wrote it.
+func PendingUpgrade(index *library.Index) (LibraryUpgrade, error) {
+	upgrade := LibraryUpgrade{From: SchemaVersion, To: SchemaVersion}
+	root := index.Root()
+	if root == "" || len(index.Recorded()) == 0 {
+		return upgrade, nil
+	}
+	schema, err := readSchema(root)
+	if err != nil {
+		return upgrade, err
+	}
+	upgrade.From, upgrade.WrittenBy = schema.Version, schema.WrittenBy
+	if schema.Version > SchemaVersion {
+		return upgrade, fmt.Errorf("%w: schema %d, written by %s", ErrNewerSchema, schema.Version, cmp.Or(schema.WrittenBy, "an unknown version"))
+	}
+	for _, migration := range migrations[schema.Version:] {
+		upgrade.Steps = append(upgrade.Steps, migration.Description)
+	}
+	return upgrade, nil
+}
+
+// MigrateLibrary upgrades the sidecars and history of the library behind index
+// to SchemaVersion, one version at a time, and returns the version it was in
+// and the folder the files it rewrote were backed up to. Libraries without any
+// downloads are left as they are.
+func MigrateLibrary(index *library.Index) (int, string, error) {
+	upgrade, err := PendingUpgrade(index)
+	if err != nil || !upgrade.Pending() {
+		return upgrade.From, "", err
+	}
+	root := index.Root()
+	backup, err := backupLibrary(index, upgrade.From)
+	if err != nil {
+		return upgrade.From, "", fmt.Errorf("back up library: %w", err)
+	}
+	for next := upgrade.From; next < SchemaVersion; next++ {
+		if err := migrations[next].Migrate(index); err != nil {
+			return upgrade.From, backup, fmt.Errorf("migrate library to schema %d: %w", next+1, err)
+		}
+		if err := writeSchemaVersion(root, next+1); err != nil {
+			return upgrade.From, backup, err
+		}
+	}
+	return upgrade.From, backup, nil
+}
+
+// backupLibrary copies the history of the library behind index and the files
+// that upgrading it from version rewrites into a new folder of BackupsFolder,
+// at the paths they have under the root, and returns that folder.
+func backupLibrary(index *library.Index, version int) (string, error) {
+	root := index.Root()
+	backup := filepath.Join(root, BackupsFolder, fmt.Sprintf("schema-%d-%s", version, time.Now().Format("20060102-150405")))
+	files := []string{
+		filepath.Join(root, SchemaFileName),
+		filepath.Join(root, library.HistoryFileName),
+		filepath.Join(root, library.SkipsFileName),
+	}
+	for _, migration := range migrations[version:] {
+		files = append(files, migration.Files(index)...)
+	}
+	for _, file := range uniqueNonEmptyPaths(files) {
+		relative, err := filepath.Rel(root, file)
+		if err != nil || !filepath.IsLocal(relative) {
+			continue
+		}
+		if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		target := filepath.Join(backup, relative)
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return "", err
+		}
+		if err := copyFile(file, target); err != nil {
+			return "", err
+		}
+	}
+	return backup, nil
+}
+
+func readSchema(root string) (librarySchema, error) {
+	var schema librarySchema
+	data, err := os.ReadFile(filepath.Join(root, SchemaFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return schema, nil
+	}
+	if err != nil {
+		return schema, err
+	}
+	err = json.Unmarshal(data, &schema)
+	return schema, err
+}
+
+func writeSchemaVersion(root string, version int) error {
+	data, err := json.MarshalIndent(librarySchema{Version: version, WrittenBy: buildinfo.DisplayVersion()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(root, SchemaFileName), append(data, '\n'), 0o600)
+}
+
+// migrateMetadataSidecars brings the metadata sidecars to schema 1, which
+// stamps them with their version and adds the artists and upload_datetime
+// fields older sidecars were written without.
+func migrateMetadataSidecars(index *library.Index) error {
+	for _, path := range metadataSidecarPaths(index) {
+		if err := migrateMetadataSidecar(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// metadataSidecarPaths lists where the metadata sidecars of the files recorded
+// in index would be.
+func metadataSidecarPaths(index *library.Index) []string {
+	var paths []string
+	for _, entry := range index.Recorded() {
+		for _, file := range index.Files(entry) {
+			if path := sidecarPath(file, metadataSidecarSuffix); path != "" && path != filepath.Clean(file) {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+func migrateMetadataSidecar(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var metadata SubmissionFileMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil || metadata.SchemaVersion >= 1 {
+		// A .json file that does not parse was not written as a sidecar.
+		return nil
+	}
+
+	if metadata.Artists == nil {
+		metadata.Artists = SubmissionArtists(metadata.SubmissionDetails)
+	}
+	if metadata.Uploaded == "" {
+		if uploaded := UploadTime(metadata.SubmissionDetails, metadata.File, TimeZoneSite); !uploaded.IsZero() {
+			metadata.Uploaded = uploaded.Format(time.RFC3339)
+		}
+	}
+	metadata.SchemaVersion = 1
+
+	payload, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(payload, '\n'), 0o600)
+}
@@ -0,0 +1,50 @@
+package downloads
+
+import "sync"
+
+// OpenFileLimit caps the file handles that downloads hold open at once. Each
+// download holds its part file and a response body per connection, so one
+// with several connections takes several handles. A nil limit allows any
+// number.
+type OpenFileLimit struct {
+	mu    sync.Mutex
+	freed *sync.Cond
+	limit int
+	open  int
+}
+
+func NewOpenFileLimit(limit int) *OpenFileLimit {
+	if limit <= 0 {
+		return nil
+	}
+	l := &OpenFileLimit{limit: limit}
+	l.freed = sync.NewCond(&l.mu)
+	return l
+}
+
+// Acquire blocks until n more handles fit under the limit and returns the
+// number taken, which is to be passed to Release. A download needing more
+// handles than the whole limit takes all of them rather than waiting forever.
+func (l *OpenFileLimit) Acquire(n int) int {
+	if l == nil {
+		return 0
+	}
+	n = min(n, l.limit)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.open+n > l.limit {
+		l.freed.Wait()
+	}
+	l.open += n
+	return n
+}
+
+func (l *OpenFileLimit) Release(n int) {
+	if l == nil || n == 0 {
+		return
+	}
+	l.mu.Lock()
+	l.open -= n
+	l.mu.Unlock()
+	l.freed.Broadcast()
+}
@@ -0,0 +1,204 @@
+package downloads
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidPageTemplate = errors.New("invalid page template")
+
+// pageSnapshotSuffix is what the page snapshot of a submission is named with
+// after its submission ID, kept apart from the .html description export.
+const pageSnapshotSuffix = ".page.html"
+
+// defaultPageTemplate lays the page snapshot out like the submission page, as
+// plain HTML that opens without the site or any script.
+const defaultPageTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}} by {{.Artist}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60em; margin: 2em auto; padding: 0 1em; }
+.stats, .archived { color: #666; }
+.keywords a { display: inline-block; margin: 0 .5em .25em 0; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>by <a href="{{.ArtistURL}}">{{.Artist}}</a> · <a href="{{.URL}}">{{.URL}}</a></p>
+<p class="stats">{{with .Type}}{{.}} · {{end}}{{with .Rating}}{{.}} · {{end}}{{with .Uploaded}}uploaded {{.}} · {{end}}{{.Views}} views · {{.Favorites}} favorites · {{.Comments}} comments</p>
+{{with .Files}}<h2>Files</h2>
+<ol class="files">
+{{range .}}<li><a href="{{.URL}}">{{.Name}}</a>{{with .MD5}} <code>{{.}}</code>{{end}}</li>
+{{end}}</ol>
+{{end}}{{with .Description}}<h2>Description</h2>
+<div class="description">
+{{.}}
+</div>
+{{end}}{{with .Writing}}<h2>Story</h2>
+<div class="writing">
+{{.}}
+</div>
+{{end}}{{with .Keywords}}<h2>Keywords</h2>
+<p class="keywords">{{range .}}<a href="{{.URL}}">{{.Name}}</a> {{end}}</p>
+{{end}}{{with .Pools}}<h2>Pools</h2>
+<ul class="pools">
+{{range .}}<li><a href="{{.URL}}">{{.Name}}</a></li>
+{{end}}</ul>
+{{end}}<p class="archived">Archived {{.Archived}}</p>
+</body>
+</html>
+`
+
+// pageSnapshot is what page templates are filled in with.
+type pageSnapshot struct {
+	Title     string
+	Artist    string
+	ArtistURL string
+	URL       string
+	Type      string
+	Rating    string
+	Uploaded  string
+	Archived  string
+	Views     int
+	Favorites int
+	Comments  int
+	Files     []pageLink
+	Keywords  []pageLink
+	Pools     []pageLink
+	// Description and Writing are converted from BBCode, see BBCodeToHTML.
+	Description template.HTML
+	Writing     template.HTML
+}
+
+type pageLink struct {
+	Name string
+	URL  string
+	MD5  string
+}
+
+var (
+	pageTemplatesMu sync.Mutex
+	// pageTemplates holds the templates read so far by their path, with the
+	// default under "".
+	pageTemplates = make(map[string]*template.Template)
+)
+
+// ParsePageTemplate reads the html/template at path that page snapshots are
+// written with, or the default one when path is empty.
+func ParsePageTemplate(path string) (*template.Template, error) {
+	path = strings.TrimSpace(path)
+	pageTemplatesMu.Lock()
+	defer pageTemplatesMu.Unlock()
+	if parsed, ok := pageTemplates[path]; ok {
+		return parsed, nil
+	}
+
+	text := defaultPageTemplate
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	parsed, err := template.New("page").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPageTemplate, err)
+	}
+	pageTemplates[path] = parsed
+	return parsed, nil
+}
+
+// PageSnapshotPath is where the page snapshot of the submission behind
+// destination goes.
+func PageSnapshotPath(destination string, submissionID string) string {
+	clean := filepath.Clean(strings.TrimSpace(destination))
+	if clean == "." || clean == "" || submissionID == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(clean), submissionID+pageSnapshotSuffix)
+}
+
+// writePageSnapshots renders the page of the submission of details with the
+// template at templatePath into every folder of destinations. Like the
+// submission sidecar, each file of the submission writes it again.
+func writePageSnapshots(destinations []string, details SubmissionFileMetadata, templatePath string) error {
+	page, err := ParsePageTemplate(templatePath)
+	if err != nil {
+		return err
+	}
+	var payload bytes.Buffer
+	if err := page.Execute(&payload, newPageSnapshot(details, time.Now())); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidPageTemplate, err)
+	}
+
+	written := make(map[string]bool)
+	for _, destination := range uniqueNonEmptyPaths(destinations) {
+		path := PageSnapshotPath(destination, details.SubmissionID.String())
+		if path == "" || written[path] {
+			continue
+		}
+		written[path] = true
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, payload.Bytes(), 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newPageSnapshot(details SubmissionFileMetadata, archived time.Time) pageSnapshot {
+	artist := strings.TrimSpace(details.Username)
+	page := pageSnapshot{
+		Title:     strings.TrimSpace(details.Title),
+		Artist:    artist,
+		ArtistURL: "https://inkbunny.net/" + url.PathEscape(artist),
+		URL:       submissionSource(details),
+		Type:      strings.TrimSpace(details.TypeName),
+		Rating:    strings.TrimSpace(details.RatingName),
+		Uploaded:  details.Uploaded,
+		Archived:  archived.Format(time.DateTime),
+		Views:     details.Views.Int(),
+		Favorites: details.FavoritesCount.Int(),
+		Comments:  details.CommentsCount.Int(),
+	}
+	if description := strings.TrimSpace(details.Description); description != "" {
+		page.Description = template.HTML(BBCodeToHTML(description))
+	}
+	if writing := strings.TrimSpace(details.Writing); writing != "" {
+		page.Writing = template.HTML(BBCodeToHTML(writing))
+	}
+
+	files := details.submissionFiles
+	if len(files) == 0 {
+		files = append(files, details.File)
+	}
+	for _, file := range files {
+		page.Files = append(page.Files, pageLink{Name: file.FileName, URL: file.FileURLFull.String(), MD5: file.FullFileMD5})
+	}
+	for _, keyword := range details.Keywords {
+		page.Keywords = append(page.Keywords, pageLink{
+			Name: keyword.KeywordName,
+			URL:  fmt.Sprintf("https://inkbunny.net/search_process.php?keyword_id=%s", keyword.KeywordID),
+		})
+	}
+	for _, pool := range details.Pools {
+		page.Pools = append(page.Pools, pageLink{
+			Name: pool.Name,
+			URL:  fmt.Sprintf("https://inkbunny.net/poolview_process.php?pool_id=%s", pool.PoolID),
+		})
+	}
+	return page
+}
@@ -1,11 +1,14 @@
 package downloads
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"slices"
 	"strings"
 	"time"
 	"unicode"
@@ -15,7 +18,11 @@ import (
 
 const DefaultPattern = "inkbunny/{artist}/{file_name_full}"
 
-var downloadPatternTokenRE = regexp.MustCompile(`\{([a-z0-9_]+)\}`)
+// downloadPatternTokenRE matches a token and the functions it is piped
+// through, which stay within a path segment.
+var downloadPatternTokenRE = regexp.MustCompile(`\{([a-z0-9_]+)((?:\|[^{}|/\\]+)*)\}`)
+
+var ErrUnknownPatternToken = errors.New("unknown pattern token")
 
 var previewSubmission = inkbunny.SubmissionDetails{
 	SubmissionBasic: inkbunny.SubmissionBasic{
@@ -50,10 +57,24 @@ type downloadPathContext struct {
 	Submission inkbunny.SubmissionDetails
 	File       inkbunny.File
 	Pool       *inkbunny.Pool
+	Artist     string
 	Time       time.Time
 	Number     int
 }
 
+// Layout holds the placement options that apply on top of a download pattern.
+type Layout struct {
+	Collabs    string
+	Characters []string
+	// TimeZone is what the date tokens are rendered in, see NormalizeTimeZone.
+	TimeZone string
+	// Routes move or convert files by their MIME type, see ParseMIMERoutes.
+	Routes []MIMERoute
+	// Pools places the submissions it has a page for in their pool folder
+	// instead of where the pattern puts them, see PoolDestination.
+	Pools *PoolOrder
+}
+
 func NormalizePattern(pattern string) string {
 	trimmed := strings.TrimSpace(pattern)
 	if trimmed == "" {
@@ -62,11 +83,35 @@ func NormalizePattern(pattern string) string {
 	return trimmed
 }
 
+// ValidatePattern reports the first token in pattern that downloads have no
+// value for, which would otherwise end up in the path as written.
+func ValidatePattern(pattern string) error {
+	for _, match := range downloadPatternTokenRE.FindAllStringSubmatch(NormalizePattern(pattern), -1) {
+		if _, ok := downloadTokenValue(match[1], downloadPathContext{}); !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownPatternToken, match[0])
+		}
+		if _, err := applyPatternFunctions("", match[2]); err != nil {
+			return fmt.Errorf("%w in %q", err, match[0])
+		}
+	}
+	return nil
+}
+
 func ResolveDestinations(
 	root string,
 	pattern string,
 	submission inkbunny.SubmissionDetails,
 	file inkbunny.File,
+) []string {
+	return ResolveLayoutDestinations(root, pattern, submission, file, Layout{})
+}
+
+func ResolveLayoutDestinations(
+	root string,
+	pattern string,
+	submission inkbunny.SubmissionDetails,
+	file inkbunny.File,
+	layout Layout,
 ) []string {
 	cleanRoot := filepath.Clean(strings.TrimSpace(root))
 	if cleanRoot == "" {
@@ -77,21 +122,53 @@ func ResolveDestinations(
 	baseContext := downloadPathContext{
 		Submission: submission,
 		File:       file,
-		Time:       resolveDownloadTimestamp(submission, file),
+		Time:       UploadTime(submission, file, layout.TimeZone),
 		Number:     resolveFileNumber(file),
 	}
 
-	if !patternUsesPoolTokens(pattern) || len(submission.Pools) == 0 {
-		return []string{renderDownloadDestination(cleanRoot, pattern, baseContext)}
+	contexts := []downloadPathContext{baseContext}
+	if artists := SubmissionArtists(submission); len(artists) > 1 {
+		switch NormalizeCollabsMode(layout.Collabs) {
+		case CollabsFolder:
+			contexts[0].Artist = collabsFolderName
+		case CollabsLinks:
+			contexts = contexts[:0]
+			for _, artist := range artists {
+				ctx := baseContext
+				ctx.Artist = artist
+				contexts = append(contexts, ctx)
+			}
+		}
+	}
+
+	page, pooled := layout.Pools.Page(submission.SubmissionID.String())
+	if !pooled && patternUsesPoolTokens(pattern) && len(submission.Pools) > 0 {
+		pooled := make([]downloadPathContext, 0, len(contexts)*len(submission.Pools))
+		for _, ctx := range contexts {
+			for i := range submission.Pools {
+				pool := submission.Pools[i]
+				ctx.Pool = &pool
+				pooled = append(pooled, ctx)
+			}
+		}
+		contexts = pooled
 	}
 
-	destinations := make([]string, 0, len(submission.Pools))
-	for i := range submission.Pools {
-		pool := submission.Pools[i]
-		ctx := baseContext
-		ctx.Pool = &pool
+	destinations := make([]string, 0, len(contexts))
+	for _, ctx := range contexts {
 		destinations = append(destinations, renderDownloadDestination(cleanRoot, pattern, ctx))
 	}
+	if pooled {
+		// The pool folder takes the place of the owner's; the other artists of a
+		// collab keep theirs.
+		destinations[0] = PoolDestination(cleanRoot, page, submission, file)
+	}
+	destinations = append(destinations, characterDestinations(cleanRoot, submission, file, layout.Characters)...)
+	if route := Route(layout.Routes, file); route.Action != RouteDownload {
+		for i, destination := range destinations {
+			destinations[i] = route.Destination(destination)
+		}
+	}
 	return uniqueNonEmptyPaths(destinations)
 }
 
@@ -198,7 +275,7 @@ func renderDownloadDestination(root, pattern string, ctx downloadPathContext) st
 func renderDownloadComponent(component string, ctx downloadPathContext) string {
 	return downloadPatternTokenRE.ReplaceAllStringFunc(component, func(match string) string {
 		name := downloadPatternTokenRE.FindStringSubmatch(match)
-		if len(name) != 2 {
+		if len(name) != 3 {
 			return match
 		}
 
@@ -206,6 +283,10 @@ func renderDownloadComponent(component string, ctx downloadPathContext) string {
 		if !ok {
 			return match
 		}
+		value, err := applyPatternFunctions(value, name[2])
+		if err != nil {
+			return match
+		}
 		return value
 	})
 }
@@ -215,6 +296,9 @@ func downloadTokenValue(name string, ctx downloadPathContext) (string, bool) {
 
 	switch name {
 	case "artist":
+		if ctx.Artist != "" {
+			return ctx.Artist, true
+		}
 		return ctx.Submission.Username, true
 	case "artist_id":
 		return ctx.Submission.UserID.String(), true
@@ -227,8 +311,10 @@ func downloadTokenValue(name string, ctx downloadPathContext) (string, bool) {
 			return "public", true
 		}
 		return "private", true
-	case "submission_type":
+	case "submission_type", "type":
 		return normalizedSubmissionType(ctx.Submission.SubmissionTypeID.Int()), true
+	case "date":
+		return formatTimePart(ctx.Time, time.DateOnly), true
 	case "year":
 		return formatTimePart(ctx.Time, "2006"), true
 	case "month":
@@ -254,7 +340,7 @@ func downloadTokenValue(name string, ctx downloadPathContext) (string, bool) {
 			return ctx.Submission.Title, true
 		}
 		return "", true
-	case "number":
+	case "number", "page":
 		return fmt.Sprintf("%d", ctx.Number), true
 	case "ext":
 		return parts.ext, true
@@ -352,6 +438,12 @@ func downloadTargetsMatch(destinations []string, expectedMD5 string) (bool, int6
 }
 
 func ensureDownloadTargetsFromSource(source string, destinations []string, expectedMD5 string) error {
+	return placeDownloadTargets(Disk, source, destinations, expectedMD5)
+}
+
+// placeDownloadTargets places source at every destination in store that does
+// not already hold a copy matching expectedMD5.
+func placeDownloadTargets(store Storage, source string, destinations []string, expectedMD5 string) error {
 	if strings.TrimSpace(source) == "" {
 		return nil
 	}
@@ -363,22 +455,16 @@ func ensureDownloadTargetsFromSource(source string, destinations []string, expec
 			continue
 		}
 
-		result, err := verifyDownloadedFile(cleanDestination, expectedMD5)
+		result, err := sharedFileVerifier.Verify(store, cleanDestination, expectedMD5)
 		if err != nil {
 			return err
 		}
 		if result.Matches {
 			continue
 		}
-		if result.Exists {
-			if err := os.Remove(cleanDestination); err != nil && !os.IsNotExist(err) {
-				return err
-			}
-		}
-		if err := os.MkdirAll(filepath.Dir(cleanDestination), 0o755); err != nil {
-			return err
-		}
-		if err := copyFile(cleanSource, cleanDestination); err != nil {
+		// Links replace what is at the destination rather than write through
+		// it, as would a copy.
+		if err := placeFile(store, cleanSource, cleanDestination); err != nil {
 			return err
 		}
 	}
@@ -386,6 +472,12 @@ func ensureDownloadTargetsFromSource(source string, destinations []string, expec
 	return nil
 }
 
+// LinkDestinations places the downloaded source at the rest of destinations,
+// replacing any copy there that does not match expectedMD5.
+func LinkDestinations(source string, destinations []string, expectedMD5 string) error {
+	return ensureDownloadTargetsFromSource(source, destinations, expectedMD5)
+}
+
 func copyFile(source, destination string) error {
 	in, err := os.Open(source)
 	if err != nil {
@@ -424,8 +516,20 @@ func uniqueNonEmptyPaths(paths []string) []string {
 	return unique
 }
 
+// reserveDeviceNames prefixes windowsDeviceNames with _, which is only done on
+// Windows so that the paths of libraries elsewhere do not change.
+var reserveDeviceNames = runtime.GOOS == "windows"
+
+// windowsDeviceNames are the names Windows gives to devices, which no file
+// can be called whatever its extension.
+var windowsDeviceNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
 func sanitizePathComponent(value string) string {
-	trimmed := strings.TrimSpace(value)
+	trimmed := strings.TrimSpace(strings.ToValidUTF8(value, "_"))
 	if trimmed == "" {
 		return ""
 	}
@@ -447,48 +551,24 @@ func sanitizePathComponent(value string) string {
 	if cleaned == "" {
 		return ""
 	}
+	stem, _, _ := strings.Cut(cleaned, ".")
+	if reserveDeviceNames && slices.ContainsFunc(windowsDeviceNames, func(name string) bool { return strings.EqualFold(strings.TrimSpace(stem), name) }) {
+		cleaned = "_" + cleaned
+	}
 	return cleaned
 }
 
 func patternUsesPoolTokens(pattern string) bool {
-	return strings.Contains(pattern, "{pool_id}") || strings.Contains(pattern, "{pool_name}")
-}
-
-func PatternUsesPoolTokens(pattern string) bool {
-	return patternUsesPoolTokens(pattern)
-}
-
-func resolveDownloadTimestamp(submission inkbunny.SubmissionDetails, file inkbunny.File) time.Time {
-	for _, candidate := range []string{
-		submission.CreateDateUser,
-		submission.CreateDateSystem,
-		file.CreateDateTimeUser,
-		file.CreateDateTime,
-	} {
-		if parsed, ok := parseDownloadTime(candidate); ok {
-			return parsed
+	for _, match := range downloadPatternTokenRE.FindAllStringSubmatch(pattern, -1) {
+		if match[1] == "pool_id" || match[1] == "pool_name" {
+			return true
 		}
 	}
-	return time.Time{}
+	return false
 }
 
-func parseDownloadTime(value string) (time.Time, bool) {
-	trimmed := strings.TrimSpace(value)
-	if trimmed == "" {
-		return time.Time{}, false
-	}
-	layouts := []string{
-		"2006-01-02 15:04:05",
-		"2006-01-02T15:04:05",
-		time.RFC3339,
-	}
-	for _, layout := range layouts {
-		parsed, err := time.Parse(layout, trimmed)
-		if err == nil {
-			return parsed, true
-		}
-	}
-	return time.Time{}, false
+func PatternUsesPoolTokens(pattern string) bool {
+	return patternUsesPoolTokens(pattern)
 }
 
 func formatTimePart(value time.Time, layout string) string {
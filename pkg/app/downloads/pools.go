@@ -0,0 +1,74 @@
+package downloads
+
+import (
+	"cmp"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+// PoolPage is where a submission sits in a pool, counting from 1.
+type PoolPage struct {
+	Pool  inkbunny.Pool
+	Page  int
+	Pages int
+}
+
+// PoolOrder places submissions at their page in the pools they were
+// downloaded with. A nil *PoolOrder places nothing.
+type PoolOrder struct {
+	mu    sync.RWMutex
+	pages map[string]PoolPage
+}
+
+func NewPoolOrder() *PoolOrder {
+	return &PoolOrder{pages: make(map[string]PoolPage)}
+}
+
+// Add records the submissions of pool, given in pool order. A submission in
+// several pools keeps the first one it was added with.
+func (o *PoolOrder) Add(pool inkbunny.Pool, submissionIDs []string) {
+	if o == nil {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for i, id := range submissionIDs {
+		if _, ok := o.pages[id]; ok {
+			continue
+		}
+		o.pages[id] = PoolPage{Pool: pool, Page: i + 1, Pages: len(submissionIDs)}
+	}
+}
+
+// Page returns the pool page of a submission, if it has one.
+func (o *PoolOrder) Page(submissionID string) (PoolPage, bool) {
+	if o == nil {
+		return PoolPage{}, false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	page, ok := o.pages[submissionID]
+	return page, ok
+}
+
+// PoolDestination places file in a folder named after the pool under root,
+// prefixed with its page so that the pool reads in order. The files of a
+// submission with several of them are numbered after the page.
+func PoolDestination(root string, page PoolPage, submission inkbunny.SubmissionDetails, file inkbunny.File) string {
+	folder := cmp.Or(sanitizePathComponent(page.Pool.Name), "pool "+page.Pool.PoolID.String())
+	prefix := fmt.Sprintf("%0*d", len(strconv.Itoa(page.Pages)), page.Page)
+	if files := len(submission.Files); files > 1 {
+		for i, candidate := range submission.Files {
+			if candidate.FileID == file.FileID {
+				prefix += fmt.Sprintf("-%0*d", len(strconv.Itoa(files)), i+1)
+				break
+			}
+		}
+	}
+	name := sanitizePathComponent(filepath.Base(file.FileName))
+	return filepath.Join(root, folder, prefix+" "+name)
+}
@@ -0,0 +1,34 @@
+package downloads
+
+import "os"
+
+// preallocateThreshold keeps small images on the normal write path; only
+// larger files such as videos benefit from reserving their space up front.
+const preallocateThreshold = 8 << 20
+
+// PreallocateFile reserves size bytes for file when the server reported a
+// large enough size. Running out of disk space is reported here instead of
+// halfway through the download. It returns whether the file was preallocated,
+// in which case TrimPreallocatedFile must be called once writing is done.
+func PreallocateFile(file *os.File, size int64) (bool, error) {
+	if size < preallocateThreshold {
+		return false, nil
+	}
+	if err := preallocateFile(file, size); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// TrimPreallocatedFile cuts a preallocated file back to what was actually
+// written, in case the body was shorter than the reported size.
+func TrimPreallocatedFile(file *os.File, written int64) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == written {
+		return nil
+	}
+	return file.Truncate(written)
+}
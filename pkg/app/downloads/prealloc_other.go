@@ -0,0 +1,9 @@
+//go:build !linux && !windows
+
+package downloads
+
+import "os"
+
+func preallocateFile(*os.File, int64) error {
+	return nil
+}
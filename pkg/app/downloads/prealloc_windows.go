@@ -0,0 +1,11 @@
+//go:build windows
+
+package downloads
+
+import "os"
+
+// preallocateFile relies on Truncate being SetEndOfFile on Windows, which
+// reserves the clusters up front instead of growing the file write by write.
+func preallocateFile(file *os.File, size int64) error {
+	return file.Truncate(size)
+}
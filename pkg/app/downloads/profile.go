@@ -0,0 +1,143 @@
+package downloads
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// ArtistProfileFileName is written in the folder of an artist with what
+	// their profile said when they were last downloaded.
+	ArtistProfileFileName = "artist.json"
+	// ArtistProfilePageName keeps the profile page itself beside it, as the
+	// profile text is not offered by the API.
+	ArtistProfilePageName = "artist-profile.html"
+)
+
+// ArtistProfile is the profile of an artist as of their last download, kept
+// because it is gone once their account closes.
+type ArtistProfile struct {
+	SchemaVersion int    `json:"schema_version"`
+	Username      string `json:"username"`
+	UserID        string `json:"user_id,omitempty"`
+	ProfileURL    string `json:"profile_url"`
+	// Commissions are the lines of the profile page that mention commissions
+	// or prices, such as whether they are open and what they cost.
+	Commissions []string `json:"commissions,omitempty"`
+	// Links are the links of the profile page that lead off Inkbunny.
+	Links   []string `json:"links,omitempty"`
+	Fetched string   `json:"fetched_at"`
+}
+
+// NewArtistProfile reads the profile of username from their profile page.
+func NewArtistProfile(username, userID, profileURL string, page []byte) ArtistProfile {
+	profile := ArtistProfile{
+		SchemaVersion: SchemaVersion,
+		Username:      username,
+		UserID:        userID,
+		ProfileURL:    profileURL,
+		Fetched:       time.Now().UTC().Format(time.RFC3339),
+	}
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return profile
+	}
+	seen := make(map[string]struct{})
+	for node := range doc.Descendants() {
+		switch {
+		case node.Type == html.ElementNode && node.DataAtom == atom.A:
+			for _, attr := range node.Attr {
+				if attr.Key != "href" || !externalLink(attr.Val) {
+					continue
+				}
+				if _, ok := seen[attr.Val]; !ok {
+					seen[attr.Val] = struct{}{}
+					profile.Links = append(profile.Links, attr.Val)
+				}
+			}
+		case node.Type == html.TextNode && !insideScript(node):
+			for line := range strings.Lines(node.Data) {
+				line = strings.Join(strings.Fields(line), " ")
+				if mentionsCommissions(line) {
+					profile.Commissions = append(profile.Commissions, line)
+				}
+			}
+		}
+	}
+	return profile
+}
+
+// WriteArtistProfile writes profile and the page it was read from into dir,
+// replacing those of the last download.
+func WriteArtistProfile(dir string, profile ArtistProfile, page []byte) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ArtistProfilePageName), page, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ArtistProfileFileName), append(data, '\n'), 0o644)
+}
+
+// ArtistDirectory is the folder that pattern places the files of artist in:
+// the path up to the first segment with the {artist} token, or root when
+// pattern has none.
+func ArtistDirectory(root, pattern, artist string) string {
+	cleanRoot := filepath.Clean(strings.TrimSpace(root))
+	ctx := downloadPathContext{Artist: artist}
+	segments := []string{cleanRoot}
+	for part := range strings.SplitSeq(strings.ReplaceAll(NormalizePattern(pattern), "\\", "/"), "/") {
+		if rendered := sanitizePathComponent(renderDownloadComponent(part, ctx)); rendered != "" {
+			segments = append(segments, rendered)
+		}
+		for _, match := range downloadPatternTokenRE.FindAllStringSubmatch(part, -1) {
+			if match[1] == "artist" {
+				return filepath.Join(segments...)
+			}
+		}
+	}
+	return cleanRoot
+}
+
+// commissionWords pick out the lines of a profile about commissions.
+var commissionWords = []string{"commission", "price", "slot", "rates", "ych", "$", "€", "£"}
+
+func mentionsCommissions(line string) bool {
+	line = strings.ToLower(line)
+	for _, word := range commissionWords {
+		if strings.Contains(line, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func externalLink(href string) bool {
+	parsed, err := url.Parse(href)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	return host != "inkbunny.net" && !strings.HasSuffix(host, ".inkbunny.net") && !strings.HasSuffix(host, "metapix.net")
+}
+
+func insideScript(node *html.Node) bool {
+	for parent := node.Parent; parent != nil; parent = parent.Parent {
+		if parent.DataAtom == atom.Script || parent.DataAtom == atom.Style {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package downloads
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+var (
+	ErrInvalidRclone = errors.New("invalid rclone, expected a remote and a mode of copy or sync")
+	ErrRclone        = errors.New("rclone failed")
+)
+
+// Modes of rclone, see types.RcloneSettings.Mode.
+const (
+	RcloneCopy = "copy"
+	RcloneSync = "sync"
+)
+
+// Rclone copies downloads to an rclone remote by running the rclone command.
+type Rclone struct {
+	// Binary is the rclone command, found on the PATH when empty.
+	Binary string
+	Remote string
+	Mode   string
+	Flags  []string
+	// PerSubmission copies the files of each submission as it is downloaded
+	// rather than the whole download directory after the run.
+	PerSubmission bool
+}
+
+// NewRclone returns the rclone in settings, checking that it can be run.
+func NewRclone(settings types.RcloneSettings) (*Rclone, error) {
+	rclone := &Rclone{
+		Binary:        cmp.Or(strings.TrimSpace(settings.Binary), "rclone"),
+		Remote:        strings.TrimSpace(settings.Remote),
+		Flags:         settings.Flags,
+		PerSubmission: settings.PerSubmission,
+	}
+	if rclone.Remote == "" {
+		return nil, ErrInvalidRclone
+	}
+	switch mode := strings.ToLower(strings.TrimSpace(settings.Mode)); mode {
+	case "", RcloneCopy:
+		rclone.Mode = RcloneCopy
+	case RcloneSync:
+		if settings.PerSubmission {
+			return nil, fmt.Errorf("%w: sync cannot be run per submission", ErrInvalidRclone)
+		}
+		rclone.Mode = RcloneSync
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidRclone, mode)
+	}
+	if _, err := exec.LookPath(rclone.Binary); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRclone, err)
+	}
+	return rclone, nil
+}
+
+func (r *Rclone) String() string {
+	return r.Remote
+}
+
+// Run copies or syncs paths, which are under root, to the same paths under
+// the remote. Nothing else under root is uploaded, such as sid.txt or the
+// download history, and a sync only deletes from the remote what paths name.
+func (r *Rclone) Run(ctx context.Context, root string, paths []string) error {
+	return r.runFiles(ctx, r.Mode, root, paths)
+}
+
+// CopyFiles copies paths, which are under root, to the same paths under the
+// remote.
+func (r *Rclone) CopyFiles(ctx context.Context, root string, paths []string) error {
+	return r.runFiles(ctx, RcloneCopy, root, paths)
+}
+
+// runFiles runs rclone in mode from root to the remote on paths alone.
+func (r *Rclone) runFiles(ctx context.Context, mode string, root string, paths []string) error {
+	var list bytes.Buffer
+	for _, path := range uniqueNonEmptyPaths(paths) {
+		relative, err := filepath.Rel(root, path)
+		if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%w: %q is not under %q", ErrRclone, path, root)
+		}
+		list.WriteString(filepath.ToSlash(relative) + "\n")
+	}
+	if list.Len() == 0 {
+		return nil
+	}
+
+	files, err := os.CreateTemp("", "inkbunny-rclone-*.txt")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(files.Name())
+	_, err = files.Write(list.Bytes())
+	if err := errors.Join(err, files.Close()); err != nil {
+		return err
+	}
+	return r.run(ctx, append([]string{mode, root, r.Remote, "--files-from-raw", files.Name()}, r.Flags...))
+}
+
+// run runs rclone with args, logging what it reports. Its errors are logged
+// as errors and the last one is returned wrapping ErrRclone.
+func (r *Rclone) run(ctx context.Context, args []string) error {
+	command := exec.CommandContext(ctx, r.Binary, args...)
+	stderr, err := command.StderrPipe()
+	if err != nil {
+		return err
+	}
+	command.Stdout = io.Discard
+	log.Debug("Running rclone", "args", args)
+	if err := command.Start(); err != nil {
+		return fmt.Errorf("%w: %w", ErrRclone, err)
+	}
+
+	var last string
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.Contains(line, "ERROR"), strings.Contains(line, "Failed to"):
+			log.Error("rclone", "remote", r.Remote, "message", line)
+			last = line
+		case strings.Contains(line, "NOTICE"):
+			log.Warn("rclone", "remote", r.Remote, "message", line)
+		default:
+			log.Debug("rclone", "remote", r.Remote, "message", line)
+		}
+	}
+	if err := command.Wait(); err != nil {
+		if last != "" {
+			return fmt.Errorf("%w: %w: %s", ErrRclone, err, last)
+		}
+		return fmt.Errorf("%w: %w", ErrRclone, err)
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package downloads
+
+import (
+	"github.com/ellypaws/inkbunny"
+)
+
+// RelatedSubmissionIDs returns the submissions before and after submission in
+// each of its pools. Inkbunny's API has no recommendations, so pool neighbours
+// stand in for related work. The details must be fetched with ShowPools.
+func RelatedSubmissionIDs(submission inkbunny.SubmissionDetails) []string {
+	var ids []string
+	seen := map[inkbunny.IntString]struct{}{submission.SubmissionID: {}}
+	for _, pool := range submission.Pools {
+		for _, id := range []inkbunny.IntString{pool.LeftSubmissionID, pool.RightSubmissionID} {
+			if id <= 0 {
+				continue
+			}
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			ids = append(ids, id.String())
+		}
+	}
+	return ids
+}
@@ -0,0 +1,72 @@
+package downloads
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// PartSuffix marks a file that is still downloading. A file only gets its real
+// name once it is complete, so an interrupted download never looks finished and
+// the next attempt can pick up where it stopped.
+const PartSuffix = ".part"
+
+func PartPath(filename string) string {
+	return filename + PartSuffix
+}
+
+// OpenPart opens the part file of filename for writing, keeping what an earlier
+// attempt left in it. It returns how many bytes the part file already holds.
+func OpenPart(filename string) (*os.File, int64, error) {
+	file, err := os.OpenFile(PartPath(filename), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, 0, err
+	}
+	return file, info.Size(), nil
+}
+
+// RequestFrom asks for the file from offset onward when part of it is already
+// downloaded.
+func RequestFrom(req *http.Request, offset int64) {
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+}
+
+// ResumeFrom gets file ready for the body of resp and returns the offset the
+// body belongs at. When resp continues the file at offset, the bytes already in
+// it are fed to hasher, if any, so the finished file can still be checked in
+// one pass. Any other response starts the file over.
+func ResumeFrom(file *os.File, resp *http.Response, offset int64, hasher io.Writer) (int64, error) {
+	if offset > 0 && resp.StatusCode == http.StatusPartialContent &&
+		strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset)) {
+		if hasher == nil {
+			return file.Seek(offset, io.SeekStart)
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.CopyN(hasher, file, offset); err != nil {
+			return 0, err
+		}
+		return offset, nil
+	}
+	if err := file.Truncate(0); err != nil {
+		return 0, err
+	}
+	_, err := file.Seek(0, io.SeekStart)
+	return 0, err
+}
+
+// FinishPart gives the completed part file of filename its real name. The part
+// file must be closed first.
+func FinishPart(filename string) error {
+	return Disk.Move(PartPath(filename), filename)
+}
@@ -0,0 +1,186 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+// Actions a MIME route can take on the files it matches.
+const (
+	RouteDownload  = "download"
+	RouteSkip      = "skip"
+	RouteSubfolder = "subfolder"
+	RouteConvert   = "convert"
+)
+
+var (
+	ErrInvalidRoute       = errors.New("invalid mime route")
+	ErrUnsupportedConvert = errors.New("unsupported conversion format")
+)
+
+// convertExtensions are the formats files can be converted to, keyed by the
+// name image.Decode reports for them.
+var convertExtensions = map[string]string{
+	"png":  ".png",
+	"jpeg": ".jpg",
+	"gif":  ".gif",
+}
+
+// MIMERoute decides what happens to the files whose MIME type matches MIME.
+// MIME can end in "/*" to match a whole family such as "video/*", or be "*".
+type MIMERoute struct {
+	MIME   string
+	Action string
+	// Target is the folder of a subfolder route and the format of a convert route.
+	Target string
+}
+
+// ParseMIMERoutes parses routes written as "image/gif=skip,video/*=subfolder:videos,image/png=convert:jpeg".
+func ParseMIMERoutes(value string) ([]MIMERoute, error) {
+	var routes []MIMERoute
+	for rule := range strings.SplitSeq(value, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		mime, action, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRoute, rule)
+		}
+		action, target, _ := strings.Cut(strings.TrimSpace(action), ":")
+		route := MIMERoute{
+			MIME:   strings.ToLower(strings.TrimSpace(mime)),
+			Action: strings.ToLower(strings.TrimSpace(action)),
+			Target: strings.TrimSpace(target),
+		}
+		if route.MIME == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRoute, rule)
+		}
+		switch route.Action {
+		case RouteDownload, RouteSkip:
+			route.Target = ""
+		case RouteSubfolder:
+			route.Target = sanitizePathComponent(route.Target)
+			if route.Target == "" {
+				return nil, fmt.Errorf("%w: %q needs a folder", ErrInvalidRoute, rule)
+			}
+		case RouteConvert:
+			route.Target = strings.ToLower(route.Target)
+			if route.Target == "jpg" {
+				route.Target = "jpeg"
+			}
+			if _, ok := convertExtensions[route.Target]; !ok {
+				return nil, fmt.Errorf("%w: %q", ErrUnsupportedConvert, target)
+			}
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRoute, rule)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// Route returns the first route matching the MIME type of file. Files no route
+// matches are downloaded.
+func Route(routes []MIMERoute, file inkbunny.File) MIMERoute {
+	mimeType := strings.ToLower(strings.TrimSpace(file.MimeType))
+	for _, route := range routes {
+		if matched, _ := path.Match(route.MIME, mimeType); matched {
+			return route
+		}
+	}
+	return MIMERoute{MIME: mimeType, Action: RouteDownload}
+}
+
+// Destination moves destination into the folder of a subfolder route, or
+// gives it the extension of a convert route.
+func (r MIMERoute) Destination(destination string) string {
+	switch r.Action {
+	case RouteSubfolder:
+		return filepath.Join(filepath.Dir(destination), r.Target, filepath.Base(destination))
+	case RouteConvert:
+		return strings.TrimSuffix(destination, filepath.Ext(destination)) + convertExtensions[r.Target]
+	}
+	return destination
+}
+
+// Format returns the format a convert route re-encodes files in, and nothing
+// for other routes.
+func (r MIMERoute) Format() string {
+	if r.Action != RouteConvert {
+		return ""
+	}
+	return r.Target
+}
+
+// ConvertFiles re-encodes each of paths in format, leaving files already in it
+// alone. Converted files no longer match the MD5 Inkbunny lists for them.
+func ConvertFiles(paths []string, format string) error {
+	if format == "" {
+		return nil
+	}
+	for _, name := range uniqueNonEmptyPaths(paths) {
+		if err := convertFile(name, format); err != nil {
+			return fmt.Errorf("convert %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func convertFile(name string, format string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if _, current, err := image.DecodeConfig(in); err != nil {
+		return err
+	} else if current == format {
+		return nil
+	}
+	if _, err := in.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	img, _, err := image.Decode(in)
+	in.Close()
+	if err != nil {
+		return err
+	}
+
+	temp := name + ".converting"
+	out, err := os.Create(temp)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "png":
+		err = png.Encode(out, img)
+	case "jpeg":
+		err = jpeg.Encode(out, img, &jpeg.Options{Quality: 95})
+	case "gif":
+		err = gif.Encode(out, img, nil)
+	default:
+		err = fmt.Errorf("%w: %q", ErrUnsupportedConvert, format)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(temp)
+		return err
+	}
+	// Destinations can be hardlinks of each other, so the converted file
+	// replaces the link instead of writing through it.
+	return os.Rename(temp, name)
+}
@@ -0,0 +1,296 @@
+package downloads
+
+import (
+	"cmp"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidOutput = errors.New("invalid output, expected s3://bucket/prefix or webdav://host/path")
+	ErrS3            = errors.New("object storage request failed")
+)
+
+// S3Storage is a Storage in a bucket of Amazon S3 or a service compatible with
+// it, such as MinIO, Backblaze B2 or Cloudflare R2. Names are keys under
+// Prefix. Objects are addressed by path, which every such service accepts.
+type S3Storage struct {
+	// Endpoint is the base URL of the service, such as https://s3.us-east-1.amazonaws.com.
+	Endpoint string
+	Region   string
+	Bucket   string
+	Prefix   string
+
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+
+	Client *http.Client
+}
+
+// outputTimeout bounds a single request to the storage of --output, which runs
+// in the download worker, so that a server that stops answering does not hold
+// the worker up for good.
+const outputTimeout = 10 * time.Minute
+
+// ParseOutput returns the Storage that --output names, which is nil for the
+// local filesystem.
+func ParseOutput(value string) (Storage, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	scheme, _, _ := strings.Cut(strings.ToLower(value), "://")
+	switch scheme {
+	case "s3":
+		return ParseS3URL(value)
+	case "webdav", "davs", "dav":
+		return ParseWebDAVURL(value)
+	}
+	return nil, fmt.Errorf("%w: %q", ErrInvalidOutput, value)
+}
+
+// ParseS3URL reads s3://bucket/prefix, taking the credentials, region and
+// endpoint from the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY,
+// AWS_SESSION_TOKEN, AWS_REGION and AWS_ENDPOINT_URL variables the AWS tools
+// read.
+func ParseS3URL(value string) (*S3Storage, error) {
+	parsed, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || !strings.EqualFold(parsed.Scheme, "s3") || parsed.Host == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidOutput, value)
+	}
+	region := cmp.Or(os.Getenv("AWS_REGION"), os.Getenv("AWS_DEFAULT_REGION"), "us-east-1")
+	storage := &S3Storage{
+		Endpoint:     strings.TrimRight(cmp.Or(os.Getenv("AWS_ENDPOINT_URL_S3"), os.Getenv("AWS_ENDPOINT_URL"), "https://s3."+region+".amazonaws.com"), "/"),
+		Region:       region,
+		Bucket:       parsed.Host,
+		Prefix:       strings.Trim(parsed.Path, "/"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Client:       &http.Client{Timeout: outputTimeout},
+	}
+	if storage.AccessKey == "" || storage.SecretKey == "" {
+		return nil, fmt.Errorf("%w: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY for %q", ErrInvalidOutput, value)
+	}
+	return storage, nil
+}
+
+func (s *S3Storage) String() string {
+	return "s3://" + path.Join(s.Bucket, s.Prefix)
+}
+
+// Create buffers what is written in a temporary file and uploads it on Close,
+// as S3 needs the size of an object before it is sent.
+func (s *S3Storage) Create(name string) (io.WriteCloser, error) {
+	return newBufferedUpload(func(body io.Reader, size int64) error {
+		response, err := s.do(http.MethodPut, name, body, size, nil)
+		if err != nil {
+			return err
+		}
+		return response.Body.Close()
+	})
+}
+
+// bufferedUpload is a file being written to a temporary file that put sends
+// on Close, for storage that needs the size of a file before it is sent.
+type bufferedUpload struct {
+	*os.File
+	put func(body io.Reader, size int64) error
+}
+
+func newBufferedUpload(put func(body io.Reader, size int64) error) (*bufferedUpload, error) {
+	file, err := os.CreateTemp("", "inkbunny-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	return &bufferedUpload{File: file, put: put}, nil
+}
+
+func (u *bufferedUpload) Close() error {
+	defer os.Remove(u.File.Name())
+	defer u.File.Close()
+	info, err := u.File.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := u.File.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return u.put(u.File, info.Size())
+}
+
+func (s *S3Storage) Open(name string) (io.ReadCloser, error) {
+	response, err := s.do(http.MethodGet, name, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+func (s *S3Storage) Exists(name string) (bool, error) {
+	_, err := s.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *S3Storage) Stat(name string) (fs.FileInfo, error) {
+	response, err := s.do(http.MethodHead, name, nil, 0, nil)
+	if err != nil {
+		return nil, err
+	}
+	_ = response.Body.Close()
+	modified, _ := http.ParseTime(response.Header.Get("Last-Modified"))
+	return storedFileInfo{name: path.Base(filepath.ToSlash(name)), size: response.ContentLength, modified: modified}, nil
+}
+
+// Move copies from to to within the bucket and deletes from, as objects
+// cannot be renamed.
+func (s *S3Storage) Move(from, to string) error {
+	if err := s.Link(from, to); err != nil {
+		return err
+	}
+	response, err := s.do(http.MethodDelete, from, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// Link copies from to to within the bucket. Objects cannot share their data,
+// but the copy is made by the service without downloading it.
+func (s *S3Storage) Link(from, to string) error {
+	source := "/" + s.Bucket + "/" + escapeS3Key(s.key(from))
+	response, err := s.do(http.MethodPut, to, nil, 0, http.Header{"X-Amz-Copy-Source": {source}})
+	if err != nil {
+		return err
+	}
+	return response.Body.Close()
+}
+
+// key is the key of the object called name.
+func (s *S3Storage) key(name string) string {
+	name = strings.TrimLeft(path.Clean("/"+filepath.ToSlash(name)), "/")
+	if s.Prefix == "" {
+		return name
+	}
+	return s.Prefix + "/" + name
+}
+
+// do sends a request for the object called name, signed with Signature
+// Version 4. A missing object gives an error wrapping fs.ErrNotExist and any
+// other failure one wrapping ErrS3.
+func (s *S3Storage) do(method, name string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	target := s.Endpoint + "/" + s.Bucket + "/" + escapeS3Key(s.key(name))
+	request, err := http.NewRequest(method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	if body != nil {
+		request.ContentLength = size
+	}
+	s.sign(request, time.Now().UTC())
+
+	response, err := cmp.Or(s.Client, http.DefaultClient).Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s %s: %w", ErrS3, method, s.key(name), err)
+	}
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return response, nil
+	}
+	_ = response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: strings.ToLower(method), Path: s.key(name), Err: fs.ErrNotExist}
+	}
+	return nil, fmt.Errorf("%w: %s %s: %s", ErrS3, method, s.key(name), response.Status)
+}
+
+// sign adds the Signature Version 4 authorization of request at now. The body
+// is left out of the signature, which S3 allows over HTTPS, so that it does
+// not have to be read twice.
+func (s *S3Storage) sign(request *http.Request, now time.Time) {
+	const payload = "UNSIGNED-PAYLOAD"
+	date := now.Format("20060102")
+	request.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	request.Header.Set("X-Amz-Content-Sha256", payload)
+	if s.SessionToken != "" {
+		request.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+
+	headers := []string{"host"}
+	canonicalHeaders := "host:" + request.URL.Host + "\n"
+	var names []string
+	for name := range request.Header {
+		if lower := strings.ToLower(name); strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		headers = append(headers, name)
+		canonicalHeaders += name + ":" + strings.TrimSpace(request.Header.Get(name)) + "\n"
+	}
+	signedHeaders := strings.Join(headers, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		request.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payload,
+	}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	request.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.AccessKey, scope, signedHeaders, signature))
+}
+
+// escapeS3Key escapes each segment of key the way Signature Version 4
+// expects, keeping only the unreserved characters.
+func escapeS3Key(key string) string {
+	var builder strings.Builder
+	for _, b := range []byte(key) {
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9', strings.IndexByte("-_.~/", b) >= 0:
+			builder.WriteByte(b)
+		default:
+			fmt.Fprintf(&builder, "%%%02X", b)
+		}
+	}
+	return builder.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
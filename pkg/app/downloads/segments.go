@@ -0,0 +1,136 @@
+package downloads
+
+import (
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	// minSegmentedSize is the smallest file split across connections. Below it
+	// the extra requests cost more than they save.
+	minSegmentedSize = 8 << 20
+	// MaxConnectionsPerFile caps the ranged requests made for a single file.
+	MaxConnectionsPerFile = 16
+)
+
+// ErrSegmentsUnsupported is returned when a ranged request is refused, in which
+// case the file should be downloaded again over a single connection.
+var ErrSegmentsUnsupported = errors.New("ranged downloads are not supported")
+
+// NormalizeConnections clamps the connections per file to a usable value, where 1
+// means a plain download.
+func NormalizeConnections(connections int) int {
+	return min(max(connections, 1), MaxConnectionsPerFile)
+}
+
+// CanSegment reports whether the file answering resp is large enough to split
+// across connections, and whether the server said it accepts ranged requests.
+func CanSegment(resp *http.Response, connections int) bool {
+	return NormalizeConnections(connections) > 1 &&
+		resp.ContentLength >= minSegmentedSize &&
+		strings.EqualFold(strings.TrimSpace(resp.Header.Get("Accept-Ranges")), "bytes")
+}
+
+// DownloadSegments fetches the size bytes at url into file over several ranged
+// requests at once, each writing its own part of the file. It returns the MD5 of
+// the reassembled file, which is read back once every part is in, so a part that
+// went wrong shows up as a mismatch against the expected hash.
+func DownloadSegments(ctx context.Context, client *http.Client, url string, file *os.File, size int64, connections int, progress func(written int64)) (string, error) {
+	connections = NormalizeConnections(connections)
+	if err := file.Truncate(size); err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var written atomic.Int64
+	report := func(n int64) {
+		total := written.Add(n)
+		if progress != nil {
+			progress(total)
+		}
+	}
+
+	segment := (size + int64(connections) - 1) / int64(connections)
+	errs := make(chan error, connections)
+	count := 0
+	for start := int64(0); start < size; start += segment {
+		end := min(start+segment, size) - 1
+		count++
+		go func() {
+			errs <- fetchSegment(ctx, client, url, file, start, end, report)
+		}()
+	}
+
+	var firstErr error
+	for range count {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+			cancel()
+		}
+	}
+	if firstErr != nil {
+		return "", firstErr
+	}
+
+	hasher := md5.New()
+	if _, err := io.Copy(hasher, io.NewSectionReader(file, 0, size)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
+}
+
+func fetchSegment(ctx context.Context, client *http.Client, url string, file *os.File, start, end int64, report func(int64)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// A server that ignores the range answers 200 with the whole file, and one
+	// that answers a different range would scramble the parts.
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("%w: %s", ErrSegmentsUnsupported, resp.Status)
+	}
+	if want := fmt.Sprintf("bytes %d-%d/", start, end); !strings.HasPrefix(resp.Header.Get("Content-Range"), want) {
+		return fmt.Errorf("%w: unexpected content range %q", ErrSegmentsUnsupported, resp.Header.Get("Content-Range"))
+	}
+
+	length := end - start + 1
+	writer := io.NewOffsetWriter(file, start)
+	buffer := make([]byte, 32*1024)
+	var copied int64
+	for copied < length {
+		n, readErr := resp.Body.Read(buffer[:min(int64(len(buffer)), length-copied)])
+		if n > 0 {
+			if _, err := writer.Write(buffer[:n]); err != nil {
+				return err
+			}
+			copied += int64(n)
+			report(int64(n))
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+			return readErr
+		}
+	}
+	if copied != length {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+var ErrInvalidFileSelection = errors.New("invalid file selection")
+
+// FileSelection picks which files of a submission are downloaded. The zero
+// value picks all of them.
+type FileSelection struct {
+	// Patterns are file name globs such as "*.png". A file matching any of them
+	// is picked, and all files are when there are none.
+	Patterns []string
+	// First only picks the first picked file of each submission.
+	First bool
+}
+
+// ParseFileSelection parses a selection written as "first,*.png,*.jpg". The
+// file names are matched without regard to case.
+func ParseFileSelection(value string) (FileSelection, error) {
+	var selection FileSelection
+	for rule := range strings.SplitSeq(value, ",") {
+		rule = strings.ToLower(strings.TrimSpace(rule))
+		switch rule {
+		case "", "all":
+		case "first":
+			selection.First = true
+		default:
+			if _, err := path.Match(rule, ""); err != nil {
+				return FileSelection{}, fmt.Errorf("%w: %q", ErrInvalidFileSelection, rule)
+			}
+			selection.Patterns = append(selection.Patterns, rule)
+		}
+	}
+	return selection, nil
+}
+
+// Selects reports whether file is picked from the files of submission.
+func (s FileSelection) Selects(submission inkbunny.SubmissionDetails, file inkbunny.File) bool {
+	if !s.matches(file) {
+		return false
+	}
+	if !s.First {
+		return true
+	}
+	for _, candidate := range submission.Files {
+		if s.matches(candidate) {
+			return candidate.FileID == file.FileID
+		}
+	}
+	return false
+}
+
+func (s FileSelection) matches(file inkbunny.File) bool {
+	if len(s.Patterns) == 0 {
+		return true
+	}
+	name := strings.ToLower(filepath.Base(file.FileName))
+	for _, pattern := range s.Patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package downloads
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+const (
+	keywordsSidecarSuffix    = ".txt"
+	metadataSidecarSuffix    = ".json"
+	descriptionSidecarSuffix = ".md"
+	commentsSidecarSuffix    = ".comments.json"
+	submissionSidecarSuffix  = ".submission.json"
+)
+
+// submissionComments is what the comments sidecar records. The Inkbunny API
+// only exposes the comment count, so the thread itself is linked rather than
+// copied.
+type submissionComments struct {
+	SubmissionID  string `json:"submission_id"`
+	URL           string `json:"url"`
+	CommentsCount int    `json:"comments_count"`
+}
+
+// LegacySidecars maps the single saveKeywords/caption toggle onto the sidecar
+// it has always produced.
+func LegacySidecars(enabled bool) types.SidecarOptions {
+	return types.SidecarOptions{Metadata: enabled}
+}
+
+// SidecarsNeedDetails reports whether the enabled sidecars read fields that are
+// only returned by MetadataSubmissionDetailsRequest.
+func SidecarsNeedDetails(sidecars types.SidecarOptions) bool {
+	return sidecars.Metadata || sidecars.Description || sidecars.Submission || sidecars.Hydrus || sidecars.Page || sidecars.DescriptionExport != "" || (sidecars.Keywords && sidecars.DeriveKeywords)
+}
+
+func WriteSidecars(destinations []string, details SubmissionFileMetadata, sidecars types.SidecarOptions) error {
+	if !sidecars.Any() {
+		return nil
+	}
+
+	var files []sidecarFile
+	var captionFile *sidecarFile
+	if sidecars.Keywords {
+		var captioned []string
+		for _, destination := range uniqueNonEmptyPaths(destinations) {
+			if captionBeside(destination, details.SubmissionID.String(), sidecars) {
+				captioned = append(captioned, destination)
+			}
+		}
+		if names := sidecarKeywords(details, sidecars); len(names) > 0 && len(captioned) > 0 {
+			if sidecars.CaptionFormat == CaptionJSONLines {
+				if err := writeCaptionLines(captioned, details, names); err != nil {
+					return err
+				}
+			} else {
+				captionFile = &sidecarFile{suffix: keywordsSidecarSuffix, payload: []byte(captionBOM(sidecars) + caption(details, names, sidecars))}
+			}
+		}
+	}
+	if sidecars.Metadata {
+		payload, err := json.MarshalIndent(details, "", "  ")
+		if err != nil {
+			return err
+		}
+		files = append(files, sidecarFile{suffix: metadataSidecarSuffix, payload: append(payload, '\n')})
+	}
+	if sidecars.Description {
+		files = append(files, sidecarFile{suffix: descriptionSidecarSuffix, payload: descriptionSidecar(details)})
+	}
+	if sidecars.Comments {
+		payload, err := json.MarshalIndent(submissionComments{
+			SubmissionID:  details.SubmissionID.String(),
+			URL:           fmt.Sprintf("https://inkbunny.net/s/%s", details.SubmissionID),
+			CommentsCount: int(details.CommentsCount),
+		}, "", "  ")
+		if err != nil {
+			return err
+		}
+		files = append(files, sidecarFile{suffix: commentsSidecarSuffix, payload: append(payload, '\n')})
+	}
+	if sidecars.Hydrus {
+		files = append(files,
+			sidecarFile{suffix: hydrusTagsSidecarSuffix, payload: hydrusSidecar(hydrusTags(details, sidecarKeywords(details, sidecars))), hydrus: true},
+			sidecarFile{suffix: hydrusURLsSidecarSuffix, payload: hydrusSidecar(hydrusURLs(details)), hydrus: true},
+		)
+	}
+
+	for _, destination := range uniqueNonEmptyPaths(destinations) {
+		if captionFile != nil && captionBeside(destination, details.SubmissionID.String(), sidecars) {
+			if err := writeSidecar(destination, *captionFile); err != nil {
+				return err
+			}
+		}
+		for _, file := range files {
+			if err := writeSidecar(destination, file); err != nil {
+				return err
+			}
+		}
+	}
+	if sidecars.DescriptionExport != "" {
+		if err := writeDescriptionExports(destinations, details, sidecars.DescriptionExport); err != nil {
+			return err
+		}
+	}
+	if sidecars.Page {
+		if err := writePageSnapshots(destinations, details, sidecars.PageTemplate); err != nil {
+			return err
+		}
+	}
+	if sidecars.Submission {
+		return writeSubmissionSidecars(destinations, details)
+	}
+	return nil
+}
+
+// writeSubmissionSidecars writes the submission sidecar into every folder of
+// destinations. Each file of the submission writes it again, so it always
+// holds the details fetched last.
+func writeSubmissionSidecars(destinations []string, details SubmissionFileMetadata) error {
+	submission := details.SubmissionDetails
+	submission.Files = details.submissionFiles
+	payload, err := json.MarshalIndent(submission, "", "  ")
+	if err != nil {
+		return err
+	}
+	payload = append(payload, '\n')
+
+	written := make(map[string]bool)
+	for _, destination := range uniqueNonEmptyPaths(destinations) {
+		path := SubmissionSidecarPath(destination, details.SubmissionID.String())
+		if path == "" || written[path] {
+			continue
+		}
+		written[path] = true
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, payload, 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubmissionSidecarPath is where the submission sidecar of the submission
+// behind destination goes.
+func SubmissionSidecarPath(destination string, submissionID string) string {
+	clean := filepath.Clean(strings.TrimSpace(destination))
+	if clean == "." || clean == "" || submissionID == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(clean), submissionID+submissionSidecarSuffix)
+}
+
+func writeSidecar(destination string, file sidecarFile) error {
+	path := sidecarPath(destination, file.suffix)
+	if file.hydrus {
+		path = hydrusSidecarPath(destination, file.suffix)
+	}
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, file.payload, 0o600)
+}
+
+// captionOwners holds, per folder and submission, the file that gets its
+// caption with PerSubmission.
+var captionOwners sync.Map
+
+// captionBeside reports whether the file at destination gets the keywords
+// caption, which with PerSubmission is only the first file of its submission
+// written into its folder, so that a submission whose first file was skipped
+// is still captioned. The other sidecars describe a single file and are always
+// written.
+func captionBeside(destination string, submissionID string, sidecars types.SidecarOptions) bool {
+	if !sidecars.PerSubmission {
+		return true
+	}
+	clean := filepath.Clean(destination)
+	owner, _ := captionOwners.LoadOrStore(filepath.Join(filepath.Dir(clean), submissionID), clean)
+	return owner == clean
+}
+
+// CaptionPath is where the keywords caption of destination goes, unless it is
+// written to CaptionLinesFile.
+func CaptionPath(destination string) string {
+	return sidecarPath(destination, keywordsSidecarSuffix)
+}
+
+// SidecarPaths lists every sidecar that WriteSidecars may have produced for
+// destination. The submission sidecar is left out, as it is shared with the
+// other files of the submission, see SubmissionSidecarPath.
+func SidecarPaths(destination string, sidecars types.SidecarOptions) []string {
+	var suffixes []string
+	if sidecars.Keywords && sidecars.CaptionFormat != CaptionJSONLines {
+		suffixes = append(suffixes, keywordsSidecarSuffix)
+	}
+	if sidecars.Metadata {
+		suffixes = append(suffixes, metadataSidecarSuffix)
+	}
+	if sidecars.Description {
+		suffixes = append(suffixes, descriptionSidecarSuffix)
+	}
+	if sidecars.Comments {
+		suffixes = append(suffixes, commentsSidecarSuffix)
+	}
+
+	paths := make([]string, 0, len(suffixes)+2)
+	for _, suffix := range suffixes {
+		if path := sidecarPath(destination, suffix); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if sidecars.Hydrus {
+		for _, suffix := range []string{hydrusTagsSidecarSuffix, hydrusURLsSidecarSuffix} {
+			if path := hydrusSidecarPath(destination, suffix); path != "" {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+// WrittenSidecars lists, like SidecarPaths, the sidecars WriteSidecars may
+// have produced for destination, along with those it shares with the other
+// files of the submission.
+func WrittenSidecars(destination string, submissionID string, sidecars types.SidecarOptions) []string {
+	paths := SidecarPaths(destination, sidecars)
+	if sidecars.Keywords && sidecars.CaptionFormat == CaptionJSONLines {
+		paths = append(paths, filepath.Join(filepath.Dir(filepath.Clean(destination)), CaptionLinesFile))
+	}
+	if sidecars.Submission {
+		paths = append(paths, SubmissionSidecarPath(destination, submissionID))
+	}
+	if sidecars.Page {
+		paths = append(paths, PageSnapshotPath(destination, submissionID))
+	}
+	if sidecars.DescriptionExport != "" {
+		paths = append(paths, DescriptionExportPath(destination, submissionID, sidecars.DescriptionExport))
+	}
+	return paths
+}
+
+type sidecarFile struct {
+	suffix  string
+	payload []byte
+	// hydrus names the sidecar after the whole file name, see hydrusSidecarPath.
+	hydrus bool
+}
+
+// sidecarKeywords returns what the keywords sidecar lists, which is empty when
+// the submission has no keywords and none are to be derived.
+func sidecarKeywords(details SubmissionFileMetadata, sidecars types.SidecarOptions) []string {
+	if len(details.Keywords) == 0 {
+		if sidecars.DeriveKeywords {
+			return DeriveKeywords(details.SubmissionDetails)
+		}
+		return nil
+	}
+	names := make([]string, 0, len(details.Keywords))
+	for _, keyword := range details.Keywords {
+		names = append(names, keyword.KeywordName)
+	}
+	return names
+}
+
+// MissingKeywords reports whether the keywords sidecar of details is left out
+// for want of keywords.
+func MissingKeywords(details SubmissionFileMetadata, sidecars types.SidecarOptions) bool {
+	return sidecars.Keywords && len(sidecarKeywords(details, sidecars)) == 0
+}
+
+func descriptionSidecar(details SubmissionFileMetadata) []byte {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "# %s\n\n", strings.TrimSpace(details.Title))
+	fmt.Fprintf(&builder, "by %s\n", strings.TrimSpace(details.Username))
+	if description := strings.TrimSpace(details.Description); description != "" {
+		builder.WriteString("\n")
+		builder.WriteString(description)
+		builder.WriteString("\n")
+	}
+	if writing := strings.TrimSpace(details.Writing); writing != "" {
+		builder.WriteString("\n---\n\n")
+		builder.WriteString(writing)
+		builder.WriteString("\n")
+	}
+	return []byte(builder.String())
+}
+
+func sidecarPath(destination string, suffix string) string {
+	clean := filepath.Clean(strings.TrimSpace(destination))
+	if clean == "." || clean == "" {
+		return ""
+	}
+	return strings.TrimSuffix(clean, filepath.Ext(clean)) + suffix
+}
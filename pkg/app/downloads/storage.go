@@ -0,0 +1,175 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var ErrLinkUnsupported = errors.New("storage cannot link files")
+
+// Storage is what downloads are placed in. Disk is the one every run uses;
+// another, such as one on a remote server or the MemoryStorage that tests use,
+// only has to implement these for files to be placed in it.
+//
+// Names are paths in the storage. Create, Move and Link make the folders
+// above the name they write.
+type Storage interface {
+	// Create opens name for writing, replacing, not writing through, what is
+	// there.
+	Create(name string) (io.WriteCloser, error)
+	Open(name string) (io.ReadCloser, error)
+	Exists(name string) (bool, error)
+	// Move renames from to to, replacing what is at to.
+	Move(from, to string) error
+	// Stat returns an error wrapping fs.ErrNotExist for a missing name.
+	Stat(name string) (fs.FileInfo, error)
+	// Link makes to another name of the file at from, replacing what is at
+	// to, so that it only takes up space once. Storage without links returns
+	// ErrLinkUnsupported and the file is copied instead.
+	Link(from, to string) error
+}
+
+// Disk is the local filesystem.
+var Disk Storage = diskStorage{}
+
+type diskStorage struct{}
+
+func (diskStorage) Create(name string) (io.WriteCloser, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	file, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return syncedFile{file}, nil
+}
+
+// syncedFile flushes a created file to disk before closing it.
+type syncedFile struct {
+	*os.File
+}
+
+func (f syncedFile) Close() error {
+	if err := f.Sync(); err != nil {
+		_ = f.File.Close()
+		return err
+	}
+	return f.File.Close()
+}
+
+func (diskStorage) Open(name string) (io.ReadCloser, error) {
+	return os.Open(name)
+}
+
+func (diskStorage) Exists(name string) (bool, error) {
+	_, err := os.Stat(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (diskStorage) Move(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+func (diskStorage) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(name)
+}
+
+func (diskStorage) Link(from, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0o755); err != nil {
+		return err
+	}
+	if err := os.Remove(to); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Link(from, to); err != nil {
+		return errors.Join(ErrLinkUnsupported, err)
+	}
+	return nil
+}
+
+// placeFile places source at destination in store, linking it when the
+// storage can and copying it otherwise.
+func placeFile(store Storage, source, destination string) error {
+	if err := store.Link(source, destination); err == nil || !errors.Is(err, ErrLinkUnsupported) {
+		return err
+	}
+	in, err := store.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := store.Create(destination)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Offload copies each of paths, which are under root, into store at the same
+// path relative to root. Paths that do not exist are left out.
+func Offload(store Storage, root string, paths []string) error {
+	for _, path := range uniqueNonEmptyPaths(paths) {
+		name, err := filepath.Rel(root, path)
+		if err != nil || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("%w: %q is outside %q", ErrInvalidOutput, path, root)
+		}
+		if err := offloadFile(store, path, name); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func offloadFile(store Storage, path, name string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := store.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// storedFileInfo describes a file of a Storage that is not on disk.
+type storedFileInfo struct {
+	name     string
+	size     int64
+	modified time.Time
+}
+
+func (i storedFileInfo) Name() string       { return i.name }
+func (i storedFileInfo) Size() int64        { return i.size }
+func (i storedFileInfo) Mode() fs.FileMode  { return 0o644 }
+func (i storedFileInfo) ModTime() time.Time { return i.modified }
+func (i storedFileInfo) IsDir() bool        { return false }
+func (i storedFileInfo) Sys() any           { return nil }
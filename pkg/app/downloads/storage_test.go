@@ -0,0 +1,145 @@
+package downloads
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+func storages(t *testing.T) map[string]struct {
+	store Storage
+	root  string
+} {
+	return map[string]struct {
+		store Storage
+		root  string
+	}{
+		"disk":   {Disk, t.TempDir()},
+		"memory": {NewMemoryStorage(), "/library"},
+	}
+}
+
+func writeString(t *testing.T, store Storage, name, data string) {
+	t.Helper()
+	out, err := store.Create(name)
+	if err != nil {
+		t.Fatalf("Create(%q): %v", name, err)
+	}
+	if _, err := io.WriteString(out, data); err != nil {
+		t.Fatalf("write %q: %v", name, err)
+	}
+	if err := out.Close(); err != nil {
+		t.Fatalf("close %q: %v", name, err)
+	}
+}
+
+func readString(t *testing.T, store Storage, name string) string {
+	t.Helper()
+	in, err := store.Open(name)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", name, err)
+	}
+	defer in.Close()
+	data, err := io.ReadAll(in)
+	if err != nil {
+		t.Fatalf("read %q: %v", name, err)
+	}
+	return string(data)
+}
+
+func TestStorage(t *testing.T) {
+	for name, tc := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			store := tc.store
+			file := filepath.Join(tc.root, "artist", "file.png")
+
+			if ok, err := store.Exists(file); err != nil || ok {
+				t.Fatalf("Exists before Create = %v, %v, want false", ok, err)
+			}
+			if _, err := store.Stat(file); !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("Stat before Create = %v, want fs.ErrNotExist", err)
+			}
+
+			writeString(t, store, file, "image")
+			if ok, err := store.Exists(file); err != nil || !ok {
+				t.Fatalf("Exists after Create = %v, %v, want true", ok, err)
+			}
+			info, err := store.Stat(file)
+			if err != nil || info.Size() != int64(len("image")) {
+				t.Fatalf("Stat = %v, %v, want size %d", info, err, len("image"))
+			}
+
+			moved := filepath.Join(tc.root, "moved", "file.png")
+			if err := store.Move(file, moved); err != nil {
+				t.Fatalf("Move: %v", err)
+			}
+			if ok, _ := store.Exists(file); ok {
+				t.Fatalf("%s still exists after Move", file)
+			}
+			if got := readString(t, store, moved); got != "image" {
+				t.Fatalf("moved file holds %q, want %q", got, "image")
+			}
+
+			linked := filepath.Join(tc.root, "characters", "file.png")
+			writeString(t, store, linked, "stale")
+			if err := store.Link(moved, linked); err != nil {
+				t.Fatalf("Link: %v", err)
+			}
+			if got := readString(t, store, linked); got != "image" {
+				t.Fatalf("link holds %q, want %q", got, "image")
+			}
+
+			// Creating a file over a link replaces it rather than writing
+			// through it to the file it links to.
+			writeString(t, store, linked, "replaced")
+			if got := readString(t, store, moved); got != "image" {
+				t.Fatalf("linked file holds %q after the link was replaced, want %q", got, "image")
+			}
+		})
+	}
+}
+
+func TestPlaceDownloadTargets(t *testing.T) {
+	store := NewMemoryStorage()
+	source := "/library/artist/file.png"
+	store.WriteFile(source, []byte("image"))
+	store.WriteFile("/library/mismatched/file.png", []byte("corrupt"))
+	store.WriteFile("/library/matching/file.png", []byte("image"))
+	sum := fmt.Sprintf("%x", md5.Sum([]byte("image")))
+
+	destinations := []string{
+		source,
+		"/library/missing/file.png",
+		"/library/mismatched/file.png",
+		"/library/matching/file.png",
+	}
+	if err := placeDownloadTargets(store, source, destinations, sum); err != nil {
+		t.Fatalf("placeDownloadTargets: %v", err)
+	}
+	for _, destination := range destinations {
+		data, err := store.ReadFile(destination)
+		if err != nil {
+			t.Fatalf("ReadFile(%q): %v", destination, err)
+		}
+		if string(data) != "image" {
+			t.Errorf("%s holds %q, want %q", destination, data, "image")
+		}
+	}
+	if got, want := len(store.Names()), len(destinations); got != want {
+		t.Errorf("storage holds %d files, want %d: %v", got, want, store.Names())
+	}
+}
+
+func TestPlaceDownloadTargetsWithoutSource(t *testing.T) {
+	store := NewMemoryStorage()
+	if err := placeDownloadTargets(store, "", []string{"/library/file.png"}, ""); err != nil {
+		t.Fatalf("placeDownloadTargets: %v", err)
+	}
+	if names := store.Names(); len(names) != 0 {
+		t.Fatalf("storage holds %v, want nothing", names)
+	}
+}
@@ -0,0 +1,173 @@
+package downloads
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var ErrInvalidPatternFunction = errors.New("invalid pattern function")
+
+// PatternToken documents a {token} of download patterns.
+type PatternToken struct {
+	Name        string
+	Description string
+}
+
+// PatternTokens are the tokens of download patterns, in the order they are
+// documented in.
+var PatternTokens = []PatternToken{
+	{"artist", "Submission artist username."},
+	{"artist_id", "Numeric artist account ID."},
+	{"title", "Submission title."},
+	{"rating", "Coarse rating bucket, prioritized as adult, mature, then general."},
+	{"public", "Submission visibility as public or private."},
+	{"submission_type", "Sanitized one-word submission type such as picture, series, video, or music."},
+	{"type", "Alias for the submission type."},
+	{"date", "Submission upload date as year-month-day."},
+	{"year", "Submission year from the upload timestamp."},
+	{"month", "Submission month using two digits."},
+	{"day", "Submission day using two digits."},
+	{"hour", "Submission hour using 24-hour time."},
+	{"minute", "Submission minute using two digits."},
+	{"file_name_full", "Original file name including its extension."},
+	{"file_name", "Original file name after the file ID and artist prefix, without the extension."},
+	{"file_name_ext", "Original file name after the file ID and artist prefix, including the extension."},
+	{"file_id", "Numeric file ID."},
+	{"submission_name", "Submission title."},
+	{"submission_name_auto_omit", "Submission title, but omitted when the submission only has one file."},
+	{"number", "1-based file order inside the submission."},
+	{"page", "Alias for the file number."},
+	{"ext", "File extension without the dot."},
+	{"extension", "Alias for the extension without the dot."},
+	{"submission_id", "Numeric submission ID."},
+	{"submission_id_auto_omit", "Submission ID, but omitted when the submission only has one file."},
+	{"pool_id", "Pool ID. Files are placed once per pool, and the segment is omitted without pools."},
+	{"pool_name", "Pool name. Files are placed once per pool, and the segment is omitted without pools."},
+}
+
+// PatternFunction documents a function that the value of a token can be piped
+// through, as in {title|slug|truncate:40}.
+type PatternFunction struct {
+	Usage       string
+	Description string
+	Example     string
+}
+
+// PatternFunctions are the functions of download patterns.
+var PatternFunctions = []PatternFunction{
+	{"lower", "Lowercase the value.", "{artist|lower}"},
+	{"upper", "Uppercase the value.", "{ext|upper}"},
+	{"slug", "Lowercase the value and join its words with dashes, dropping anything else.", "{title|slug}"},
+	{"truncate:<n>", "Keep the first n characters of the value.", "{title|truncate:4}"},
+	{"pad:<n>", "Pad the value with leading zeros to n characters.", "{page|pad:3}"},
+	{"replace:<old>=<new>", "Replace every old in the value with new, which may be empty.", "{title|replace: =_}"},
+	{"date:<layout>", "Reformat a date, written as Go's reference time Mon Jan 2 15:04:05 2006, without slashes.", "{date|date:2006-01}"},
+}
+
+type patternFunction func(value, arg string) (string, error)
+
+var patternFunctions = map[string]patternFunction{
+	"lower": func(value, _ string) (string, error) { return strings.ToLower(value), nil },
+	"upper": func(value, _ string) (string, error) { return strings.ToUpper(value), nil },
+	"slug":  func(value, _ string) (string, error) { return slugify(value), nil },
+	"truncate": func(value, arg string) (string, error) {
+		n, err := patternCount(arg)
+		if err != nil {
+			return "", err
+		}
+		if runes := []rune(value); len(runes) > n {
+			return strings.TrimSpace(string(runes[:n])), nil
+		}
+		return value, nil
+	},
+	"pad": func(value, arg string) (string, error) {
+		n, err := patternCount(arg)
+		if err != nil {
+			return "", err
+		}
+		if pad := n - len([]rune(value)); pad > 0 {
+			return strings.Repeat("0", pad) + value, nil
+		}
+		return value, nil
+	},
+	"replace": func(value, arg string) (string, error) {
+		old, replacement, ok := strings.Cut(arg, "=")
+		if !ok || old == "" {
+			return "", fmt.Errorf("replace needs old=new, got %q", arg)
+		}
+		return strings.ReplaceAll(value, old, replacement), nil
+	},
+	"date": func(value, arg string) (string, error) {
+		if arg == "" {
+			return "", errors.New("date needs a layout")
+		}
+		for _, layout := range []string{time.DateOnly, time.DateTime, time.RFC3339} {
+			if parsed, err := time.Parse(layout, value); err == nil {
+				return parsed.Format(arg), nil
+			}
+		}
+		return value, nil
+	},
+}
+
+// applyPatternFunctions pipes value through the functions of a token, written
+// as "|name:arg|name".
+func applyPatternFunctions(value, pipes string) (string, error) {
+	if pipes == "" {
+		return value, nil
+	}
+	for call := range strings.SplitSeq(strings.TrimPrefix(pipes, "|"), "|") {
+		name, arg, _ := strings.Cut(call, ":")
+		function, ok := patternFunctions[strings.TrimSpace(name)]
+		if !ok {
+			return "", fmt.Errorf("%w: %q", ErrInvalidPatternFunction, call)
+		}
+		var err error
+		if value, err = function(value, arg); err != nil {
+			return "", fmt.Errorf("%w: %w", ErrInvalidPatternFunction, err)
+		}
+	}
+	return value, nil
+}
+
+func patternCount(arg string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(arg))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%q is not a positive count", arg)
+	}
+	return n, nil
+}
+
+func slugify(value string) string {
+	var slug strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(value) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return slug.String()
+}
+
+// PatternTokenPreview renders a token or a whole pattern component for the
+// preview submission the desktop app shows its download pattern with.
+func PatternTokenPreview(component string) string {
+	ctx := downloadPathContext{
+		Submission: previewSubmission,
+		File:       previewFile,
+		Time:       UploadTime(previewSubmission, previewFile, TimeZoneUTC),
+		Number:     resolveFileNumber(previewFile),
+		Pool:       &previewSubmission.Pools[0],
+	}
+	return renderDownloadComponent(component, ctx)
+}
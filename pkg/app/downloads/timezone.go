@@ -0,0 +1,94 @@
+package downloads
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+// Time zones that upload times are rendered in for date tokens and metadata.
+// TimeZoneSite keeps the time as Inkbunny shows it to the account, which is in
+// the time zone set in the account's settings.
+const (
+	TimeZoneSite  = "site"
+	TimeZoneLocal = "local"
+	TimeZoneUTC   = "utc"
+)
+
+// siteTimeLayouts parse create_datetime_usertime, which is written for display,
+// along with the plain layouts used by older responses.
+var siteTimeLayouts = []string{
+	"2 Jan 2006 15:04 MST",
+	"2006-01-02 15:04:05-07",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+}
+
+// systemTimeLayouts parse create_datetime, which is in UTC with a "+00" offset.
+// Fractional seconds are accepted after the seconds by every layout.
+var systemTimeLayouts = []string{
+	"2006-01-02 15:04:05-07",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+}
+
+func NormalizeTimeZone(zone string) string {
+	switch strings.ToLower(strings.TrimSpace(zone)) {
+	case TimeZoneLocal:
+		return TimeZoneLocal
+	case TimeZoneUTC:
+		return TimeZoneUTC
+	default:
+		return TimeZoneSite
+	}
+}
+
+// UploadTime returns when file was uploaded, rendered in zone. The site zone
+// falls back to UTC when Inkbunny did not send the account's time.
+func UploadTime(submission inkbunny.SubmissionDetails, file inkbunny.File, zone string) time.Time {
+	zone = NormalizeTimeZone(zone)
+	site, hasSite := parseUploadTime(siteTimeLayouts, submission.CreateDateUser, file.CreateDateTimeUser)
+	parsed, ok := parseUploadTime(systemTimeLayouts, submission.CreateDateSystem, file.CreateDateTime)
+	switch {
+	case zone == TimeZoneSite && hasSite && ok:
+		return inSiteZone(site, parsed)
+	case zone == TimeZoneSite && hasSite:
+		return site
+	case !ok && hasSite:
+		parsed = site
+	case !ok:
+		return time.Time{}
+	}
+	if zone == TimeZoneLocal {
+		return parsed.In(time.Local)
+	}
+	return parsed.UTC()
+}
+
+// inSiteZone moves the exact system time into the account's zone. The account's
+// time only has a zone abbreviation, which Go cannot resolve to an offset, so the
+// offset is taken from how far it is from the system time.
+func inSiteZone(site time.Time, system time.Time) time.Time {
+	name, _ := site.Zone()
+	wall := time.Date(site.Year(), site.Month(), site.Day(), site.Hour(), site.Minute(), 0, 0, time.UTC)
+	offset := wall.Sub(system.UTC().Truncate(time.Minute)).Round(15 * time.Minute)
+	return system.In(time.FixedZone(name, int(offset.Seconds())))
+}
+
+func parseUploadTime(layouts []string, values ...string) (time.Time, bool) {
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		for _, layout := range layouts {
+			if parsed, err := time.Parse(layout, value); err == nil {
+				return parsed, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -2,10 +2,12 @@ package downloads
 
 import (
 	"crypto/md5"
+	"errors"
 	"fmt"
 	"io"
-	"os"
+	"io/fs"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 )
@@ -17,6 +19,7 @@ type fileVerificationResult struct {
 }
 
 type fileVerificationCacheKey struct {
+	Store        string
 	Path         string
 	ExpectedMD5  string
 	Size         int64
@@ -33,18 +36,18 @@ var sharedFileVerifier = &fileVerifier{
 }
 
 func verifyDownloadedFile(path string, expectedMD5 string) (fileVerificationResult, error) {
-	return sharedFileVerifier.Verify(path, expectedMD5)
+	return sharedFileVerifier.Verify(Disk, path, expectedMD5)
 }
 
-func (v *fileVerifier) Verify(path string, expectedMD5 string) (fileVerificationResult, error) {
+func (v *fileVerifier) Verify(store Storage, path string, expectedMD5 string) (fileVerificationResult, error) {
 	cleanPath := filepath.Clean(strings.TrimSpace(path))
 	if cleanPath == "" {
 		return fileVerificationResult{}, nil
 	}
 
-	info, err := os.Stat(cleanPath)
+	info, err := store.Stat(cleanPath)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return fileVerificationResult{}, nil
 		}
 		return fileVerificationResult{}, err
@@ -63,6 +66,7 @@ func (v *fileVerifier) Verify(path string, expectedMD5 string) (fileVerification
 	}
 
 	key := fileVerificationCacheKey{
+		Store:        storeID(store),
 		Path:         cleanPath,
 		ExpectedMD5:  trimmedMD5,
 		Size:         info.Size(),
@@ -76,7 +80,7 @@ func (v *fileVerifier) Verify(path string, expectedMD5 string) (fileVerification
 		return cached, nil
 	}
 
-	file, err := os.Open(cleanPath)
+	file, err := store.Open(cleanPath)
 	if err != nil {
 		return fileVerificationResult{}, err
 	}
@@ -100,6 +104,15 @@ func (v *fileVerifier) Verify(path string, expectedMD5 string) (fileVerification
 	return result, nil
 }
 
+// storeID tells stores apart in the verification cache, as a Storage need not
+// be comparable: by the address of a pointer, or by the type of a value.
+func storeID(store Storage) string {
+	if value := reflect.ValueOf(store); value.Kind() == reflect.Pointer {
+		return fmt.Sprintf("%T@%x", store, value.Pointer())
+	}
+	return fmt.Sprintf("%T", store)
+}
+
 func downloadFilePath(root, username, fileName string) string {
 	if strings.TrimSpace(root) == "" || strings.TrimSpace(username) == "" || strings.TrimSpace(fileName) == "" {
 		return ""
@@ -0,0 +1,194 @@
+package downloads
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strings"
+	"time"
+)
+
+var ErrXMPTooLarge = errors.New("metadata is too large to embed")
+
+const (
+	// xmpJPEGHeader starts the APP1 segment that holds XMP in a JPEG.
+	xmpJPEGHeader = "http://ns.adobe.com/xap/1.0/\x00"
+	// xmpPNGKeyword is the keyword of the iTXt chunk that holds XMP in a PNG.
+	xmpPNGKeyword = "XML:com.adobe.xmp"
+	// maxJPEGSegment is the most a JPEG segment can hold after its length.
+	maxJPEGSegment = 0xFFFF - 2
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// EmbedMetadata writes the title, artists, keywords, submission URL and upload
+// date of details into each of paths as XMP, which photo managers read, so
+// that they travel with the image. Files that are neither JPEG nor PNG are left
+// alone. Like converted files, they no longer match the MD5 Inkbunny lists.
+//
+// The paths are copies of the same file, such as the character and collab
+// links of a download, so it is embedded once and the others are linked to it
+// again.
+func EmbedMetadata(paths []string, details SubmissionFileMetadata) error {
+	paths = uniqueNonEmptyPaths(paths)
+	if len(paths) == 0 {
+		return nil
+	}
+	embedded, err := embedXMP(paths[0], xmpPacket(details))
+	if err != nil {
+		return fmt.Errorf("embed metadata in %s: %w", paths[0], err)
+	}
+	if !embedded {
+		return nil
+	}
+	for _, name := range paths[1:] {
+		if err := placeFile(Disk, paths[0], name); err != nil {
+			return fmt.Errorf("embed metadata in %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// embedXMP embeds packet in the file name, and reports whether it could.
+func embedXMP(name string, packet []byte) (bool, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return false, err
+	}
+	var embedded []byte
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		embedded, err = embedJPEG(data, packet)
+	case bytes.HasPrefix(data, pngSignature):
+		embedded, err = embedPNG(data, packet)
+	default:
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	temp := name + ".embedding"
+	if err := os.WriteFile(temp, embedded, 0o644); err != nil {
+		_ = os.Remove(temp)
+		return false, err
+	}
+	// As with conversions, the file replaces a link to it rather than
+	// writing through it.
+	return true, os.Rename(temp, name)
+}
+
+// embedJPEG places packet in an APP1 segment after the leading APP segments,
+// such as JFIF and EXIF, replacing the XMP already there.
+func embedJPEG(data, packet []byte) ([]byte, error) {
+	if len(xmpJPEGHeader)+len(packet) > maxJPEGSegment {
+		return nil, ErrXMPTooLarge
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)+len(packet)+64))
+	out.Write(data[:2])
+	rest := data[2:]
+	for len(rest) >= 4 && rest[0] == 0xFF && rest[1] >= 0xE0 && rest[1] <= 0xEF {
+		length := int(binary.BigEndian.Uint16(rest[2:4])) + 2
+		if length < 4 || length > len(rest) {
+			return nil, errors.New("malformed jpeg segment")
+		}
+		segment := rest[:length]
+		if !(rest[1] == 0xE1 && bytes.HasPrefix(segment[4:], []byte(xmpJPEGHeader))) {
+			out.Write(segment)
+		}
+		rest = rest[length:]
+	}
+	out.Write([]byte{0xFF, 0xE1})
+	_ = binary.Write(out, binary.BigEndian, uint16(2+len(xmpJPEGHeader)+len(packet)))
+	out.WriteString(xmpJPEGHeader)
+	out.Write(packet)
+	out.Write(rest)
+	return out.Bytes(), nil
+}
+
+// embedPNG places packet in an iTXt chunk after the IHDR chunk, replacing the
+// XMP already there.
+func embedPNG(data, packet []byte) ([]byte, error) {
+	var chunk bytes.Buffer
+	chunk.WriteString(xmpPNGKeyword)
+	// No compression, and an empty language tag and translated keyword.
+	chunk.Write([]byte{0, 0, 0, 0, 0})
+	chunk.Write(packet)
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)+chunk.Len()+12))
+	out.Write(pngSignature)
+	rest := data[len(pngSignature):]
+	for len(rest) >= 12 {
+		length := int(binary.BigEndian.Uint32(rest[:4]))
+		if length > len(rest)-12 {
+			return nil, errors.New("malformed png chunk")
+		}
+		kind, body := string(rest[4:8]), rest[8:8+length]
+		if !(kind == "iTXt" && bytes.HasPrefix(body, []byte(xmpPNGKeyword+"\x00"))) {
+			out.Write(rest[:12+length])
+		}
+		rest = rest[12+length:]
+		if kind == "IHDR" {
+			writePNGChunk(out, "iTXt", chunk.Bytes())
+		}
+	}
+	out.Write(rest)
+	return out.Bytes(), nil
+}
+
+func writePNGChunk(out *bytes.Buffer, kind string, body []byte) {
+	_ = binary.Write(out, binary.BigEndian, uint32(len(body)))
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(kind))
+	crc.Write(body)
+	out.WriteString(kind)
+	out.Write(body)
+	_ = binary.Write(out, binary.BigEndian, crc.Sum32())
+}
+
+// xmpPacket describes details with Dublin Core, which is what photo managers
+// show as the title, creator, tags and source of an image.
+func xmpPacket(details SubmissionFileMetadata) []byte {
+	var packet bytes.Buffer
+	text := func(value string) string {
+		var escaped strings.Builder
+		_ = xml.EscapeText(&escaped, []byte(strings.TrimSpace(value)))
+		return escaped.String()
+	}
+	packet.WriteString("<?xpacket begin=\"\uFEFF\" id=\"W5M0MpCehiHzreSzNTczkc9d\"?>\n")
+	packet.WriteString("<x:xmpmeta xmlns:x=\"adobe:ns:meta/\">\n")
+	packet.WriteString(" <rdf:RDF xmlns:rdf=\"http://www.w3.org/1999/02/22-rdf-syntax-ns#\">\n")
+	packet.WriteString("  <rdf:Description rdf:about=\"\" xmlns:dc=\"http://purl.org/dc/elements/1.1/\" xmlns:xmp=\"http://ns.adobe.com/xap/1.0/\">\n")
+	if title := strings.TrimSpace(details.Title); title != "" {
+		fmt.Fprintf(&packet, "   <dc:title><rdf:Alt><rdf:li xml:lang=\"x-default\">%s</rdf:li></rdf:Alt></dc:title>\n", text(title))
+	}
+	artists := details.Artists
+	if len(artists) == 0 && details.Username != "" {
+		artists = []string{details.Username}
+	}
+	if len(artists) > 0 {
+		packet.WriteString("   <dc:creator><rdf:Seq>")
+		for _, artist := range artists {
+			fmt.Fprintf(&packet, "<rdf:li>%s</rdf:li>", text(artist))
+		}
+		packet.WriteString("</rdf:Seq></dc:creator>\n")
+	}
+	if len(details.Keywords) > 0 {
+		packet.WriteString("   <dc:subject><rdf:Bag>")
+		for _, keyword := range details.Keywords {
+			fmt.Fprintf(&packet, "<rdf:li>%s</rdf:li>", text(keyword.KeywordName))
+		}
+		packet.WriteString("</rdf:Bag></dc:subject>\n")
+	}
+	if details.SubmissionID > 0 {
+		fmt.Fprintf(&packet, "   <dc:source>https://inkbunny.net/s/%s</dc:source>\n", details.SubmissionID)
+	}
+	if uploaded := UploadTime(details.SubmissionDetails, details.File, TimeZoneUTC); !uploaded.IsZero() {
+		fmt.Fprintf(&packet, "   <xmp:CreateDate>%s</xmp:CreateDate>\n", uploaded.Format(time.RFC3339))
+	}
+	packet.WriteString("  </rdf:Description>\n </rdf:RDF>\n</x:xmpmeta>\n<?xpacket end=\"w\"?>")
+	return packet.Bytes()
+}
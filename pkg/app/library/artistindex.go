@@ -0,0 +1,140 @@
+package library
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArtistIndexFileName is written in the folder of every artist with what the
+// library holds of them, so that the folder can be browsed without any tools.
+const ArtistIndexFileName = "INDEX.md"
+
+// markdownEscaper escapes what would otherwise be read as markdown in a title.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", "*", `\*`, "_", `\_`, "[", `\[`, "]", `\]`, "<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`,
+)
+
+type indexedSubmission struct {
+	id       string
+	title    string
+	uploaded time.Time
+	files    []string
+}
+
+// EntriesByArtist groups the recorded entries by their artist, ignoring case,
+// so that the index of many artists is written from a single pass.
+func (i *Index) EntriesByArtist() map[string][]Entry {
+	artists := make(map[string][]Entry)
+	for _, entry := range i.Recorded() {
+		key := strings.ToLower(entry.Artist)
+		artists[key] = append(artists[key], entry)
+	}
+	return artists
+}
+
+// WriteArtistIndex writes ArtistIndexFileName into dir, listing every
+// submission of artist in entries, as grouped by EntriesByArtist, newest first
+// with its title, upload date, the files kept of it and a link to it on
+// Inkbunny. It replaces the last one written.
+func (i *Index) WriteArtistIndex(dir, artist string, entries []Entry) error {
+	sorted := i.indexedSubmissions(dir, entries)
+	if len(sorted) == 0 {
+		return nil
+	}
+
+	var index strings.Builder
+	fmt.Fprintf(&index, "# %s\n\n", markdownEscaper.Replace(artist))
+	fmt.Fprintf(&index, "%d submissions archived from [their gallery](https://inkbunny.net/%s), as of %s.\n\n",
+		len(sorted), artist, time.Now().UTC().Format(time.DateOnly))
+	writeIndexTable(&index, sorted)
+	return writeIndexFile(dir, index.String())
+}
+
+// indexedSubmissions groups the files of entries by submission, newest first,
+// linking each relative to dir.
+func (i *Index) indexedSubmissions(dir string, entries []Entry) []*indexedSubmission {
+	submissions := make(map[string]*indexedSubmission)
+	for _, entry := range entries {
+		if entry.SubmissionID == "" {
+			continue
+		}
+		submission, ok := submissions[entry.SubmissionID]
+		if !ok {
+			submission = &indexedSubmission{id: entry.SubmissionID}
+			submissions[entry.SubmissionID] = submission
+		}
+		submission.title = cmp.Or(submission.title, entry.Title)
+		if submission.uploaded.IsZero() {
+			submission.uploaded = entry.Uploaded
+		}
+		submission.files = append(submission.files, indexLink(dir, i.Files(entry)))
+	}
+
+	sorted := make([]*indexedSubmission, 0, len(submissions))
+	for _, submission := range submissions {
+		slices.Sort(submission.files)
+		sorted = append(sorted, submission)
+	}
+	slices.SortFunc(sorted, func(a, b *indexedSubmission) int {
+		if c := b.uploaded.Compare(a.uploaded); c != 0 {
+			return c
+		}
+		return cmp.Compare(submissionNumber(b.id), submissionNumber(a.id))
+	})
+	return sorted
+}
+
+// writeIndexTable writes a table of submissions with their upload date, a
+// link to each on Inkbunny and the files kept of it.
+func writeIndexTable(index *strings.Builder, submissions []*indexedSubmission) {
+	index.WriteString("| Uploaded | Submission | Files |\n")
+	index.WriteString("| --- | --- | --- |\n")
+	for _, submission := range submissions {
+		uploaded := ""
+		if !submission.uploaded.IsZero() {
+			uploaded = submission.uploaded.Format(time.DateOnly)
+		}
+		title := cmp.Or(submission.title, "Submission "+submission.id)
+		fmt.Fprintf(index, "| %s | [%s](https://inkbunny.net/s/%s) | %s |\n",
+			uploaded, markdownEscaper.Replace(title), submission.id, strings.Join(submission.files, "<br>"))
+	}
+}
+
+// writeIndexFile writes index as the ArtistIndexFileName of dir.
+func writeIndexFile(dir string, index string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ArtistIndexFileName), []byte(index), 0o644)
+}
+
+// indexLink links the copy of a file that is under dir, or its first copy when
+// none is, relative to dir.
+func indexLink(dir string, files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	file := files[0]
+	for _, candidate := range files {
+		if relative, err := filepath.Rel(dir, candidate); err == nil && !strings.HasPrefix(relative, "..") {
+			file = candidate
+			break
+		}
+	}
+	target := file
+	if relative, err := filepath.Rel(dir, file); err == nil {
+		target = relative
+	}
+	return fmt.Sprintf("[%s](<%s>)", markdownEscaper.Replace(filepath.Base(file)), filepath.ToSlash(target))
+}
+
+func submissionNumber(id string) int {
+	n, _ := strconv.Atoi(id)
+	return n
+}
@@ -0,0 +1,23 @@
+package library
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WriteCollectionIndex writes ArtistIndexFileName into the folder dir of the
+// collection name, listing the submissions of entries like WriteArtistIndex
+// does. about says what the collection holds.
+func (i *Index) WriteCollectionIndex(dir, name, about string, entries []Entry) error {
+	sorted := i.indexedSubmissions(dir, entries)
+
+	var index strings.Builder
+	fmt.Fprintf(&index, "# %s\n\n", markdownEscaper.Replace(name))
+	fmt.Fprintf(&index, "%d submissions %s, as of %s.\n\n",
+		len(sorted), markdownEscaper.Replace(about), time.Now().UTC().Format(time.DateOnly))
+	if len(sorted) > 0 {
+		writeIndexTable(&index, sorted)
+	}
+	return writeIndexFile(dir, index.String())
+}
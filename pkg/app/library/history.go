@@ -0,0 +1,486 @@
+package library
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+// HistoryFileName is the append-only download history kept in the library root.
+const HistoryFileName = ".inkbunny-history.jsonl"
+
+type Entry struct {
+	SubmissionID string    `json:"submission_id"`
+	FileID       string    `json:"file_id"`
+	MD5          string    `json:"md5,omitempty"`
+	Variant      Variant   `json:"variant,omitempty"`
+	Artist       string    `json:"artist,omitempty"`
+	Title        string    `json:"title,omitempty"`
+	Uploaded     time.Time `json:"uploaded,omitzero"`
+	Size         int64     `json:"size,omitempty"`
+	Keywords     []string  `json:"keywords,omitempty"`
+	Paths        []string  `json:"paths"`
+	Time         time.Time `json:"time"`
+	Ignored      bool      `json:"ignored,omitempty"`
+	Removed      bool      `json:"removed,omitempty"`
+	// Offloaded is the --output the file was uploaded to before its copy in
+	// the library was removed. Paths are still where it was in the library,
+	// which is also where it is under the output.
+	Offloaded string `json:"offloaded,omitempty"`
+}
+
+// Index is the in-memory view of the history file. Lookups only touch memory,
+// so checking whether a file is already in the library does not stat the
+// filesystem and keeps working when the download pattern changes.
+// A nil *Index is valid and behaves like an empty library.
+//
+// Entries without a file ID apply to a whole submission and are only used to
+// ignore or forget it.
+type Index struct {
+	root    string
+	ignores *IgnoreList
+	// invalidIgnores are the lines of the IgnoreFileName that were skipped.
+	invalidIgnores error
+
+	mu      sync.RWMutex
+	files   map[string]Entry
+	md5s    map[string]string
+	ignored map[string]struct{}
+	// keywords is built by KeywordCounts and dropped whenever the index changes.
+	keywords map[string]int
+
+	// skips holds the last skip of every file, loaded by RecordSkip.
+	skipsMu sync.Mutex
+	skips   map[string]Skip
+}
+
+// Open loads the history file and the IgnoreFileName under root into memory.
+// A missing file yields an empty index.
+func Open(root string) (*Index, error) {
+	index := &Index{
+		root:    filepath.Clean(strings.TrimSpace(root)),
+		files:   make(map[string]Entry),
+		md5s:    make(map[string]string),
+		ignored: make(map[string]struct{}),
+	}
+
+	ignores, ignoreErr := LoadIgnoreList(index.root)
+	index.ignores = ignores
+	if errors.Is(ignoreErr, ErrInvalidIgnorePattern) {
+		// The valid rules still apply, see InvalidIgnores.
+		index.invalidIgnores, ignoreErr = ignoreErr, nil
+	}
+
+	file, err := os.Open(index.historyPath())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return index, ignoreErr
+		}
+		return index, errors.Join(ignoreErr, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		index.storeLocked(entry)
+	}
+	return index, errors.Join(ignoreErr, scanner.Err())
+}
+
+// InvalidIgnores returns the lines of the IgnoreFileName that are not valid
+// patterns and were skipped by Open, or nil when there are none.
+func (i *Index) InvalidIgnores() error {
+	if i == nil {
+		return nil
+	}
+	return i.invalidIgnores
+}
+
+func (i *Index) Root() string {
+	if i == nil {
+		return ""
+	}
+	return i.root
+}
+
+// Lookup finds a previously downloaded file by its file ID, falling back to its MD5.
+func (i *Index) Lookup(fileID string, md5 string) (Entry, bool) {
+	if i == nil {
+		return Entry{}, false
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if entry, ok := i.files[strings.TrimSpace(fileID)]; ok {
+		return entry, true
+	}
+	if id, ok := i.md5s[strings.ToLower(strings.TrimSpace(md5))]; ok {
+		entry, ok := i.files[id]
+		return entry, ok
+	}
+	return Entry{}, false
+}
+
+// Covers reports whether every one of paths is recorded for the file.
+func (i *Index) Covers(fileID string, md5 string, paths []string) (Entry, bool) {
+	entry, ok := i.Lookup(fileID, md5)
+	if !ok || len(paths) == 0 {
+		return entry, false
+	}
+	for _, path := range paths {
+		if !slices.Contains(entry.Paths, i.relative(path)) {
+			return entry, false
+		}
+	}
+	return entry, true
+}
+
+// Source returns a path holding an already downloaded copy of the file, preferring want.
+// When want is recorded it is returned without touching the filesystem; otherwise the
+// recorded paths are checked so a file saved under an older layout can be reused.
+func (i *Index) Source(fileID string, md5 string, want string) (string, bool) {
+	entry, ok := i.Lookup(fileID, md5)
+	if !ok {
+		return "", false
+	}
+
+	want = i.relative(want)
+	for _, path := range entry.Paths {
+		if path == want {
+			return i.absolute(path), true
+		}
+	}
+	for _, path := range entry.Paths {
+		if _, err := os.Stat(i.absolute(path)); err == nil {
+			return i.absolute(path), true
+		}
+	}
+	return "", false
+}
+
+// Record adds entry to the index and appends it to the history file.
+// Entries whose paths, MD5 and keywords are all already known are not written again.
+func (i *Index) Record(entry Entry) error {
+	if i == nil {
+		return nil
+	}
+
+	paths := make([]string, 0, len(entry.Paths))
+	for _, path := range entry.Paths {
+		if relative := i.relative(path); relative != "" {
+			paths = append(paths, relative)
+		}
+	}
+	entry.Paths = paths
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if previous, ok := i.files[strings.TrimSpace(entry.FileID)]; ok {
+		knownPaths := len(mergePaths(previous.Paths, entry.Paths)) == len(previous.Paths)
+		knownKeywords := len(entry.Keywords) == 0 || len(previous.Keywords) > 0
+		knownTitle := entry.Title == "" || previous.Title != ""
+		knownOffload := entry.Offloaded == "" || entry.Offloaded == previous.Offloaded
+		if knownPaths && knownKeywords && knownTitle && knownOffload && (entry.MD5 == "" || strings.EqualFold(entry.MD5, previous.MD5)) {
+			return nil
+		}
+	}
+	i.storeLocked(entry)
+	return i.appendLocked(entry)
+}
+
+// Forget drops a file from the index so the next sync downloads it again.
+func (i *Index) Forget(fileID string) error {
+	if i == nil {
+		return nil
+	}
+	fileID = strings.TrimSpace(fileID)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.files[fileID]; !ok {
+		return nil
+	}
+	entry := Entry{FileID: fileID, Time: time.Now(), Removed: true}
+	i.storeLocked(entry)
+	return i.appendLocked(entry)
+}
+
+// ForgetSubmission drops every file of a submission, along with any ignore, so it downloads again.
+func (i *Index) ForgetSubmission(submissionID string) error {
+	if i == nil {
+		return nil
+	}
+	entry := Entry{SubmissionID: strings.TrimSpace(submissionID), Time: time.Now(), Removed: true}
+	if entry.SubmissionID == "" {
+		return nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.storeLocked(entry)
+	return i.appendLocked(entry)
+}
+
+// Ignore marks a file, or a whole submission when fileID is empty, as never to be downloaded again.
+func (i *Index) Ignore(submissionID string, fileID string) error {
+	if i == nil {
+		return nil
+	}
+	entry := Entry{
+		SubmissionID: strings.TrimSpace(submissionID),
+		FileID:       strings.TrimSpace(fileID),
+		Time:         time.Now(),
+		Ignored:      true,
+	}
+	if entry.SubmissionID == "" && entry.FileID == "" {
+		return nil
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.storeLocked(entry)
+	return i.appendLocked(entry)
+}
+
+// Ignored reports whether the file or its submission was ignored.
+func (i *Index) Ignored(submissionID string, fileID string) bool {
+	if i == nil {
+		return false
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if _, ok := i.ignored[strings.TrimSpace(submissionID)]; ok {
+		return true
+	}
+	entry, ok := i.files[strings.TrimSpace(fileID)]
+	return ok && entry.Ignored
+}
+
+// Excluded reports whether the file should be left out of every download,
+// either because it was ignored or because it matches the IgnoreFileName.
+func (i *Index) Excluded(submission inkbunny.SubmissionDetails, file inkbunny.File) bool {
+	if i == nil {
+		return false
+	}
+	return i.Ignored(submission.SubmissionID.String(), file.FileID.String()) || i.ignores.Excludes(submission, file)
+}
+
+// Missing returns the recorded files that no longer exist at any of their paths,
+// which is what is left behind when files are deleted from the library by hand.
+// Files offloaded to --output are not missing.
+func (i *Index) Missing() []Entry {
+	if i == nil {
+		return nil
+	}
+	i.mu.RLock()
+	entries := make([]Entry, 0, len(i.files))
+	for _, entry := range i.files {
+		if !entry.Ignored && len(entry.Paths) > 0 && entry.Offloaded == "" {
+			entries = append(entries, entry)
+		}
+	}
+	i.mu.RUnlock()
+
+	var missing []Entry
+	for _, entry := range entries {
+		found := false
+		for _, path := range entry.Paths {
+			if _, err := os.Stat(i.absolute(path)); err == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, entry)
+		}
+	}
+	return missing
+}
+
+// Recorded returns every file in the library that was not ignored, ordered
+// by file ID.
+func (i *Index) Recorded() []Entry {
+	if i == nil {
+		return nil
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	entries := make([]Entry, 0, len(i.files))
+	for _, entry := range i.files {
+		if !entry.Ignored && len(entry.Paths) > 0 {
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return cmp.Compare(a.FileID, b.FileID)
+	})
+	return entries
+}
+
+// Upgradable returns the recorded files that are only a screen, preview or
+// thumbnail sized copy of their submission file, such as ones adopted from an
+// old folder, and can be replaced by the full size file.
+func (i *Index) Upgradable() []Entry {
+	if i == nil {
+		return nil
+	}
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	var entries []Entry
+	for _, entry := range i.files {
+		if entry.Ignored || len(entry.Paths) == 0 {
+			continue
+		}
+		switch entry.Variant {
+		case VariantScreen, VariantPreview, VariantThumbnail:
+			entries = append(entries, entry)
+		}
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return cmp.Compare(a.FileID, b.FileID)
+	})
+	return entries
+}
+
+// Files returns the paths recorded for entry, resolved against the library root.
+func (i *Index) Files(entry Entry) []string {
+	if i == nil {
+		return nil
+	}
+	files := make([]string, len(entry.Paths))
+	for n, path := range entry.Paths {
+		files[n] = i.absolute(path)
+	}
+	return files
+}
+
+func (i *Index) appendLocked(entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(i.root, 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(i.historyPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (i *Index) storeLocked(entry Entry) {
+	i.keywords = nil
+	id := strings.TrimSpace(entry.FileID)
+	if id == "" {
+		i.storeSubmissionLocked(entry)
+		return
+	}
+	if entry.Removed {
+		if previous, ok := i.files[id]; ok {
+			delete(i.md5s, strings.ToLower(strings.TrimSpace(previous.MD5)))
+		}
+		delete(i.files, id)
+		return
+	}
+	if previous, ok := i.files[id]; ok {
+		entry.Paths = mergePaths(previous.Paths, entry.Paths)
+		entry.Ignored = entry.Ignored || previous.Ignored
+		entry.SubmissionID = cmp.Or(entry.SubmissionID, previous.SubmissionID)
+		// The variant describes the copy with this MD5, so it only carries over with it.
+		if entry.MD5 == "" {
+			entry.MD5, entry.Variant = previous.MD5, previous.Variant
+		}
+		entry.Artist = cmp.Or(entry.Artist, previous.Artist)
+		entry.Title = cmp.Or(entry.Title, previous.Title)
+		if entry.Uploaded.IsZero() {
+			entry.Uploaded = previous.Uploaded
+		}
+		entry.Size = cmp.Or(entry.Size, previous.Size)
+		if len(entry.Keywords) == 0 {
+			entry.Keywords = previous.Keywords
+		}
+		entry.Offloaded = cmp.Or(entry.Offloaded, previous.Offloaded)
+	}
+	i.files[id] = entry
+	if md5 := strings.ToLower(strings.TrimSpace(entry.MD5)); md5 != "" {
+		i.md5s[md5] = id
+	}
+}
+
+func (i *Index) storeSubmissionLocked(entry Entry) {
+	submissionID := strings.TrimSpace(entry.SubmissionID)
+	if submissionID == "" {
+		return
+	}
+	switch {
+	case entry.Ignored:
+		i.ignored[submissionID] = struct{}{}
+	case entry.Removed:
+		delete(i.ignored, submissionID)
+		for id, file := range i.files {
+			if file.SubmissionID != submissionID {
+				continue
+			}
+			delete(i.md5s, strings.ToLower(strings.TrimSpace(file.MD5)))
+			delete(i.files, id)
+		}
+	}
+}
+
+func (i *Index) historyPath() string {
+	return filepath.Join(i.root, HistoryFileName)
+}
+
+func (i *Index) relative(path string) string {
+	path = filepath.Clean(strings.TrimSpace(path))
+	if path == "." || path == "" {
+		return ""
+	}
+	if relative, err := filepath.Rel(i.root, path); err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(relative)
+	}
+	return filepath.ToSlash(path)
+}
+
+func (i *Index) absolute(path string) string {
+	native := filepath.FromSlash(path)
+	if filepath.IsAbs(native) {
+		return native
+	}
+	return filepath.Join(i.root, native)
+}
+
+func mergePaths(existing []string, added []string) []string {
+	merged := append([]string(nil), existing...)
+	for _, path := range added {
+		if !slices.Contains(merged, path) {
+			merged = append(merged, path)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,163 @@
+package library
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+// IgnoreFileName is the gitignore-style exclusion list read from the library root.
+//
+// Each line is a pattern, blank lines and lines starting with # are skipped and
+// a leading ! re-includes what an earlier line excluded, the last match winning.
+// Patterns are matched case-insensitively with * and ? wildcards:
+//
+//	artist:<name>   submissions by the artist
+//	tag:<keyword>   submissions with the keyword, spaces and underscores alike
+//	id:<number>     the submission with this ID
+//	<filename>      files whose name matches
+const IgnoreFileName = ".ibignore"
+
+var ErrInvalidIgnorePattern = errors.New("invalid ignore pattern")
+
+type ignoreField int
+
+const (
+	ignoreFileName ignoreField = iota
+	ignoreArtist
+	ignoreTag
+	ignoreSubmissionID
+)
+
+type ignoreRule struct {
+	field   ignoreField
+	pattern string
+	negate  bool
+}
+
+// IgnoreList holds the rules from an IgnoreFileName. A nil *IgnoreList excludes nothing.
+type IgnoreList struct {
+	rules []ignoreRule
+}
+
+// LoadIgnoreList reads the ignore file under root. A missing file yields a nil list.
+// Lines that are not valid patterns are skipped, so that the other rules still
+// apply, and returned as errors naming their line.
+func LoadIgnoreList(root string) (*IgnoreList, error) {
+	name := filepath.Join(root, IgnoreFileName)
+	file, err := os.Open(name)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		list    IgnoreList
+		invalid []error
+	)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		rule, ok, err := parseIgnoreRule(scanner.Text())
+		if err != nil {
+			invalid = append(invalid, fmt.Errorf("%s:%d: %w", name, line, err))
+			continue
+		}
+		if ok {
+			list.rules = append(list.rules, rule)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return &list, errors.Join(invalid...)
+}
+
+func parseIgnoreRule(line string) (ignoreRule, bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false, nil
+	}
+
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = strings.TrimSpace(line[1:])
+	} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+		line = line[1:]
+	}
+
+	rule.field = ignoreFileName
+	if prefix, value, ok := strings.Cut(line, ":"); ok {
+		switch strings.ToLower(strings.TrimSpace(prefix)) {
+		case "artist":
+			rule.field, line = ignoreArtist, value
+		case "tag":
+			rule.field, line = ignoreTag, normalizeKeyword(value)
+		case "id":
+			rule.field, line = ignoreSubmissionID, value
+		}
+	}
+
+	rule.pattern = strings.ToLower(strings.TrimSpace(line))
+	if rule.pattern == "" {
+		return ignoreRule{}, false, nil
+	}
+	if _, err := path.Match(rule.pattern, ""); err != nil {
+		return ignoreRule{}, false, fmt.Errorf("%w: %q", ErrInvalidIgnorePattern, line)
+	}
+	return rule, true, nil
+}
+
+// Excludes reports whether the file of the submission is excluded by the list.
+func (l *IgnoreList) Excludes(submission inkbunny.SubmissionDetails, file inkbunny.File) bool {
+	if l == nil {
+		return false
+	}
+
+	excluded := false
+	for _, rule := range l.rules {
+		if rule.negate == !excluded {
+			continue
+		}
+		if rule.matches(submission, file) {
+			excluded = !rule.negate
+		}
+	}
+	return excluded
+}
+
+func (r ignoreRule) matches(submission inkbunny.SubmissionDetails, file inkbunny.File) bool {
+	switch r.field {
+	case ignoreArtist:
+		return r.match(submission.Username)
+	case ignoreTag:
+		for _, keyword := range submission.Keywords {
+			if r.match(normalizeKeyword(keyword.KeywordName)) {
+				return true
+			}
+		}
+		return false
+	case ignoreSubmissionID:
+		return r.match(submission.SubmissionID.String())
+	default:
+		return r.match(filepath.Base(file.FileName))
+	}
+}
+
+func (r ignoreRule) match(value string) bool {
+	matched, _ := path.Match(r.pattern, strings.ToLower(strings.TrimSpace(value)))
+	return matched
+}
+
+func normalizeKeyword(keyword string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(keyword, "_", " ")), " ")
+}
@@ -0,0 +1,67 @@
+package library
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+// SubmissionKeywords returns the keyword names of submission for recording in
+// an Entry.
+func SubmissionKeywords(submission inkbunny.SubmissionDetails) []string {
+	if len(submission.Keywords) == 0 {
+		return nil
+	}
+	keywords := make([]string, 0, len(submission.Keywords))
+	for _, keyword := range submission.Keywords {
+		if name := strings.TrimSpace(keyword.KeywordName); name != "" {
+			keywords = append(keywords, name)
+		}
+	}
+	return keywords
+}
+
+// KeywordCounts returns how many downloaded submissions carry each keyword,
+// keyed by its lowercase name. The map is shared and must not be modified.
+func (i *Index) KeywordCounts() map[string]int {
+	if i == nil {
+		return nil
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.keywords != nil {
+		return i.keywords
+	}
+
+	seen := make(map[string]struct{})
+	counts := make(map[string]int)
+	for _, entry := range i.files {
+		if entry.Ignored || len(entry.Keywords) == 0 {
+			continue
+		}
+		// Every file of a submission carries its keywords, so each is counted once.
+		if _, ok := seen[entry.SubmissionID]; ok {
+			continue
+		}
+		seen[entry.SubmissionID] = struct{}{}
+		for _, keyword := range entry.Keywords {
+			counts[strings.ToLower(keyword)]++
+		}
+	}
+	i.keywords = counts
+	return counts
+}
+
+// RankKeywords stably sorts items so that the keywords found most often in
+// the library come first, keeping the site's order otherwise.
+func RankKeywords[T any](index *Index, items []T, keyword func(T) string) {
+	counts := index.KeywordCounts()
+	if len(counts) == 0 {
+		return
+	}
+	slices.SortStableFunc(items, func(a, b T) int {
+		return cmp.Compare(counts[strings.ToLower(strings.TrimSpace(keyword(b)))], counts[strings.ToLower(strings.TrimSpace(keyword(a)))])
+	})
+}
@@ -0,0 +1,109 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockFileName is held by a run for as long as it works on the library, so that
+// overlapping runs do not download the same files or interleave the history.
+const LockFileName = ".inkbunny.lock"
+
+var ErrLocked = errors.New("library is in use by another run")
+
+// Lock is an exclusive hold on a library. The operating system releases it when
+// the process exits, so a crashed run never leaves a library locked.
+type Lock struct {
+	file *os.File
+}
+
+// TryLock takes the lock of the library at root, failing with ErrLocked when
+// another run holds it.
+func TryLock(root string) (*Lock, error) {
+	root = filepath.Clean(strings.TrimSpace(root))
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	name := filepath.Join(root, LockFileName)
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := lockFile(file); err != nil {
+		_ = file.Close()
+		if !errors.Is(err, errLockHeld) {
+			return nil, err
+		}
+		if pid := lockHolder(name); pid != "" {
+			return nil, fmt.Errorf("%w: %q is locked by process %s", ErrLocked, root, pid)
+		}
+		return nil, fmt.Errorf("%w: %q", ErrLocked, root)
+	}
+
+	// The holder is only written for the error above and Holder, so failing to
+	// is not fatal. It starts after the first byte, which Windows locks.
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(" "+strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{file: file}, nil
+}
+
+// WaitLock waits for the lock of the library at root to be released and takes it.
+func WaitLock(ctx context.Context, root string) (*Lock, error) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		lock, err := TryLock(root)
+		if !errors.Is(err, ErrLocked) {
+			return lock, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Holder returns the process ID of the run holding the lock of the library at
+// root, and whether any run holds it at all. It only reads who wrote the lock
+// last, without taking it, so that it never gets in the way of that run.
+func Holder(root string) (string, bool, error) {
+	pid := lockHolder(filepath.Join(filepath.Clean(strings.TrimSpace(root)), LockFileName))
+	n, err := strconv.Atoi(pid)
+	if err != nil {
+		return "", false, nil
+	}
+	// A run that crashed leaves its process ID behind.
+	if !processAlive(n) {
+		return "", false, nil
+	}
+	return pid, true, nil
+}
+
+func (l *Lock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	_ = l.file.Truncate(0)
+	err := unlockFile(l.file)
+	return errors.Join(err, l.file.Close())
+}
+
+func lockHolder(name string) string {
+	file, err := os.Open(name)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	data := make([]byte, 32)
+	n, _ := file.ReadAt(data, 1)
+	return strings.TrimSpace(string(data[:n]))
+}
@@ -0,0 +1,304 @@
+package library
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+// md5SearchBatch is how many hashes are sent in a single MD5 search.
+const md5SearchBatch = 25
+
+var ErrInvalidMD5 = errors.New("invalid md5")
+
+// HashedFile is a local file and the MD5 of its contents.
+// Path is empty when the hash came from a bare list.
+type HashedFile struct {
+	Path string
+	MD5  string
+	// Size and Modified are only set by HashFolder, for RehashFolder to tell
+	// whether the file changed since.
+	Size     int64
+	Modified time.Time
+}
+
+// Variant names which copy of an Inkbunny file an MD5 matched.
+type Variant string
+
+const (
+	VariantInitial   Variant = "initial"
+	VariantFull      Variant = "full"
+	VariantScreen    Variant = "screen"
+	VariantPreview   Variant = "preview"
+	VariantThumbnail Variant = "thumbnail"
+	// VariantMetadata is a submission without files, recorded by the metadata
+	// written for it, see MetadataFileID.
+	VariantMetadata Variant = "metadata"
+)
+
+// MetadataFileID is what the entry of a submission without files is recorded
+// under, as it has no file ID of its own.
+func MetadataFileID(submissionID string) string {
+	return "submission-" + strings.TrimSpace(submissionID)
+}
+
+// MD5Source is the submission file an MD5 was found to belong to.
+type MD5Source struct {
+	Submission inkbunny.SubmissionDetails
+	File       inkbunny.File
+	Variant    Variant
+}
+
+// MD5Match is one line of a reverse lookup report. Unmatched files only carry MD5 and Path.
+type MD5Match struct {
+	MD5          string  `json:"md5"`
+	Path         string  `json:"path,omitempty"`
+	SubmissionID string  `json:"submission_id,omitempty"`
+	FileID       string  `json:"file_id,omitempty"`
+	FileName     string  `json:"file_name,omitempty"`
+	Variant      Variant `json:"variant,omitempty"`
+	Title        string  `json:"title,omitempty"`
+	Artist       string  `json:"artist,omitempty"`
+	URL          string  `json:"url,omitempty"`
+}
+
+type MD5Report struct {
+	Matched   []MD5Match `json:"matched"`
+	Unmatched []MD5Match `json:"unmatched"`
+}
+
+func HashFile(name string) (string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// HashFolder hashes every regular file under root, skipping hidden files and
+// folders such as the history and ignore files, and unfinished .part downloads.
+func HashFolder(ctx context.Context, root string) ([]HashedFile, error) {
+	return RehashFolder(ctx, root, nil)
+}
+
+// RehashFolder is HashFolder reusing the hashes of previous, the result of an
+// earlier call, for the files whose size and modification time are unchanged.
+func RehashFolder(ctx context.Context, root string, previous []HashedFile) ([]HashedFile, error) {
+	known := make(map[string]HashedFile, len(previous))
+	for _, file := range previous {
+		known[file.Path] = file
+	}
+	var files []HashedFile
+	err := filepath.WalkDir(root, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if name != root && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), ".part") {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if file, ok := known[name]; ok && file.Size == info.Size() && file.Modified.Equal(info.ModTime()) {
+			files = append(files, file)
+			return nil
+		}
+		sum, err := HashFile(name)
+		if err != nil {
+			return err
+		}
+		files = append(files, HashedFile{Path: name, MD5: sum, Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	return files, err
+}
+
+// ReadMD5List reads hashes one per line, either bare or in the "<md5>  <path>"
+// form written by md5sum. Blank lines and lines starting with # are skipped.
+func ReadMD5List(name string) ([]HashedFile, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var files []HashedFile
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		sum, path, _ := strings.Cut(text, " ")
+		sum = strings.ToLower(sum)
+		if !validMD5(sum) {
+			return nil, fmt.Errorf("%s:%d: %w: %q", name, line, ErrInvalidMD5, sum)
+		}
+		// md5sum marks files hashed in binary mode with a leading *.
+		path = strings.TrimPrefix(strings.TrimSpace(path), "*")
+		files = append(files, HashedFile{Path: path, MD5: sum})
+	}
+	return files, scanner.Err()
+}
+
+// LookupMD5s uses Inkbunny's MD5 search to find the submission files the hashes
+// came from. Hashes that match nothing are left out of the result.
+func LookupMD5s(ctx context.Context, user *inkbunny.User, hashes []string, details inkbunny.SubmissionDetailsRequest) (map[string]MD5Source, error) {
+	wanted := make(map[string]struct{}, len(hashes))
+	var unique []string
+	for _, sum := range hashes {
+		sum = strings.ToLower(strings.TrimSpace(sum))
+		if _, ok := wanted[sum]; ok || !validMD5(sum) {
+			continue
+		}
+		wanted[sum] = struct{}{}
+		unique = append(unique, sum)
+	}
+
+	found := make(map[string]MD5Source, len(unique))
+	for batch := range slices.Chunk(unique, md5SearchBatch) {
+		ids, err := searchMD5s(ctx, user, batch)
+		if err != nil {
+			return found, err
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		request := details
+		request.SID = user.SID
+		request.SubmissionIDs = ""
+		request.SubmissionIDSlice = ids
+		response, err := user.SubmissionDetailsContext(ctx, request)
+		if err != nil {
+			return found, err
+		}
+		for _, submission := range response.Submissions {
+			for _, file := range submission.Files {
+				for sum, variant := range fileVariants(file) {
+					if _, ok := wanted[sum]; !ok {
+						continue
+					}
+					if _, ok := found[sum]; !ok {
+						found[sum] = MD5Source{Submission: submission, File: file, Variant: variant}
+					}
+				}
+			}
+		}
+	}
+	return found, nil
+}
+
+// NewMD5Report pairs every file with what LookupMD5s found for it.
+func NewMD5Report(files []HashedFile, found map[string]MD5Source) MD5Report {
+	report := MD5Report{Matched: []MD5Match{}, Unmatched: []MD5Match{}}
+	for _, file := range files {
+		match := MD5Match{MD5: file.MD5, Path: file.Path}
+		source, ok := found[file.MD5]
+		if !ok {
+			report.Unmatched = append(report.Unmatched, match)
+			continue
+		}
+		match.SubmissionID = source.Submission.SubmissionID.String()
+		match.FileID = source.File.FileID.String()
+		match.FileName = source.File.FileName
+		match.Variant = source.Variant
+		match.Title = source.Submission.Title
+		match.Artist = source.Submission.Username
+		match.URL = "https://inkbunny.net/s/" + match.SubmissionID
+		report.Matched = append(report.Matched, match)
+	}
+	return report
+}
+
+func searchMD5s(ctx context.Context, user *inkbunny.User, hashes []string) ([]string, error) {
+	request := inkbunny.SubmissionSearchRequest{
+		SID:                user.SID,
+		SubmissionIDsOnly:  inkbunny.Yes,
+		SubmissionsPerPage: 100,
+		GetRID:             inkbunny.Yes,
+		Text:               strings.Join(hashes, " "),
+		StringJoinType:     inkbunny.JoinTypeOr,
+		SearchInKeywords:   &inkbunny.No,
+		MD5:                &inkbunny.Yes,
+	}
+
+	var ids []string
+	for {
+		response, err := user.SearchSubmissionsContext(ctx, request)
+		if err != nil {
+			return ids, err
+		}
+		for _, submission := range response.Submissions {
+			ids = append(ids, submission.SubmissionID.String())
+		}
+		if response.Page >= response.PagesCount || response.RID == "" {
+			return ids, nil
+		}
+		request = inkbunny.SubmissionSearchRequest{
+			SID:                user.SID,
+			RID:                response.RID,
+			SubmissionIDsOnly:  inkbunny.Yes,
+			SubmissionsPerPage: 100,
+			Page:               response.Page + 1,
+		}
+	}
+}
+
+func fileVariants(file inkbunny.File) map[string]Variant {
+	variants := make(map[string]Variant, 5)
+	// Later variants do not override earlier ones, so an unchanged upload
+	// reports as the full file rather than the initial one.
+	for _, candidate := range []struct {
+		md5     string
+		variant Variant
+	}{
+		{file.FullFileMD5, VariantFull},
+		{file.InitialFileMD5, VariantInitial},
+		{file.LargeFileMD5, VariantScreen},
+		{file.SmallFileMD5, VariantPreview},
+		{file.ThumbnailMD5, VariantThumbnail},
+	} {
+		sum := strings.ToLower(strings.TrimSpace(candidate.md5))
+		if _, ok := variants[sum]; sum != "" && !ok {
+			variants[sum] = candidate.variant
+		}
+	}
+	return variants
+}
+
+func validMD5(sum string) bool {
+	if len(sum) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(sum)
+	return err == nil
+}
@@ -0,0 +1,123 @@
+package library
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// MirrorFileName records the newest submission of every mirrored artist, so
+// that mirroring them again only fetches what they uploaded since.
+const MirrorFileName = ".inkbunny-mirror.json"
+
+type mirrorState struct {
+	// Artists maps the lowercased username of an artist to the ID of their
+	// newest mirrored submission.
+	Artists map[string]int `json:"artists"`
+}
+
+// Mirror follows the gallery of one artist across runs. A nil *Mirror keeps
+// every submission.
+type Mirror struct {
+	root   string
+	artist string
+	since  int
+
+	mu     sync.Mutex
+	newest int
+}
+
+// OpenMirror loads where the last mirror of artist into the library at root
+// stopped.
+func OpenMirror(root, artist string) (*Mirror, error) {
+	root = filepath.Clean(strings.TrimSpace(root))
+	artist = strings.ToLower(strings.TrimSpace(artist))
+	state, err := readMirrorState(root)
+	if err != nil {
+		return nil, err
+	}
+	since := state.Artists[artist]
+	return &Mirror{root: root, artist: artist, since: since, newest: since}, nil
+}
+
+// NewMirror follows submissions newer than since in memory only, for runs that
+// keep their own record of where they stopped. Saving it does nothing.
+func NewMirror(since int) *Mirror {
+	return &Mirror{since: since, newest: since}
+}
+
+// Since is the newest submission of the last mirror, or 0 on the first one.
+func (m *Mirror) Since() int {
+	if m == nil {
+		return 0
+	}
+	return m.since
+}
+
+// Keep reports whether the submission is newer than the last mirror.
+func (m *Mirror) Keep(submissionID int) bool {
+	if m == nil {
+		return true
+	}
+	if submissionID <= m.since {
+		return false
+	}
+	m.mu.Lock()
+	m.newest = max(m.newest, submissionID)
+	m.mu.Unlock()
+	return true
+}
+
+// Newest is the newest submission kept so far, or Since when none was.
+func (m *Mirror) Newest() int {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.newest
+}
+
+// Save records the newest submission kept, for the next mirror to start from.
+// It is only to be called once everything kept was downloaded.
+func (m *Mirror) Save() error {
+	if m == nil || m.root == "" {
+		return nil
+	}
+	m.mu.Lock()
+	newest := m.newest
+	m.mu.Unlock()
+	if newest == m.since {
+		return nil
+	}
+
+	state, err := readMirrorState(m.root)
+	if err != nil {
+		return err
+	}
+	if state.Artists == nil {
+		state.Artists = make(map[string]int)
+	}
+	state.Artists[m.artist] = newest
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(m.root, MirrorFileName), append(data, '\n'), 0o600)
+}
+
+func readMirrorState(root string) (mirrorState, error) {
+	var state mirrorState
+	data, err := os.ReadFile(filepath.Join(root, MirrorFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	err = json.Unmarshal(data, &state)
+	return state, err
+}
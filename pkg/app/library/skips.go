@@ -0,0 +1,157 @@
+package library
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SkipsFileName is the append-only record, kept in the library root, of why
+// files were not downloaded.
+const SkipsFileName = ".inkbunny-skips.jsonl"
+
+// Reasons a file was not downloaded, see Skip.Reason.
+const (
+	SkipExisting  = "existing"
+	SkipDuplicate = "duplicate"
+	SkipIgnored   = "ignored"
+	SkipMIME      = "mime"
+	SkipSelection = "selection"
+	SkipLanguage  = "language"
+	SkipAI        = "ai"
+	SkipKeyword   = "keyword"
+	SkipEmpty     = "empty"
+)
+
+// Skip records why a file, or a whole submission when FileID is empty, was
+// not downloaded.
+type Skip struct {
+	SubmissionID string `json:"submission_id"`
+	FileID       string `json:"file_id,omitempty"`
+	Reason       string `json:"reason"`
+	// Detail is what the reason was about, such as the MIME type, the
+	// blacklisted keyword or the file already downloaded.
+	Detail string    `json:"detail,omitempty"`
+	Time   time.Time `json:"time"`
+}
+
+// RecordSkip appends skip to the skips file. A file skipped again for the
+// same reason is not written again, so a sync that passes over the whole
+// library does not grow the file, and the time recorded is when the file was
+// first skipped for it.
+func (i *Index) RecordSkip(skip Skip) error {
+	if i == nil {
+		return nil
+	}
+	if skip.Time.IsZero() {
+		skip.Time = time.Now()
+	}
+
+	i.skipsMu.Lock()
+	defer i.skipsMu.Unlock()
+	if err := i.loadSkipsLocked(); err != nil {
+		return err
+	}
+	key := skipKey(skip)
+	if previous, ok := i.skips[key]; ok && previous.Reason == skip.Reason && previous.Detail == skip.Detail {
+		return nil
+	}
+	data, err := json.Marshal(skip)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(i.root, 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(i.skipsPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	i.skips[key] = skip
+	return nil
+}
+
+// Skips returns every skip recorded for the submission, oldest first.
+func (i *Index) Skips(submissionID string) ([]Skip, error) {
+	if i == nil {
+		return nil, nil
+	}
+	submissionID = strings.TrimSpace(submissionID)
+
+	var skips []Skip
+	err := readSkips(i.skipsPath(), func(skip Skip) {
+		if skip.SubmissionID == submissionID {
+			skips = append(skips, skip)
+		}
+	})
+	return skips, err
+}
+
+// Submission returns the files of the submission that are in the library.
+func (i *Index) Submission(submissionID string) []Entry {
+	var entries []Entry
+	for _, entry := range i.Recorded() {
+		if entry.SubmissionID == strings.TrimSpace(submissionID) {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// loadSkipsLocked reads the last skip of every file the first time one is
+// recorded, so a run that skips nothing never reads the skips file.
+func (i *Index) loadSkipsLocked() error {
+	if i.skips != nil {
+		return nil
+	}
+	skips := make(map[string]Skip)
+	err := readSkips(i.skipsPath(), func(skip Skip) {
+		skips[skipKey(skip)] = skip
+	})
+	if err != nil {
+		return err
+	}
+	i.skips = skips
+	return nil
+}
+
+func readSkips(path string, yield func(Skip)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var skip Skip
+		if err := json.Unmarshal(scanner.Bytes(), &skip); err != nil {
+			continue
+		}
+		yield(skip)
+	}
+	return scanner.Err()
+}
+
+func skipKey(skip Skip) string {
+	return strings.TrimSpace(skip.SubmissionID) + "/" + strings.TrimSpace(skip.FileID)
+}
+
+func (i *Index) skipsPath() string {
+	return filepath.Join(i.root, SkipsFileName)
+}
@@ -32,6 +32,7 @@ const (
 	authCookieName       = "inkbunny_remote"
 	authHeaderName       = "X-Inkbunny-Remote-Auth"
 	authQueryParam       = "remoteAuth"
+	webhookHeaderName    = "X-Inkbunny-Webhook-Token"
 )
 
 type Config struct {
@@ -49,6 +50,7 @@ type Server struct {
 	assetFS    fs.FS
 	devProxy   *httputil.ReverseProxy
 	upgrader   websocket.Upgrader
+	thumbnails *apputils.ThumbnailCache
 
 	mu           sync.RWMutex
 	selectedHost string
@@ -79,12 +81,16 @@ func NewServer(app *state.App, cfg Config) (*Server, error) {
 		assetFS, _ = fs.Sub(cfg.Assets, "app/dist")
 	}
 
+	// Without a cache directory thumbnails are still proxied, just fetched every time.
+	thumbnails, _ := apputils.NewThumbnailCache()
+
 	server := &Server{
-		app:       app,
-		listener:  listener,
-		assetFS:   assetFS,
-		pairToken: randomToken(32),
-		sessions:  make(map[string]struct{}),
+		app:        app,
+		thumbnails: thumbnails,
+		listener:   listener,
+		assetFS:    assetFS,
+		pairToken:  randomToken(32),
+		sessions:   make(map[string]struct{}),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: checkOrigin,
 		},
@@ -115,6 +121,8 @@ func NewServer(app *state.App, cfg Config) (*Server, error) {
 	mux.HandleFunc("GET /pair", server.handlePair)
 	mux.HandleFunc("GET /api/avatar/image", server.handleAvatarImage)
 	mux.HandleFunc("GET /api/remote/qrcode.png", server.handleQRCode)
+	mux.Handle("POST /api/webhook/presets/{name}", server.requireWebhookAuth(http.HandlerFunc(server.handleWebhookSync)))
+	mux.Handle("GET /api/webhook/runs/{id}", server.requireWebhookAuth(http.HandlerFunc(server.handleWebhookRun)))
 	mux.Handle("GET /ws", server.requireAuth(http.HandlerFunc(server.handleWebSocket)))
 	mux.Handle("/api/", server.requireAuth(http.HandlerFunc(server.handleAPI)))
 	mux.Handle("/", http.HandlerFunc(server.handleFrontend))
@@ -172,6 +180,7 @@ func (s *Server) buildInfo(host string) (types.RemoteAccessInfo, error) {
 		PairingURL:    pairingURL,
 		SelectedHost:  host,
 		QRCodeDataURL: s.buildQRCodeImageURL(),
+		WebhookToken:  s.app.WebhookToken(),
 	}, nil
 }
 
@@ -201,6 +210,29 @@ func (s *Server) requireAuth(next http.Handler) http.Handler {
 	})
 }
 
+// requireWebhookAuth accepts either a paired session or the webhook token, sent as a
+// bearer token or in the webhook header, so scripts can call in without pairing first.
+func (s *Server) requireWebhookAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.isAuthorized(r) && !s.app.ValidWebhookToken(webhookTokenFromRequest(r)) {
+			writeJSONError(w, http.StatusUnauthorized, "webhook token required")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func webhookTokenFromRequest(r *http.Request) string {
+	if value := strings.TrimSpace(r.Header.Get(webhookHeaderName)); value != "" {
+		return value
+	}
+	scheme, token, ok := strings.Cut(strings.TrimSpace(r.Header.Get("Authorization")), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
 func (s *Server) isAuthorized(r *http.Request) bool {
 	sessionID := authTokenFromRequest(r)
 	if sessionID == "" {
@@ -403,6 +435,30 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		writeNoContent(w)
+	case r.Method == http.MethodGet && r.URL.Path == "/api/presets":
+		writeJSON(w, http.StatusOK, s.app.GetPresets())
+	case r.Method == http.MethodPost && r.URL.Path == "/api/presets":
+		var preset types.SyncPreset
+		if !decodeJSON(w, r, &preset) {
+			return
+		}
+		presets, err := s.app.SavePreset(preset)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, presets)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/presets/delete":
+		var req presetNameRequest
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+		presets, err := s.app.DeletePreset(req.Name)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, presets)
 	case r.Method == http.MethodGet && r.URL.Path == "/api/queue":
 		writeJSON(w, http.StatusOK, s.app.GetQueueSnapshot())
 	case r.Method == http.MethodGet && r.URL.Path == "/api/submission-description":
@@ -587,6 +643,28 @@ func (s *Server) handleAPI(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func (s *Server) handleWebhookSync(w http.ResponseWriter, r *http.Request) {
+	run, err := s.app.TriggerPresetSync(r.PathValue("name"))
+	if err != nil {
+		if errors.Is(err, state.ErrUnknownPreset) {
+			writeJSONError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, run)
+}
+
+func (s *Server) handleWebhookRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.app.GetSyncRun(r.PathValue("id"))
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "sync run not found")
+		return
+	}
+	writeJSON(w, http.StatusOK, run)
+}
+
 func (s *Server) handleFrontend(w http.ResponseWriter, r *http.Request) {
 	if s.devProxy != nil {
 		s.devProxy.ServeHTTP(w, r)
@@ -690,6 +768,10 @@ type loadMoreRequest struct {
 	OperationID string `json:"operationId"`
 }
 
+type presetNameRequest struct {
+	Name string `json:"name"`
+}
+
 type searchOperationRequest struct {
 	OperationID string `json:"operationId"`
 }
@@ -756,6 +838,10 @@ func (s *Server) proxyRemoteResource(w http.ResponseWriter, r *http.Request) {
 		writeJSONError(w, http.StatusBadRequest, "unsupported resource url")
 		return
 	}
+	if apputils.IsThumbnailURL(target) {
+		s.serveThumbnail(w, r, target)
+		return
+	}
 	req, err := apputils.NewApprovedGetRequest(r.Context(), target)
 	if err != nil {
 		writeJSONError(w, http.StatusBadRequest, "invalid resource url")
@@ -789,6 +875,19 @@ func (s *Server) proxyRemoteResource(w http.ResponseWriter, r *http.Request) {
 	_, _ = io.Copy(w, response.Body)
 }
 
+func (s *Server) serveThumbnail(w http.ResponseWriter, r *http.Request, target *url.URL) {
+	body, contentType, err := s.thumbnails.Get(r.Context(), target)
+	if err != nil {
+		writeJSONError(w, http.StatusBadGateway, "resource fetch failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, max-age=86400")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
 func copyHeaderIfPresent(target http.Header, source http.Header, name string) {
 	value := strings.TrimSpace(source.Get(name))
 	if value == "" {
@@ -1,6 +1,7 @@
 package state
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
@@ -9,6 +10,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/ellypaws/inkbunny"
 	wruntime "github.com/wailsapp/wails/v2/pkg/runtime"
@@ -31,6 +33,7 @@ type App struct {
 	user              *inkbunny.User
 	settings          types.AppSettings
 	workspace         types.WorkspaceState
+	presets           []types.SyncPreset
 	sessionAvatar     string
 	searches          map[string]*searchState
 	lastSearchID      string
@@ -55,6 +58,13 @@ type App struct {
 	remoteControl     RemoteControl
 	remoteInfoMu      sync.RWMutex
 	remoteInfo        types.RemoteAccessInfo
+	syncRunsMu        sync.Mutex
+	syncRuns          map[string]*types.SyncRun
+	syncRunSeq        uint64
+	// ratingsBefore holds, per username, the ratings mask an account had
+	// before UpdateRatings first changed it, see types.AppSettings.RestoreRatings.
+	ratingsMu     sync.Mutex
+	ratingsBefore map[string]string
 }
 
 const submissionDetailsBatchSize = 100
@@ -73,7 +83,10 @@ func NewApp() *App {
 		workspace:   defaultState.Workspace,
 		searches:    make(map[string]*searchState),
 		searchOps:   make(map[string]searchOperation),
+		syncRuns:    make(map[string]*types.SyncRun),
 		rateLimiter: apputils.NewRateLimiter(nil),
+
+		ratingsBefore: make(map[string]string),
 	}
 }
 
@@ -90,6 +103,7 @@ func (a *App) Startup(ctx context.Context) {
 		if err == nil {
 			a.settings = state.Settings
 			a.workspace = state.Workspace
+			a.presets = state.Presets
 			a.lastSearchID = state.Session.LastSearchID
 			a.sessionAvatar = state.Session.AvatarURL
 			a.user = storage.RestoreUser(state.User)
@@ -109,6 +123,11 @@ func (a *App) Startup(ctx context.Context) {
 			a.publishSharedEvent(event, payload)
 		}
 	})
+	if policy, err := downloads.ParseSyncPolicy(a.settings.FsyncPolicy); err == nil {
+		a.downloadManager.SetSyncPolicy(policy)
+	}
+	a.downloadManager.SetConnectionsPerFile(a.settings.ConnectionsPerFile)
+	downloads.UpgradeLibraries(a.settings.UpgradeLibrary)
 	a.broadcastSessionState()
 	a.broadcastSettingsState()
 	a.broadcastWorkspaceState()
@@ -126,6 +145,10 @@ func (a *App) Shutdown(context.Context) {
 	if a.remoteControl != nil {
 		_ = a.remoteControl.Close()
 	}
+	if a.downloadManager != nil {
+		_ = a.downloadManager.FlushSync()
+	}
+	a.restoreRatings()
 }
 
 func (a *App) beginSearchOperation(operationID string) (context.Context, func()) {
@@ -276,6 +299,7 @@ func (a *App) UpdateRatings(mask string) (types.SessionInfo, error) {
 		return types.SessionInfo{}, err
 	}
 	ratings := inkbunny.ParseMask(strings.TrimSpace(mask))
+	before := user.Ratings.String()
 
 	_, err = apputils.ExecuteWithRateLimitRetry(a.ctx, a.rateLimiter, "ratings", func() (struct{}, error) {
 		return struct{}{}, user.ChangeRatings(ratings)
@@ -286,6 +310,7 @@ func (a *App) UpdateRatings(mask string) (types.SessionInfo, error) {
 		}
 		return types.SessionInfo{}, err
 	}
+	a.recordRatingsChange(user.Username, before, user.Ratings.String(), "desktop app")
 
 	a.mu.Lock()
 	if a.user != nil && a.user.SID == user.SID {
@@ -316,6 +341,9 @@ func (a *App) UpdateSettings(settings types.AppSettings) (types.AppSettings, err
 	if settings.MaxActive > 0 {
 		a.settings.MaxActive = apputils.NormalizeMaxActive(settings.MaxActive)
 	}
+	if settings.TimeZone != "" {
+		a.settings.TimeZone = downloads.NormalizeTimeZone(settings.TimeZone)
+	}
 	a.settings.DarkMode = settings.DarkMode
 	a.settings.MotionEnabled = settings.MotionEnabled
 	a.settings.AutoClearCompleted = settings.AutoClearCompleted
@@ -493,6 +521,13 @@ func (a *App) ClearCompletedSubmissions(submissionIDs []string) types.QueueSnaps
 }
 
 func (a *App) EnqueueDownloads(searchID string, selection types.DownloadSelection, options types.DownloadOptions) (types.QueueSnapshot, error) {
+	return a.enqueueDownloads(searchID, selection, options, true)
+}
+
+// enqueueDownloads queues the selected files. The directory, pattern and layout
+// in options override the settings for these files only, and are saved as the
+// new defaults when remember is set.
+func (a *App) enqueueDownloads(searchID string, selection types.DownloadSelection, options types.DownloadOptions, remember bool) (types.QueueSnapshot, error) {
 	user, err := a.ensureSearchSession()
 	if err != nil {
 		return types.QueueSnapshot{}, err
@@ -521,27 +556,50 @@ func (a *App) EnqueueDownloads(searchID string, selection types.DownloadSelectio
 		return a.GetQueueSnapshot(), nil
 	}
 
-	saveKeywords := options.SaveKeywords
+	sidecars := downloads.LegacySidecars(options.SaveKeywords)
+	if options.Sidecars != nil {
+		sidecars = *options.Sidecars
+	}
+	settings := a.GetSession().Settings
+	// The app has no toggles for derived keywords or per submission sidecars, so
+	// they come from the settings file.
+	sidecars.DeriveKeywords = sidecars.DeriveKeywords || settings.Sidecars.DeriveKeywords
+	sidecars.PerSubmission = sidecars.PerSubmission || settings.Sidecars.PerSubmission
 	downloadRoot, err := a.resolveDownloadDirectory()
 	if err != nil {
 		return types.QueueSnapshot{}, err
 	}
-	downloadRoot, err = normalizeDownloadDirectory(downloadRoot)
+	downloadRoot, err = normalizeDownloadDirectory(cmp.Or(strings.TrimSpace(options.DownloadDirectory), downloadRoot))
 	if err != nil {
 		return types.QueueSnapshot{}, err
 	}
 	maxActive := options.MaxActive
 	if maxActive <= 0 {
-		maxActive = a.GetSession().Settings.MaxActive
+		maxActive = settings.MaxActive
+	}
+	downloadPattern := downloads.NormalizePattern(cmp.Or(strings.TrimSpace(options.DownloadPattern), settings.DownloadPattern))
+	layout := downloads.Layout{Collabs: cmp.Or(options.Collabs, settings.Collabs), Characters: settings.Characters, TimeZone: settings.TimeZone}
+	if options.Characters != nil {
+		layout.Characters = downloads.NormalizeCharacters(options.Characters)
 	}
-	downloadPattern := downloads.NormalizePattern(options.DownloadPattern)
-	if strings.TrimSpace(downloadPattern) == "" {
-		downloadPattern = a.GetSession().Settings.DownloadPattern
+	routes, err := downloads.ParseMIMERoutes(settings.MimeRoutes)
+	if err != nil {
+		return types.QueueSnapshot{}, err
+	}
+	layout.Routes = routes
+	fileSelection, err := downloads.ParseFileSelection(settings.FileSelection)
+	if err != nil {
+		return types.QueueSnapshot{}, err
+	}
+	languages, err := downloads.ParseLanguages(settings.Languages)
+	if err != nil {
+		return types.QueueSnapshot{}, err
 	}
 	if err := os.MkdirAll(downloadRoot, 0o755); err != nil {
 		return types.QueueSnapshot{}, err
 	}
 
+	index, _ := downloads.LibraryIndex(downloadRoot)
 	searchResultsByID := a.lookupSeenSearchResults(searchID, submissionIDs)
 	includePools := downloads.PatternUsesPoolTokens(downloadPattern)
 	tasks := make([]downloads.Task, 0, len(submissionIDs))
@@ -561,12 +619,30 @@ func (a *App) EnqueueDownloads(searchID string, selection types.DownloadSelectio
 
 		for _, submission := range batch.Submissions {
 			allowed := selectedFiles[submission.SubmissionID.String()]
+			if len(allowed) == 0 && !downloads.KeepsLanguage(languages, downloads.DetectLanguage(submission)) {
+				continue
+			}
+			if _, ok := downloads.BlacklistedKeyword(submission, settings.KeywordBlacklist); ok && len(allowed) == 0 {
+				continue
+			}
+			if len(allowed) == 0 && !downloads.KeepsAI(settings.AIFilter, submission) {
+				continue
+			}
 			searchResult, hasSearchResult := searchResultsByID[submission.SubmissionID.String()]
 			for _, file := range submission.Files {
 				if len(allowed) > 0 {
 					if _, ok := allowed[file.FileID.String()]; !ok {
 						continue
 					}
+				} else if !fileSelection.Selects(submission, file) {
+					continue
+				}
+				if !options.ForceRedownload && index.Excluded(submission, file) {
+					continue
+				}
+				route := downloads.Route(routes, file)
+				if route.Action == downloads.RouteSkip {
+					continue
 				}
 				tasks = append(tasks, downloads.Task{
 					SessionID:    user.SID,
@@ -578,26 +654,29 @@ func (a *App) EnqueueDownloads(searchID string, selection types.DownloadSelectio
 					FileMD5:      file.FullFileMD5,
 					URL:          file.FileURLFull.String(),
 					IsPublic:     submission.Public.Bool(),
-					Metadata:     downloads.NewSubmissionFileMetadata(submission, file),
+					Metadata:     downloads.NewSubmissionFileMetadata(submission, file, layout.TimeZone),
 					PreviewURL:   queuePreviewURL(submission, file, user.SID),
-					SaveKeywords: saveKeywords,
+					Sidecars:     sidecars,
 					DownloadRoot: downloadRoot,
-					Destinations: downloads.ResolveDestinations(downloadRoot, downloadPattern, submission, file),
+					Destinations: downloads.ResolveLayoutDestinations(downloadRoot, downloadPattern, submission, file, layout),
+					Convert:      route.Format(),
 				})
 			}
 		}
 	}
 
-	_, _ = a.UpdateSettings(types.AppSettings{
-		DownloadDirectory:  downloadRoot,
-		DownloadPattern:    downloadPattern,
-		MaxActive:          maxActive,
-		DarkMode:           a.settings.DarkMode,
-		MotionEnabled:      a.settings.MotionEnabled,
-		AutoClearCompleted: a.settings.AutoClearCompleted,
-		SkippedReleaseTag:  a.settings.SkippedReleaseTag,
-		HasLoggedInBefore:  a.settings.HasLoggedInBefore,
-	})
+	if remember {
+		_, _ = a.UpdateSettings(types.AppSettings{
+			DownloadDirectory:  downloadRoot,
+			DownloadPattern:    downloadPattern,
+			MaxActive:          maxActive,
+			DarkMode:           a.settings.DarkMode,
+			MotionEnabled:      a.settings.MotionEnabled,
+			AutoClearCompleted: a.settings.AutoClearCompleted,
+			SkippedReleaseTag:  a.settings.SkippedReleaseTag,
+			HasLoggedInBefore:  a.settings.HasLoggedInBefore,
+		})
+	}
 
 	if len(tasks) == 0 {
 		return a.GetQueueSnapshot(), nil
@@ -676,6 +755,7 @@ func (a *App) persist() error {
 		User:      storage.ToStoredUser(a.user),
 		Settings:  a.settings,
 		Workspace: a.workspace,
+		Presets:   a.presets,
 	})
 }
 
@@ -1018,3 +1098,53 @@ func ternary[T any](condition bool, truthy, falsy T) T {
 	}
 	return falsy
 }
+
+// recordRatingsChange remembers the ratings username had before the app first
+// changed them, and appends the change to the ratings audit.
+func (a *App) recordRatingsChange(username, from, to, reason string) {
+	a.ratingsMu.Lock()
+	key := strings.ToLower(username)
+	if _, ok := a.ratingsBefore[key]; !ok {
+		a.ratingsBefore[key] = from
+	}
+	a.ratingsMu.Unlock()
+
+	if a.store == nil {
+		return
+	}
+	if err := a.store.AppendRatingsAudit(types.RatingsAuditEntry{
+		Time:     time.Now(),
+		Username: username,
+		From:     from,
+		To:       to,
+		Reason:   reason,
+	}); err != nil {
+		a.emitDebugLog("warn", "ratings.audit", "failed to record ratings change", map[string]any{"err": err.Error()})
+	}
+}
+
+// restoreRatings puts the ratings of the signed in account back to what they
+// were before the app first changed them, when RestoreRatings is set. Guests
+// are skipped since their ratings are discarded together with the session.
+func (a *App) restoreRatings() {
+	a.mu.RLock()
+	user, enabled := a.user, a.settings.RestoreRatings
+	a.mu.RUnlock()
+	if !enabled || user == nil || user.SID == "" || strings.EqualFold(user.Username, "guest") {
+		return
+	}
+
+	a.ratingsMu.Lock()
+	before, ok := a.ratingsBefore[strings.ToLower(user.Username)]
+	a.ratingsMu.Unlock()
+	current := user.Ratings.String()
+	if !ok || before == current {
+		return
+	}
+	if err := user.ChangeRatings(inkbunny.ParseMask(before)); err != nil {
+		a.emitDebugLog("error", "ratings.restore", "failed to restore ratings", map[string]any{"err": err.Error()})
+		return
+	}
+	a.recordRatingsChange(user.Username, current, before, "restore on exit")
+	_ = a.persist()
+}
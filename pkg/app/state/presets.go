@@ -0,0 +1,251 @@
+package state
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+const (
+	syncRunRunning   = "running"
+	syncRunCompleted = "completed"
+	syncRunFailed    = "failed"
+
+	// maxSyncRuns bounds how many finished runs are kept around for polling.
+	maxSyncRuns = 50
+)
+
+var (
+	ErrUnknownPreset     = errors.New("unknown preset")
+	ErrInvalidWebhookURL = errors.New("webhook url must be an http or https url")
+)
+
+func (a *App) GetPresets() []types.SyncPreset {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return slices.Clone(a.presets)
+}
+
+// SavePreset adds the preset or replaces the one with the same name.
+func (a *App) SavePreset(preset types.SyncPreset) ([]types.SyncPreset, error) {
+	preset.Name = strings.TrimSpace(preset.Name)
+	if preset.Name == "" {
+		return nil, errors.New("preset name is required")
+	}
+	// Presets keep their own directory, pattern, layout and sidecars so that
+	// each one can write somewhere different without touching the settings.
+	if directory := strings.TrimSpace(preset.Options.DownloadDirectory); directory != "" {
+		normalized, err := normalizeDownloadDirectory(directory)
+		if err != nil {
+			return nil, err
+		}
+		preset.Options.DownloadDirectory = normalized
+	}
+	preset.Options.DownloadPattern = strings.TrimSpace(preset.Options.DownloadPattern)
+	if preset.Options.Collabs != "" {
+		preset.Options.Collabs = downloads.NormalizeCollabsMode(preset.Options.Collabs)
+	}
+	if preset.Watch != nil {
+		watch := *preset.Watch
+		watch.WebhookURL = strings.TrimSpace(watch.WebhookURL)
+		if watch.WebhookURL != "" {
+			if parsed, err := url.Parse(watch.WebhookURL); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidWebhookURL, watch.WebhookURL)
+			}
+		}
+		preset.Watch = &watch
+	}
+
+	a.mu.Lock()
+	a.presets = storage.NormalizePresets(append(slices.Clone(a.presets), preset))
+	presets := slices.Clone(a.presets)
+	a.mu.Unlock()
+	return presets, a.persist()
+}
+
+func (a *App) DeletePreset(name string) ([]types.SyncPreset, error) {
+	a.mu.Lock()
+	a.presets = slices.DeleteFunc(slices.Clone(a.presets), func(preset types.SyncPreset) bool {
+		return strings.EqualFold(preset.Name, strings.TrimSpace(name))
+	})
+	presets := slices.Clone(a.presets)
+	a.mu.Unlock()
+	return presets, a.persist()
+}
+
+func (a *App) preset(name string) (types.SyncPreset, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for _, preset := range a.presets {
+		if strings.EqualFold(preset.Name, strings.TrimSpace(name)) {
+			return preset, true
+		}
+	}
+	return types.SyncPreset{}, false
+}
+
+// TriggerPresetSync starts searching with the named preset in the background
+// and queues everything it finds. The returned run can be polled with GetSyncRun.
+func (a *App) TriggerPresetSync(name string) (types.SyncRun, error) {
+	preset, ok := a.preset(name)
+	if !ok {
+		return types.SyncRun{}, fmt.Errorf("%w: %q", ErrUnknownPreset, strings.TrimSpace(name))
+	}
+
+	a.syncRunsMu.Lock()
+	a.syncRunSeq++
+	run := &types.SyncRun{
+		ID:        fmt.Sprintf("run-%d-%d", time.Now().Unix(), a.syncRunSeq),
+		Preset:    preset.Name,
+		Status:    syncRunRunning,
+		StartedAt: time.Now().Format(time.RFC3339Nano),
+	}
+	a.syncRuns[run.ID] = run
+	a.pruneSyncRunsLocked()
+	snapshot := *run
+	a.syncRunsMu.Unlock()
+
+	go a.runPresetSync(run.ID, preset)
+	return snapshot, nil
+}
+
+func (a *App) GetSyncRun(id string) (types.SyncRun, bool) {
+	a.syncRunsMu.Lock()
+	defer a.syncRunsMu.Unlock()
+	run, ok := a.syncRuns[strings.TrimSpace(id)]
+	if !ok {
+		return types.SyncRun{}, false
+	}
+	return *run, true
+}
+
+func (a *App) runPresetSync(runID string, preset types.SyncPreset) {
+	var (
+		queued, matched int
+		err             error
+	)
+	if preset.Watch != nil {
+		matched, err = a.watchPreset(preset)
+	} else {
+		queued, err = a.queuePresetResults(preset)
+	}
+
+	a.syncRunsMu.Lock()
+	defer a.syncRunsMu.Unlock()
+	run := a.syncRuns[runID]
+	if run == nil {
+		return
+	}
+	run.Queued = queued
+	run.Matched = matched
+	run.Status = syncRunCompleted
+	run.FinishedAt = time.Now().Format(time.RFC3339Nano)
+	if err != nil {
+		run.Status = syncRunFailed
+		run.Error = err.Error()
+	}
+	a.emitDebugLog("info", "preset.sync", "preset sync finished", map[string]any{
+		"runId":   run.ID,
+		"preset":  run.Preset,
+		"status":  run.Status,
+		"queued":  run.Queued,
+		"matched": run.Matched,
+	})
+}
+
+func (a *App) queuePresetResults(preset types.SyncPreset) (int, error) {
+	params := preset.Search
+	params.Page = 1
+	params.ClientOperationID = ""
+	response, err := a.Search(params)
+	if err != nil {
+		return 0, err
+	}
+
+	limit := params.MaxDownloads
+	selection := types.DownloadSelection{}
+	addResults := func(results []types.SubmissionCard) {
+		for _, result := range results {
+			if limit > 0 && len(selection.Submissions) >= limit {
+				return
+			}
+			selection.Submissions = append(selection.Submissions, types.SelectedSubmission{SubmissionID: result.SubmissionID})
+		}
+	}
+	addResults(response.Results)
+	for page := 2; page <= response.PagesCount; page++ {
+		if limit > 0 && len(selection.Submissions) >= limit {
+			break
+		}
+		more, err := a.LoadMoreResults(response.SearchID, page, "")
+		if err != nil {
+			return 0, err
+		}
+		addResults(more.Results)
+	}
+
+	if _, err := a.enqueueDownloads(response.SearchID, selection, preset.Options, false); err != nil {
+		return 0, err
+	}
+	return len(selection.Submissions), nil
+}
+
+func (a *App) pruneSyncRunsLocked() {
+	for len(a.syncRuns) > maxSyncRuns {
+		oldest := ""
+		for id, run := range a.syncRuns {
+			if run.Status == syncRunRunning {
+				continue
+			}
+			if oldest == "" || run.StartedAt < a.syncRuns[oldest].StartedAt {
+				oldest = id
+			}
+		}
+		if oldest == "" {
+			return
+		}
+		delete(a.syncRuns, oldest)
+	}
+}
+
+// WebhookToken returns the secret external callers use to trigger preset
+// syncs, generating and saving one the first time it is needed.
+func (a *App) WebhookToken() string {
+	a.mu.Lock()
+	token := a.settings.WebhookToken
+	if token != "" {
+		a.mu.Unlock()
+		return token
+	}
+	value := make([]byte, 32)
+	if _, err := rand.Read(value); err != nil {
+		a.mu.Unlock()
+		return ""
+	}
+	token = base64.RawURLEncoding.EncodeToString(value)
+	a.settings.WebhookToken = token
+	a.mu.Unlock()
+
+	if err := a.persist(); err != nil {
+		a.emitDebugLog("warn", "preset.webhook", "failed to save webhook token", map[string]any{
+			"error": err.Error(),
+		})
+	}
+	return token
+}
+
+func (a *App) ValidWebhookToken(token string) bool {
+	expected := a.WebhookToken()
+	token = strings.TrimSpace(token)
+	return expected != "" && token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,6 +16,7 @@ import (
 	"github.com/ellypaws/inkbunny"
 
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
 	apputils "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/utils"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flight"
@@ -59,6 +61,14 @@ type artistSearchState struct {
 const defaultSearchPerPage = 30
 const maxSearchPerPage = 100
 
+// Suggestions are kept for suggestionTTL, and lookups that failed or found
+// nobody for suggestionNegativeTTL, so that typing a name that does not exist
+// does not ask Inkbunny again on every keystroke.
+const (
+	suggestionTTL         = 10 * time.Minute
+	suggestionNegativeTTL = 30 * time.Second
+)
+
 func (a *App) Search(params types.SearchParams) (resp types.SearchResponse, err error) {
 	startedAt := time.Now()
 	a.emitDebugLog("debug", "search.run", "search requested", debugSearchParamsFields(params))
@@ -626,6 +636,15 @@ func (a *App) GetKeywordSuggestions(query string) (values []types.KeywordSuggest
 	if err != nil {
 		return nil, err
 	}
+	// Keywords the library already has a lot of are the likeliest to be wanted.
+	if root, rootErr := a.resolveDownloadDirectory(); rootErr == nil {
+		if index, indexErr := downloads.LibraryIndex(root); indexErr == nil {
+			items = slices.Clone(items)
+			library.RankKeywords(index, items, func(item inkbunny.KeywordAutocomplete) string {
+				return item.Value
+			})
+		}
+	}
 	values = make([]types.KeywordSuggestion, 0, minInt(len(items), 10))
 	for i, item := range items {
 		if i >= 10 {
@@ -1947,7 +1966,7 @@ func (a *App) ensureCaches(user *inkbunny.User) {
 				ratings := inkbunny.ParseMask(key.RatingsMask)
 				return inkbunny.KeywordSuggestion(key.Query, ratings, key.Underscore)
 			})
-		})
+		}).WithTTL(suggestionTTL, suggestionNegativeTTL).WithEmpty(flight.EmptySlice)
 		a.keywordCache = &cache
 	}
 	if a.usernameCache == nil {
@@ -1970,7 +1989,7 @@ func (a *App) ensureCaches(user *inkbunny.User) {
 				a.mu.RUnlock()
 				return prependCurrentUserSuggestion(suggestions, current, avatar, key.Query), nil
 			})
-		})
+		}).WithTTL(suggestionTTL, suggestionNegativeTTL).WithEmpty(flight.EmptySlice)
 		a.usernameCache = &cache
 	}
 	if a.avatarCache == nil {
@@ -2045,7 +2064,7 @@ func (a *App) resetCaches(user *inkbunny.User) {
 			ratings := inkbunny.ParseMask(key.RatingsMask)
 			return inkbunny.KeywordSuggestion(key.Query, ratings, key.Underscore)
 		})
-	})
+	}).WithTTL(suggestionTTL, suggestionNegativeTTL).WithEmpty(flight.EmptySlice)
 	usernameCache := flight.NewCache(func(ctx context.Context, key usernameCacheKey) ([]types.UsernameSuggestion, error) {
 		return apputils.ExecuteWithRateLimitRetry(ctx, a.rateLimiter, "username suggestions", func() ([]types.UsernameSuggestion, error) {
 			current, err := a.ensureSearchSession()
@@ -2065,7 +2084,7 @@ func (a *App) resetCaches(user *inkbunny.User) {
 			a.mu.RUnlock()
 			return prependCurrentUserSuggestion(suggestions, current, avatar, key.Query), nil
 		})
-	})
+	}).WithTTL(suggestionTTL, suggestionNegativeTTL).WithEmpty(flight.EmptySlice)
 	avatarCache := flight.NewCache(func(ctx context.Context, key avatarCacheKey) (string, error) {
 		return apputils.ExecuteWithRateLimitRetry(ctx, a.rateLimiter, "avatar lookups", func() (string, error) {
 			current, err := a.ensureSearchSession()
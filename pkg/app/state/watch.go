@@ -0,0 +1,119 @@
+package state
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+const watchWebhookTimeout = 30 * time.Second
+
+var ErrWatchWebhook = errors.New("watch webhook failed")
+
+// watchPreset searches with a watch preset and reports the matches newer than
+// the ones it saw before, without downloading anything. It returns how many
+// matches were reported.
+func (a *App) watchPreset(preset types.SyncPreset) (int, error) {
+	params := preset.Search
+	params.Page = 1
+	params.ClientOperationID = ""
+	response, err := a.Search(params)
+	if err != nil {
+		return 0, err
+	}
+
+	last := preset.Watch.LastSubmissionID
+	newest := last
+	var matches []types.SubmissionCard
+	collect := func(results []types.SubmissionCard) {
+		for _, result := range results {
+			id, err := strconv.Atoi(result.SubmissionID)
+			if err != nil {
+				continue
+			}
+			newest = max(newest, id)
+			if last > 0 && id > last {
+				matches = append(matches, result)
+			}
+		}
+	}
+	collect(response.Results)
+	for page := 2; page <= response.PagesCount; page++ {
+		more, err := a.LoadMoreResults(response.SearchID, page, "")
+		if err != nil {
+			return 0, err
+		}
+		collect(more.Results)
+	}
+
+	if newest != last {
+		if err := a.recordWatchProgress(preset.Name, newest); err != nil {
+			return 0, err
+		}
+	}
+	if len(matches) == 0 {
+		return 0, nil
+	}
+
+	a.emitNotification(types.AppNotification{
+		ID:        fmt.Sprintf("watch-%s-%d", strings.ToLower(preset.Name), time.Now().UnixNano()),
+		Level:     "info",
+		Message:   fmt.Sprintf("%d new submissions match %q.", len(matches), preset.Name),
+		Scope:     "watch",
+		DedupeKey: "watch-" + strings.ToLower(preset.Name),
+	})
+	if preset.Watch.WebhookURL != "" {
+		alert := types.WatchAlert{Preset: preset.Name, Submissions: matches}
+		if err := postWatchAlert(preset.Watch.WebhookURL, alert); err != nil {
+			return len(matches), err
+		}
+	}
+	return len(matches), nil
+}
+
+// recordWatchProgress saves the newest match of the named watch preset so the
+// next run only reports what came after it.
+func (a *App) recordWatchProgress(name string, newest int) error {
+	a.mu.Lock()
+	for i, preset := range a.presets {
+		if !strings.EqualFold(preset.Name, name) || preset.Watch == nil {
+			continue
+		}
+		watch := *preset.Watch
+		watch.LastSubmissionID = newest
+		a.presets[i].Watch = &watch
+	}
+	a.mu.Unlock()
+	return a.persist()
+}
+
+func postWatchAlert(url string, alert types.WatchAlert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), watchWebhookTimeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWatchWebhook, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return fmt.Errorf("%w: %s", ErrWatchWebhook, response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package storage
+
+import "errors"
+
+// FreeSpace is not known on this platform.
+func FreeSpace(string) (uint64, error) {
+	return 0, errors.ErrUnsupported
+}
@@ -0,0 +1,15 @@
+//go:build unix
+
+package storage
+
+import "golang.org/x/sys/unix"
+
+// FreeSpace returns how many bytes can still be written to the filesystem
+// that holds path.
+func FreeSpace(path string) (uint64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
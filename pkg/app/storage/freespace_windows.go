@@ -0,0 +1,19 @@
+//go:build windows
+
+package storage
+
+import "golang.org/x/sys/windows"
+
+// FreeSpace returns how many bytes can still be written to the volume that
+// holds path.
+func FreeSpace(path string) (uint64, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available uint64
+	if err := windows.GetDiskFreeSpaceEx(name, &available, nil, nil); err != nil {
+		return 0, err
+	}
+	return available, nil
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var ErrInvalidLibraryName = errors.New("invalid library name")
+
+// activeLibrary is the named library selected with --library. Every library
+// keeps its own state file, history and default download directory; the empty
+// name is the default library that predates named ones.
+var activeLibrary struct {
+	mu   sync.RWMutex
+	name string
+}
+
+// UseLibrary selects the library every StateStore opened afterwards belongs to.
+func UseLibrary(name string) error {
+	name, err := NormalizeLibraryName(name)
+	if err != nil {
+		return err
+	}
+	activeLibrary.mu.Lock()
+	activeLibrary.name = name
+	activeLibrary.mu.Unlock()
+	return nil
+}
+
+func ActiveLibrary() string {
+	activeLibrary.mu.RLock()
+	defer activeLibrary.mu.RUnlock()
+	return activeLibrary.name
+}
+
+// libraryDirectory is where the state of the active library is kept.
+func libraryDirectory() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	root := filepath.Join(base, "inkbunny-downloader")
+	if library := ActiveLibrary(); library != "" {
+		root = filepath.Join(root, "libraries", library)
+	}
+	return root, nil
+}
+
+// NormalizeLibraryName trims name and makes sure it is usable as a single folder name.
+func NormalizeLibraryName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil
+	}
+	if name == "." || name == ".." {
+		return "", fmt.Errorf("%w: %q", ErrInvalidLibraryName, name)
+	}
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(" -_.", r) {
+			return "", fmt.Errorf("%w: %q", ErrInvalidLibraryName, name)
+		}
+	}
+	return name, nil
+}
@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultKeepRuns is how many runs of a library are kept before the oldest are removed.
+const DefaultKeepRuns = 20
+
+const runIDLayout = "20060102-150405"
+
+// Run is the folder one invocation keeps its log, reports and progress in,
+// under runs/<id> of the active library. IDs sort in the order runs started.
+type Run struct {
+	ID  string
+	Dir string
+}
+
+// RunsDirectory is where the runs of the active library are kept.
+func RunsDirectory() (string, error) {
+	root, err := libraryDirectory()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, "runs"), nil
+}
+
+// NewRun creates the folder of a run starting now.
+func NewRun() (*Run, error) {
+	runs, err := RunsDirectory()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(runs, 0o755); err != nil {
+		return nil, err
+	}
+
+	base := time.Now().Format(runIDLayout)
+	for n := 1; ; n++ {
+		id := base
+		if n > 1 {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		dir := filepath.Join(runs, id)
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		return &Run{ID: id, Dir: dir}, nil
+	}
+}
+
+// Path returns where the run keeps the file called name.
+func (r *Run) Path(name string) string {
+	if r == nil {
+		return name
+	}
+	return filepath.Join(r.Dir, name)
+}
+
+// ListRuns returns the runs of the active library, oldest first.
+func ListRuns() ([]Run, error) {
+	runs, err := RunsDirectory()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(runs)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var list []Run
+	for _, entry := range entries {
+		if entry.IsDir() {
+			list = append(list, Run{ID: entry.Name(), Dir: filepath.Join(runs, entry.Name())})
+		}
+	}
+	slices.SortFunc(list, func(a, b Run) int {
+		return compareRunIDs(a.ID, b.ID)
+	})
+	return list, nil
+}
+
+// PruneRuns removes the oldest runs so that at most keep are left, never
+// removing current. A keep of zero or less keeps every run.
+func PruneRuns(keep int, current *Run) (int, error) {
+	if keep <= 0 {
+		return 0, nil
+	}
+	list, err := ListRuns()
+	if err != nil {
+		return 0, err
+	}
+	return removeRuns(list[:max(len(list)-keep, 0)], current)
+}
+
+// CleanRuns removes every run but current.
+func CleanRuns(current *Run) (int, error) {
+	list, err := ListRuns()
+	if err != nil {
+		return 0, err
+	}
+	return removeRuns(list, current)
+}
+
+func removeRuns(list []Run, current *Run) (int, error) {
+	removed := 0
+	for _, run := range list {
+		if current != nil && run.ID == current.ID {
+			continue
+		}
+		if err := os.RemoveAll(run.Dir); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// compareRunIDs orders IDs by the time they started, then IDs started in the
+// same second by their numeric suffix.
+func compareRunIDs(a, b string) int {
+	base := min(len(a), len(b), len(runIDLayout))
+	return cmp.Or(
+		strings.Compare(a[:base], b[:base]),
+		cmp.Compare(len(a), len(b)),
+		strings.Compare(a, b),
+	)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+// RunSnapshotFileName is the file in a run folder that records how the run was
+// started, for --from-run to start it again.
+const RunSnapshotFileName = "run.json"
+
+var ErrUnknownRun = errors.New("unknown run")
+
+// RunSnapshot is the effective configuration of a run. Credentials are never
+// recorded, so a run started from it logs in as the current one does.
+type RunSnapshot struct {
+	Version string `json:"version"`
+	Started string `json:"started_at"`
+	// Args are the subcommand and every flag given to the run, with the values
+	// they resolved to.
+	Args []string `json:"args"`
+	// Preset is the preset the run searched with as it was then, so that
+	// changing it since does not change the run.
+	Preset *types.SyncPreset `json:"preset,omitempty"`
+	// Search is the search the run sent to Inkbunny, after every option and
+	// filter was applied.
+	Search *inkbunny.SubmissionSearchRequest `json:"search,omitempty"`
+}
+
+// WriteSnapshot records snapshot in the run folder, replacing the one written
+// before it.
+func (r *Run) WriteSnapshot(snapshot RunSnapshot) error {
+	if r == nil {
+		return nil
+	}
+	snapshot.Started = time.Now().UTC().Format(time.RFC3339)
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path(RunSnapshotFileName), append(data, '\n'), 0o644)
+}
+
+// ReadRunSnapshot reads the snapshot of the run called id in the active library.
+func ReadRunSnapshot(id string) (RunSnapshot, error) {
+	if id == "" || filepath.Base(id) != id {
+		return RunSnapshot{}, fmt.Errorf("%w: %q", ErrUnknownRun, id)
+	}
+	runs, err := RunsDirectory()
+	if err != nil {
+		return RunSnapshot{}, err
+	}
+	data, err := os.ReadFile(filepath.Join(runs, id, RunSnapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return RunSnapshot{}, fmt.Errorf("%w: %q has no %s", ErrUnknownRun, id, RunSnapshotFileName)
+	}
+	if err != nil {
+		return RunSnapshot{}, err
+	}
+	var snapshot RunSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return RunSnapshot{}, err
+	}
+	return snapshot, nil
+}
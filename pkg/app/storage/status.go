@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// StatusFileName is where a downloading run keeps its progress, so that other
+// processes can check on it.
+const StatusFileName = "status.json"
+
+var ErrNoRunStatus = errors.New("no run has reported its progress")
+
+// RunProgress counts the files of a run by where they are in the queue.
+type RunProgress struct {
+	Queued     int   `json:"queued"`
+	Active     int   `json:"active"`
+	Downloaded int   `json:"downloaded"`
+	Failed     int   `json:"failed"`
+	Bytes      int64 `json:"bytes"`
+}
+
+// RunStatus is the progress a run last reported.
+type RunStatus struct {
+	RunProgress
+	PID     int       `json:"pid"`
+	Library string    `json:"library"`
+	Started time.Time `json:"started"`
+	Updated time.Time `json:"updated"`
+	// BytesPerSecond is smoothed over the last few reports.
+	BytesPerSecond float64 `json:"bytes_per_second"`
+	// ETA is the time left for the queued files at the rate files finished so
+	// far, zero when it cannot be told yet.
+	ETA      time.Duration `json:"eta"`
+	Finished bool          `json:"finished"`
+}
+
+// StatusWriter turns the progress of a run into the status other processes read.
+type StatusWriter struct {
+	path   string
+	status RunStatus
+	// sampled and sampledBytes are where the speed was last measured from.
+	sampled      time.Time
+	sampledBytes int64
+}
+
+// NewStatusWriter reports the progress of the run downloading into library,
+// which is recorded as an absolute path so that it can be checked from any
+// directory. A nil run gives a nil writer, which reports nothing.
+func (r *Run) NewStatusWriter(library string) *StatusWriter {
+	if r == nil {
+		return nil
+	}
+	if abs, err := filepath.Abs(library); err == nil {
+		library = abs
+	}
+	now := time.Now()
+	return &StatusWriter{
+		path:    r.Path(StatusFileName),
+		sampled: now,
+		status: RunStatus{
+			PID:     os.Getpid(),
+			Library: library,
+			Started: now,
+			Updated: now,
+		},
+	}
+}
+
+// Update records progress. It is meant to be called every few seconds.
+func (w *StatusWriter) Update(progress RunProgress) error {
+	if w == nil {
+		return nil
+	}
+	now := time.Now()
+	status := &w.status
+	if elapsed := now.Sub(w.sampled); elapsed >= time.Second {
+		speed := max(float64(progress.Bytes-w.sampledBytes), 0) / elapsed.Seconds()
+		if status.BytesPerSecond == 0 {
+			status.BytesPerSecond = speed
+		} else {
+			status.BytesPerSecond = 0.7*status.BytesPerSecond + 0.3*speed
+		}
+		w.sampled, w.sampledBytes = now, progress.Bytes
+	}
+	status.ETA = 0
+	if done := progress.Downloaded + progress.Failed; done > 0 {
+		perFile := now.Sub(status.Started) / time.Duration(done)
+		status.ETA = (perFile * time.Duration(progress.Queued+progress.Active)).Round(time.Second)
+	}
+	status.RunProgress = progress
+	status.Updated = now
+	return w.write()
+}
+
+// Finish records the final progress of the run. Files still queued were left
+// out by a download limit, so they are no longer counted.
+func (w *StatusWriter) Finish(progress RunProgress) error {
+	if w == nil {
+		return nil
+	}
+	progress.Queued, progress.Active = 0, 0
+	w.status.RunProgress = progress
+	w.status.Updated = time.Now()
+	w.status.BytesPerSecond = 0
+	w.status.ETA = 0
+	w.status.Finished = true
+	return w.write()
+}
+
+func (w *StatusWriter) write() error {
+	data, err := json.MarshalIndent(w.status, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Written aside and renamed so a reader never sees half a file.
+	temp := w.path + ".tmp"
+	if err := os.WriteFile(temp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp, w.path)
+}
+
+// LatestRunStatus returns the newest run of the active library that reported
+// its progress downloading into the directory library, so that runs into
+// other directories sharing its state are told apart.
+func LatestRunStatus(library string) (Run, RunStatus, error) {
+	if abs, err := filepath.Abs(library); err == nil {
+		library = abs
+	}
+	list, err := ListRuns()
+	if err != nil {
+		return Run{}, RunStatus{}, err
+	}
+	for _, run := range slices.Backward(list) {
+		data, err := os.ReadFile(filepath.Join(run.Dir, StatusFileName))
+		if err != nil {
+			continue
+		}
+		var status RunStatus
+		if err := json.Unmarshal(data, &status); err != nil {
+			return run, RunStatus{}, err
+		}
+		if filepath.Clean(status.Library) != filepath.Clean(library) {
+			continue
+		}
+		return run, status, nil
+	}
+	return Run{}, RunStatus{}, ErrNoRunStatus
+}
@@ -24,11 +24,10 @@ type StateStore struct {
 }
 
 func NewStateStore() (*StateStore, error) {
-	base, err := os.UserConfigDir()
+	root, err := libraryDirectory()
 	if err != nil {
 		return nil, err
 	}
-	root := filepath.Join(base, "inkbunny-downloader")
 	if err := os.MkdirAll(root, 0o755); err != nil {
 		return nil, err
 	}
@@ -77,6 +76,29 @@ func (s *StateStore) writeStateLocked(state types.StoredState) error {
 	return os.WriteFile(s.path, data, 0o600)
 }
 
+// AppendRatingsAudit appends entry as a JSON line to ratings-audit.jsonl next to the state file.
+func (s *StateStore) AppendRatingsAudit(entry types.RatingsAuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.root, 0o755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filepath.Join(s.root, "ratings-audit.jsonl"), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
 func DefaultStoredState() types.StoredState {
 	settings := types.AppSettings{
 		DownloadDirectory:  DefaultDownloadDirectory(),
@@ -99,12 +121,14 @@ func DefaultStoredState() types.StoredState {
 	}
 }
 
+// DefaultDownloadDirectory is the user's downloads folder, or a folder named
+// after the active library inside it so named libraries never share files.
 func DefaultDownloadDirectory() string {
 	base, err := resolveDownloadsDirectory()
 	if err != nil || strings.TrimSpace(base) == "" {
-		return "Downloads"
+		base = "Downloads"
 	}
-	return filepath.Clean(base)
+	return filepath.Join(base, ActiveLibrary())
 }
 
 func ResolveDownloadPickerDirectory(current string) string {
@@ -177,6 +201,9 @@ func normalizeStoredState(state types.StoredState) types.StoredState {
 	if state.Settings.DownloadPattern == "" {
 		state.Settings.DownloadPattern = defaultState.Settings.DownloadPattern
 	}
+	state.Settings.Collabs = downloads.NormalizeCollabsMode(state.Settings.Collabs)
+	state.Settings.Characters = downloads.NormalizeCharacters(state.Settings.Characters)
+	state.Presets = NormalizePresets(state.Presets)
 	state.Workspace.ActiveTabID = strings.TrimSpace(state.Workspace.ActiveTabID)
 	if state.Workspace.Tabs == nil {
 		state.Workspace.Tabs = []types.SavedSearchTab{}
@@ -191,6 +218,27 @@ func normalizeStoredState(state types.StoredState) types.StoredState {
 	return state
 }
 
+// NormalizePresets trims preset names and drops unnamed presets, keeping the
+// last preset saved under a name when it appears more than once.
+func NormalizePresets(presets []types.SyncPreset) []types.SyncPreset {
+	normalized := make([]types.SyncPreset, 0, len(presets))
+	index := make(map[string]int, len(presets))
+	for _, preset := range presets {
+		preset.Name = strings.TrimSpace(preset.Name)
+		if preset.Name == "" {
+			continue
+		}
+		key := strings.ToLower(preset.Name)
+		if i, ok := index[key]; ok {
+			normalized[i] = preset
+			continue
+		}
+		index[key] = len(normalized)
+		normalized = append(normalized, preset)
+	}
+	return normalized
+}
+
 func (s *StateStore) backupInvalidStateFile() error {
 	if strings.TrimSpace(s.path) == "" {
 		return nil
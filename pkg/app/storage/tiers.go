@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+)
+
+var ErrInvalidStorageTier = errors.New("invalid storage tier, expected <size>=<folder> such as 1G=/mnt/hdd")
+
+// StorageTier sends files of at least MinSize bytes to Root instead of the
+// download directory, keeping the path they would have had under it.
+type StorageTier struct {
+	MinSize int64
+	Root    string
+}
+
+// StorageTiers are sorted by MinSize, largest first.
+type StorageTiers []StorageTier
+
+// ParseStorageTiers parses tiers written as "1G=/mnt/hdd,100M=/mnt/bulk".
+func ParseStorageTiers(value string) (StorageTiers, error) {
+	var tiers StorageTiers
+	for rule := range strings.SplitSeq(value, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		size, root, ok := strings.Cut(rule, "=")
+		root = strings.TrimSpace(root)
+		if !ok || root == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidStorageTier, rule)
+		}
+		minSize, err := downloads.ParseSize(size)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidStorageTier, err)
+		}
+		tiers = append(tiers, StorageTier{MinSize: minSize, Root: filepath.Clean(root)})
+	}
+	slices.SortStableFunc(tiers, func(a, b StorageTier) int { return cmp.Compare(b.MinSize, a.MinSize) })
+	return tiers, nil
+}
+
+// Root is the folder a file of size bytes goes under: that of the tier with
+// the largest MinSize it reaches, or primary. A file of unknown size, given
+// as a negative size, stays in primary.
+func (t StorageTiers) Root(primary string, size int64) string {
+	for _, tier := range t {
+		if size >= tier.MinSize {
+			return tier.Root
+		}
+	}
+	return primary
+}
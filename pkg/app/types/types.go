@@ -1,6 +1,9 @@
 package types
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type SessionInfo struct {
 	HasSession     bool        `json:"hasSession"`
@@ -32,14 +35,77 @@ type SubmissionKeyword struct {
 }
 
 type AppSettings struct {
-	DownloadDirectory  string `json:"downloadDirectory"`
-	DownloadPattern    string `json:"downloadPattern"`
-	MaxActive          int    `json:"maxActive"`
-	DarkMode           bool   `json:"darkMode"`
-	MotionEnabled      bool   `json:"motionEnabled"`
-	AutoClearCompleted bool   `json:"autoClearCompleted"`
-	SkippedReleaseTag  string `json:"skippedReleaseTag"`
-	HasLoggedInBefore  bool   `json:"hasLoggedInBefore"`
+	DownloadDirectory  string         `json:"downloadDirectory"`
+	DownloadPattern    string         `json:"downloadPattern"`
+	MaxActive          int            `json:"maxActive"`
+	Sidecars           SidecarOptions `json:"sidecars"`
+	Collabs            string         `json:"collabs"`
+	Characters         []string       `json:"characters,omitempty"`
+	KeywordBlacklist   []string       `json:"keywordBlacklist,omitempty"`
+	AIFilter           string         `json:"aiFilter,omitempty"`
+	FsyncPolicy        string         `json:"fsyncPolicy,omitempty"`
+	ConnectionsPerFile int            `json:"connectionsPerFile,omitempty"`
+	TimeZone           string         `json:"timeZone,omitempty"`
+	MimeRoutes         string         `json:"mimeRoutes,omitempty"`
+	FileSelection      string         `json:"fileSelection,omitempty"`
+	Languages          string         `json:"languages,omitempty"`
+	DarkMode           bool           `json:"darkMode"`
+	MotionEnabled      bool           `json:"motionEnabled"`
+	AutoClearCompleted bool           `json:"autoClearCompleted"`
+	SkippedReleaseTag  string         `json:"skippedReleaseTag"`
+	GuestRatingsMask   string         `json:"guestRatingsMask,omitempty"`
+	WebhookToken       string         `json:"webhookToken,omitempty"`
+	DiscordWebhook     string         `json:"discordWebhook,omitempty"`
+	Booru              BooruSettings  `json:"booru,omitzero"`
+	Rclone             RcloneSettings `json:"rclone,omitzero"`
+	Collections        []Collection   `json:"collections,omitempty"`
+	// UpgradeLibrary lets the app upgrade a library written by an older
+	// version when it opens it, after backing up what the upgrade rewrites.
+	// Without it the library is left as it is until the terminal UI offers
+	// the upgrade.
+	UpgradeLibrary bool `json:"upgradeLibrary,omitempty"`
+	// RestoreRatings puts the ratings of the account back to what they were
+	// before the app first changed them when it shuts down.
+	RestoreRatings    bool `json:"restoreRatings,omitempty"`
+	HasLoggedInBefore bool `json:"hasLoggedInBefore"`
+}
+
+// BooruSettings is the image board --booru uploads downloaded files to.
+type BooruSettings struct {
+	// Kind is "szurubooru" (the default) or "danbooru".
+	Kind string `json:"kind,omitempty"`
+	URL  string `json:"url"`
+	// Username and Token log in to the booru: a login token on szurubooru,
+	// an API key on Danbooru.
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// RcloneSettings is the rclone remote --rclone copies downloads to.
+type RcloneSettings struct {
+	// Remote is where downloads are copied to, such as "gdrive:inkbunny".
+	Remote string `json:"remote"`
+	// Mode is "copy" (the default) or "sync", which also deletes what is on
+	// the remote but not in the download directory.
+	Mode string `json:"mode,omitempty"`
+	// Flags are passed on to rclone, such as ["--transfers", "8"].
+	Flags []string `json:"flags,omitempty"`
+	// PerSubmission copies each submission as soon as it is downloaded
+	// instead of the download directory after the run. It only copies.
+	PerSubmission bool `json:"perSubmission,omitempty"`
+	// Binary is the rclone command to run, rclone on the PATH by default.
+	Binary string `json:"binary,omitempty"`
+}
+
+// Collection is a folder of the library that every sync fills with links to
+// the downloads that carry all of Tags and were uploaded between From and To.
+type Collection struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+	// From and To are dates such as "2024-01-01", both included. Either can
+	// be left out.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
 }
 
 type SearchParams struct {
@@ -201,11 +267,62 @@ type DownloadSelection struct {
 }
 
 type DownloadOptions struct {
-	SaveKeywords      bool   `json:"saveKeywords"`
-	MaxActive         int    `json:"maxActive"`
-	DownloadDirectory string `json:"downloadDirectory"`
-	DownloadPattern   string `json:"downloadPattern"`
-	ForceRedownload   bool   `json:"forceRedownload"`
+	SaveKeywords      bool            `json:"saveKeywords"`
+	Sidecars          *SidecarOptions `json:"sidecars,omitempty"`
+	MaxActive         int             `json:"maxActive"`
+	DownloadDirectory string          `json:"downloadDirectory"`
+	DownloadPattern   string          `json:"downloadPattern"`
+	Collabs           string          `json:"collabs,omitempty"`
+	Characters        []string        `json:"characters,omitempty"`
+	ForceRedownload   bool            `json:"forceRedownload"`
+}
+
+type SidecarOptions struct {
+	Keywords    bool `json:"keywords"`
+	Metadata    bool `json:"metadata"`
+	Description bool `json:"description"`
+	Comments    bool `json:"comments"`
+	// Submission writes the full submission details, with the MD5 of every file,
+	// to <submission_id>.submission.json in the folder of its files.
+	Submission bool `json:"submission,omitempty"`
+	// DescriptionExport writes the description and story of a submission,
+	// converted from BBCode, to <submission_id>.description.md when "markdown"
+	// or <submission_id>.description.html when "html", in the folder of its
+	// files.
+	DescriptionExport string `json:"descriptionExport,omitempty"`
+	// DeriveKeywords fills the keywords sidecar of a submission without keywords
+	// with terms from its title and description.
+	DeriveKeywords bool `json:"deriveKeywords,omitempty"`
+	// CaptionFormat is how the keywords sidecar is written: "tags" (the
+	// default) joins them with commas, "booru" joins them with spaces after
+	// replacing the spaces in each with underscores, "jsonl" adds a line to
+	// metadata.jsonl in the folder instead, and "template" fills in
+	// CaptionTemplate.
+	CaptionFormat string `json:"captionFormat,omitempty"`
+	// CaptionTemplate is the caption written with the "template" format, in
+	// which {tags}, {booru}, {artist} and {title} are replaced.
+	CaptionTemplate string `json:"captionTemplate,omitempty"`
+	// CaptionBOM starts the keywords sidecar with a UTF-8 byte order mark,
+	// which some training tools on Windows need to read it as UTF-8: "always",
+	// "windows" to only do so on Windows, or "never" (the default).
+	CaptionBOM string `json:"captionBOM,omitempty"`
+	// Hydrus writes Hydrus Network import sidecars beside each file:
+	// <file>.tags.txt with its namespaced tags and <file>.urls.txt with its
+	// submission and file URLs.
+	Hydrus bool `json:"hydrus,omitempty"`
+	// Page writes a snapshot of the submission page, with its description,
+	// keywords and stats at the time, to <submission_id>.page.html in the
+	// folder of its files, rendered with PageTemplate or the default template.
+	Page         bool   `json:"page,omitempty"`
+	PageTemplate string `json:"pageTemplate,omitempty"`
+	// PerSubmission writes the keywords caption of a submission once, beside
+	// the first of its files that is downloaded, instead of beside every one
+	// of its files. The other sidecars are still written beside every file.
+	PerSubmission bool `json:"perSubmission,omitempty"`
+}
+
+func (s SidecarOptions) Any() bool {
+	return s.Keywords || s.Metadata || s.Description || s.Comments || s.Submission || s.Hydrus || s.Page || s.DescriptionExport != ""
 }
 
 type QueueSnapshot struct {
@@ -292,6 +409,7 @@ type RemoteAccessInfo struct {
 	SelectedHost   string   `json:"selectedHost,omitempty"`
 	AvailableHosts []string `json:"availableHosts,omitempty"`
 	QRCodeDataURL  string   `json:"qrCodeDataUrl,omitempty"`
+	WebhookToken   string   `json:"webhookToken,omitempty"`
 }
 
 type SessionStateUpdate struct {
@@ -345,6 +463,51 @@ type StoredState struct {
 	User      SessionUser    `json:"user"`
 	Settings  AppSettings    `json:"settings"`
 	Workspace WorkspaceState `json:"workspace"`
+	Presets   []SyncPreset   `json:"presets,omitempty"`
+}
+
+// SyncPreset is a named search that is re-run to queue every submission it finds.
+type SyncPreset struct {
+	Name    string          `json:"name"`
+	Search  SearchParams    `json:"search"`
+	Options DownloadOptions `json:"options"`
+	// Watch makes the preset report new matches instead of downloading them.
+	Watch *PresetWatch `json:"watch,omitempty"`
+}
+
+// PresetWatch is how a watch preset reports new matches. They are always shown
+// as an app notification.
+type PresetWatch struct {
+	// WebhookURL also receives the new matches as a WatchAlert when set.
+	WebhookURL string `json:"webhookUrl,omitempty"`
+	// LastSubmissionID is the newest match seen so far. The first run only
+	// records it, so matches that already existed are not reported.
+	LastSubmissionID int `json:"lastSubmissionId,omitempty"`
+}
+
+// WatchAlert is posted to the webhook of a watch preset.
+type WatchAlert struct {
+	Preset      string           `json:"preset"`
+	Submissions []SubmissionCard `json:"submissions"`
+}
+
+type SyncRun struct {
+	ID         string `json:"id"`
+	Preset     string `json:"preset"`
+	Status     string `json:"status"`
+	Queued     int    `json:"queued"`
+	Matched    int    `json:"matched,omitempty"`
+	Error      string `json:"error,omitempty"`
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt,omitempty"`
+}
+
+type RatingsAuditEntry struct {
+	Time     time.Time `json:"time"`
+	Username string    `json:"username"`
+	From     string    `json:"from"`
+	To       string    `json:"to"`
+	Reason   string    `json:"reason"`
 }
 
 type SessionUser struct {
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -13,6 +15,11 @@ import (
 
 const maxRateLimitAttempts = 4
 
+// defaultRetryAfter is how long Client holds back requests after a 429
+// response that does not say how long to wait, and the least it holds them
+// back for.
+const defaultRetryAfter = 5 * time.Second
+
 type RateLimiter struct {
 	mu            sync.Mutex
 	cooldownUntil time.Time
@@ -70,11 +77,16 @@ func (l *RateLimiter) Wait(ctx context.Context) error {
 }
 
 func (l *RateLimiter) Register(scope string, attempt int) time.Duration {
+	return l.Pause(scope, rateLimitDelay(attempt))
+}
+
+// Pause holds back every request waiting on l for delay, such as the
+// Retry-After of a 429 response, unless it is already held back for longer.
+func (l *RateLimiter) Pause(scope string, delay time.Duration) time.Duration {
 	if l == nil {
 		return 0
 	}
 
-	delay := rateLimitDelay(attempt)
 	now := time.Now()
 	until := now.Add(delay)
 
@@ -100,6 +112,49 @@ func (l *RateLimiter) Register(scope string, attempt int) time.Duration {
 	return effective
 }
 
+// Remaining returns how long requests waiting on l are still held back.
+func (l *RateLimiter) Remaining() time.Duration {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return max(time.Until(l.cooldownUntil), 0)
+}
+
+// Client returns an HTTP client whose requests wait on l, and which holds
+// back every request waiting on it for the Retry-After of a 429 response.
+func (l *RateLimiter) Client(client *http.Client, scope string) *http.Client {
+	if l == nil {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	throttled := *client
+	throttled.Transport = throttleTransport{base: base, limiter: l, scope: scope}
+	return &throttled
+}
+
+type throttleTransport struct {
+	base    http.RoundTripper
+	limiter *RateLimiter
+	scope   string
+}
+
+func (t throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.Wait(req.Context()); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		delay, _ := RetryAfter(resp)
+		t.limiter.Pause(t.scope, max(delay, defaultRetryAfter))
+	}
+	return resp, err
+}
+
 func (l *RateLimiter) Exhausted(scope string, err error) error {
 	message := fmt.Sprintf("Inkbunny is still rate limiting %s. Please try again in a moment.", scope)
 	if l != nil {
@@ -162,6 +217,19 @@ func rateLimitDelay(attempt int) time.Duration {
 	return base + jitter
 }
 
+// RetryAfter parses the Retry-After header of resp, given in seconds or as an
+// HTTP date, and reports whether it asked to wait at all.
+func RetryAfter(resp *http.Response) (time.Duration, bool) {
+	value := strings.TrimSpace(resp.Header.Get("Retry-After"))
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		delay = time.Until(at)
+	}
+	return delay, delay > 0
+}
+
 func humanizeRetryDelay(delay time.Duration) string {
 	if delay <= 0 {
 		return "a moment"
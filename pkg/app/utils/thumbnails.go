@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// maxThumbnailBytes is larger than any thumbnail or preview Inkbunny serves.
+	maxThumbnailBytes = 4 << 20
+	// maxThumbnailCacheBytes is how large the cache may grow before the least
+	// recently used thumbnails are removed on startup.
+	maxThumbnailCacheBytes = 512 << 20
+)
+
+// ThumbnailCache keeps submission thumbnails and previews on disk so result
+// grids can be shown again without fetching them from Inkbunny every time.
+// A nil *ThumbnailCache fetches without caching.
+type ThumbnailCache struct {
+	dir string
+}
+
+func NewThumbnailCache() (*ThumbnailCache, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(base, "inkbunny-downloader", "thumbnails")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	cache := &ThumbnailCache{dir: dir}
+	cache.prune(maxThumbnailCacheBytes)
+	return cache, nil
+}
+
+// IsThumbnailURL reports whether target is one of the small previews of a
+// submission rather than a full size file.
+func IsThumbnailURL(target *url.URL) bool {
+	if target == nil || !IsApprovedInkbunnyHost(target.Hostname()) {
+		return false
+	}
+	escapedPath := strings.ToLower(target.EscapedPath())
+	return strings.Contains(escapedPath, "/thumbnails/") || strings.Contains(escapedPath, "/files/preview/")
+}
+
+// Get returns the thumbnail at target, reading it from disk when it was fetched before.
+func (c *ThumbnailCache) Get(ctx context.Context, target *url.URL) ([]byte, string, error) {
+	if !IsThumbnailURL(target) {
+		return nil, "", errApprovedURLDenied
+	}
+	contentType := mime.TypeByExtension(path.Ext(target.Path))
+	if contentType == "" {
+		contentType = "image/jpeg"
+	}
+
+	name := c.path(target)
+	if name != "" {
+		if body, err := os.ReadFile(name); err == nil {
+			now := time.Now()
+			_ = os.Chtimes(name, now, now)
+			return body, contentType, nil
+		}
+	}
+
+	body, err := fetchThumbnail(ctx, target)
+	if err != nil {
+		return nil, "", err
+	}
+	if name != "" {
+		_ = writeFileAtomic(name, body)
+	}
+	return body, contentType, nil
+}
+
+// path keys the cache on the URL without its query, since the session ID
+// attached to private previews changes between logins.
+func (c *ThumbnailCache) path(target *url.URL) string {
+	if c == nil {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(strings.ToLower(target.Host) + target.EscapedPath()))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+path.Ext(target.Path))
+}
+
+func (c *ThumbnailCache) prune(limit int64) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	type cachedFile struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	files := make([]cachedFile, 0, len(entries))
+	var total int64
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, cachedFile{name: filepath.Join(c.dir, entry.Name()), size: info.Size(), modTime: info.ModTime()})
+		total += info.Size()
+	}
+	if total <= limit {
+		return
+	}
+
+	slices.SortFunc(files, func(a, b cachedFile) int {
+		return a.modTime.Compare(b.modTime)
+	})
+	for _, file := range files {
+		if total <= limit {
+			return
+		}
+		if err := os.Remove(file.name); err == nil {
+			total -= file.size
+		}
+	}
+}
+
+func fetchThumbnail(ctx context.Context, target *url.URL) ([]byte, error) {
+	request, err := NewApprovedGetRequest(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", "image/avif,image/webp,image/apng,image/*,*/*;q=0.8")
+	request.Header.Set("Origin", "https://inkbunny.net")
+	request.Header.Set("Referer", "https://inkbunny.net/")
+	request.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/136.0.0.0 Safari/537.36")
+
+	client := &http.Client{
+		Timeout: 15 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return checkApprovedRedirect(req, via, ParseApprovedInkbunnyURL)
+		},
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("thumbnail status: %s", response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxThumbnailBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxThumbnailBytes {
+		return nil, errors.New("thumbnail too large")
+	}
+	return body, nil
+}
+
+func writeFileAtomic(name string, data []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(name), ".thumbnail-*")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), name); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return nil
+}
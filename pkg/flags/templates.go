@@ -0,0 +1,38 @@
+package flags
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/charmbracelet/lipgloss"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+)
+
+// printTemplatesHelp prints the templates help command: the fields of each
+// template and the functions they can be piped through.
+func printTemplatesHelp(out io.Writer) {
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF79C6"))
+	headingStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#BD93F9")).Underline(true)
+	flagStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#50FA7B")).Bold(true)
+	descStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#F8F8F2"))
+	exampleStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("#8BE9FD")).Italic(true)
+
+	fmt.Fprintf(out, "%s\n\n", titleStyle.Render("Template fields and functions"))
+
+	fmt.Fprintf(out, "%s\n", headingStyle.Render("DOWNLOAD PATTERN (--pattern, and the download pattern setting of the apps):"))
+	fmt.Fprintf(out, "%s\n\n", descStyle.Render("Fields are written as {field}. Examples are of a three file series by 'elly'."))
+	for _, token := range appdownloads.PatternTokens {
+		field := "{" + token.Name + "}"
+		fmt.Fprintf(out, "  %s %s\n", flagStyle.Render(field), exampleStyle.Render(appdownloads.PatternTokenPreview(field)))
+		fmt.Fprintf(out, "      %s\n", descStyle.Render(token.Description))
+	}
+
+	fmt.Fprintf(out, "\n%s\n", headingStyle.Render("FUNCTIONS:"))
+	fmt.Fprintf(out, "%s\n\n", descStyle.Render("Pipe a field through functions, applied left to right, as in {title|slug|truncate:40}."))
+	for _, function := range appdownloads.PatternFunctions {
+		fmt.Fprintf(out, "  %s\n", flagStyle.Render(function.Usage))
+		fmt.Fprintf(out, "      %s\n", descStyle.Render(function.Description))
+		fmt.Fprintf(out, "      %s %s -> %s\n", descStyle.Render("Example:"), exampleStyle.Render(function.Example), exampleStyle.Render(appdownloads.PatternTokenPreview(function.Example)))
+	}
+}
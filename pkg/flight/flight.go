@@ -2,15 +2,25 @@ package flight
 
 import (
 	"context"
+	"errors"
 	"sync"
+	"time"
 )
 
 type Cache[K comparable, V any] struct {
-	finished map[K]V
+	finished map[K]entry[V]
+	failed   map[K]failure
 	fmu      *sync.RWMutex
 	pending  map[K]*job[V]
 	pmu      *sync.Mutex
 	work     func(context.Context, K) (V, error)
+
+	// ttl is how long a result is kept, and negativeTTL how long an error or
+	// an empty result is. A zero ttl keeps results for good, and a zero
+	// negativeTTL keeps neither errors nor empty results.
+	ttl         time.Duration
+	negativeTTL time.Duration
+	empty       func(V) bool
 }
 
 type job[V any] struct {
@@ -19,9 +29,25 @@ type job[V any] struct {
 	done chan struct{}
 }
 
+// entry is a cached result. A zero expires never expires.
+type entry[V any] struct {
+	val     V
+	expires time.Time
+}
+
+type failure struct {
+	err     error
+	expires time.Time
+}
+
+func expired(expires time.Time) bool {
+	return !expires.IsZero() && time.Now().After(expires)
+}
+
 func NewCache[K comparable, V any](work func(context.Context, K) (V, error)) Cache[K, V] {
 	return Cache[K, V]{
-		finished: make(map[K]V),
+		finished: make(map[K]entry[V]),
+		failed:   make(map[K]failure),
 		fmu:      new(sync.RWMutex),
 		pending:  make(map[K]*job[V]),
 		pmu:      new(sync.Mutex),
@@ -29,6 +55,21 @@ func NewCache[K comparable, V any](work func(context.Context, K) (V, error)) Cac
 	}
 }
 
+// WithTTL keeps results for ttl, and errors and empty results for negative,
+// so that a query that keeps failing or finding nothing is not asked again
+// on every call, yet is asked again soon.
+func (p Cache[K, V]) WithTTL(ttl, negative time.Duration) Cache[K, V] {
+	p.ttl, p.negativeTTL = ttl, negative
+	return p
+}
+
+// WithEmpty tells which results count as finding nothing, to be kept as long
+// as errors are.
+func (p Cache[K, V]) WithEmpty(empty func(V) bool) Cache[K, V] {
+	p.empty = empty
+	return p
+}
+
 func (p *Cache[K, V]) Get(k K) (V, error) {
 	return p.GetWithContext(context.Background(), k)
 }
@@ -42,10 +83,15 @@ func (p *Cache[K, V]) GetWithContext(ctx context.Context, k K) (V, error) {
 	p.pmu.Lock()
 	p.fmu.RLock()
 	finished, ok := p.finished[k]
+	failed, hasFailed := p.failed[k]
 	p.fmu.RUnlock()
-	if ok {
+	if ok && !expired(finished.expires) {
 		p.pmu.Unlock()
-		return finished, nil
+		return finished.val, nil
+	}
+	if hasFailed && !expired(failed.expires) {
+		p.pmu.Unlock()
+		return zero, failed.err
 	}
 
 	pending, ok := p.pending[k]
@@ -64,11 +110,23 @@ func (p *Cache[K, V]) GetWithContext(ctx context.Context, k K) (V, error) {
 	p.pmu.Unlock()
 
 	j.val, j.err = p.work(ctx, k)
-	if j.err == nil {
-		p.fmu.Lock()
-		p.finished[k] = j.val
-		p.fmu.Unlock()
+	p.fmu.Lock()
+	delete(p.failed, k)
+	switch {
+	case j.err != nil:
+		// A call given up by its caller says nothing about the query.
+		canceled := errors.Is(j.err, context.Canceled) || errors.Is(j.err, context.DeadlineExceeded)
+		if p.negativeTTL > 0 && !canceled {
+			p.failed[k] = failure{err: j.err, expires: time.Now().Add(p.negativeTTL)}
+		}
+	case p.empty != nil && p.empty(j.val):
+		if p.negativeTTL > 0 {
+			p.finished[k] = entry[V]{val: j.val, expires: time.Now().Add(p.negativeTTL)}
+		}
+	default:
+		p.finished[k] = p.entry(j.val)
 	}
+	p.fmu.Unlock()
 
 	p.pmu.Lock()
 	close(j.done)
@@ -78,12 +136,20 @@ func (p *Cache[K, V]) GetWithContext(ctx context.Context, k K) (V, error) {
 	return j.val, j.err
 }
 
+func (p *Cache[K, V]) entry(value V) entry[V] {
+	if p.ttl <= 0 {
+		return entry[V]{val: value}
+	}
+	return entry[V]{val: value, expires: time.Now().Add(p.ttl)}
+}
+
 func (p *Cache[K, V]) Delete(k K) {
 	p.pmu.Lock()
 	defer p.pmu.Unlock()
 
 	p.fmu.Lock()
 	delete(p.finished, k)
+	delete(p.failed, k)
 	p.fmu.Unlock()
 }
 
@@ -93,6 +159,7 @@ func (p *Cache[K, V]) Clear() {
 
 	p.fmu.Lock()
 	clear(p.finished)
+	clear(p.failed)
 	p.fmu.Unlock()
 }
 
@@ -100,8 +167,12 @@ func (p *Cache[K, V]) Peek(k K) (V, bool) {
 	p.fmu.RLock()
 	defer p.fmu.RUnlock()
 
-	value, ok := p.finished[k]
-	return value, ok
+	finished, ok := p.finished[k]
+	if !ok || expired(finished.expires) {
+		var zero V
+		return zero, false
+	}
+	return finished.val, true
 }
 
 func (p *Cache[K, V]) Store(k K, value V) {
@@ -109,6 +180,12 @@ func (p *Cache[K, V]) Store(k K, value V) {
 	defer p.pmu.Unlock()
 
 	p.fmu.Lock()
-	p.finished[k] = value
+	p.finished[k] = p.entry(value)
+	delete(p.failed, k)
 	p.fmu.Unlock()
 }
+
+// EmptySlice reports whether a slice result found nothing, for WithEmpty.
+func EmptySlice[T any](value []T) bool {
+	return len(value) == 0
+}
@@ -0,0 +1,82 @@
+package modes
+
+import (
+	"os"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+// runAdopt identifies the files under --adopt by their MD5 and moves them into
+// the library at root as if they had been downloaded there, writing sidecars
+// and recording them in the download history.
+func runAdopt(config flags.Config, root string, pattern string, layout appdownloads.Layout) {
+	index, err := library.Open(root)
+	if err != nil {
+		log.Warn("failed to load download history", "err", err)
+	}
+	files, err := hashFolder(config.Adopt)
+	if err != nil {
+		fatal(ExitFailure, "failed to hash files to adopt", "path", config.Adopt, "err", err)
+	}
+	log.Info("Looking up files to adopt", "files", len(files), "library", root)
+
+	sidecars := config.Sidecars
+	if !sidecars.Any() {
+		sidecars.Metadata = true
+	}
+	var detailsRequest inkbunny.SubmissionDetailsRequest
+	if appdownloads.SidecarsNeedDetails(sidecars) {
+		detailsRequest = appdownloads.MetadataSubmissionDetailsRequest()
+	}
+	found := lookupFiles(config, files, detailsRequest)
+
+	var adopted, unmatched, failed int
+	for _, file := range files {
+		source, ok := found[file.MD5]
+		if !ok {
+			unmatched++
+			log.Debug("No submission found for file", "file", file.Path)
+			continue
+		}
+
+		destinations := appdownloads.ResolveLayoutDestinations(root, pattern, source.Submission, source.File, layout)
+		if len(destinations) == 0 {
+			continue
+		}
+		if err := appdownloads.AdoptFile(file.Path, destinations, file.MD5); err != nil {
+			failed++
+			log.Error("failed to adopt file", "file", file.Path, "err", err)
+			continue
+		}
+		if err := appdownloads.WriteSidecars(destinations, appdownloads.NewSubmissionFileMetadata(source.Submission, source.File, layout.TimeZone), sidecars); err != nil {
+			log.Warn("failed to write sidecars", "file", destinations[0], "err", err)
+		}
+
+		entry := library.Entry{
+			SubmissionID: source.Submission.SubmissionID.String(),
+			FileID:       source.File.FileID.String(),
+			MD5:          file.MD5,
+			Variant:      source.Variant,
+			Artist:       source.Submission.Username,
+			Title:        source.Submission.Title,
+			Uploaded:     appdownloads.UploadTime(source.Submission, source.File, appdownloads.TimeZoneUTC),
+			Keywords:     library.SubmissionKeywords(source.Submission),
+			Paths:        destinations,
+		}
+		if info, err := os.Stat(destinations[0]); err == nil {
+			entry.Size = info.Size()
+		}
+		if err := index.Record(entry); err != nil {
+			log.Warn("failed to record download history", "err", err)
+		}
+		adopted++
+		log.Info("Adopted file", "file", file.Path, "to", destinations[0], "submission", "https://inkbunny.net/s/"+entry.SubmissionID)
+	}
+	log.Info("Adopted files into the library", "library", root, "adopted", adopted, "unmatched", unmatched, "failed", failed)
+}
@@ -0,0 +1,100 @@
+package modes
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/log"
+
+	uitui "github.com/ellypaws/inkbunny/cmd/downloader/pkg/tui"
+)
+
+// approvalSamples is how many titles of each artist the approval lists.
+const approvalSamples = 3
+
+// artistSummary is what a search found of one artist, for approving them.
+type artistSummary struct {
+	Artist      string
+	Submissions int
+	Files       int
+	Titles      []string
+}
+
+// summarizeArtists groups the submissions of items by artist, with the
+// artists with the most submissions first.
+func summarizeArtists(items []*uitui.DownloadItem) []artistSummary {
+	byArtist := make(map[string]*artistSummary)
+	seen := make(map[string]bool)
+	var summaries []*artistSummary
+	for _, item := range items {
+		summary, ok := byArtist[item.Username]
+		if !ok {
+			summary = &artistSummary{Artist: item.Username}
+			byArtist[item.Username] = summary
+			summaries = append(summaries, summary)
+		}
+		summary.Files++
+		if seen[item.SubmissionID] {
+			continue
+		}
+		seen[item.SubmissionID] = true
+		summary.Submissions++
+		if len(summary.Titles) < approvalSamples {
+			summary.Titles = append(summary.Titles, item.Title)
+		}
+	}
+	slices.SortStableFunc(summaries, func(a, b *artistSummary) int {
+		return cmp.Compare(b.Submissions, a.Submissions)
+	})
+	result := make([]artistSummary, len(summaries))
+	for i, summary := range summaries {
+		result[i] = *summary
+	}
+	return result
+}
+
+// approveArtists asks which artists to download from when items span at least
+// minimum of them, such as for a keyword search, and leaves out the files of
+// those that were denied. Every artist starts approved.
+func approveArtists(items []*uitui.DownloadItem, minimum int) ([]*uitui.DownloadItem, error) {
+	summaries := summarizeArtists(items)
+	if minimum <= 0 || len(summaries) < minimum {
+		return items, nil
+	}
+	options := make([]huh.Option[string], len(summaries))
+	for i, summary := range summaries {
+		label := fmt.Sprintf("%s (%d submissions, %d files): %s", summary.Artist, summary.Submissions, summary.Files, strings.Join(summary.Titles, ", "))
+		if more := summary.Submissions - len(summary.Titles); more > 0 {
+			label += fmt.Sprintf(" and %d more", more)
+		}
+		options[i] = huh.NewOption(label, summary.Artist).Selected(true)
+	}
+	var approved []string
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewMultiSelect[string]().
+				Title(fmt.Sprintf("The search found %d artists, untick those not to download from", len(summaries))).
+				Description("Type / to filter.").
+				Options(options...).
+				Filterable(true).
+				Height(min(len(options)+2, 20)).
+				Value(&approved),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return nil, err
+	}
+
+	denied := len(summaries) - len(approved)
+	if denied == 0 {
+		return items, nil
+	}
+	kept := slices.DeleteFunc(items, func(item *uitui.DownloadItem) bool {
+		return !slices.Contains(approved, item.Username)
+	})
+	log.Info("Left out the artists that were not approved", "denied", denied, "files", len(kept))
+	return kept, nil
+}
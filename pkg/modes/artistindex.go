@@ -0,0 +1,58 @@
+package modes
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+)
+
+// artistIndexes collects the artists a run comes across, whose index is
+// written again once it is done. A nil *artistIndexes writes none.
+type artistIndexes struct {
+	mu      sync.Mutex
+	artists map[string]string
+}
+
+func newArtistIndexes(enabled bool) *artistIndexes {
+	if !enabled {
+		return nil
+	}
+	return &artistIndexes{artists: make(map[string]string)}
+}
+
+func (a *artistIndexes) Add(artist string) {
+	if a == nil || artist == "" {
+		return
+	}
+	a.mu.Lock()
+	a.artists[strings.ToLower(artist)] = artist
+	a.mu.Unlock()
+}
+
+// Write regenerates the index in the folder of every artist that was added.
+// Patterns without an {artist} folder have nowhere to put one.
+func (a *artistIndexes) Write(index *library.Index, root, pattern string) {
+	if a == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.artists) == 0 {
+		return
+	}
+	entries := index.EntriesByArtist()
+	for key, artist := range a.artists {
+		dir := appdownloads.ArtistDirectory(root, pattern, artist)
+		if dir == filepath.Clean(root) {
+			return
+		}
+		if err := index.WriteArtistIndex(dir, artist, entries[key]); err != nil {
+			log.Warn("failed to write the artist index", "artist", artist, "err", err)
+		}
+	}
+}
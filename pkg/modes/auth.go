@@ -20,6 +20,7 @@ const (
 	authSourceProvidedCredentials authSource = "provided_credentials"
 	authSourceSavedSession        authSource = "saved_session"
 	authSourcePrompt              authSource = "prompt"
+	authSourceSimulation          authSource = "simulation"
 )
 
 var (
@@ -27,9 +28,13 @@ var (
 	errUsernameRequired     = errors.New("username is required when --password is provided")
 	errPasswordRequired     = errors.New("password is required when --username is provided, unless the username is guest")
 	errLoginPromptAborted   = errors.New("login prompt aborted")
+	errSessionExpired       = errors.New("saved session expired")
 )
 
 func authenticateUser(config flags.Config, allowPrompt bool) (*inkbunny.User, authSource, bool, error) {
+	if config.Simulate != "" {
+		return simulatedUser(), authSourceSimulation, false, nil
+	}
 	if err := validateAuthInputs(config); err != nil {
 		return nil, "", false, err
 	}
@@ -48,9 +53,16 @@ func authenticateUser(config flags.Config, allowPrompt bool) (*inkbunny.User, au
 	}
 
 	user, err := loadSession()
+	if err == nil {
+		err = validateSession(user)
+	}
 	if err == nil {
 		return user, authSourceSavedSession, false, nil
 	}
+	if errors.Is(err, errSessionExpired) {
+		log.Warn("Saved session expired, please login again")
+		invalidateAuthSource(&config, authSourceSavedSession)
+	}
 	if !allowPrompt {
 		return nil, "", false, fmt.Errorf("%w: %v", errHeadlessAuthRequired, err)
 	}
@@ -59,6 +71,23 @@ func authenticateUser(config flags.Config, allowPrompt bool) (*inkbunny.User, au
 	return user, authSourcePrompt, shouldPersistSession(user), err
 }
 
+// validateSession checks a saved session against the API before it is used, so
+// an expired one asks for a login right away instead of failing the search.
+// Errors other than an invalid session keep the session, e.g. when offline.
+func validateSession(user *inkbunny.User) error {
+	if strings.EqualFold(user.Username, "guest") {
+		return nil
+	}
+	_, err := user.GetWatching()
+	if err, ok := errors.AsType[inkbunny.ErrorResponse](err); ok && err.Code != nil && *err.Code == inkbunny.ErrInvalidSessionID {
+		return errSessionExpired
+	}
+	if err != nil {
+		log.Debug("could not validate saved session", "err", err)
+	}
+	return nil
+}
+
 func validateAuthInputs(config flags.Config) error {
 	if strings.TrimSpace(config.SID) != "" {
 		return nil
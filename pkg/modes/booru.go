@@ -0,0 +1,47 @@
+package modes
+
+import (
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+)
+
+// loadBooru returns the uploader for the booru in the settings file, where
+// its credentials are kept rather than on the command line.
+func loadBooru() (appdownloads.Booru, error) {
+	store, err := appstorage.NewStateStore()
+	if err != nil {
+		return nil, err
+	}
+	state, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return appdownloads.NewBooru(state.Settings.Booru)
+}
+
+// loadRclone returns the rclone remote in the settings file.
+func loadRclone() (*appdownloads.Rclone, error) {
+	store, err := appstorage.NewStateStore()
+	if err != nil {
+		return nil, err
+	}
+	state, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return appdownloads.NewRclone(state.Settings.Rclone)
+}
+
+// loadDiscord returns the notifier for the Discord webhook in the settings
+// file, or nil when there is none.
+func loadDiscord() (appdownloads.Notifier, error) {
+	store, err := appstorage.NewStateStore()
+	if err != nil {
+		return nil, err
+	}
+	state, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return appdownloads.ParseDiscordWebhook(state.Settings.DiscordWebhook)
+}
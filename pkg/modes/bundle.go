@@ -0,0 +1,44 @@
+package modes
+
+import (
+	"cmp"
+	"errors"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+// runBundle packages the submissions of the bundle subcommand from the
+// library at root into a zip to share.
+func runBundle(config flags.Config, root string) {
+	index, err := library.Open(root)
+	if err != nil {
+		fatal(ExitFailure, "failed to load download history", "library", root, "err", err)
+	}
+
+	var found []string
+	for _, id := range config.Bundle {
+		if len(index.Submission(id)) == 0 {
+			log.Warn("Submission is not in the library, leaving it out of the bundle", "submission", id)
+			continue
+		}
+		found = append(found, id)
+	}
+
+	destination := cmp.Or(config.BundleOut, "bundle-"+time.Now().Format("20060102-150405")+".zip")
+	bundled, err := appdownloads.WriteBundle(index, found, destination, config.BundleStrip)
+	if errors.Is(err, appdownloads.ErrBundleEmpty) {
+		fatal(ExitFailure, "Nothing to bundle, none of the submissions have files in the library", "library", root)
+	}
+	if err != nil {
+		fatal(ExitFailure, "failed to write bundle", "bundle", destination, "err", err)
+	}
+	if bundled < len(config.Bundle) {
+		setExitCode(ExitPartial)
+	}
+	log.Info("Wrote bundle", "bundle", destination, "submissions", bundled, "of", len(config.Bundle))
+}
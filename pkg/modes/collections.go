@@ -0,0 +1,43 @@
+package modes
+
+import (
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+// loadCollections returns the collections in the settings file.
+func loadCollections() ([]apptypes.Collection, error) {
+	store, err := appstorage.NewStateStore()
+	if err != nil {
+		return nil, err
+	}
+	state, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	return state.Settings.Collections, nil
+}
+
+// placeCollections fills the folder of every collection in settings with what
+// the library holds that matches it.
+func placeCollections(index *library.Index, root string, settings []apptypes.Collection) {
+	if len(settings) == 0 || index == nil {
+		return
+	}
+	collections, err := appdownloads.ParseCollections(settings)
+	if err != nil {
+		log.Error("Failed to read the collections in the settings file", "err", err)
+		return
+	}
+	for _, collection := range collections {
+		files, err := appdownloads.PlaceCollection(index, root, collection)
+		if err != nil {
+			log.Warn("Failed to place some files of a collection", "collection", collection.Name, "err", err)
+		}
+		log.Info("Placed collection", "collection", collection.Name, "files", files)
+	}
+}
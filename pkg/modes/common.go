@@ -8,6 +8,7 @@ import (
 	"os"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/huh"
 	"github.com/charmbracelet/huh/spinner"
@@ -16,17 +17,48 @@ import (
 
 	"github.com/ellypaws/inkbunny"
 
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/utils"
 )
 
 const sidFile = "sid.txt"
 
-func InitLogging() func() {
-	restore := utils.LogOutput(os.Stdout)
+// currentRun is the run folder of this invocation, nil if it could not be created.
+var currentRun *appstorage.Run
+
+// InitLogging starts a run folder for the library selected by config, logging
+// to its log.txt, and removes the oldest runs beyond --keep-runs.
+func InitLogging(config flags.Config) func() {
+	// Checking on another run only prints, so it does not take up a run folder.
+	if config.Status {
+		restore := utils.LogOutput(os.Stdout, "")
+		setupLogger()
+		return restore
+	}
+
+	logPath := "log.txt"
+	run, runErr := appstorage.NewRun()
+	if runErr == nil {
+		currentRun = run
+		logPath = run.Path("log.txt")
+	}
+	restore := utils.LogOutput(os.Stdout, logPath)
+	setupLogger()
+	if runErr != nil {
+		log.Warn("failed to create run folder, logging to the current directory", "err", runErr)
+		return restore
+	}
+	if _, err := appstorage.PruneRuns(config.KeepRuns, currentRun); err != nil {
+		log.Warn("failed to remove old runs", "err", err)
+	}
+	return restore
+}
+
+func setupLogger() {
 	log.SetLevel(log.DebugLevel)
 	log.SetReportTimestamp(true)
 	log.SetColorProfile(termenv.TrueColor)
-	return restore
 }
 
 func loadSession() (*inkbunny.User, error) {
@@ -71,7 +103,7 @@ func normalizedRatingsMask(mask string) string {
 	return base
 }
 
-func syncUserRatingsMask(user *inkbunny.User, mask string) (bool, error) {
+func syncUserRatingsMask(user *inkbunny.User, mask string, reason string) (bool, error) {
 	if user == nil || user.SID == "" {
 		return false, nil
 	}
@@ -94,6 +126,7 @@ func syncUserRatingsMask(user *inkbunny.User, mask string) (bool, error) {
 	}
 
 	user.Ratings = ratings
+	recordRatingsChange(user, currentMask, targetMask, reason)
 	if shouldPersistSession(user) {
 		if err := saveSession(user); err != nil {
 			log.Warn("failed to save session after ratings update", "err", err)
@@ -228,11 +261,16 @@ func changeRatings(user *inkbunny.User) error {
 		Action(func() {
 			err = user.ChangeRatings(ratings)
 		}).Run()
+	if err != nil {
+		return err
+	}
 
-	return err
+	recordRatingsChange(user, user.Ratings.String(), ratings.String(), "guest agreement")
+	user.Ratings = ratings
+	return nil
 }
 
-func prepareGuestSession(user *inkbunny.User, allowInteractive bool) func() {
+func prepareGuestSession(config flags.Config, user *inkbunny.User, allowInteractive bool) func() {
 	if user == nil {
 		return func() {}
 	}
@@ -240,12 +278,8 @@ func prepareGuestSession(user *inkbunny.User, allowInteractive bool) func() {
 		return func() {}
 	}
 
-	if allowInteractive {
-		if err := changeRatings(user); err != nil {
-			log.Fatal("failed to change ratings", "err", err)
-		}
-	} else {
-		log.Info("Running headless with a guest session; default guest ratings will be used")
+	if err := applyGuestRatings(user, allowInteractive && !config.Guest, config.GuestRatings); err != nil {
+		fatal(ExitFailure, "failed to change ratings", "err", err)
 	}
 
 	return func() {
@@ -253,13 +287,34 @@ func prepareGuestSession(user *inkbunny.User, allowInteractive bool) func() {
 		spinner.New().
 			Title(fmt.Sprintf("Logging out %q...", user.Username)).
 			Action(func() {
-				err = user.Logout()
+				err = logoutWithin(user, config.LogoutTimeout)
 			}).Run()
 		if err != nil {
-			log.Fatal("failed to logout", "err", err)
+			// The run is over by now, so a failed logout only leaves a guest
+			// session to expire on its own unless asked to fail the run.
+			if config.StrictLogout {
+				fatal(ExitFailure, "Failed to logout", "err", err)
+			}
+			log.Warn("failed to logout, the session expires on its own", "err", err)
+			return
 		}
 		if err := os.Remove(sidFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
 			log.Warn("failed to remove session file", "err", err)
 		}
 	}
 }
+
+var errLogoutTimeout = errors.New("logout timed out")
+
+// logoutWithin logs user out, giving up after timeout. The request itself
+// cannot be cancelled, so it is left to finish in the background.
+func logoutWithin(user *inkbunny.User, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() { done <- user.Logout() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("%w after %s", errLogoutTimeout, timeout)
+	}
+}
@@ -0,0 +1,58 @@
+package modes
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+)
+
+// treeDuplicates finds files by their MD5 among those already in the download
+// folder, including files the history does not know of, such as ones saved by
+// hand or by another tool under a different name. A nil *treeDuplicates finds
+// nothing. It is only read once the workers start.
+type treeDuplicates struct {
+	paths map[string]string
+}
+
+// hashDownloadTree hashes every file under root once, before anything is
+// downloaded, as hashing a large library takes a while. The cycles of a watch
+// keep the hashes in watch, so that each one only hashes new or changed files.
+func hashDownloadTree(root string, enabled bool, watch *watchState) *treeDuplicates {
+	if !enabled {
+		return nil
+	}
+	started := time.Now()
+	var previous []library.HashedFile
+	if watch != nil {
+		previous = watch.tree
+	}
+	files, err := library.RehashFolder(context.Background(), root, previous)
+	if err != nil {
+		log.Warn("failed to hash the download folder, only the history is checked for duplicates", "err", err)
+		return nil
+	}
+	tree := &treeDuplicates{paths: make(map[string]string, len(files))}
+	for _, file := range files {
+		if _, ok := tree.paths[file.MD5]; !ok {
+			tree.paths[file.MD5] = file.Path
+		}
+	}
+	if watch != nil {
+		watch.tree = files
+	}
+	log.Info("Hashed download folder", "files", len(files), "took", time.Since(started).Round(time.Millisecond))
+	return tree
+}
+
+// Find returns a file under the download folder with the MD5 md5.
+func (t *treeDuplicates) Find(md5 string) (string, bool) {
+	if t == nil || md5 == "" {
+		return "", false
+	}
+	path, ok := t.paths[strings.ToLower(strings.TrimSpace(md5))]
+	return path, ok
+}
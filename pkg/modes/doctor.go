@@ -0,0 +1,193 @@
+package modes
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/utils"
+)
+
+const (
+	// doctorTimeout is how long doctor waits for each host to answer.
+	doctorTimeout = 15 * time.Second
+	// doctorLowSpace is the free space below which doctor warns.
+	doctorLowSpace = 1 << 30
+)
+
+// doctor reports the result of each check, with how to fix the ones that did
+// not pass.
+type doctor struct {
+	warnings int
+	failures int
+}
+
+func (d *doctor) pass(check string, keyvals ...any) {
+	log.Info("ok: "+check, keyvals...)
+}
+
+func (d *doctor) warn(check string, fix string, keyvals ...any) {
+	d.warnings++
+	log.Warn(check, append(keyvals, "fix", fix)...)
+}
+
+func (d *doctor) fail(check string, fix string, keyvals ...any) {
+	d.failures++
+	log.Error(check, append(keyvals, "fix", fix)...)
+}
+
+// runDoctor checks what a run needs: that Inkbunny and its file servers can
+// be reached, that the session works, that root can be written to and has
+// room, and that --limit-rate reads as fast as it says.
+func runDoctor(config flags.Config, root string) {
+	var d doctor
+	client := &http.Client{Timeout: doctorTimeout}
+
+	d.reach(client, "Inkbunny API", "https://inkbunny.net/api_login.php")
+	for _, host := range utils.FileHosts {
+		d.reach(client, "file server "+host, "https://"+host+"/")
+	}
+	d.session(config)
+	d.library(root)
+	for _, tier := range config.StorageTiers {
+		d.library(tier.Root)
+	}
+	d.rateLimit(config)
+
+	switch {
+	case d.failures > 0:
+		log.Error("Doctor found problems that will stop runs", "failures", d.failures, "warnings", d.warnings)
+		setExitCode(ExitFailure)
+	case d.warnings > 0:
+		log.Warn("Doctor found nothing that stops runs, but has warnings", "warnings", d.warnings)
+	default:
+		log.Info("Doctor found no problems")
+	}
+}
+
+// reach checks that url answers. Any answer counts, as only the connection is
+// being checked.
+func (d *doctor) reach(client *http.Client, name string, url string) {
+	start := time.Now()
+	response, err := client.Head(url)
+	if err != nil {
+		d.fail("Cannot reach "+name, "check your connection, DNS, proxy (HTTPS_PROXY) and firewall", "url", url, "err", err)
+		return
+	}
+	_ = response.Body.Close()
+	latency := time.Since(start).Round(time.Millisecond)
+	if response.StatusCode >= 500 {
+		d.warn(name+" is having trouble", "try again later", "url", url, "status", response.Status)
+		return
+	}
+	d.pass(name+" can be reached", "latency", latency.String())
+}
+
+// session checks the credentials or saved session a run would use. A session
+// it logs in for is logged out again, as nothing saves or uses it.
+func (d *doctor) session(config flags.Config) {
+	user, source, _, err := authenticateUser(config, false)
+	if err != nil {
+		d.fail("Cannot log in", "run once with --username and --password to save a session, or use --username guest", "err", err)
+		return
+	}
+	if source == authSourceProvidedCredentials {
+		defer func() {
+			if err := logoutWithin(user, config.LogoutTimeout); err != nil {
+				log.Warn("failed to logout, the session expires on its own", "err", err)
+			}
+		}()
+	}
+	if strings.EqualFold(user.Username, "guest") {
+		d.pass("Guest session", "source", source)
+		return
+	}
+	_, err = user.GetWatching()
+	if err, ok := errors.AsType[inkbunny.ErrorResponse](err); ok && err.Code != nil && *err.Code == inkbunny.ErrInvalidSessionID {
+		d.fail("Session has expired", "log in again with --username and --password", "source", source)
+		return
+	}
+	if err != nil {
+		d.warn("Could not check the session", "check that the Inkbunny API can be reached", "source", source, "err", err)
+		return
+	}
+	d.pass("Session is valid", "username", user.Username, "source", source)
+}
+
+// library checks that files can be written to root and that it has room.
+func (d *doctor) library(root string) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		d.fail("Cannot create the download directory", "pick another one with --library-dir or fix its permissions", "root", root, "err", err)
+		return
+	}
+	probe, err := os.CreateTemp(root, ".inkbunny-doctor-*")
+	if err == nil {
+		_, err = probe.WriteString("doctor")
+		err = errors.Join(err, probe.Close(), os.Remove(probe.Name()))
+	}
+	if err != nil {
+		d.fail("Cannot write to the download directory", "fix its permissions or pick another one with --library-dir", "root", root, "err", err)
+		return
+	}
+	d.pass("Download directory is writable", "root", root)
+
+	free, err := appstorage.FreeSpace(root)
+	switch {
+	case err != nil:
+		d.warn("Could not check the free space", "check that the download directory has room", "root", root, "err", err)
+	case free < doctorLowSpace:
+		d.warn("Download directory is low on space", "free some space or move the library with --library-dir", "root", root, "free", appdownloads.FormatSize(float64(free)))
+	default:
+		d.pass("Download directory has room", "root", root, "free", appdownloads.FormatSize(float64(free)))
+	}
+}
+
+// rateLimit reads through the limiter of --limit-rate for about half a second
+// after its burst and compares how fast that went with the limit.
+func (d *doctor) rateLimit(config flags.Config) {
+	if config.LimitRate <= 0 {
+		d.pass("No --limit-rate, downloads read at full speed", "connections", config.Connections)
+		return
+	}
+	limiter := appdownloads.NewRateLimiter(config.LimitRate)
+	burst := max(config.LimitRate/10, 32<<10)
+	measured := config.LimitRate / 2
+
+	reader := limiter.Reader(io.NopCloser(io.LimitReader(zeroReader{}, burst+measured)))
+	start := time.Now()
+	_, err := io.Copy(io.Discard, reader)
+	elapsed := time.Since(start)
+	if err != nil || elapsed <= 0 {
+		d.warn("Could not measure --limit-rate", "try running again", "err", err)
+		return
+	}
+	rate := float64(measured) / elapsed.Seconds()
+	keyvals := []any{"limit", appdownloads.FormatSize(float64(config.LimitRate)) + "/s", "measured", appdownloads.FormatSize(rate) + "/s"}
+	if rate > float64(config.LimitRate)*1.2 || rate < float64(config.LimitRate)*0.8 {
+		d.warn("--limit-rate does not read at the rate it is set to", "check that the machine is not overloaded", keyvals...)
+		return
+	}
+	if connections := max(config.Connections, 1); config.LimitRate/int64(connections) < 16<<10 {
+		d.warn("--limit-rate leaves each connection under 16K/s", "raise --limit-rate or lower --connections", append(keyvals, "connections", connections)...)
+		return
+	}
+	d.pass("--limit-rate reads at its limit", keyvals...)
+}
+
+// zeroReader reads zeros without end.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
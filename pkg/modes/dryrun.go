@@ -0,0 +1,69 @@
+package modes
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+)
+
+// dryRun lists the files a run would download instead of downloading them. A
+// nil *dryRun lists nothing.
+type dryRun struct {
+	files, bytes, unsized atomic.Int64
+}
+
+func newDryRun(enabled bool) *dryRun {
+	if !enabled {
+		return nil
+	}
+	log.Info("Dry run, listing the files that would be downloaded without writing any")
+	return &dryRun{}
+}
+
+// List logs the file that would be downloaded from url to filename, with its
+// size when the server tells it. It reports whether the run is a dry run, in
+// which case the file is to be left alone.
+func (d *dryRun) List(client *http.Client, filename, url, sidURL string) bool {
+	if d == nil {
+		return false
+	}
+	d.files.Add(1)
+	size := remoteSize(client, sidURL)
+	if size < 0 {
+		d.unsized.Add(1)
+		log.Info("Would download", "file", filename, "url", url)
+		return true
+	}
+	d.bytes.Add(size)
+	log.Info("Would download", "file", filename, "url", url, "size", appdownloads.FormatSize(float64(size)))
+	return true
+}
+
+// Report logs how much the run would have downloaded.
+func (d *dryRun) Report() {
+	if d == nil {
+		return
+	}
+	keyvals := []any{"files", d.files.Load(), "size", appdownloads.FormatSize(float64(d.bytes.Load()))}
+	if unsized := d.unsized.Load(); unsized > 0 {
+		keyvals = append(keyvals, "without a size", unsized)
+	}
+	log.Info("Would download", keyvals...)
+}
+
+// remoteSize asks for the size of the file at url without downloading it, or
+// returns -1 when the server does not tell.
+func remoteSize(client *http.Client, url string) int64 {
+	resp, err := client.Head(url)
+	if err != nil {
+		return -1
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return -1
+	}
+	return resp.ContentLength
+}
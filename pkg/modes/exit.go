@@ -0,0 +1,87 @@
+package modes
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+)
+
+// Exit codes tell wrapper scripts and schedulers how a run ended without
+// parsing its log. Invalid flags exit with ExitUsage from flags.Parse, and any
+// other error that stops the run exits with ExitFailure.
+const (
+	ExitSuccess = 0
+	ExitFailure = 1
+	ExitUsage   = 2
+	// ExitPartial means the run finished but some files failed to download.
+	ExitPartial = 3
+	ExitAuth    = 4
+	// ExitNothingMatched means the search found nothing to download.
+	ExitNothingMatched = 5
+	// ExitAborted means the user stopped the run, matching how shells report
+	// an interrupt.
+	ExitAborted = 130
+)
+
+var exitCode = ExitSuccess
+
+// exitHooks are run by fatal before it exits, as os.Exit skips deferred calls.
+var exitHooks = struct {
+	mu    sync.Mutex
+	hooks []*exitHook
+}{}
+
+type exitHook struct {
+	once sync.Once
+	run  func()
+}
+
+// atExit returns hook to be deferred by the caller, and also has fatal run it
+// when the run exits before the deferred call. It runs at most once, and is
+// forgotten by fatal once the deferred call has run it.
+func atExit(hook func()) func() {
+	registered := &exitHook{run: hook}
+	exitHooks.mu.Lock()
+	exitHooks.hooks = append(exitHooks.hooks, registered)
+	exitHooks.mu.Unlock()
+	return func() {
+		exitHooks.mu.Lock()
+		exitHooks.hooks = slices.DeleteFunc(exitHooks.hooks, func(hook *exitHook) bool { return hook == registered })
+		exitHooks.mu.Unlock()
+		registered.once.Do(registered.run)
+	}
+}
+
+// ExitCode returns how the run ended. When the terminal UI restarts a search,
+// the last search decides the code.
+func ExitCode() int {
+	return exitCode
+}
+
+func setExitCode(code int) {
+	exitCode = code
+}
+
+// fatal logs msg as an error and exits with code, like log.Fatal, after
+// running the hooks of atExit. A long run also shows that it failed on the
+// desktop.
+func fatal(code int, msg any, keyvals ...any) {
+	log.Error(msg, keyvals...)
+	notifyLongRun(runStarted, appdownloads.Notification{
+		Title:   "Inkbunny Downloader failed",
+		Message: fmt.Sprint(msg),
+		Level:   appdownloads.NotifyError,
+	})
+	exitHooks.mu.Lock()
+	hooks := slices.Clone(exitHooks.hooks)
+	exitHooks.mu.Unlock()
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hooks[i].once.Do(hooks[i].run)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,64 @@
+package modes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+)
+
+// exportSearch writes every result of the search of request, whose first page
+// is firstPage, to path instead of downloading it, so that it can be reviewed
+// or handed to other tools first.
+func exportSearch(user *inkbunny.User, request inkbunny.SubmissionSearchRequest, firstPage inkbunny.SubmissionSearchResponse, path string) {
+	var rows []appdownloads.ExportRow
+	addPage := func(page inkbunny.IntString, results []inkbunny.SubmissionSearch) {
+		if len(results) == 0 {
+			return
+		}
+		ids := make([]string, len(results))
+		for i, result := range results {
+			ids[i] = result.SubmissionID.String()
+		}
+		what := fmt.Sprintf("details of page %d", page)
+		details, err := retryAPIStep(nil, what, func(ctx context.Context) (inkbunny.SubmissionDetailsResponse, error) {
+			return user.SubmissionDetailsContext(ctx, inkbunny.SubmissionDetailsRequest{SID: user.SID, SubmissionIDSlice: ids})
+		})
+		if err != nil {
+			log.Error("Failed to get submission details, leaving the page out of the export", "page", page, "err", err)
+			setExitCode(ExitPartial)
+			return
+		}
+		for _, submission := range details.Submissions {
+			rows = append(rows, appdownloads.NewExportRow(submission))
+		}
+	}
+
+	addPage(firstPage.Page, firstPage.Submissions)
+	followUpRequest := request
+	followUpRequest.SID = user.SID
+	followUpRequest.GetRID = inkbunny.No
+	followUpRequest.RID = firstPage.RID
+	for page := firstPage.Page + 1; page <= firstPage.PagesCount; page++ {
+		followUpRequest.Page = page
+		what := fmt.Sprintf("search page %d of %d", page, firstPage.PagesCount)
+		results, err := retryAPIStep(nil, what, func(ctx context.Context) (inkbunny.SubmissionSearchResponse, error) {
+			return user.SearchSubmissionsContext(ctx, followUpRequest)
+		})
+		if err != nil {
+			log.Error("Failed to search submissions, leaving the page out of the export", "page", page, "err", err)
+			setExitCode(ExitPartial)
+			continue
+		}
+		addPage(page, results.Submissions)
+	}
+
+	if err := appdownloads.WriteExport(path, rows); err != nil {
+		fatal(ExitFailure, "Failed to write the export", "path", path, "err", err)
+	}
+	log.Info("Exported the search results without downloading them", "path", path, "submissions", len(rows))
+}
@@ -0,0 +1,76 @@
+package modes
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+var errFavoritesNeedLogin = errors.New("downloading your own favorites needs a logged in account, or name a user as in: favorites <username>")
+
+// favoritesConfig turns config into the search of the favorites subcommand:
+// everything favorited by the named user, or by user when nobody is named,
+// most recently favorited first.
+func favoritesConfig(config flags.Config, user *inkbunny.User) (flags.Config, error) {
+	if config.FavBy == "" {
+		if user == nil || user.SID == "" || strings.EqualFold(user.Username, "guest") {
+			return config, errFavoritesNeedLogin
+		}
+		config.FavBy = user.Username
+	}
+	config.ArtistName = ""
+	config.SearchWords = ""
+	config.TimeRange = 0
+	config.SubmissionTypes = nil
+	config.OrderBy = inkbunny.OrderByFavDatetime
+	return config, nil
+}
+
+// favoritesRequest widens request to every favorite, whatever its type, age
+// or artist.
+func favoritesRequest(request *inkbunny.SubmissionSearchRequest) {
+	request.Text = ""
+	request.Username = ""
+	request.UserID = 0
+	request.DaysLimit = 0
+	request.PoolID = 0
+	request.Type = []inkbunny.SubmissionType{inkbunny.SubmissionTypeAny}
+	request.Scraps = inkbunny.ScrapsBoth
+	request.OrderBy = inkbunny.OrderByFavDatetime
+	request.UnreadSubmissions = inkbunny.No
+}
+
+// favoritesProgress reports each page of favorites as it is queued, since a
+// long list of them takes a while to fetch before much is downloaded. A nil
+// *favoritesProgress reports nothing.
+type favoritesProgress struct {
+	owner  string
+	total  inkbunny.IntString
+	queued int
+}
+
+func newFavoritesProgress(config flags.Config, total inkbunny.IntString) *favoritesProgress {
+	if !config.Favorites {
+		return nil
+	}
+	log.Info("Downloading favorites", "user", config.FavBy, "submissions", total)
+	return &favoritesProgress{owner: config.FavBy, total: total}
+}
+
+func (p *favoritesProgress) page(page, pages inkbunny.IntString, submissions int) {
+	if p == nil {
+		return
+	}
+	p.queued += submissions
+	log.Info("Queued favorites",
+		"user", p.owner,
+		"page", fmt.Sprintf("%d/%d", page, pages),
+		"submissions", fmt.Sprintf("%d/%d", p.queued, p.total),
+	)
+}
@@ -0,0 +1,103 @@
+package modes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+)
+
+var ratingsMaskLabels = [5]string{"General", "Nudity", "Mild Violence", "Sexual", "Strong Violence"}
+
+func loadGuestRatingsMask() string {
+	store, err := appstorage.NewStateStore()
+	if err != nil {
+		return ""
+	}
+	state, err := store.Load()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(state.Settings.GuestRatingsMask)
+}
+
+func saveGuestRatingsMask(mask string) error {
+	store, err := appstorage.NewStateStore()
+	if err != nil {
+		return err
+	}
+	state, err := store.Load()
+	if err != nil {
+		return err
+	}
+	state.Settings.GuestRatingsMask = normalizedRatingsMask(mask)
+	state.Session.Settings = state.Settings
+	return store.Save(state)
+}
+
+func describeRatingsMask(mask string) string {
+	mask = normalizedRatingsMask(mask)
+	labels := make([]string, 0, len(ratingsMaskLabels))
+	for i, label := range ratingsMaskLabels {
+		if mask[i] == '1' {
+			labels = append(labels, label)
+		}
+	}
+	return strings.Join(labels, ", ")
+}
+
+// applyGuestRatings agrees to the ratings of mask when it is set, as given to
+// --ratings, and otherwise reuses the ratings a guest agreed to on a previous
+// run, or walks the agreement form and remembers the answer for next time.
+func applyGuestRatings(user *inkbunny.User, allowInteractive bool, mask string) error {
+	if mask != "" {
+		log.Info("Using a guest session with the ratings of --ratings", "ratings", describeRatingsMask(mask))
+		if _, err := syncUserRatingsMask(user, mask, "--ratings"); err != nil {
+			return err
+		}
+		if err := saveGuestRatingsMask(mask); err != nil {
+			log.Warn("failed to save guest ratings", "err", err)
+		}
+		return nil
+	}
+	saved := loadGuestRatingsMask()
+	if !allowInteractive {
+		if saved == "" {
+			log.Info("Using a guest session; default guest ratings will be used")
+			return nil
+		}
+		log.Info("Using a guest session with the saved guest ratings", "ratings", describeRatingsMask(saved))
+		_, err := syncUserRatingsMask(user, saved, "saved guest ratings")
+		return err
+	}
+
+	if saved != "" {
+		reuse := true
+		if err := huh.NewForm(huh.NewGroup(huh.NewConfirm().
+			Title("Reuse your saved guest ratings?").
+			Description(fmt.Sprintf("Last time you chose: %s", describeRatingsMask(saved))).
+			Affirmative("Reuse").
+			Negative("Choose again").
+			Value(&reuse),
+		)).Run(); err != nil {
+			return err
+		}
+		if reuse {
+			_, err := syncUserRatingsMask(user, saved, "saved guest ratings")
+			return err
+		}
+	}
+
+	if err := changeRatings(user); err != nil {
+		return err
+	}
+	if err := saveGuestRatingsMask(user.Ratings.String()); err != nil {
+		log.Warn("failed to save guest ratings", "err", err)
+	}
+	return nil
+}
@@ -1,7 +1,6 @@
 package modes
 
 import (
-	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,9 +8,10 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"runtime"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -20,43 +20,183 @@ import (
 
 	"github.com/ellypaws/inkbunny"
 
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flight"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/utils"
 )
 
 func RunHeadless(config flags.Config) {
+	notifyAfter = config.NotifyAfter
+	if config.Status {
+		runStatus(headlessRoot(config))
+		return
+	}
+	if config.Clean {
+		runClean()
+		return
+	}
+	if config.MD5Lookup != "" {
+		runMD5Lookup(config)
+		return
+	}
+	config, err := presetLibraryDir(config)
+	if err != nil {
+		fatal(ExitFailure, "failed to load preset", "err", err)
+	}
+	root := headlessRoot(config)
+	if config.Simulate != "" {
+		root = simulationRoot(config.Simulate)
+		log.Info("Simulating the run without network requests", "recordings", config.Simulate, "files", root)
+	}
+	if len(config.Why) > 0 {
+		runWhy(config, root)
+		return
+	}
+	if config.Doctor {
+		runDoctor(config, root)
+		return
+	}
+	if len(config.Bundle) > 0 {
+		runBundle(config, root)
+		return
+	}
+	defer lockLibrary(config, root)()
+	if config.LibraryCommand() {
+		runLibraryCommands(config, root)
+		return
+	}
+	if config.Adopt != "" {
+		layout := appdownloads.Layout{Collabs: config.Collabs, Characters: appdownloads.ParseCharacters(config.Characters), TimeZone: config.TimeZone}
+		runAdopt(config, root, config.Pattern, layout)
+		return
+	}
+	if config.Upgrade {
+		runUpgrade(config, root)
+		return
+	}
+	if config.Lint {
+		runLint(config, root, config.Sidecars)
+		return
+	}
+	if len(config.PoolIDs) > 0 {
+		runPoolTargets(config, root)
+		return
+	}
+	if config.Watch {
+		runWatch(config, root)
+		return
+	}
+	downloadSearch(config, root, nil)
+}
+
+// downloadSearch runs the search of config and downloads what it finds into
+// root. A watch cycle only downloads what is newer than the cycles before it.
+func downloadSearch(config flags.Config, root string, watch *watchState) {
+	started := time.Now()
 	var (
 		request      inkbunny.SubmissionSearchRequest
 		searchIn     []int
 		favBy        string
 		maxDownloads string
 
-		toDownload      int
-		downloadCaption bool
-		downloaded      atomic.Int64
-		firstPage       inkbunny.SubmissionSearchResponse
+		toDownload int
+		sidecars   apptypes.SidecarOptions
+		downloaded atomic.Int64
+		// processed counts the submissions gone through, and submissions
+		// those that downloaded at least one file.
+		processed   atomic.Int64
+		submissions atomic.Int64
+		skipped     atomic.Int64
+		firstPage   inkbunny.SubmissionSearchResponse
+		// artists counts the files downloaded of each artist.
+		artists   = make(map[string]int)
+		artistsMu sync.Mutex
 	)
+	var runPreset *apptypes.SyncPreset
+	if config.Preset != "" {
+		preset, err := loadPreset(config.Preset)
+		if config.RunPreset != nil {
+			preset, err = *config.RunPreset, nil
+		}
+		if err != nil {
+			fatal(ExitFailure, "failed to load preset", "err", err)
+		}
+		if err := headlessPreset(config, preset); err != nil {
+			fatal(ExitUsage, "failed to run preset", "err", err)
+		}
+		runPreset = &preset
+		config = applyPreset(config, preset)
+		if artist, _, several := strings.Cut(config.ArtistName, ","); several {
+			log.Warn("Headless mode searches a single artist, using the first one of the preset", "artist", artist)
+			config.ArtistName = strings.TrimSpace(artist)
+		}
+	}
+	var mirror *library.Mirror
+	if config.Mirror != "" {
+		config = mirrorConfig(config)
+		mirror = openMirror(root, config.Mirror)
+	} else if watch != nil {
+		mirror = library.NewMirror(watch.since)
+	}
+	defer atExit(func() { restoreRatingsOnExit(config.RestoreRatings) })()
+	var usage runUsage
+	tracer := newHTTPTracer(config.TraceHTTP, config.TraceHTTPMin)
+	defer tracer.Report()
+	throttle := newThrottle()
+	inkbunny.DefaultClient.SetClient(throttle.Client(usage.Client(tracer.Client(simulationClient(config, &http.Client{Timeout: 5 * time.Minute}))), "API requests"))
 
 Login:
-	user, source, persistSession, err := authenticateUser(config, false)
-	if err != nil {
-		log.Fatal("Failed to authenticate", "err", err)
+	var err error
+	user, source, reused := watch.session()
+	if !reused {
+		var persistSession bool
+		user, source, persistSession, err = authenticateUser(config, false)
+		if err != nil {
+			if watch != nil {
+				log.Error("Failed to authenticate, the next cycle tries once more", "err", err)
+				watch.failed = true
+				return
+			}
+			fatal(ExitAuth, "Failed to authenticate", "err", err)
+		}
+		if persistSession {
+			if err := saveSession(user); err != nil {
+				log.Warn("failed to save session", "err", err)
+			}
+		}
+		logAuthenticatedUser(user, source)
 	}
-	if persistSession {
-		if err := saveSession(user); err != nil {
-			log.Warn("failed to save session", "err", err)
+	if config.Favorites {
+		if config, err = favoritesConfig(config, user); err != nil {
+			fatal(ExitUsage, "Failed to download favorites", "err", err)
 		}
 	}
-	logAuthenticatedUser(user, source)
 
-	cleanup := prepareGuestSession(user, false)
-	defer cleanup()
+	if !reused {
+		cleanup := prepareGuestSession(config, user, false)
+		// A watch keeps its session for the next cycle instead.
+		if watch == nil {
+			defer cleanup()
+		}
+	}
+	watch.keep(user, source)
 
 	usernameCache := flight.NewCache(func(_ context.Context, query string) ([]inkbunny.Autocomplete, error) {
 		return user.SearchMembers(query)
 	})
-	config.ApplyTo(&request, &searchIn, &favBy, &maxDownloads, nil, &downloadCaption)
+	config.ApplyTo(&request, &searchIn, &favBy, &maxDownloads, nil, &sidecars)
+	if config.Mirror != "" {
+		mirrorRequest(&request)
+	}
+	if config.Favorites {
+		favoritesRequest(&request)
+	}
+	if len(config.PoolIDs) > 0 {
+		poolRequest(&request, config.PoolIDs[0])
+	}
 
 	request.SearchInKeywords = nil
 	request.Title = nil
@@ -83,12 +223,18 @@ Login:
 				request.FavsUserID = v.ID
 			}
 		}
+		if config.Favorites && strings.EqualFold(favBy, user.Username) {
+			request.FavsUserID = user.UserID
+		}
+		if config.Favorites && request.FavsUserID == 0 {
+			fatal(ExitUsage, "Failed to find the user to download the favorites of", "user", favBy)
+		}
 	}
 
 	if maxDownloads != "" {
 		toDownload, err = strconv.Atoi(maxDownloads)
 		if err != nil {
-			log.Fatal(err)
+			fatal(ExitFailure, err)
 		}
 	}
 
@@ -105,151 +251,766 @@ Login:
 		}
 	}
 
+	recordRun(config, runPreset, request)
+
 	spinner.New().
 		Title("Searching...").
 		Action(func() {
-			for page, pageErr := range request.AllPages() {
-				if pageErr != nil {
-					err = pageErr
-					return
-				}
-				firstPage = page
-				return
-			}
+			firstPage, err = retryAPIStep(nil, "search", func(ctx context.Context) (inkbunny.SubmissionSearchResponse, error) {
+				return user.SearchSubmissionsContext(ctx, request)
+			})
 		}).Run()
 	if err != nil {
 		if err, ok := errors.AsType[inkbunny.ErrorResponse](err); ok && err.Code != nil && *err.Code == inkbunny.ErrInvalidSessionID {
 			invalidateAuthSource(&config, source)
+			watch.keep(nil, "")
 			log.Warn("Session expired, please login again")
 			goto Login
 		}
-		log.Fatal("failed to search submissions", "err", err)
+		if watch != nil {
+			log.Error("failed to search submissions, trying again next cycle", "err", err)
+			watch.failed = true
+			return
+		}
+		fatal(ExitFailure, "failed to search submissions", "err", err)
 	}
 	log.Infof("Total number of submissions: %d", firstPage.ResultsCountAll)
+	if config.Export != "" {
+		exportSearch(user, request, firstPage, config.Export)
+		return
+	}
+	if config.ArtistProfile && config.ArtistName != "" && !config.DryRun {
+		artist := config.ArtistName
+		if len(firstPage.Submissions) > 0 && strings.EqualFold(firstPage.Submissions[0].Username, artist) {
+			artist = firstPage.Submissions[0].Username
+		}
+		profileClient := throttle.Client(usage.Client(tracer.Client(simulationClient(config, &http.Client{Timeout: time.Minute}))), "artist profiles")
+		saveArtistProfile(profileClient, appdownloads.ArtistDirectory(root, config.Pattern, artist), artist, request.UserID)
+	}
+	if firstPage.ResultsCountAll == 0 {
+		setExitCode(ExitNothingMatched)
+		return
+	}
 	if toDownload > 0 {
 		log.Infof("To download: %d", toDownload)
 	} else {
 		log.Info("To download: Unlimited")
 	}
 
-	client := &http.Client{Timeout: 5 * time.Minute}
-	downloader := utils.NewWorkerPool(runtime.NumCPU(), func(details inkbunny.SubmissionDetails) error {
+	skipLog := utils.NewSkipLog(config.SkipLog)
+	index, err := library.Open(root)
+	if err != nil {
+		log.Warn("failed to load download history", "err", err)
+	}
+	warnInvalidIgnores(index)
+	if !config.DryRun {
+		migrateLibrary(index, false)
+	}
+	skips := skipRecorder{log: skipLog, index: index, record: !config.DryRun, files: &skipped}
+	var booru appdownloads.Booru
+	if config.Booru && !config.DryRun {
+		if booru, err = loadBooru(); err != nil {
+			fatal(ExitUsage, "Failed to load the booru to upload to, set booru in the settings file", "err", err)
+		}
+	}
+	var rclone *appdownloads.Rclone
+	if config.Rclone && !config.DryRun {
+		if rclone, err = loadRclone(); err != nil {
+			fatal(ExitUsage, "Failed to load the rclone remote to copy to, set rclone in the settings file", "err", err)
+		}
+	}
+	notifiers := config.Notify
+	if !config.DryRun {
+		if discord, err := loadDiscord(); err != nil {
+			log.Warn("Failed to load the Discord webhook from the settings file", "err", err)
+		} else if discord != nil {
+			notifiers = append(slices.Clip(notifiers), discord)
+		}
+	}
+	dryRun := newDryRun(config.DryRun)
+	hosts := newHostFailover()
+	tree := hashDownloadTree(root, config.Dedupe, watch)
+	artistIndex := newArtistIndexes(config.ArtistIndex && !config.DryRun)
+	syncPolicy, _ := appdownloads.ParseSyncPolicy(config.Fsync)
+	syncer := appdownloads.NewFileSyncer(syncPolicy)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxConnsPerHost = config.HostConnections
+	client := appdownloads.NewRateLimiter(config.LimitRate).Client(throttle.Client(usage.Client(tracer.Client(simulationClient(config, &http.Client{Timeout: 5 * time.Minute, Transport: transport}))), "downloads"))
+	openFiles := appdownloads.NewOpenFileLimit(config.MaxOpenFiles)
+	var pools *appdownloads.PoolOrder
+	if config.Pools || len(config.PoolIDs) > 0 {
+		pools = appdownloads.NewPoolOrder()
+	}
+	layout := appdownloads.Layout{
+		Collabs:    config.Collabs,
+		Characters: appdownloads.ParseCharacters(config.Characters),
+		TimeZone:   config.TimeZone,
+		Routes:     config.MimeRoutes,
+		Pools:      pools,
+	}
+	var counters runCounters
+	defer reportStatus(root, counters.progress)()
+	// The producer blocks on a full queue while files download, so finished
+	// files count as progress and the watchdog only acts while nothing downloads.
+	watchdog := watchProducer(func() bool { return counters.active.Load() == 0 })
+	defer watchdog.Stop()
+	offline := newOfflineGate(watchdog.Alive)
+	if config.Simulate != "" {
+		// Nothing is requested from Inkbunny, so there is nothing to wait for.
+		offline = nil
+	}
+	downloader := utils.NewWorkerPool(config.Workers, func(details inkbunny.SubmissionDetails) error {
+		// inFlight is set while a file is downloading, so that returning early counts it as failed.
+		var inFlight bool
+		defer func() {
+			if inFlight {
+				counters.active.Add(-1)
+				counters.failed.Add(1)
+			}
+		}()
+		processed.Add(1)
 		numOfFiles := len(details.Files)
+		submissionURL := fmt.Sprintf("https://inkbunny.net/s/%d", details.SubmissionID)
+		submissionSkip := func(reason, detail string) library.Skip {
+			return library.Skip{SubmissionID: details.SubmissionID.String(), Reason: reason, Detail: detail}
+		}
 		if numOfFiles == 0 {
+			if config.EmptySubmissions != appdownloads.EmptyRecord || config.DryRun {
+				skips.Skip(submissionSkip(library.SkipEmpty, ""), "submissions without files", "Submission has no files", "url", submissionURL)
+				return nil
+			}
+			artistIndex.Add(details.Username)
+			recordEmptySubmission(index, root, config.Pattern, details, layout, sidecars)
 			return nil
 		}
+		artistIndex.Add(details.Username)
 
-		var keywords bytes.Buffer
-		for i, keyword := range details.Keywords {
-			if i > 0 {
-				keywords.WriteString(", ")
+		if language := appdownloads.DetectLanguage(details); !appdownloads.KeepsLanguage(config.Languages, language) {
+			counters.queued.Add(-int64(numOfFiles))
+			skips.SkipSubmission(submissionSkip(library.SkipLanguage, language), numOfFiles, "submissions in other languages", "Submission is in another language", "url", submissionURL, "language", language)
+			return nil
+		}
+		if !appdownloads.KeepsAI(config.AIFilter, details) {
+			counters.queued.Add(-int64(numOfFiles))
+			if config.AIFilter == appdownloads.AIOnly {
+				skips.SkipSubmission(submissionSkip(library.SkipAI, "not AI-generated"), numOfFiles, "submissions not made with AI", "Submission is not tagged as AI-generated", "url", submissionURL)
+			} else {
+				skips.SkipSubmission(submissionSkip(library.SkipAI, "AI-generated"), numOfFiles, "AI-generated submissions", "Submission is tagged as AI-generated", "url", submissionURL)
 			}
-			keywords.WriteString(keyword.KeywordName)
+			return nil
+		}
+		if keyword, ok := appdownloads.BlacklistedKeyword(details, config.Blacklist); ok {
+			counters.queued.Add(-int64(numOfFiles))
+			skips.SkipSubmission(submissionSkip(library.SkipKeyword, keyword), numOfFiles, "submissions with blacklisted keywords", "Submission has a blacklisted keyword", "url", submissionURL, "keyword", keyword)
+			return nil
 		}
-
-		submissionURL := fmt.Sprintf("https://inkbunny.net/s/%d", details.SubmissionID)
 		padding := digitCount(numOfFiles)
 		log.Debug("Downloading submission", "url", submissionURL, "files", numOfFiles)
+		// pages are the files of the submission that are on disk, for its archive.
+		var pages []appdownloads.ArchivePage
+		// uploads are the files downloaded this time, for --output, and
+		// uploaded the history entries of them.
+		var uploads []string
+		var uploaded []library.Entry
+		event := appdownloads.SubmissionEvent{
+			SubmissionID: details.SubmissionID.String(),
+			Artist:       details.Username,
+			Title:        details.Title,
+			URL:          submissionURL,
+		}
 		for i, file := range details.Files {
 			if toDownload > 0 && int(downloaded.Load()) >= toDownload {
 				return nil
 			}
+			counters.queued.Add(-1)
 
-			folder := filepath.Join("inkbunny", details.Username)
-			filename := filepath.Join(folder, filepath.Base(file.FileName))
+			route := appdownloads.Route(config.MimeRoutes, file)
+			place := func(root string) []string {
+				return appdownloads.ResolveLayoutDestinations(root, config.Pattern, details, file, layout)
+			}
+			// The file downloads to the first destination and is linked to the rest.
+			destinations := place(root)
+			filename := destinations[0]
+			folder := filepath.Dir(filename)
+			entry := library.Entry{
+				SubmissionID: details.SubmissionID.String(),
+				FileID:       file.FileID.String(),
+				MD5:          file.FullFileMD5,
+				Artist:       details.Username,
+				Title:        details.Title,
+				Uploaded:     appdownloads.UploadTime(details, file, appdownloads.TimeZoneUTC),
+				Keywords:     library.SubmissionKeywords(details),
+				Paths:        destinations,
+			}
+			fileSkip := func(reason, detail string) library.Skip {
+				return library.Skip{SubmissionID: entry.SubmissionID, FileID: entry.FileID, Reason: reason, Detail: detail}
+			}
+			if index.Excluded(details, file) {
+				skips.Skip(fileSkip(library.SkipIgnored, ""), "ignored files", "File ignored", "file", filename)
+				continue
+			}
+			if route.Action == appdownloads.RouteSkip {
+				skips.Skip(fileSkip(library.SkipMIME, file.MimeType), "files skipped by type", "File skipped by its MIME route", "file", filename, "mime", file.MimeType)
+				continue
+			}
+			if !config.SelectFiles.Selects(details, file) {
+				skips.Skip(fileSkip(library.SkipSelection, ""), "files not selected", "File not selected", "file", filename)
+				continue
+			}
+			// Only the file ID is looked up: the same file in another
+			// submission is left to --dedupe.
+			if previous, ok := index.Lookup(entry.FileID, ""); ok {
+				if paths := index.Files(previous); len(paths) > 0 && fileExists(paths[0]) {
+					pages = append(pages, appdownloads.ArchivePage{Order: file.SubmissionFileOrder.Int(), Path: paths[0]})
+				}
+				skips.Skip(fileSkip(library.SkipExisting, ""), "files already downloaded", "File already downloaded", "file", filename)
+				continue
+			}
+			if len(config.StorageTiers) > 0 {
+				size := remoteSize(client, utils.AppendSID(file.FileURLFull.String(), user.SID))
+				if tierRoot := config.StorageTiers.Root(root, size); tierRoot != root {
+					destinations = place(tierRoot)
+					filename, folder = destinations[0], filepath.Dir(destinations[0])
+					entry.Paths = destinations
+				}
+			}
+			if fileExists(filename) && config.DryRun {
+				skips.Skip(fileSkip(library.SkipExisting, ""), "files already downloaded", "File already downloaded", "file", filename)
+				continue
+			}
 			if fileExists(filename) {
+				if err := appdownloads.LinkDestinations(filename, destinations, file.FullFileMD5); err != nil {
+					return err
+				}
+				if err := appdownloads.ConvertFiles(destinations, route.Format()); err != nil {
+					return err
+				}
+				if err := index.Record(entry); err != nil {
+					log.Warn("failed to record download history", "err", err)
+				}
+				pages = append(pages, appdownloads.ArchivePage{Order: file.SubmissionFileOrder.Int(), Path: filename})
+				skips.Skip(fileSkip(library.SkipExisting, ""), "files already downloaded", "File already downloaded", "file", filename)
 				continue
 			}
+			if existing, ok := tree.Find(file.FullFileMD5); ok {
+				if !config.DryRun {
+					// Recording the copy that is already there lets later runs
+					// skip the file from the history alone.
+					entry.Paths = []string{existing}
+					if err := index.Record(entry); err != nil {
+						log.Warn("failed to record download history", "err", err)
+					}
+				}
+				pages = append(pages, appdownloads.ArchivePage{Order: file.SubmissionFileOrder.Int(), Path: existing})
+				skips.Skip(fileSkip(library.SkipDuplicate, existing), "duplicate files", "File already in the download folder under another name", "file", filename, "existing", existing)
+				continue
+			}
+			url := utils.ResourceURL(file.FileURLFull.String(), user.SID, details.Public.Bool())
+			if dryRun.List(client, filename, file.FileURLFull.String(), url) {
+				downloaded.Add(1)
+				continue
+			}
+			counters.active.Add(1)
+			inFlight = true
 			if err := os.MkdirAll(folder, os.ModePerm); err != nil {
 				return err
 			}
-			f, err := os.Create(filename)
+			sidURL := utils.AppendSID(file.FileURLFull.String(), user.SID)
+			err := hosts.Fetch(url, sidURL, func(url, sidURL string) error {
+				return retryFile(offline, config.Retries, filename, func() error {
+					return fetchFile(client, url, sidURL, filename, file.FullFileMD5, config.Connections, syncer, openFiles, &counters)
+				})
+			})
 			if err != nil {
 				return err
 			}
-			defer f.Close()
-
-			var resp *http.Response
-			url := utils.ResourceURL(file.FileURLFull.String(), user.SID, details.Public.Bool())
-			sidURL := utils.AppendSID(file.FileURLFull.String(), user.SID)
-			for {
-				resp, err = client.Get(url)
-				if err != nil {
-					return err
-				}
-				if resp.StatusCode == http.StatusOK {
-					break
-				}
-				if resp.StatusCode == http.StatusTooManyRequests {
-					resp.Body.Close()
-					log.Warn("Rate limited, waiting 5 seconds before retrying...")
-					time.Sleep(5 * time.Second)
-					continue
-				}
-				resp.Body.Close()
-				if sidURL != "" && sidURL != url {
-					url = sidURL
-					continue
+			if err := appdownloads.LinkDestinations(filename, destinations, file.FullFileMD5); err != nil {
+				return err
+			}
+			if err := appdownloads.ConvertFiles(destinations, route.Format()); err != nil {
+				return err
+			}
+			metadata := appdownloads.NewSubmissionFileMetadata(details, file, config.TimeZone)
+			if config.EmbedMetadata {
+				if err := appdownloads.EmbedMetadata(destinations, metadata); err != nil {
+					log.Warn("failed to embed metadata", "file", filename, "err", err)
 				}
-				return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 			}
 
-			_, err = io.Copy(f, resp.Body)
-			resp.Body.Close()
-			if err != nil {
+			if err := appdownloads.WriteSidecars(destinations, metadata, sidecars); err != nil {
 				return err
 			}
 
-			if downloadCaption && len(details.Keywords) > 0 {
-				if err := os.WriteFile(strings.TrimSuffix(filename, filepath.Ext(filename))+".txt", keywords.Bytes(), 0o600); err != nil {
-					return err
+			if err := index.Record(entry); err != nil {
+				log.Warn("failed to record download history", "err", err)
+			}
+			if booru != nil {
+				if err := booru.Upload(context.Background(), filename, metadata); err != nil {
+					log.Warn("failed to upload to booru", "file", filename, "err", err)
 				}
 			}
-
+			pages = append(pages, appdownloads.ArchivePage{Order: file.SubmissionFileOrder.Int(), Path: filename})
+			uploads = append(uploads, destinations...)
+			uploaded = append(uploaded, entry)
+			event.Files++
+			if info, err := os.Stat(filename); err == nil {
+				event.Bytes += info.Size()
+			}
 			log.Debug(fmt.Sprintf("Downloaded file %0*d/%0*d", padding, i+1, padding, numOfFiles), "url", file.FileURLFull)
 			downloaded.Add(1)
+			inFlight = false
+			counters.active.Add(-1)
+			counters.downloaded.Add(1)
+			watchdog.Alive()
+		}
+		var archive string
+		if config.Archive != "" && numOfFiles > 1 && len(pages) > 0 && !config.DryRun {
+			path := appdownloads.ArchivePath(details, pages, config.Archive)
+			if err := appdownloads.WriteArchive(path, details, pages); err != nil {
+				log.Warn("failed to archive submission", "url", submissionURL, "err", err)
+			} else {
+				archive = path
+				log.Debug("Archived submission", "url", submissionURL, "archive", path, "pages", len(pages))
+			}
 		}
-		if downloadCaption && len(details.Keywords) <= 0 {
-			log.Warn("There are no keywords on the submission", "url", submissionURL)
+		if rclone != nil && rclone.PerSubmission && len(uploads) > 0 {
+			if err := rclone.CopyFiles(context.Background(), root, submissionPaths(details.SubmissionID.String(), uploads, sidecars, archive)); err != nil {
+				log.Warn("failed to copy submission with rclone", "url", submissionURL, "remote", rclone, "err", err)
+			}
+		}
+		if config.Output != nil && len(uploads) > 0 {
+			offloadSubmission(config.Output, index, root, details.SubmissionID.String(), uploads, uploaded, sidecars, archive)
+		}
+		if appdownloads.MissingKeywords(appdownloads.SubmissionFileMetadata{SubmissionDetails: details}, sidecars) {
+			skipLog.Skip("submissions without keywords", "There are no keywords on the submission", "url", submissionURL)
+		}
+		if !config.DryRun {
+			log.Info("Downloaded submission", "url", submissionURL, "files", numOfFiles)
+		}
+		if event.Files > 0 {
+			submissions.Add(1)
+			artistsMu.Lock()
+			artists[event.Artist] += event.Files
+			artistsMu.Unlock()
+			if config.Webhook != nil {
+				notifySubmission(config.Webhook, event)
+			}
 		}
-		log.Info("Downloaded submission", "url", submissionURL, "files", numOfFiles)
 		return nil
 	})
 
+	var detailsRequest inkbunny.SubmissionDetailsRequest
+	if appdownloads.SidecarsNeedDetails(sidecars) || len(config.Languages) > 0 || config.EmbedMetadata || config.Archive != "" || config.EmptySubmissions == appdownloads.EmptyRecord {
+		detailsRequest = appdownloads.MetadataSubmissionDetailsRequest()
+	}
+	if config.Related > 0 || pools != nil {
+		detailsRequest.ShowPools = inkbunny.Yes
+	}
+
+	// incomplete is set when the producer leaves search results unqueued, so
+	// that a mirror is not recorded past submissions it did not download.
+	var incomplete atomic.Bool
+	// Only a search newest first ends at the first page without anything newer
+	// than the last mirror or watch cycle.
+	newestFirst := request.OrderBy == inkbunny.OrderByCreateDatetime
+	favorites := newFavoritesProgress(config, firstPage.ResultsCountAll)
+	sample := newPreviewSample(config.Sample)
 	go func() {
 		defer downloader.Close()
-		details, err := firstPage.Details()
-		if err != nil {
-			log.Error("Failed to get submission details", "err", err)
-		} else {
-			downloader.Add(details.Submissions...)
+		// seen keeps a submission reached both by the search and through a pool
+		// from being queued twice.
+		seen := make(map[string]struct{})
+		// With --pools, every pool of a search result is listed before the
+		// result is queued, so that its files land at their page, and the rest
+		// of the pool is kept in poolMembers to queue after it. The pools of
+		// those members are not followed in turn.
+		listedPools := make(map[inkbunny.IntString]struct{})
+		var poolMembers []string
+		placePools := func(submissions []inkbunny.SubmissionDetails) {
+			for _, submission := range submissions {
+				for _, pool := range submission.Pools {
+					if _, ok := listedPools[pool.PoolID]; ok {
+						continue
+					}
+					// A pool of the pool subcommand is downloaded on its own,
+					// without the other pools its members are in.
+					if !config.Pools && !slices.Contains(config.PoolIDs, pool.PoolID) {
+						continue
+					}
+					listedPools[pool.PoolID] = struct{}{}
+					watchdog.Progress(fmt.Sprintf("pool %q", pool.Name))
+					ids, err := poolSubmissionIDs(user, offline, pool)
+					if err != nil {
+						log.Error("Failed to list the submissions of a pool", "pool", pool.Name, "err", err)
+						incomplete.Store(true)
+						continue
+					}
+					pools.Add(pool, ids)
+					poolMembers = append(poolMembers, ids...)
+				}
+			}
+		}
+		// queue queues the submissions of request, placing their pools first when
+		// they are search results.
+		queue := func(what string, request inkbunny.SubmissionDetailsRequest, results bool) ([]inkbunny.SubmissionDetails, bool) {
+			watchdog.Progress(what)
+			details, err := retryAPIStep(offline, what, func(ctx context.Context) (inkbunny.SubmissionDetailsResponse, error) {
+				return user.SubmissionDetailsContext(ctx, request)
+			})
+			if err != nil {
+				log.Error("Failed to get submission details", "err", err)
+				incomplete.Store(true)
+				return nil, true
+			}
+			submissions := slices.DeleteFunc(details.Submissions, func(submission inkbunny.SubmissionDetails) bool {
+				id := submission.SubmissionID.String()
+				_, ok := seen[id]
+				seen[id] = struct{}{}
+				return ok
+			})
+			if pools != nil && results {
+				placePools(submissions)
+			}
+			counters.queued.Add(fileCount(submissions))
+			watchdog.Progress("queueing " + what)
+			downloader.Add(submissions...)
 			if toDownload > 0 && int(downloaded.Load()) >= toDownload {
+				incomplete.Store(true)
+				return submissions, false
+			}
+			return submissions, true
+		}
+		// queueRelated follows the pools of submissions for up to --related hops.
+		queueRelated := func(submissions []inkbunny.SubmissionDetails) bool {
+			for hop := 1; hop <= config.Related && len(submissions) > 0; hop++ {
+				var ids []string
+				pending := make(map[string]struct{})
+				for _, submission := range submissions {
+					for _, id := range appdownloads.RelatedSubmissionIDs(submission) {
+						if _, ok := seen[id]; ok {
+							continue
+						}
+						if _, ok := pending[id]; ok {
+							continue
+						}
+						pending[id] = struct{}{}
+						ids = append(ids, id)
+					}
+				}
+				submissions = nil
+				for batch := range slices.Chunk(ids, 100) {
+					relatedRequest := detailsRequest
+					relatedRequest.SID = user.SID
+					relatedRequest.SubmissionIDSlice = batch
+					found, ok := queue(fmt.Sprintf("related submissions, hop %d", hop), relatedRequest, false)
+					if !ok {
+						return false
+					}
+					submissions = append(submissions, found...)
+				}
+			}
+			return true
+		}
+
+		// queuePools queues the members of the pools placed so far.
+		queuePools := func() bool {
+			var ids []string
+			pending := make(map[string]struct{})
+			for _, id := range poolMembers {
+				if _, ok := seen[id]; ok {
+					continue
+				}
+				if _, ok := pending[id]; ok {
+					continue
+				}
+				pending[id] = struct{}{}
+				ids = append(ids, id)
+			}
+			poolMembers = nil
+			for batch := range slices.Chunk(ids, 100) {
+				poolRequest := detailsRequest
+				poolRequest.SID = user.SID
+				poolRequest.SubmissionIDSlice = batch
+				if _, ok := queue("submissions of pools", poolRequest, false); !ok {
+					return false
+				}
+			}
+			return true
+		}
+
+		firstPageRequest := detailsRequest
+		firstPageRequest.SID = user.SID
+		ids, more := mirrorPage(mirror, firstPage.Submissions)
+		ids = sample.Keep(firstPage.Submissions, ids)
+		firstPageRequest.SubmissionIDSlice = ids
+		if len(ids) > 0 {
+			submissions, ok := queue(fmt.Sprintf("details of page %d", firstPage.Page), firstPageRequest, true)
+			favorites.page(firstPage.Page, firstPage.PagesCount, len(submissions))
+			if !ok || !queueRelated(submissions) || !queuePools() {
 				return
 			}
 		}
+		if !more && newestFirst {
+			return
+		}
 
 		followUpRequest := request
+		followUpRequest.SID = user.SID
 		followUpRequest.GetRID = inkbunny.No
 		followUpRequest.RID = firstPage.RID
-		followUpRequest.Page = firstPage.Page + 1
-
-		for details, detailsErr := range followUpRequest.AllDetails(inkbunny.SubmissionDetailsRequest{}) {
-			if detailsErr != nil {
-				log.Error("Failed to get submission details", "err", detailsErr)
+		for page := firstPage.Page + 1; page <= firstPage.PagesCount; page++ {
+			followUpRequest.Page = page
+			what := fmt.Sprintf("search page %d of %d", page, firstPage.PagesCount)
+			watchdog.Progress(what)
+			results, err := retryAPIStep(offline, what, func(ctx context.Context) (inkbunny.SubmissionSearchResponse, error) {
+				return user.SearchSubmissionsContext(ctx, followUpRequest)
+			})
+			if err != nil {
+				log.Error("Failed to search submissions", "page", page, "err", err)
+				incomplete.Store(true)
 				continue
 			}
-			downloader.Add(details.Submissions...)
-			if toDownload > 0 && int(downloaded.Load()) >= toDownload {
+			ids, more := mirrorPage(mirror, results.Submissions)
+			ids = sample.Keep(results.Submissions, ids)
+			if len(ids) > 0 {
+				pageRequest := detailsRequest
+				pageRequest.SID = user.SID
+				pageRequest.SubmissionIDSlice = ids
+				submissions, ok := queue(fmt.Sprintf("details of page %d", page), pageRequest, true)
+				favorites.page(page, firstPage.PagesCount, len(submissions))
+				if !ok || !queueRelated(submissions) || !queuePools() {
+					return
+				}
+			}
+			if !more && newestFirst {
 				return
 			}
 		}
 	}()
 
+	hideProgress := showProgress(&counters, config.Progress && !config.DryRun)
 	for err := range downloader.Work() {
 		if err != nil {
 			log.Error("Failed to download submissions", "err", err)
 		}
 	}
+	hideProgress()
 
-	log.Infof("Downloaded %d files", downloaded.Load())
+	if err := syncer.Flush(); err != nil {
+		log.Error("Failed to sync downloads", "err", err)
+	}
+	artistIndex.Write(index, root, config.Pattern)
+	if !config.DryRun {
+		if collections, err := loadCollections(); err != nil {
+			log.Warn("Failed to load the collections from the settings file", "err", err)
+		} else {
+			placeCollections(index, root, collections)
+		}
+	}
+	skipLog.Summarize()
+	summary := appdownloads.RunSummary{
+		Processed:   processed.Load(),
+		Submissions: submissions.Load(),
+		Files:       downloaded.Load(),
+		Skipped:     skipped.Load(),
+		Failed:      counters.failed.Load(),
+		Bytes:       counters.bytes.Load(),
+		Seconds:     time.Since(started).Seconds(),
+		Artists:     topArtists(artists),
+	}
+	if dryRun != nil {
+		dryRun.Report()
+	} else {
+		reportRun(summary, artists)
+	}
+	sample.Report()
+	usage.Report()
+	hosts.Report()
+	if config.KeywordReport != "" && !config.DryRun {
+		writeKeywordReport(index, config.KeywordReport)
+	}
+	failed := summary.Failed
+	if failed > 0 {
+		log.Warn("Some files failed to download", "failed", failed)
+		setExitCode(ExitPartial)
+	}
+	complete := failed == 0 && !incomplete.Load()
+	if config.Mirror != "" && !config.DryRun {
+		saveMirror(mirror, complete)
+	}
+	watch.finish(mirror, downloaded.Load(), failed, complete)
+	if rclone != nil && !rclone.PerSubmission {
+		log.Info("Copying downloads with rclone", "mode", rclone.Mode, "remote", rclone)
+		if err := rclone.Run(context.Background(), root, libraryPaths(index, sidecars)); err != nil {
+			log.Error("Failed to copy downloads with rclone", "remote", rclone, "err", err)
+		}
+	}
+	if !config.DryRun {
+		notifyRun(notifiers, summary, watch != nil)
+		if !notifiesDesktop(notifiers) {
+			notifyLongRun(started, runNotification(summary))
+		}
+	}
+}
+
+// recordEmptySubmission keeps the metadata and description of a submission
+// without files, such as a story told in its description, in the library, once.
+func recordEmptySubmission(index *library.Index, root, pattern string, details inkbunny.SubmissionDetails, layout appdownloads.Layout, sidecars apptypes.SidecarOptions) {
+	submissionURL := fmt.Sprintf("https://inkbunny.net/s/%d", details.SubmissionID)
+	if _, ok := index.Lookup(library.MetadataFileID(details.SubmissionID.String()), ""); ok {
+		log.Debug("Submission without files already recorded", "url", submissionURL)
+		return
+	}
+	entry, err := appdownloads.WriteEmptySubmission(root, pattern, details, layout, sidecars)
+	if err != nil {
+		log.Warn("failed to record submission without files", "url", submissionURL, "err", err)
+		return
+	}
+	if err := index.Record(entry); err != nil {
+		log.Warn("failed to record download history", "err", err)
+	}
+	log.Info("Recorded submission without files", "url", submissionURL, "paths", entry.Paths)
+}
+
+func fileCount(submissions []inkbunny.SubmissionDetails) int64 {
+	var count int64
+	for _, submission := range submissions {
+		count += int64(len(submission.Files))
+	}
+	return count
+}
+
+// fetchFile downloads url into the part file of filename and gives it its real
+// name once complete. The part file and every response body are closed before
+// it returns, so a worker only holds the handles of the file it is on, however
+// many files the submission has.
+func fetchFile(client *http.Client, url, sidURL, filename, md5 string, connections int, syncer *appdownloads.FileSyncer, openFiles *appdownloads.OpenFileLimit, counters *runCounters) error {
+	defer openFiles.Release(openFiles.Acquire(1 + max(connections, 1)))
+
+	f, offset, err := appdownloads.OpenPart(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var resp *http.Response
+	for limited := 0; ; {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		appdownloads.RequestFrom(req, offset)
+		resp, err = client.Do(req)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode == http.StatusOK || resp.StatusCode == http.StatusPartialContent {
+			break
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			// The part file is no shorter than the file, such as one left preallocated.
+			offset = 0
+			continue
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			// The client holds back the retry for as long as the server asked.
+			if limited++; limited > maxRateLimited {
+				return errRateLimited
+			}
+			continue
+		}
+		if gatewayStatus(resp.StatusCode) {
+			return fmt.Errorf("%w: status code %d", errSiteUnavailable, resp.StatusCode)
+		}
+		if resp.StatusCode >= http.StatusInternalServerError {
+			return fmt.Errorf("%w: status code %d", errServerStatus, resp.StatusCode)
+		}
+		if sidURL != "" && sidURL != url {
+			url = sidURL
+			continue
+		}
+		return fmt.Errorf("%w: %d", errFileStatus, resp.StatusCode)
+	}
+
+	// A resumed file counts the part already downloaded toward its progress.
+	resumed := int64(0)
+	if resp.StatusCode == http.StatusPartialContent {
+		resumed = offset
+	}
+	transfer := counters.transfers.start(filename)
+	defer counters.transfers.done(transfer)
+	transfer.written.Store(resumed)
+	transfer.total.Store(max(resumed+resp.ContentLength, 0))
+
+	segmented := false
+	if offset == 0 && appdownloads.CanSegment(resp, connections) {
+		resp.Body.Close()
+		sum, err := appdownloads.DownloadSegments(context.Background(), client, url, f, resp.ContentLength, connections, transfer.written.Store)
+		if err != nil && !errors.Is(err, appdownloads.ErrSegmentsUnsupported) {
+			return err
+		}
+		segmented = err == nil && (md5 == "" || sum == md5)
+		if segmented {
+			counters.bytes.Add(resp.ContentLength)
+		}
+		if !segmented {
+			log.Warn("Segmented download failed, downloading over one connection", "file", filename, "err", err)
+			if err := f.Truncate(0); err != nil {
+				return err
+			}
+			if resp, err = client.Get(url); err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
+				return fmt.Errorf("%w: %d", errFileStatus, resp.StatusCode)
+			}
+		}
+	}
+	if !segmented {
+		transfer.written.Store(resumed)
+		resp.Body = countingBody{ReadCloser: resp.Body, n: &counters.bytes}
+		resp.Body = countingBody{ReadCloser: resp.Body, n: &transfer.written}
+		if err := writeResponse(f, resp, offset); err != nil {
+			return err
+		}
+	}
+	if err := syncer.Written(f, filename); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return appdownloads.FinishPart(filename)
+}
+
+// writeResponse writes the body of resp into the part file f, after the offset
+// bytes it already holds when resp is the rest of the file. What was written is
+// kept when the body breaks off, so the next run can resume it.
+func writeResponse(f *os.File, resp *http.Response, offset int64) error {
+	defer resp.Body.Close()
+	offset, err := appdownloads.ResumeFrom(f, resp, offset, nil)
+	if err != nil {
+		return err
+	}
+	preallocated := false
+	if offset == 0 {
+		if preallocated, err = appdownloads.PreallocateFile(f, resp.ContentLength); err != nil {
+			return err
+		}
+	}
+	buffered := appdownloads.NewFileWriter(f)
+	written, err := io.Copy(buffered, resp.Body)
+	if err != nil {
+		_ = buffered.Flush()
+		return err
+	}
+	if err := buffered.Flush(); err != nil {
+		return err
+	}
+	if preallocated {
+		return appdownloads.TrimPreallocatedFile(f, written)
+	}
+	return nil
 }
@@ -0,0 +1,95 @@
+package modes
+
+import (
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/utils"
+)
+
+var errFileStatus = errors.New("unexpected status code")
+
+// hostFailover downloads a file from the other hosts Inkbunny serves it from
+// once its own host keeps failing, and remembers which host last worked so
+// that the files after it start there.
+type hostFailover struct {
+	mu        sync.Mutex
+	preferred string
+	served    map[string]int
+}
+
+func newHostFailover() *hostFailover {
+	return &hostFailover{served: make(map[string]int)}
+}
+
+// Fetch runs fetch with url and sidURL, then with them moved to each other
+// host while the download fails in a way another host may not.
+func (h *hostFailover) Fetch(url, sidURL string, fetch func(url, sidURL string) error) error {
+	host := utils.HostOf(url)
+	if !utils.FileHost(host) {
+		return fetch(url, sidURL)
+	}
+	var err error
+	for _, candidate := range h.candidates(host) {
+		if candidate != host {
+			log.Warn("Trying another host for the file", "host", candidate, "err", err)
+		}
+		err = fetch(utils.WithHost(url, candidate), utils.WithHost(sidURL, candidate))
+		if err == nil {
+			h.succeeded(host, candidate)
+			return nil
+		}
+		if !transientError(err) && !errors.Is(err, errFileStatus) {
+			return err
+		}
+	}
+	return err
+}
+
+// candidates orders the hosts to try a file on: the host that last worked,
+// the host of the file, then the rest.
+func (h *hostFailover) candidates(host string) []string {
+	h.mu.Lock()
+	preferred := h.preferred
+	h.mu.Unlock()
+	hosts := []string{host}
+	if preferred != "" && preferred != host {
+		hosts = []string{preferred, host}
+	}
+	for _, other := range utils.FileHosts {
+		if !slices.Contains(hosts, other) {
+			hosts = append(hosts, other)
+		}
+	}
+	return hosts
+}
+
+func (h *hostFailover) succeeded(host, served string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.served[served]++
+	if served != host && served != h.preferred {
+		log.Info("Downloading from another host from now on", "host", served, "instead of", host)
+	}
+	if served != host || h.preferred != "" {
+		h.preferred = served
+	}
+}
+
+// Report logs how many files each host served when any came from a host
+// other than their own.
+func (h *hostFailover) Report() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.preferred == "" {
+		return
+	}
+	for _, host := range utils.FileHosts {
+		if served := h.served[host]; served > 0 {
+			log.Info("Files served", "host", host, "files", served)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package modes
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// httpTraceFile is where --trace-http writes the timings of each request, in
+// the run folder.
+const httpTraceFile = "http-trace.jsonl"
+
+// httpTracer times each request with httptrace and writes the timings to
+// http-trace.jsonl in the run folder, to tell whether a slow run waits on the
+// API, on the file servers or on the connection. A nil tracer traces nothing.
+type httpTracer struct {
+	minimum time.Duration
+
+	mu      sync.Mutex
+	file    *os.File
+	encoder *json.Encoder
+	phases  [usagePhases]traceTotals
+}
+
+// traceTotals adds up the timings of the requests of a phase for Report.
+type traceTotals struct {
+	requests, reused                int64
+	dns, connect, tls, wait, header time.Duration
+}
+
+// httpTrace is a line of http-trace.jsonl. Only the host and path of a request
+// are written, as the query of an API request holds the session ID.
+type httpTrace struct {
+	Time   time.Time `json:"time"`
+	Phase  string    `json:"phase"`
+	Method string    `json:"method"`
+	Host   string    `json:"host"`
+	Path   string    `json:"path"`
+	Status int       `json:"status,omitempty"`
+	Error  string    `json:"error,omitempty"`
+	// Reused is set when the request went over a connection that was already
+	// open, which leaves DNS, Connect and TLS at zero.
+	Reused  bool    `json:"reused"`
+	DNS     float64 `json:"dns_ms"`
+	Connect float64 `json:"connect_ms"`
+	TLS     float64 `json:"tls_ms"`
+	// Wait is from the request being sent to the first byte of the response,
+	// how long the server took, and Header from the start to that byte.
+	Wait   float64 `json:"wait_ms"`
+	Header float64 `json:"header_ms"`
+}
+
+// newHTTPTracer starts tracing into the run folder when enabled, leaving out
+// requests whose response took less than minimum to start.
+func newHTTPTracer(enabled bool, minimum time.Duration) *httpTracer {
+	if !enabled {
+		return nil
+	}
+	path := currentRun.Path(httpTraceFile)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		log.Warn("failed to create the HTTP trace, requests are not traced", "path", path, "err", err)
+		return nil
+	}
+	keyvals := []any{"path", path}
+	if minimum > 0 {
+		keyvals = append(keyvals, "slower than", minimum)
+	}
+	log.Info("Tracing HTTP requests", keyvals...)
+	return &httpTracer{minimum: minimum, file: file, encoder: json.NewEncoder(file)}
+}
+
+// Client returns an HTTP client whose requests are traced by t.
+func (t *httpTracer) Client(client *http.Client) *http.Client {
+	if t == nil {
+		return client
+	}
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	traced := *client
+	traced.Transport = traceTransport{base: base, tracer: t}
+	return &traced
+}
+
+// Report logs the average timings of every phase that made a request, and
+// closes the trace.
+func (t *httpTracer) Report() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for phase, totals := range t.phases {
+		if totals.requests == 0 {
+			continue
+		}
+		average := func(total time.Duration, requests int64) string {
+			if requests == 0 {
+				return "-"
+			}
+			return (total / time.Duration(requests)).Round(10 * time.Microsecond).String()
+		}
+		dialed := totals.requests - totals.reused
+		log.Info("HTTP timings",
+			"phase", usagePhaseNames[phase],
+			"requests", totals.requests,
+			"new connections", dialed,
+			"dns", average(totals.dns, dialed),
+			"connect", average(totals.connect, dialed),
+			"tls", average(totals.tls, dialed),
+			"server wait", average(totals.wait, totals.requests),
+			"first byte", average(totals.header, totals.requests),
+		)
+	}
+	if err := t.file.Close(); err != nil {
+		log.Warn("failed to write the HTTP trace", "err", err)
+	}
+	t.phases = [usagePhases]traceTotals{}
+}
+
+func (t *httpTracer) record(phase usagePhase, trace httpTrace, timings *requestTimings) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	totals := &t.phases[phase]
+	totals.requests++
+	if trace.Reused {
+		totals.reused++
+	} else {
+		totals.dns += timings.dns
+		totals.connect += timings.connect
+		totals.tls += timings.tls
+	}
+	totals.wait += timings.wait()
+	totals.header += timings.header()
+	if timings.header() < t.minimum {
+		return
+	}
+	if err := t.encoder.Encode(trace); err != nil {
+		log.Debug("failed to write the HTTP trace", "err", err)
+	}
+}
+
+// requestTimings collects the httptrace events of a request, which the dialer
+// can send from goroutines of its own.
+type requestTimings struct {
+	mu                                sync.Mutex
+	start, dnsStart, connectStart     time.Time
+	tlsStart, wroteRequest, firstByte time.Time
+	dns, connect, tls                 time.Duration
+	reused                            bool
+}
+
+func (r *requestTimings) clientTrace() *httptrace.ClientTrace {
+	at := func(set func(now time.Time)) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		set(time.Now())
+	}
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { at(func(now time.Time) { r.dnsStart = now }) },
+		DNSDone:  func(httptrace.DNSDoneInfo) { at(func(now time.Time) { r.dns = now.Sub(r.dnsStart) }) },
+		ConnectStart: func(string, string) {
+			at(func(now time.Time) { r.connectStart = now })
+		},
+		ConnectDone: func(string, string, error) {
+			at(func(now time.Time) { r.connect = now.Sub(r.connectStart) })
+		},
+		TLSHandshakeStart: func() { at(func(now time.Time) { r.tlsStart = now }) },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			at(func(now time.Time) { r.tls = now.Sub(r.tlsStart) })
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			at(func(time.Time) { r.reused = info.Reused })
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { at(func(now time.Time) { r.wroteRequest = now }) },
+		GotFirstResponseByte: func() { at(func(now time.Time) { r.firstByte = now }) },
+	}
+}
+
+func (r *requestTimings) wait() time.Duration {
+	if r.wroteRequest.IsZero() || r.firstByte.IsZero() {
+		return 0
+	}
+	return r.firstByte.Sub(r.wroteRequest)
+}
+
+func (r *requestTimings) header() time.Duration {
+	if r.firstByte.IsZero() {
+		return time.Since(r.start)
+	}
+	return r.firstByte.Sub(r.start)
+}
+
+type traceTransport struct {
+	base   http.RoundTripper
+	tracer *httpTracer
+}
+
+func (t traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	timings := &requestTimings{start: time.Now()}
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), timings.clientTrace())))
+
+	timings.mu.Lock()
+	defer timings.mu.Unlock()
+	phase := phaseOf(req)
+	milliseconds := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	trace := httpTrace{
+		Time:    timings.start,
+		Phase:   usagePhaseNames[phase],
+		Method:  req.Method,
+		Host:    req.URL.Host,
+		Path:    req.URL.Path,
+		Reused:  timings.reused,
+		DNS:     milliseconds(timings.dns),
+		Connect: milliseconds(timings.connect),
+		TLS:     milliseconds(timings.tls),
+		Wait:    milliseconds(timings.wait()),
+		Header:  milliseconds(timings.header()),
+	}
+	if err != nil {
+		trace.Error = err.Error()
+	} else {
+		trace.Status = resp.StatusCode
+	}
+	t.tracer.record(phase, trace, timings)
+	return resp, err
+}
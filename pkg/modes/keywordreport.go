@@ -0,0 +1,19 @@
+package modes
+
+import (
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+)
+
+// writeKeywordReport writes the keyword counts of the library to path for
+// --keyword-report.
+func writeKeywordReport(index *library.Index, path string) {
+	stats, submissions := appdownloads.KeywordStats(index)
+	if err := appdownloads.WriteKeywordReport(path, stats); err != nil {
+		log.Warn("failed to write the keyword report", "path", path, "err", err)
+		return
+	}
+	log.Info("Wrote keyword report", "path", path, "keywords", len(stats), "submissions", submissions)
+}
@@ -0,0 +1,140 @@
+package modes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+// migrateLibrary upgrades the sidecars of a library written by an older version
+// before a run adds to it. When interactive, it first asks, and a library that
+// is not upgraded is offered again on the next run.
+func migrateLibrary(index *library.Index, interactive bool) {
+	upgrade, err := appdownloads.PendingUpgrade(index)
+	if err != nil {
+		log.Warn("failed to upgrade library", "library", index.Root(), "err", err)
+		return
+	}
+	if !upgrade.Pending() {
+		return
+	}
+	if interactive {
+		proceed := true
+		if err := huh.NewForm(huh.NewGroup(huh.NewConfirm().
+			Title("Upgrade this library?").
+			Description(fmt.Sprintf("It was written by an older version of the downloader. The files the upgrade rewrites are backed up to %s first.\n\n- %s",
+				filepath.Join(index.Root(), appdownloads.BackupsFolder), strings.Join(upgrade.Steps, "\n- "))).
+			Affirmative("Upgrade").
+			Negative("Not now").
+			Value(&proceed),
+		)).Run(); err != nil || !proceed {
+			log.Warn("Library was not upgraded, it will be offered again next time", "library", index.Root(), "schema", upgrade.From)
+			return
+		}
+	}
+	version, backup, err := appdownloads.MigrateLibrary(index)
+	if err != nil {
+		log.Warn("failed to upgrade library", "library", index.Root(), "backup", backup, "err", err)
+		return
+	}
+	log.Info("Upgraded library", "library", index.Root(), "from", version, "to", appdownloads.SchemaVersion, "backup", backup)
+}
+
+// runLibraryCommands applies --ignore, --forget and --scan-deleted to the
+// download history in the library at root.
+func runLibraryCommands(config flags.Config, root string) {
+	index, err := library.Open(root)
+	if err != nil {
+		fatal(ExitFailure, "failed to load download history", "library", root, "err", err)
+	}
+
+	for _, id := range config.Forget {
+		if err := index.ForgetSubmission(id); err != nil {
+			fatal(ExitFailure, "failed to forget submission", "id", id, "err", err)
+		}
+		log.Info("Forgot submission, it will be downloaded again", "id", id)
+	}
+	for _, id := range config.Ignore {
+		if err := index.Ignore(id, ""); err != nil {
+			fatal(ExitFailure, "failed to ignore submission", "id", id, "err", err)
+		}
+		log.Info("Ignoring submission in future syncs", "id", id)
+	}
+	if config.ScanDeleted {
+		missing := index.Missing()
+		for _, entry := range missing {
+			if err := index.Ignore(entry.SubmissionID, entry.FileID); err != nil {
+				fatal(ExitFailure, "failed to ignore deleted file", "file", entry.FileID, "err", err)
+			}
+			log.Debug("Ignoring deleted file", "submission", entry.SubmissionID, "file", entry.FileID, "paths", entry.Paths)
+		}
+		log.Info("Scanned library for deleted files", "library", root, "ignored", len(missing))
+	}
+}
+
+// runClean removes the run folders of every run of the library but this one.
+func runClean() {
+	removed, err := appstorage.CleanRuns(currentRun)
+	if err != nil {
+		fatal(ExitFailure, "failed to remove old runs", "err", err)
+	}
+	dir, _ := appstorage.RunsDirectory()
+	log.Info("Removed previous runs", "runs", dir, "removed", removed)
+}
+
+// lockLibrary keeps other runs out of the library at root until the returned
+// func is called. A busy library stops the run unless --wait was given.
+func lockLibrary(config flags.Config, root string) func() {
+	lock, err := library.TryLock(root)
+	if errors.Is(err, library.ErrLocked) && config.WaitLock {
+		log.Info("Waiting for another run to finish with the library", "library", root)
+		lock, err = library.WaitLock(context.Background(), root)
+	}
+	if err != nil {
+		fatal(ExitFailure, "failed to lock library, pass --wait to queue behind the other run", "library", root, "err", err)
+	}
+	return func() {
+		if err := lock.Unlock(); err != nil {
+			log.Warn("failed to unlock library", "library", root, "err", err)
+		}
+	}
+}
+
+// headlessRoot is where headless runs keep their files: --library-dir when given,
+// the current directory for the default library, and the download directory of
+// a named one.
+func headlessRoot(config flags.Config) string {
+	if dir := strings.TrimSpace(config.LibraryDir); dir != "" {
+		return dir
+	}
+	if appstorage.ActiveLibrary() == "" {
+		return "."
+	}
+	store, err := appstorage.NewStateStore()
+	if err != nil {
+		return appstorage.DefaultDownloadDirectory()
+	}
+	state, err := store.Load()
+	if err != nil || strings.TrimSpace(state.Settings.DownloadDirectory) == "" {
+		return appstorage.DefaultDownloadDirectory()
+	}
+	return state.Settings.DownloadDirectory
+}
+
+// warnInvalidIgnores logs the lines of the ignore file of index that were
+// skipped, as the files they were meant to exclude will be downloaded.
+func warnInvalidIgnores(index *library.Index) {
+	if err := index.InvalidIgnores(); err != nil {
+		log.Warn("Skipped invalid lines of the ignore file, the files they match will not be ignored", "err", err)
+	}
+}
@@ -0,0 +1,133 @@
+package modes
+
+import (
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+// runLint checks that every file in the library at root has its sidecars and
+// that no sidecar is left without its file. With --fix the missing sidecars are
+// written from freshly fetched submission details and the orphans are removed.
+func runLint(config flags.Config, root string, sidecars apptypes.SidecarOptions) {
+	index, err := library.Open(root)
+	if err != nil {
+		log.Warn("failed to load download history", "err", err)
+	}
+	if !sidecars.Any() {
+		sidecars.Metadata = true
+	}
+
+	missing := appdownloads.FindMissingSidecars(index, sidecars)
+	for _, file := range missing {
+		log.Info("File is missing sidecars", "file", file.File, "sidecars", file.Sidecars)
+	}
+	orphans := appdownloads.FindOrphanSidecars(root, index)
+	for _, orphan := range orphans {
+		log.Info("Sidecar has no file", "sidecar", orphan)
+	}
+	log.Info("Checked library sidecars", "library", root, "missing", len(missing), "orphaned", len(orphans))
+	if !config.Fix || len(missing)+len(orphans) == 0 {
+		return
+	}
+
+	removed := 0
+	for _, orphan := range orphans {
+		if err := os.Remove(orphan); err != nil {
+			log.Error("failed to remove orphaned sidecar", "sidecar", orphan, "err", err)
+			continue
+		}
+		removed++
+	}
+
+	fixed := 0
+	if len(missing) > 0 {
+		fixed = fixSidecars(config, missing, sidecars)
+	}
+	log.Info("Fixed library sidecars", "library", root, "written", fixed, "removed", removed)
+}
+
+// fixSidecars fetches the submissions of missing and writes their sidecars,
+// returning how many files were fixed.
+func fixSidecars(config flags.Config, missing []appdownloads.MissingSidecar, sidecars apptypes.SidecarOptions) int {
+	bySubmission := make(map[string][]appdownloads.MissingSidecar)
+	for _, file := range missing {
+		bySubmission[file.Entry.SubmissionID] = append(bySubmission[file.Entry.SubmissionID], file)
+	}
+	ids := make([]string, 0, len(bySubmission))
+	for id := range bySubmission {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	detailsRequest := inkbunny.SubmissionDetailsRequest{}
+	if appdownloads.SidecarsNeedDetails(sidecars) {
+		detailsRequest = appdownloads.MetadataSubmissionDetailsRequest()
+	}
+	user, cleanup := commandLogin(config)
+	defer cleanup()
+
+	// A caption written once per submission goes beside the first of its files
+	// that is in the library, even when that is not the submission's first file.
+	perFile := sidecars
+	perFile.PerSubmission = false
+
+	fixed := 0
+	for batch := range slices.Chunk(ids, 100) {
+		request := detailsRequest
+		request.SID = user.SID
+		request.SubmissionIDSlice = batch
+		details, err := user.SubmissionDetails(request)
+		if err != nil {
+			log.Error("Failed to get submission details", "err", err)
+			continue
+		}
+		for _, submission := range details.Submissions {
+			files := bySubmission[submission.SubmissionID.String()]
+			uncaptioned := slices.ContainsFunc(files, func(file appdownloads.MissingSidecar) bool {
+				return slices.Contains(file.Sidecars, appdownloads.CaptionPath(file.File))
+			})
+			positions := make([]int, len(files))
+			for i, file := range files {
+				positions[i] = slices.IndexFunc(submission.Files, func(candidate inkbunny.File) bool {
+					return candidate.FileID.String() == file.Entry.FileID
+				})
+			}
+			first := -1
+			for i, position := range positions {
+				if position >= 0 && (first < 0 || position < positions[first]) {
+					first = i
+				}
+			}
+			for i, file := range files {
+				position := positions[i]
+				if position < 0 {
+					log.Warn("File is no longer part of its submission", "submission", file.Entry.SubmissionID, "file", file.Entry.FileID)
+					continue
+				}
+				write := perFile
+				if sidecars.PerSubmission && (i != first || !uncaptioned) {
+					write.Keywords = false
+				}
+				metadata := appdownloads.NewSubmissionFileMetadata(submission, submission.Files[position], config.TimeZone)
+				if err := appdownloads.WriteSidecars([]string{file.File}, metadata, write); err != nil {
+					log.Error("failed to write sidecars", "file", file.File, "err", err)
+					continue
+				}
+				if appdownloads.MissingKeywords(metadata, write) {
+					log.Warn("Submission has no keywords, so no keywords sidecar was written", "file", file.File)
+				}
+				fixed++
+			}
+		}
+	}
+	return fixed
+}
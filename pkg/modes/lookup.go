@@ -0,0 +1,107 @@
+package modes
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+// runMD5Lookup finds the submissions behind the files or hashes given to
+// --md5-lookup and writes the mapping to --report, or into the run folder.
+func runMD5Lookup(config flags.Config) {
+	files, err := readLookupInput(config.MD5Lookup)
+	if err != nil {
+		fatal(ExitFailure, "failed to read files to look up", "path", config.MD5Lookup, "err", err)
+	}
+	log.Info("Looking up files by MD5", "files", len(files))
+
+	found := lookupFiles(config, files, inkbunny.SubmissionDetailsRequest{})
+	report := library.NewMD5Report(files, found)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fatal(ExitFailure, "failed to encode report", "err", err)
+	}
+	path := config.Report
+	if path == "" {
+		path = currentRun.Path("md5-lookup.json")
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		fatal(ExitFailure, "failed to write report", "path", path, "err", err)
+	}
+	log.Info("Wrote MD5 lookup report", "path", path, "matched", len(report.Matched), "unmatched", len(report.Unmatched))
+}
+
+// readLookupInput hashes the files of a folder, or reads a list of MD5s from a file.
+func readLookupInput(path string) ([]library.HashedFile, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return library.ReadMD5List(path)
+	}
+	return hashFolder(path)
+}
+
+func hashFolder(path string) ([]library.HashedFile, error) {
+	var (
+		files []library.HashedFile
+		err   error
+	)
+	spinner.New().
+		Title("Hashing files...").
+		Action(func() {
+			files, err = library.HashFolder(context.Background(), path)
+		}).Run()
+	return files, err
+}
+
+// commandLogin authenticates without prompting for the one-off commands that
+// talk to Inkbunny. The returned func restores a guest session's ratings.
+func commandLogin(config flags.Config) (*inkbunny.User, func()) {
+	user, source, persistSession, err := authenticateUser(config, false)
+	if err != nil {
+		fatal(ExitAuth, "Failed to authenticate", "err", err)
+	}
+	if persistSession {
+		if err := saveSession(user); err != nil {
+			log.Warn("failed to save session", "err", err)
+		}
+	}
+	logAuthenticatedUser(user, source)
+	return user, prepareGuestSession(config, user, false)
+}
+
+// lookupFiles logs in and searches Inkbunny for the hashes of files.
+func lookupFiles(config flags.Config, files []library.HashedFile, details inkbunny.SubmissionDetailsRequest) map[string]library.MD5Source {
+	user, cleanup := commandLogin(config)
+	defer cleanup()
+
+	hashes := make([]string, len(files))
+	for i, file := range files {
+		hashes[i] = file.MD5
+	}
+
+	var (
+		found map[string]library.MD5Source
+		err   error
+	)
+	spinner.New().
+		Title("Searching by MD5...").
+		Action(func() {
+			found, err = library.LookupMD5s(context.Background(), user, hashes, details)
+		}).Run()
+	if err != nil {
+		log.Error("MD5 search stopped early", "err", err)
+	}
+	return found
+}
@@ -0,0 +1,77 @@
+package modes
+
+import (
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+// mirrorConfig turns config into the search of the mirror subcommand: every
+// submission of the artist, newest first.
+func mirrorConfig(config flags.Config) flags.Config {
+	config.ArtistName = config.Mirror
+	config.SearchWords = ""
+	config.FavBy = ""
+	config.TimeRange = 0
+	config.SubmissionTypes = nil
+	config.OrderBy = inkbunny.OrderByCreateDatetime
+	return config
+}
+
+// mirrorRequest widens request to the whole gallery of its artist, scraps
+// included, ordered so that the newest submissions come first.
+func mirrorRequest(request *inkbunny.SubmissionSearchRequest) {
+	request.Text = ""
+	request.DaysLimit = 0
+	request.PoolID = 0
+	request.Type = []inkbunny.SubmissionType{inkbunny.SubmissionTypeAny}
+	request.Scraps = inkbunny.ScrapsBoth
+	request.OrderBy = inkbunny.OrderByCreateDatetime
+	request.UnreadSubmissions = inkbunny.No
+}
+
+// openMirror loads where the last mirror of artist stopped. Without it the
+// whole gallery is mirrored and the run is not recorded.
+func openMirror(root, artist string) *library.Mirror {
+	mirror, err := library.OpenMirror(root, artist)
+	if err != nil {
+		log.Warn("failed to load the last mirror, mirroring the whole gallery", "artist", artist, "err", err)
+		return nil
+	}
+	if since := mirror.Since(); since > 0 {
+		log.Info("Mirroring new submissions", "artist", artist, "since", since)
+	} else {
+		log.Info("Mirroring the whole gallery", "artist", artist)
+	}
+	return mirror
+}
+
+// mirrorPage lists the submissions of a search page that are newer than the
+// last mirror, and reports whether later pages may still hold any. Pages are
+// newest first, so a page without new submissions ends the mirror.
+func mirrorPage(mirror *library.Mirror, submissions []inkbunny.SubmissionSearch) ([]string, bool) {
+	ids := make([]string, 0, len(submissions))
+	for _, submission := range submissions {
+		if mirror.Keep(submission.SubmissionID.Int()) {
+			ids = append(ids, submission.SubmissionID.String())
+		}
+	}
+	return ids, len(ids) > 0 || len(submissions) == 0
+}
+
+// saveMirror records the mirror once everything it found was downloaded.
+func saveMirror(mirror *library.Mirror, complete bool) {
+	if mirror == nil {
+		return
+	}
+	if !complete {
+		log.Warn("Not every new submission was downloaded, the next mirror fetches them again")
+		return
+	}
+	if err := mirror.Save(); err != nil {
+		log.Warn("failed to record the mirror", "err", err)
+	}
+}
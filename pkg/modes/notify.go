@@ -0,0 +1,95 @@
+package modes
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+)
+
+// notifyAfter is how long a run takes before notifyLongRun shows it, see
+// --notify-after.
+var notifyAfter time.Duration
+
+// runStarted is when the program started, for the runs that fatal stops.
+var runStarted = time.Now()
+
+// notifyRun tells notifiers how a run went. Watch cycles that found nothing
+// new are not worth a notification.
+func notifyRun(notifiers appdownloads.Notifiers, summary appdownloads.RunSummary, cycle bool) {
+	if len(notifiers) == 0 || (cycle && summary.Files == 0 && summary.Failed == 0) {
+		return
+	}
+	if err := notifiers.Notify(context.Background(), runNotification(summary)); err != nil {
+		log.Warn("Failed to send notification", "err", err)
+	}
+}
+
+// runNotification is the notification of how a run went.
+func runNotification(summary appdownloads.RunSummary) appdownloads.Notification {
+	notification := appdownloads.Notification{
+		Title:   "Inkbunny Downloader finished",
+		Message: fmt.Sprintf("Downloaded %d files.", summary.Files),
+		Level:   appdownloads.NotifyInfo,
+		Run:     &summary,
+	}
+	if summary.Failed > 0 {
+		notification.Title = "Inkbunny Downloader finished with failures"
+		notification.Message = fmt.Sprintf("Downloaded %d files, %d failed.", summary.Files, summary.Failed)
+		notification.Level = appdownloads.NotifyWarn
+	}
+	return notification
+}
+
+// notifyLongRun shows notification on the desktop when the run that started
+// at started took at least --notify-after, so that a terminal left in the
+// background tells when it is done. Not every machine has a desktop to show it
+// on, so failing to is only logged when debugging.
+func notifyLongRun(started time.Time, notification appdownloads.Notification) {
+	if notifyAfter <= 0 || time.Since(started) < notifyAfter {
+		return
+	}
+	if err := (appdownloads.DesktopNotifier{}).Notify(context.Background(), notification); err != nil {
+		log.Debug("Failed to show desktop notification", "err", err)
+	}
+}
+
+// notifiesDesktop reports whether notifiers already show notifications on the
+// desktop.
+func notifiesDesktop(notifiers appdownloads.Notifiers) bool {
+	return slices.ContainsFunc(notifiers, func(notifier appdownloads.Notifier) bool {
+		_, ok := notifier.(appdownloads.DesktopNotifier)
+		return ok
+	})
+}
+
+// topArtists lists the artists of counts by how many of their files were
+// downloaded, most first.
+func topArtists(counts map[string]int) []string {
+	artists := slices.Collect(maps.Keys(counts))
+	slices.SortFunc(artists, func(a, b string) int {
+		return cmp.Or(cmp.Compare(counts[b], counts[a]), strings.Compare(a, b))
+	})
+	return artists
+}
+
+// notifySubmission posts a submission that was just downloaded to the webhook
+// of --webhook.
+func notifySubmission(webhook appdownloads.Notifier, event appdownloads.SubmissionEvent) {
+	notification := appdownloads.Notification{
+		Title:      "Downloaded submission",
+		Message:    fmt.Sprintf("%s by %s, %d files.", event.Title, event.Artist, event.Files),
+		Level:      appdownloads.NotifyInfo,
+		Submission: &event,
+	}
+	if err := webhook.Notify(context.Background(), notification); err != nil {
+		log.Warn("Failed to post submission to webhook", "submission", event.SubmissionID, "err", err)
+	}
+}
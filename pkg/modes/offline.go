@@ -0,0 +1,140 @@
+package modes
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// siteProbeURL is requested to tell whether Inkbunny can be reached again.
+	siteProbeURL      = "https://inkbunny.net/"
+	siteProbeTimeout  = 30 * time.Second
+	offlineMinBackoff = 5 * time.Second
+	offlineMaxBackoff = 5 * time.Minute
+	// offlineMaxWait is how long a run stays parked before the files it held
+	// back fail like any other.
+	offlineMaxWait = 30 * time.Minute
+)
+
+var errSiteUnavailable = errors.New("inkbunny is unavailable")
+
+// offlineGate parks a run while Inkbunny cannot be reached. The first worker
+// to find the site gone probes it with a growing backoff until it answers, and
+// every other one waits for that instead of failing what it was on. A nil
+// *offlineGate parks nothing.
+type offlineGate struct {
+	// alive is called while parked, so that waiting for the site does not count
+	// as the run having stalled.
+	alive func()
+
+	mu   sync.Mutex
+	park *offlinePark
+}
+
+// offlinePark is a single stretch of the site being gone. back is only read
+// once done is closed.
+type offlinePark struct {
+	done chan struct{}
+	back bool
+}
+
+func newOfflineGate(alive func()) *offlineGate {
+	return &offlineGate{alive: alive}
+}
+
+// Wait blocks until Inkbunny can be reached again when err tells that it could
+// not be, and reports whether it came back for the caller to try again. Only
+// a site that also fails its own probe parks the run, so a file host that is
+// down is left to the retries and the host failover. It gives up and reports
+// false after offlineMaxWait.
+func (g *offlineGate) Wait(err error) bool {
+	if g == nil || !siteUnreachable(err) {
+		return false
+	}
+	g.mu.Lock()
+	park := g.park
+	g.mu.Unlock()
+	if park == nil {
+		if siteReachable() {
+			return false
+		}
+		g.mu.Lock()
+		if g.park == nil {
+			g.park = &offlinePark{done: make(chan struct{})}
+			log.Warn("Inkbunny cannot be reached, parking the queue until it is back", "err", err)
+			go g.probe(g.park)
+		}
+		park = g.park
+		g.mu.Unlock()
+	}
+	<-park.done
+	return park.back
+}
+
+func (g *offlineGate) probe(park *offlinePark) {
+	started := time.Now()
+	for delay := offlineMinBackoff; ; delay = min(delay*2, offlineMaxBackoff) {
+		g.alive()
+		time.Sleep(delay)
+		if siteReachable() {
+			park.back = true
+			break
+		}
+		if time.Since(started) >= offlineMaxWait {
+			break
+		}
+		log.Info("Inkbunny is still unreachable", "offline", time.Since(started).Round(time.Second), "next try", min(delay*2, offlineMaxBackoff))
+	}
+	if park.back {
+		log.Info("Inkbunny is back, resuming the queue", "offline", time.Since(started).Round(time.Second))
+	} else {
+		log.Warn("Inkbunny is still unreachable, no longer parking the queue", "offline", time.Since(started).Round(time.Second))
+	}
+	g.alive()
+
+	g.mu.Lock()
+	g.park = nil
+	g.mu.Unlock()
+	close(park.done)
+}
+
+func siteReachable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), siteProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, siteProbeURL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+// siteUnreachable reports whether err means that Inkbunny could not be reached
+// at all, rather than that it turned a request down.
+func siteUnreachable(err error) bool {
+	if errors.Is(err, errSiteUnavailable) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// gatewayStatus reports whether a response status means Inkbunny itself is
+// down behind its proxy.
+func gatewayStatus(code int) bool {
+	switch code {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
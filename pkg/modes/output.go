@@ -0,0 +1,66 @@
+package modes
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+// offloadSubmission uploads the files of a submission just downloaded into
+// root, with their sidecars and archive, to output and then removes the files
+// and archive. entries, the history of those files, are recorded again as
+// offloaded, which keeps later runs from downloading them again and lint and
+// --scan-deleted from taking them for deleted. The sidecars stay as a local
+// record of what is in output.
+func offloadSubmission(output appdownloads.Storage, index *library.Index, root string, submissionID string, files []string, entries []library.Entry, sidecars apptypes.SidecarOptions, archive string) {
+	paths := submissionPaths(submissionID, files, sidecars, archive)
+	if archive != "" {
+		files = append(files, archive)
+	}
+	if err := appdownloads.Offload(output, root, paths); err != nil {
+		log.Warn("failed to upload submission, keeping its files", "output", output, "submission", submissionID, "err", err)
+		return
+	}
+	for _, entry := range entries {
+		entry.Offloaded = fmt.Sprint(output)
+		if err := index.Record(entry); err != nil {
+			log.Warn("failed to record the upload, keeping its files", "output", output, "submission", submissionID, "err", err)
+			return
+		}
+	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			log.Warn("failed to remove uploaded file", "file", file, "err", err)
+		}
+	}
+	log.Debug("Uploaded submission", "output", output, "submission", submissionID, "files", len(files))
+}
+
+// submissionPaths lists the files of a submission just downloaded with the
+// sidecars written beside them and its archive.
+func submissionPaths(submissionID string, files []string, sidecars apptypes.SidecarOptions, archive string) []string {
+	paths := slices.Clone(files)
+	for _, file := range files {
+		paths = append(paths, appdownloads.WrittenSidecars(file, submissionID, sidecars)...)
+	}
+	if archive != "" {
+		paths = append(paths, archive)
+	}
+	return paths
+}
+
+// libraryPaths lists every file recorded in index with the sidecars written
+// beside it.
+func libraryPaths(index *library.Index, sidecars apptypes.SidecarOptions) []string {
+	var paths []string
+	for _, entry := range index.Recorded() {
+		paths = append(paths, submissionPaths(entry.SubmissionID, index.Files(entry), sidecars, "")...)
+	}
+	return paths
+}
@@ -0,0 +1,69 @@
+package modes
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+// poolSubmissionIDs lists the submissions of pool in their order in it.
+func poolSubmissionIDs(user *inkbunny.User, offline *offlineGate, pool inkbunny.Pool) ([]string, error) {
+	request := inkbunny.SubmissionSearchRequest{
+		SID:                user.SID,
+		PoolID:             pool.PoolID,
+		OrderBy:            inkbunny.OrderByPoolOrder,
+		Scraps:             inkbunny.ScrapsBoth,
+		SubmissionsPerPage: 100,
+		GetRID:             inkbunny.Yes,
+	}
+	var ids []string
+	for page := inkbunny.IntString(1); ; page++ {
+		request.Page = page
+		results, err := retryAPIStep(offline, fmt.Sprintf("pool %q, page %d", pool.Name, page), func(ctx context.Context) (inkbunny.SubmissionSearchResponse, error) {
+			return user.SearchSubmissionsContext(ctx, request)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, submission := range results.Submissions {
+			ids = append(ids, submission.SubmissionID.String())
+		}
+		if page >= results.PagesCount || len(results.Submissions) == 0 {
+			return ids, nil
+		}
+		request.RID = results.RID
+		request.GetRID = inkbunny.No
+	}
+}
+
+// runPoolTargets downloads each pool of the pool subcommand in turn.
+func runPoolTargets(config flags.Config, root string) {
+	for _, id := range config.PoolIDs {
+		log.Info("Downloading pool", "pool", id, "url", "https://inkbunny.net/poolview_process.php?pool_id="+id.String())
+		single := config
+		single.PoolIDs = []inkbunny.IntString{id}
+		single.ArtistName = ""
+		single.SearchWords = ""
+		downloadSearch(single, root, nil)
+	}
+}
+
+// poolRequest turns request into the listing of the pool of the pool
+// subcommand in its order, whatever the type, age or artist of its members.
+func poolRequest(request *inkbunny.SubmissionSearchRequest, poolID inkbunny.IntString) {
+	request.Text = ""
+	request.Username = ""
+	request.UserID = 0
+	request.DaysLimit = 0
+	request.FavsUserID = 0
+	request.PoolID = poolID
+	request.Type = []inkbunny.SubmissionType{inkbunny.SubmissionTypeAny}
+	request.Scraps = inkbunny.ScrapsBoth
+	request.OrderBy = inkbunny.OrderByPoolOrder
+	request.UnreadSubmissions = inkbunny.No
+}
@@ -0,0 +1,180 @@
+package modes
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/huh"
+
+	"github.com/ellypaws/inkbunny"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+var (
+	errUnknownPreset  = errors.New("unknown preset")
+	errPresetWatching = errors.New("headless mode cannot search the artists you watch, give --artist instead")
+)
+
+// loadPreset returns the preset called name from the saved state of the active
+// library.
+func loadPreset(name string) (apptypes.SyncPreset, error) {
+	store, err := appstorage.NewStateStore()
+	if err != nil {
+		return apptypes.SyncPreset{}, err
+	}
+	state, err := store.Load()
+	if err != nil {
+		return apptypes.SyncPreset{}, err
+	}
+	return findPreset(state.Presets, name)
+}
+
+func findPreset(presets []apptypes.SyncPreset, name string) (apptypes.SyncPreset, error) {
+	name = strings.TrimSpace(name)
+	for _, preset := range presets {
+		if strings.EqualFold(preset.Name, name) {
+			return preset, nil
+		}
+	}
+	return apptypes.SyncPreset{}, fmt.Errorf("%w: %q", errUnknownPreset, name)
+}
+
+// presetLibraryDir makes the download directory of the preset of config the
+// directory of a headless run, as in the terminal UI. --library-dir keeps its
+// value.
+func presetLibraryDir(config flags.Config) (flags.Config, error) {
+	if config.Preset == "" || config.Provided("library-dir") {
+		return config, nil
+	}
+	preset, err := loadPreset(config.Preset)
+	if config.RunPreset != nil {
+		preset, err = *config.RunPreset, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if directory := strings.TrimSpace(preset.Options.DownloadDirectory); directory != "" {
+		config.LibraryDir = directory
+	}
+	return config, nil
+}
+
+// headlessPreset reports whether preset can run without the terminal UI, which
+// is the only one to list the artists watched.
+func headlessPreset(config flags.Config, preset apptypes.SyncPreset) error {
+	if preset.Search.UseWatchingArtists && !config.Provided("artist") {
+		return fmt.Errorf("%w: %q", errPresetWatching, preset.Name)
+	}
+	return nil
+}
+
+// applyPreset fills the search of config from preset. Flags given on the
+// command line keep their values.
+func applyPreset(config flags.Config, preset apptypes.SyncPreset) flags.Config {
+	search := preset.Search
+	if !config.Provided("search", "text") {
+		config.SearchWords = search.Query
+	}
+	if !config.Provided("join") && search.JoinType != "" {
+		config.StringJoinType = strings.ToLower(search.JoinType)
+	}
+	if !config.Provided("in") {
+		var fields []string
+		if search.SearchInKeywords {
+			fields = append(fields, "keywords")
+		}
+		if search.SearchInTitle {
+			fields = append(fields, "title")
+		}
+		if search.SearchInDescription {
+			fields = append(fields, "description")
+		}
+		if search.SearchInMD5 {
+			fields = append(fields, "md5")
+		}
+		if len(fields) > 0 {
+			config.SearchIn = strings.Join(fields, ",")
+		}
+	}
+	if !config.Provided("artist") {
+		config.ArtistName = strings.Join(search.ArtistNames, ",")
+	}
+	if !config.Provided("favby") {
+		config.FavBy = search.FavoritesBy
+	}
+	if !config.Provided("time") {
+		config.TimeRange = search.TimeRangeDays
+	}
+	if !config.Provided("type") {
+		config.SubmissionTypes = nil
+		for _, submissionType := range search.SubmissionTypes {
+			config.SubmissionTypes = append(config.SubmissionTypes, inkbunny.SubmissionType(submissionType))
+		}
+	}
+	if search.PoolID > 0 {
+		config.PoolID = inkbunny.IntString(search.PoolID)
+	}
+	if search.Scraps != "" {
+		config.Scraps = inkbunny.Scraps(strings.ToLower(search.Scraps))
+	}
+	if !config.Provided("order") && search.OrderBy != "" {
+		config.OrderBy = search.OrderBy
+	}
+	if !config.Provided("limit", "max") && search.MaxDownloads > 0 {
+		config.MaxDownloads = strconv.Itoa(search.MaxDownloads)
+	}
+	if active := cmp.Or(preset.Options.MaxActive, search.MaxActive); !config.Provided("active") && active > 0 {
+		config.MaxActive = strconv.Itoa(active)
+	}
+	if !config.Provided("caption", "sidecars", "no-captions") {
+		sidecars := appdownloads.LegacySidecars(preset.Options.SaveKeywords || search.SaveKeywords)
+		if preset.Options.Sidecars != nil {
+			sidecars = *preset.Options.Sidecars
+		}
+		sidecars.DeriveKeywords = sidecars.DeriveKeywords || config.Sidecars.DeriveKeywords
+		sidecars.PerSubmission = sidecars.PerSubmission || config.Sidecars.PerSubmission
+		config.Sidecars = sidecars
+	}
+	if !config.Provided("pattern") && preset.Options.DownloadPattern != "" {
+		config.Pattern = preset.Options.DownloadPattern
+	}
+	if !config.Provided("collabs") && preset.Options.Collabs != "" {
+		config.Collabs = appdownloads.NormalizeCollabsMode(preset.Options.Collabs)
+	}
+	if !config.Provided("characters") && len(preset.Options.Characters) > 0 {
+		config.Characters = strings.Join(preset.Options.Characters, ",")
+	}
+	return config
+}
+
+// promptPreset asks which saved preset to fill the search form with. It
+// returns false when none was picked.
+func promptPreset(presets []apptypes.SyncPreset) (apptypes.SyncPreset, bool, error) {
+	choice := -1
+	options := []huh.Option[int]{huh.NewOption("None", -1)}
+	for i, preset := range presets {
+		options = append(options, huh.NewOption(preset.Name, i))
+	}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[int]().
+				Title("Load preset").
+				Options(options...).
+				Value(&choice),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return apptypes.SyncPreset{}, false, err
+	}
+	if choice < 0 {
+		return apptypes.SyncPreset{}, false, nil
+	}
+	return presets[choice], true, nil
+}
@@ -0,0 +1,50 @@
+package modes
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+)
+
+// maxProfilePage bounds how much of a profile page is kept.
+const maxProfilePage = 8 << 20
+
+// saveArtistProfile refreshes the profile kept in the folder of artist. Without
+// it only the profile is missing, so failures are logged and the run goes on.
+func saveArtistProfile(client *http.Client, dir, artist string, userID inkbunny.IntString) {
+	profileURL := "https://inkbunny.net/" + url.PathEscape(artist)
+	page, err := fetchProfilePage(client, profileURL)
+	if err != nil {
+		log.Warn("failed to fetch the artist profile, keeping the last one", "artist", artist, "err", err)
+		return
+	}
+	var id string
+	if userID > 0 {
+		id = userID.String()
+	}
+	profile := appdownloads.NewArtistProfile(artist, id, profileURL, page)
+	if err := appdownloads.WriteArtistProfile(dir, profile, page); err != nil {
+		log.Warn("failed to save the artist profile", "artist", artist, "err", err)
+		return
+	}
+	log.Info("Saved artist profile", "artist", artist, "links", len(profile.Links), "folder", dir)
+}
+
+func fetchProfilePage(client *http.Client, profileURL string) ([]byte, error) {
+	resp, err := client.Get(profileURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxProfilePage))
+}
@@ -0,0 +1,169 @@
+package modes
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tea "charm.land/bubbletea/v2"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/utils"
+)
+
+const (
+	progressRefresh = 200 * time.Millisecond
+	progressBarSize = 30
+	// progressNameSize is how much of a file name is shown beside its bar.
+	progressNameSize = 40
+)
+
+// transfer is a file being downloaded, with its size from Content-Length, or
+// 0 when the server does not send one.
+type transfer struct {
+	name    string
+	started time.Time
+	written atomic.Int64
+	total   atomic.Int64
+}
+
+// transferList holds the files the workers of a run are downloading.
+type transferList struct {
+	mu   sync.Mutex
+	list []*transfer
+}
+
+func (l *transferList) start(filename string) *transfer {
+	t := &transfer{name: filepath.Base(filename), started: time.Now()}
+	l.mu.Lock()
+	l.list = append(l.list, t)
+	l.mu.Unlock()
+	return t
+}
+
+func (l *transferList) done(t *transfer) {
+	l.mu.Lock()
+	l.list = slices.DeleteFunc(l.list, func(other *transfer) bool { return other == t })
+	l.mu.Unlock()
+}
+
+func (l *transferList) active() []*transfer {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.list)
+}
+
+// showProgress takes over the terminal with the progress of every file being
+// downloaded and of the run as a whole, while the logs only go to log.txt.
+// Without a terminal or a log file to keep the logs in, nothing is shown. The
+// returned func removes the view and sends the logs to the terminal again.
+func showProgress(counters *runCounters, enabled bool) func() {
+	if !enabled || !terminalOutput() {
+		return func() {}
+	}
+	restoreLogs, ok := utils.LogToFileOnly()
+	if !ok {
+		return func() {}
+	}
+	bar := progress.New(progress.WithDefaultGradient(), progress.WithWidth(progressBarSize))
+	program := tea.NewProgram(progressView{counters: counters, bar: bar, started: time.Now()},
+		tea.WithInput(nil),
+		tea.WithoutSignalHandler(),
+		tea.WithOutput(os.Stdout),
+	)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = program.Run()
+	}()
+	return func() {
+		program.Quit()
+		<-done
+		restoreLogs()
+	}
+}
+
+func terminalOutput() bool {
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+type progressTickMsg struct{}
+
+// progressView shows a bar for each file being downloaded and one for the run.
+type progressView struct {
+	counters *runCounters
+	bar      progress.Model
+	started  time.Time
+	width    int
+}
+
+func (m progressView) Init() tea.Cmd {
+	return m.tick()
+}
+
+func (m progressView) tick() tea.Cmd {
+	return tea.Tick(progressRefresh, func(time.Time) tea.Msg { return progressTickMsg{} })
+}
+
+func (m progressView) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+	case progressTickMsg:
+		return m, m.tick()
+	}
+	return m, nil
+}
+
+func (m progressView) View() tea.View {
+	faint := lipgloss.NewStyle().Faint(true)
+	var view strings.Builder
+	for _, t := range m.counters.transfers.active() {
+		written, total := t.written.Load(), t.total.Load()
+		fraction := 0.0
+		if total > 0 {
+			fraction = float64(written) / float64(total)
+		}
+		size := appdownloads.FormatSize(float64(written))
+		if total > 0 {
+			size += " / " + appdownloads.FormatSize(float64(total))
+		}
+		speed := float64(written) / max(time.Since(t.started).Seconds(), 1)
+		fmt.Fprintf(&view, "%-*s %s %s %s\n",
+			progressNameSize, ansi.Truncate(t.name, progressNameSize, "…"),
+			m.bar.ViewAs(fraction), size, faint.Render(appdownloads.FormatSize(speed)+"/s"))
+	}
+
+	run := m.counters.progress()
+	files := run.Downloaded + run.Failed + run.Active + run.Queued
+	fraction := 0.0
+	if files > 0 {
+		fraction = float64(run.Downloaded+run.Failed) / float64(files)
+	}
+	speed := float64(run.Bytes) / max(time.Since(m.started).Seconds(), 1)
+	fmt.Fprintf(&view, "\n%-*s %s %d/%d files, %s %s",
+		progressNameSize, "Total", m.bar.ViewAs(fraction),
+		run.Downloaded, files, appdownloads.FormatSize(float64(run.Bytes)), faint.Render(appdownloads.FormatSize(speed)+"/s"))
+	if run.Failed > 0 {
+		fmt.Fprintf(&view, ", %d failed", run.Failed)
+	}
+
+	content := view.String()
+	if m.width > 0 {
+		lines := strings.Split(content, "\n")
+		for i, line := range lines {
+			lines[i] = ansi.Truncate(line, m.width, "")
+		}
+		content = strings.Join(lines, "\n")
+	}
+	return tea.NewView(content)
+}
@@ -0,0 +1,101 @@
+package modes
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/huh/spinner"
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+type ratingsChange struct {
+	user     *inkbunny.User
+	original string
+	current  string
+}
+
+// ratingsChanges remembers, per account, the ratings it had before this
+// process first changed them so they can be put back on exit.
+var ratingsChanges = struct {
+	mu       sync.Mutex
+	accounts map[string]*ratingsChange
+}{accounts: make(map[string]*ratingsChange)}
+
+func recordRatingsChange(user *inkbunny.User, from, to string, reason string) {
+	if user == nil {
+		return
+	}
+	from = normalizedRatingsMask(from)
+	to = normalizedRatingsMask(to)
+
+	ratingsChanges.mu.Lock()
+	key := strings.ToLower(user.Username)
+	change, ok := ratingsChanges.accounts[key]
+	if !ok {
+		change = &ratingsChange{original: from}
+		ratingsChanges.accounts[key] = change
+	}
+	change.user = user
+	change.current = to
+	ratingsChanges.mu.Unlock()
+
+	store, err := appstorage.NewStateStore()
+	if err != nil {
+		log.Warn("failed to open state store for ratings audit", "err", err)
+		return
+	}
+	if err := store.AppendRatingsAudit(types.RatingsAuditEntry{
+		Time:     time.Now(),
+		Username: user.Username,
+		From:     from,
+		To:       to,
+		Reason:   reason,
+	}); err != nil {
+		log.Warn("failed to record ratings change", "err", err)
+	}
+}
+
+// restoreRatingsOnExit puts every account whose ratings were changed during
+// this run back to what it had before. Guests are skipped since their ratings
+// are discarded together with the session on logout.
+func restoreRatingsOnExit(enabled bool) {
+	if !enabled {
+		return
+	}
+
+	ratingsChanges.mu.Lock()
+	pending := make([]ratingsChange, 0, len(ratingsChanges.accounts))
+	for _, change := range ratingsChanges.accounts {
+		if change.original == change.current || change.user == nil || change.user.SID == "" {
+			continue
+		}
+		if strings.EqualFold(change.user.Username, "guest") {
+			continue
+		}
+		pending = append(pending, *change)
+	}
+	ratingsChanges.mu.Unlock()
+
+	for _, change := range pending {
+		var err error
+		ratings := inkbunny.ParseMask(change.original)
+		spinner.New().
+			Title("Restoring ratings...").
+			Action(func() {
+				err = change.user.ChangeRatings(ratings)
+			}).Run()
+		if err != nil {
+			log.Error("failed to restore ratings", "username", change.user.Username, "err", err)
+			continue
+		}
+		change.user.Ratings = ratings
+		recordRatingsChange(change.user, change.current, change.original, "restore on exit")
+		log.Info("Restored ratings", "username", change.user.Username, "ratings", describeRatingsMask(change.original))
+	}
+}
@@ -0,0 +1,67 @@
+package modes
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	retryMinBackoff = time.Second
+	retryMaxBackoff = 30 * time.Second
+)
+
+// maxRateLimited is how many 429 responses in a row a file is retried after,
+// each held back by the throttle of the run, before it counts as failed.
+const maxRateLimited = 5
+
+var (
+	errServerStatus = errors.New("inkbunny failed to serve the file")
+	errRateLimited  = errors.New("inkbunny kept rate limiting the file")
+)
+
+// retryFile runs fetch until the file downloads, trying again up to retries
+// times after a transient failure. A try that waited for offline to unpark
+// the run counts as one of them. A failed fetch keeps its part file, so each
+// try resumes where the last one broke off.
+func retryFile(offline *offlineGate, retries int, filename string, fetch func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fetch()
+		if err == nil {
+			return nil
+		}
+		if attempt > retries || !transientError(err) {
+			return err
+		}
+		if offline.Wait(err) {
+			continue
+		}
+		delay := retryBackoff(attempt)
+		log.Warn("Download failed, retrying", "file", filename, "attempt", attempt, "retries", retries, "in", delay.Round(time.Millisecond), "err", err)
+		time.Sleep(delay)
+	}
+}
+
+// transientError reports whether err may go away when the download is tried
+// again: a network error, a timeout, a body that broke off, or a server error.
+func transientError(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, errServerStatus) ||
+		errors.Is(err, errRateLimited) ||
+		errors.Is(err, errSiteUnavailable)
+}
+
+// retryBackoff doubles the wait with every attempt, with up to half of it
+// left to chance so that workers failing together do not retry together.
+func retryBackoff(attempt int) time.Duration {
+	delay := min(retryMinBackoff<<(attempt-1), retryMaxBackoff)
+	return delay/2 + rand.N(delay/2+1)
+}
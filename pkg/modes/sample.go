@@ -0,0 +1,58 @@
+package modes
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+)
+
+// previewSample keeps the first few submissions of each artist a search
+// finds, so that a broad search shows what every artist makes before any of
+// them is mirrored. A nil *previewSample keeps everything. It is only used by
+// the goroutine that pages through the search.
+type previewSample struct {
+	per     int
+	kept    map[string]int
+	skipped int
+}
+
+func newPreviewSample(per int) *previewSample {
+	if per <= 0 {
+		return nil
+	}
+	return &previewSample{per: per, kept: make(map[string]int)}
+}
+
+// Keep narrows ids, which are from a search page of submissions, down to those
+// of artists that have not had their sample yet.
+func (s *previewSample) Keep(submissions []inkbunny.SubmissionSearch, ids []string) []string {
+	if s == nil {
+		return ids
+	}
+	artists := make(map[string]string, len(submissions))
+	for _, submission := range submissions {
+		artists[submission.SubmissionID.String()] = strings.ToLower(submission.Username)
+	}
+	kept := ids[:0]
+	for _, id := range ids {
+		artist := artists[id]
+		if s.kept[artist] >= s.per {
+			s.skipped++
+			continue
+		}
+		s.kept[artist]++
+		kept = append(kept, id)
+	}
+	return kept
+}
+
+// Report logs how many artists were sampled and how many submissions were
+// left out of their samples.
+func (s *previewSample) Report() {
+	if s == nil {
+		return
+	}
+	log.Info("Preview sample", "artists", len(s.kept), "per artist", s.per, "left out", s.skipped)
+}
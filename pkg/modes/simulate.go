@@ -0,0 +1,207 @@
+package modes
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+// simulationFolder is where a simulation places its files, inside the folder
+// of its recordings, so that it never touches the library.
+const simulationFolder = "files"
+
+var errNotRecorded = errors.New("request was not recorded")
+
+// simulationRoot is the download folder of a simulation from dir.
+func simulationRoot(dir string) string {
+	return filepath.Join(dir, simulationFolder)
+}
+
+// simulatedUser stands in for the logged in user of a simulation, which makes
+// no login request.
+func simulatedUser() *inkbunny.User {
+	return &inkbunny.User{Username: "simulation", SID: "simulation"}
+}
+
+// simulationClient returns client with its API responses recorded into
+// --record, or with every request answered from --simulate instead of the
+// network.
+func simulationClient(config flags.Config, client *http.Client) *http.Client {
+	var transport http.RoundTripper
+	switch {
+	case config.Simulate != "":
+		transport = replayTransport{dir: config.Simulate}
+	case config.Record != "":
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		transport = recordingTransport{base: base, dir: config.Record}
+	default:
+		return client
+	}
+	wrapped := *client
+	wrapped.Transport = transport
+	return &wrapped
+}
+
+// apiRequest reports whether req calls the Inkbunny API rather than fetching
+// a file.
+func apiRequest(req *http.Request) bool {
+	return strings.HasPrefix(path.Base(req.URL.Path), "api_")
+}
+
+// recordingName is the file a response to req is recorded in. Requests that
+// only differ in their session share it, so a recording replays under any
+// session.
+func recordingName(req *http.Request) (string, error) {
+	values, raw, err := requestValues(req)
+	if err != nil {
+		return "", err
+	}
+	values.Del("sid")
+	hash := sha256.New()
+	io.WriteString(hash, req.Method+" "+req.URL.Path+"?"+values.Encode())
+	hash.Write(raw)
+	endpoint := strings.TrimSuffix(path.Base(req.URL.Path), ".php")
+	return fmt.Sprintf("%s-%s.json", endpoint, hex.EncodeToString(hash.Sum(nil))[:16]), nil
+}
+
+// requestValues reads the query and form of req, leaving its body to be read
+// again. A body that is not a form is returned as is.
+func requestValues(req *http.Request) (url.Values, []byte, error) {
+	values := req.URL.Query()
+	if req.Body == nil {
+		return values, nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	mediaType, params, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, nil, err
+		}
+		for key, value := range form {
+			values[key] = append(values[key], value...)
+		}
+		return values, nil, nil
+	case strings.HasPrefix(mediaType, "multipart/"):
+		form, err := multipart.NewReader(bytes.NewReader(body), params["boundary"]).ReadForm(1 << 20)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer form.RemoveAll()
+		for key, value := range form.Value {
+			values[key] = append(values[key], value...)
+		}
+		return values, nil, nil
+	}
+	return values, body, nil
+}
+
+// recordingTransport keeps every API response in dir for a simulation to
+// replay. Logins are left out, as their response holds a session, and a
+// simulation does not log in.
+type recordingTransport struct {
+	base http.RoundTripper
+	dir  string
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !apiRequest(req) || path.Base(req.URL.Path) == "api_login.php" {
+		return t.base.RoundTrip(req)
+	}
+	name, err := recordingName(req)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	err = os.MkdirAll(t.dir, 0o755)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(t.dir, name), body, 0o644)
+	}
+	if err != nil {
+		log.Warn("failed to record response", "endpoint", path.Base(req.URL.Path), "err", err)
+	}
+	return resp, nil
+}
+
+// replayTransport answers API requests from the recordings in dir and every
+// file with a few placeholder bytes, making no network request at all. An API
+// request that was not recorded falls back to <endpoint>.json in dir, such as
+// api_search.json, so that mock responses can be written by hand.
+type replayTransport struct {
+	dir string
+}
+
+func (t replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !apiRequest(req) {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return replayResponse(req, []byte("simulated "+path.Base(req.URL.Path)+"\n")), nil
+	}
+	name, err := recordingName(req)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := strings.TrimSuffix(path.Base(req.URL.Path), ".php")
+	for _, candidate := range []string{name, endpoint + ".json"} {
+		body, err := os.ReadFile(filepath.Join(t.dir, candidate))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return replayResponse(req, body), nil
+	}
+	return nil, fmt.Errorf("%w: %s", errNotRecorded, name)
+}
+
+func replayResponse(req *http.Request, body []byte) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Length": {fmt.Sprint(len(body))}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
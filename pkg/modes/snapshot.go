@@ -0,0 +1,27 @@
+package modes
+
+import (
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/buildinfo"
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+// recordRun writes the snapshot of the run to its folder, for --from-run to
+// start the same run again. The session of request is left out.
+func recordRun(config flags.Config, preset *apptypes.SyncPreset, request inkbunny.SubmissionSearchRequest) {
+	request.SID = ""
+	snapshot := appstorage.RunSnapshot{
+		Version: buildinfo.DisplayVersion(),
+		Args:    config.Args,
+		Preset:  preset,
+		Search:  &request,
+	}
+	if err := currentRun.WriteSnapshot(snapshot); err != nil {
+		log.Warn("failed to record the run", "err", err)
+	}
+}
@@ -0,0 +1,126 @@
+package modes
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+)
+
+// statusInterval is how often a downloading run reports its progress.
+const statusInterval = 2 * time.Second
+
+// runStatus prints the progress of the run downloading into root, or of the
+// last one when none is running.
+func runStatus(root string) {
+	run, status, err := appstorage.LatestRunStatus(root)
+	if errors.Is(err, appstorage.ErrNoRunStatus) {
+		log.Info("No run has downloaded into this library yet")
+		return
+	}
+	if err != nil {
+		fatal(ExitFailure, "failed to read run status", "run", run.ID, "err", err)
+	}
+
+	holder, locked, err := library.Holder(status.Library)
+	if err != nil {
+		log.Warn("failed to check whether the library is in use", "library", status.Library, "err", err)
+	}
+	running := locked && holder == strconv.Itoa(status.PID) && !status.Finished
+
+	fields := []any{
+		"run", run.ID,
+		"library", status.Library,
+		"queued", status.Queued,
+		"active", status.Active,
+		"downloaded", status.Downloaded,
+		"failed", status.Failed,
+		"transferred", appdownloads.FormatSize(float64(status.Bytes)),
+	}
+	switch {
+	case running:
+		fields = append(fields,
+			"pid", status.PID,
+			"speed", appdownloads.FormatSize(status.BytesPerSecond)+"/s",
+			"eta", status.ETA,
+			"updated", time.Since(status.Updated).Round(time.Second).String()+" ago",
+		)
+		log.Info("Run in progress", fields...)
+	case locked:
+		log.Info("Library is in use by a run that has not reported progress yet", "pid", holder)
+		log.Info("Last reported run", fields...)
+	case status.Finished:
+		log.Info("No run is using the library, the last one finished", append(fields, "at", status.Updated.Format(time.DateTime))...)
+	default:
+		log.Info("No run is using the library, the last one stopped early", append(fields, "at", status.Updated.Format(time.DateTime))...)
+	}
+}
+
+// reportStatus writes the progress of the run downloading into root every few
+// seconds until the returned func is called with its final progress.
+func reportStatus(root string, progress func() appstorage.RunProgress) func() {
+	if currentRun == nil {
+		return func() {}
+	}
+	writer := currentRun.NewStatusWriter(root)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(statusInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := writer.Update(progress()); err != nil {
+					log.Debug("failed to write run status", "err", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+		if err := writer.Finish(progress()); err != nil {
+			log.Warn("failed to write run status", "err", err)
+		}
+	}
+}
+
+// runCounters tracks the files of a headless run for its status.
+type runCounters struct {
+	queued, active, downloaded, failed, bytes atomic.Int64
+	// transfers are the files being downloaded, for the progress view.
+	transfers transferList
+}
+
+func (c *runCounters) progress() appstorage.RunProgress {
+	return appstorage.RunProgress{
+		Queued:     int(c.queued.Load()),
+		Active:     int(c.active.Load()),
+		Downloaded: int(c.downloaded.Load()),
+		Failed:     int(c.failed.Load()),
+		Bytes:      c.bytes.Load(),
+	}
+}
+
+// countingBody adds the bytes read from a response body to n.
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (r countingBody) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+	return n, err
+}
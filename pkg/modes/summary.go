@@ -0,0 +1,42 @@
+package modes
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+)
+
+// summaryArtists is how many of the artists with the most files the summary of
+// a run lists.
+const summaryArtists = 5
+
+// reportRun logs what a run did once it is done: what it went through,
+// downloaded, skipped and failed, how much and how fast, and the artists it
+// downloaded the most files of.
+func reportRun(summary appdownloads.RunSummary, files map[string]int) {
+	elapsed := time.Duration(summary.Seconds * float64(time.Second))
+	speed := 0.0
+	if summary.Seconds > 0 {
+		speed = float64(summary.Bytes) / summary.Seconds
+	}
+	log.Info("Run summary",
+		"submissions", summary.Processed,
+		"downloaded", summary.Files,
+		"skipped", summary.Skipped,
+		"failed", summary.Failed,
+		"size", appdownloads.FormatSize(float64(summary.Bytes)),
+		"speed", appdownloads.FormatSize(speed)+"/s",
+		"elapsed", elapsed.Round(time.Second).String(),
+	)
+	if len(summary.Artists) == 0 {
+		return
+	}
+	var top []any
+	for _, artist := range summary.Artists[:min(len(summary.Artists), summaryArtists)] {
+		top = append(top, artist, strconv.Itoa(files[artist])+" files")
+	}
+	log.Info("Top artists", top...)
+}
@@ -0,0 +1,16 @@
+package modes
+
+import (
+	"github.com/charmbracelet/log"
+
+	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+	apputils "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/utils"
+)
+
+// newThrottle returns the limiter that pauses every request of a run for the
+// Retry-After of a 429 response, logging how long it waits.
+func newThrottle() *apputils.RateLimiter {
+	return apputils.NewRateLimiter(func(notification apptypes.AppNotification) {
+		log.Warn(notification.Message)
+	})
+}
@@ -1,13 +1,16 @@
 package modes
 
 import (
+	"cmp"
 	"context"
 	"errors"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	tea "charm.land/bubbletea/v2"
 	spinnerModel "github.com/charmbracelet/bubbles/spinner"
@@ -19,15 +22,22 @@ import (
 
 	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
 	appinfo "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/info"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
 	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
 	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
 	apputils "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/utils"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flight"
 	uitui "github.com/ellypaws/inkbunny/cmd/downloader/pkg/tui"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/utils"
 )
 
 func RunTUI(config flags.Config) {
+	notifyAfter = config.NotifyAfter
+	tracer := newHTTPTracer(config.TraceHTTP, config.TraceHTTPMin)
+	defer tracer.Report()
+	throttle := newThrottle()
+	inkbunny.DefaultClient.SetClient(throttle.Client(tracer.Client(&http.Client{Timeout: 5 * time.Minute}), "API requests"))
 	var (
 		request         inkbunny.SubmissionSearchRequest
 		searchIn        []int
@@ -40,14 +50,16 @@ func RunTUI(config flags.Config) {
 		favoriteFilters []string
 		resultsPerPage  = 30
 
-		toDownload      int
-		downloadCaption bool
-		releaseStatus   apptypes.ReleaseStatus
-		store           *appstorage.StateStore
-		storedState     = appstorage.DefaultStoredState()
-		ratingsChanged  bool
-		err             error
+		toDownload     int
+		mirror         *library.Mirror
+		sidecars       apptypes.SidecarOptions
+		releaseStatus  apptypes.ReleaseStatus
+		store          *appstorage.StateStore
+		storedState    = appstorage.DefaultStoredState()
+		ratingsChanged bool
+		err            error
 	)
+	defer atExit(func() { restoreRatingsOnExit(config.RestoreRatings) })()
 
 	store, err = appstorage.NewStateStore()
 	if err != nil {
@@ -58,6 +70,100 @@ func RunTUI(config flags.Config) {
 	} else {
 		storedState = loadedState
 	}
+	// The library commands act on --library-dir, like in headless mode.
+	libraryDir := cmp.Or(strings.TrimSpace(config.LibraryDir), strings.TrimSpace(storedState.Settings.DownloadDirectory))
+	if libraryDir == "" {
+		libraryDir = appstorage.DefaultDownloadDirectory()
+	}
+	config.TimeZone = cmp.Or(config.TimeZone, storedState.Settings.TimeZone)
+	if !config.Provided("mime-routes") {
+		if routes, err := appdownloads.ParseMIMERoutes(storedState.Settings.MimeRoutes); err != nil {
+			log.Warn("ignoring invalid mime routes", "value", storedState.Settings.MimeRoutes, "err", err)
+		} else {
+			config.MimeRoutes = routes
+		}
+	}
+	if !config.Provided("select-files") {
+		if selection, err := appdownloads.ParseFileSelection(storedState.Settings.FileSelection); err != nil {
+			log.Warn("ignoring invalid file selection", "value", storedState.Settings.FileSelection, "err", err)
+		} else {
+			config.SelectFiles = selection
+		}
+	}
+	if !config.Provided("language") {
+		if languages, err := appdownloads.ParseLanguages(storedState.Settings.Languages); err != nil {
+			log.Warn("ignoring invalid languages", "value", storedState.Settings.Languages, "err", err)
+		} else {
+			config.Languages = languages
+		}
+	}
+	if !config.Provided("blacklist") {
+		config.Blacklist = storedState.Settings.KeywordBlacklist
+	}
+	if !config.Provided("ai") {
+		if filter, err := appdownloads.ParseAIFilter(storedState.Settings.AIFilter); err != nil {
+			log.Warn("ignoring invalid AI filter", "value", storedState.Settings.AIFilter, "err", err)
+		} else {
+			config.AIFilter = filter
+		}
+	}
+	var presetOptions apptypes.DownloadOptions
+	if config.Preset != "" {
+		preset, presetErr := findPreset(storedState.Presets, config.Preset)
+		if presetErr != nil {
+			fatal(ExitFailure, "failed to load preset", "err", presetErr)
+		}
+		config = applyPreset(config, preset)
+		presetOptions = preset.Options
+	}
+	if config.Mirror != "" {
+		config = mirrorConfig(config)
+	}
+	if config.Status {
+		runStatus(libraryDir)
+		return
+	}
+	if config.Clean {
+		runClean()
+		return
+	}
+	if config.MD5Lookup != "" {
+		runMD5Lookup(config)
+		return
+	}
+	if config.LibraryCommand() || config.Adopt != "" || config.Upgrade || config.Lint {
+		defer lockLibrary(config, libraryDir)()
+	}
+	if config.LibraryCommand() {
+		runLibraryCommands(config, libraryDir)
+		return
+	}
+	if config.Adopt != "" {
+		layout := appdownloads.Layout{
+			Collabs:    cmp.Or(config.Collabs, storedState.Settings.Collabs),
+			Characters: storedState.Settings.Characters,
+			TimeZone:   config.TimeZone,
+		}
+		if characters := appdownloads.ParseCharacters(config.Characters); len(characters) > 0 {
+			layout.Characters = characters
+		}
+		runAdopt(config, libraryDir, cmp.Or(config.Pattern, storedState.Settings.DownloadPattern), layout)
+		return
+	}
+	if config.Upgrade {
+		runUpgrade(config, libraryDir)
+		return
+	}
+	if config.Lint {
+		sidecars := config.Sidecars
+		if !sidecars.Any() {
+			sidecars = storedState.Settings.Sidecars
+			sidecars.DeriveKeywords = sidecars.DeriveKeywords || config.Sidecars.DeriveKeywords
+			sidecars.PerSubmission = sidecars.PerSubmission || config.Sidecars.PerSubmission
+		}
+		runLint(config, libraryDir, sidecars)
+		return
+	}
 
 	skippedReleaseTag := loadSkippedReleaseTag()
 	if !config.NoTUI {
@@ -69,6 +175,11 @@ func RunTUI(config flags.Config) {
 	}
 	showLoginReleaseNotice := shouldShowReleaseNotice(releaseStatus, skippedReleaseTag)
 	showSearchReleaseNotice := showLoginReleaseNotice
+	// The library stays locked when the search restarts, and is only let go
+	// of when another download directory is picked.
+	var locked string
+	unlock := func() {}
+	defer func() { unlock() }()
 
 Login:
 	if showLoginReleaseNotice && needsInteractiveLogin(config) {
@@ -76,6 +187,7 @@ Login:
 		if promptErr != nil {
 			if errors.Is(promptErr, errLoginPromptAborted) {
 				log.Info("Login aborted by user")
+				setExitCode(ExitAborted)
 				return
 			}
 			log.Warn("failed to show update notice", "err", promptErr)
@@ -95,6 +207,7 @@ Login:
 	if err != nil {
 		if errors.Is(err, errLoginPromptAborted) {
 			log.Info("Login aborted by user")
+			setExitCode(ExitAborted)
 			return
 		}
 		log.Error("Failed to login", "err", err)
@@ -106,16 +219,23 @@ Login:
 		}
 	}
 	logAuthenticatedUser(user, source)
+	if config.Favorites {
+		if config, err = favoritesConfig(config, user); err != nil {
+			fatal(ExitUsage, "Failed to download favorites", "err", err)
+		}
+	}
 
-	cleanup := prepareGuestSession(user, true)
+	cleanup := prepareGuestSession(config, user, true)
 	defer cleanup()
 
+	// Suggestions that failed or found nothing are only kept briefly, so that
+	// typing a name that does not exist is not asked of Inkbunny per keystroke.
 	usernameCache := flight.NewCache(func(_ context.Context, query string) ([]inkbunny.Autocomplete, error) {
 		return user.SearchMembers(query)
-	})
+	}).WithTTL(0, 30*time.Second).WithEmpty(flight.EmptySlice)
 	keywordSuggestionsCache := flight.NewCache(func(_ context.Context, query string) ([]inkbunny.KeywordAutocomplete, error) {
 		return keywordCache(user.Ratings)(query)
-	})
+	}).WithTTL(0, 30*time.Second).WithEmpty(flight.EmptySlice)
 	canUseUnread := user != nil && user.SID != "" && !strings.EqualFold(user.Username, "guest")
 	unreadCount := 0
 	canUseWatching := user != nil && user.SID != "" && !strings.EqualFold(user.Username, "guest")
@@ -158,7 +278,10 @@ Login:
 			if store == nil {
 				return nil
 			}
-			nextState := storedState
+			nextState, loadErr := store.Load()
+			if loadErr != nil {
+				nextState = storedState
+			}
 			downloadDirectory := strings.TrimSpace(settings.DownloadDirectory)
 			if downloadDirectory == "" {
 				downloadDirectory = appstorage.DefaultDownloadDirectory()
@@ -169,6 +292,11 @@ Login:
 				nextState.Settings.DownloadPattern = appdownloads.DefaultPattern
 			}
 			nextState.Settings.MaxActive = apputils.NormalizeMaxActive(settings.MaxActive)
+			nextState.Settings.Sidecars = settings.Sidecars
+			nextState.Settings.Collabs = appdownloads.NormalizeCollabsMode(settings.Collabs)
+			nextState.Settings.Characters = appdownloads.NormalizeCharacters(settings.Characters)
+			nextState.Settings.KeywordBlacklist = appdownloads.NormalizeCharacters(settings.KeywordBlacklist)
+			nextState.Settings.AIFilter = settings.AIFilter
 			nextState.Session.Settings = nextState.Settings
 			if nextState.Settings.DarkMode {
 				nextState.Session.EffectiveTheme = "dark"
@@ -184,6 +312,39 @@ Login:
 		&keywordSuggestionsCache,
 		&usernameCache,
 	)
+	if model.History, err = library.Open(libraryDir); err != nil {
+		log.Warn("failed to load download history", "err", err)
+	}
+	if config.Sidecars.Any() {
+		model.Sidecars = config.Sidecars
+	}
+	model.Sidecars.DeriveKeywords = model.Sidecars.DeriveKeywords || config.Sidecars.DeriveKeywords
+	model.Sidecars.PerSubmission = model.Sidecars.PerSubmission || config.Sidecars.PerSubmission
+	model.Sidecars.CaptionTemplate = cmp.Or(config.Sidecars.CaptionTemplate, model.Sidecars.CaptionTemplate)
+	if config.Pattern != "" {
+		model.DownloadPath.SetValue(config.Pattern)
+	}
+	if config.Collabs != "" {
+		model.SetCollabs(config.Collabs)
+	}
+	if config.Characters != "" {
+		model.Characters.SetValue(config.Characters)
+	}
+	if config.Provided("blacklist") {
+		model.Blacklist.SetValue(strings.Join(config.Blacklist, ", "))
+	}
+	model.SetAIFilter(config.AIFilter)
+	if config.Mirror != "" {
+		model.ArtistName.SetValue(config.Mirror)
+		model.MirrorMode = true
+	}
+	if config.Favorites {
+		model.ArtistName.SetValue("")
+		model.FavBy.SetValue(config.FavBy)
+	}
+	if preset, presetErr := findPreset(storedState.Presets, config.Preset); config.Preset != "" && presetErr == nil {
+		model.ApplyPreset(preset)
+	}
 
 	var (
 		p          *tea.Program
@@ -193,23 +354,37 @@ Login:
 	)
 Search:
 	if config.NoTUI {
-		config.ApplyTo(&request, &searchIn, &favBy, &maxDownloads, &maxActiveStr, &downloadCaption)
+		config.ApplyTo(&request, &searchIn, &favBy, &maxDownloads, &maxActiveStr, &sidecars)
+		downloadDir = cmp.Or(strings.TrimSpace(presetOptions.DownloadDirectory), downloadDir)
+		downloadPath = cmp.Or(strings.TrimSpace(config.Pattern), downloadPath)
 		goto Process
 	}
+	if config.Preset == "" && len(storedState.Presets) > 0 {
+		preset, ok, presetErr := promptPreset(storedState.Presets)
+		if errors.Is(presetErr, huh.ErrUserAborted) {
+			log.Info("Search aborted by user")
+			setExitCode(ExitAborted)
+			return
+		}
+		if ok {
+			model.ApplyPreset(preset)
+		}
+	}
 
 	p = tea.NewProgram(model)
 	rawModel, err = p.Run()
 	if errors.Is(err, tea.ErrInterrupted) {
 		log.Info("Search aborted by user")
+		setExitCode(ExitAborted)
 		return
 	}
 	if err != nil {
-		log.Fatal(err)
+		fatal(ExitFailure, err)
 	}
 
 	finalModel, ok = rawModel.(*uitui.Model)
 	if !ok {
-		log.Fatal("Could not cast model")
+		fatal(ExitFailure, "Could not cast model")
 	}
 	if finalModel.SkippedReleaseTag != "" {
 		if err := saveSkippedReleaseTag(finalModel.SkippedReleaseTag); err != nil {
@@ -231,10 +406,11 @@ Search:
 
 	if finalModel.Aborted {
 		log.Info("Search aborted by user")
+		setExitCode(ExitAborted)
 		return
 	}
 
-	ratingsChanged, err = syncUserRatingsMask(user, finalModel.RatingsMaskValue())
+	ratingsChanged, err = syncUserRatingsMask(user, finalModel.RatingsMaskValue(), "search form")
 	if err != nil {
 		log.Error("failed to update ratings", "err", err)
 		goto Search
@@ -265,7 +441,9 @@ Search:
 	downloadDir = finalModel.DownloadDirectoryValue()
 	downloadPath = finalModel.DownloadPatternValue()
 	resultsPerPage = finalModel.ResultsPerPageValue()
-	downloadCaption = finalModel.DownloadCaption
+	sidecars = finalModel.Sidecars
+	config.Blacklist = finalModel.BlacklistValue()
+	config.AIFilter = finalModel.AIFilter()
 	if finalModel.UnreadMode {
 		request.UnreadSubmissions = inkbunny.Yes
 	}
@@ -318,12 +496,16 @@ Process:
 	}
 	downloadDir = filepath.Clean(downloadDir)
 	downloadPath = appdownloads.NormalizePattern(downloadPath)
+	layout := appdownloads.Layout{Collabs: model.Collabs(), Characters: model.CharactersValue(), TimeZone: config.TimeZone, Routes: config.MimeRoutes}
 
 	request.SID = user.SID
 	request.GetRID = inkbunny.Yes
 	if finalModel == nil {
-		if trimmed := strings.TrimSpace(request.Username); trimmed != "" {
-			artistFilters = []string{trimmed}
+		// Presets can name several artists, separated by commas.
+		for artist := range strings.SplitSeq(request.Username, ",") {
+			if trimmed := strings.TrimSpace(artist); trimmed != "" {
+				artistFilters = append(artistFilters, trimmed)
+			}
 		}
 		if trimmed := strings.TrimSpace(favBy); trimmed != "" {
 			favoriteFilters = []string{trimmed}
@@ -338,6 +520,19 @@ Process:
 			log.Warn("your watch list is empty, so artist My watches cannot be used")
 			goto Search
 		}
+		if finalModel.MirrorMode && len(artistFilters) != 1 {
+			log.Warn("mirroring a gallery needs exactly one artist")
+			goto Search
+		}
+	}
+	mirror = nil
+	if config.Mirror != "" || (finalModel != nil && finalModel.MirrorMode) {
+		mirrorRequest(&request)
+		favoriteFilters = nil
+		mirror = openMirror(downloadDir, artistFilters[0])
+	}
+	if config.Favorites && finalModel == nil {
+		favoritesRequest(&request)
 	}
 
 	requests, err := buildSearchRequests(request, artistFilters, favoriteFilters, &usernameCache)
@@ -353,6 +548,18 @@ Process:
 		log.Info("To download: Unlimited")
 	}
 
+	if downloadDir != locked {
+		unlock()
+		unlock, locked = lockLibrary(config, downloadDir), downloadDir
+	}
+	index, err := library.Open(downloadDir)
+	if err != nil {
+		log.Warn("failed to load download history", "err", err)
+	}
+	warnInvalidIgnores(index)
+	migrateLibrary(index, true)
+	model.History = index
+
 	var items []*uitui.DownloadItem
 	gather := spinner.New().Title("Gathering files to download...")
 
@@ -360,14 +567,38 @@ Process:
 		pageCount       int
 		submissionCount int
 		fileCount       int
+		blacklisted     int
+		aiFiltered      int
 	)
 	gather.Action(func() {
 		seenSubmissions := make(map[string]struct{})
 		seenFiles := make(map[string]struct{})
 		processDetails := func(details inkbunny.SubmissionDetailsResponse) bool {
 			pageCount++
+			kept := 0
 			for _, d := range details.Submissions {
+				if !mirror.Keep(d.SubmissionID.Int()) {
+					continue
+				}
+				kept++
+				if !appdownloads.KeepsLanguage(config.Languages, appdownloads.DetectLanguage(d)) {
+					continue
+				}
 				submissionID := d.SubmissionID.String()
+				if _, ok := appdownloads.BlacklistedKeyword(d, config.Blacklist); ok {
+					if _, ok := seenSubmissions[submissionID]; !ok {
+						seenSubmissions[submissionID] = struct{}{}
+						blacklisted++
+					}
+					continue
+				}
+				if !appdownloads.KeepsAI(config.AIFilter, d) {
+					if _, ok := seenSubmissions[submissionID]; !ok {
+						seenSubmissions[submissionID] = struct{}{}
+						aiFiltered++
+					}
+					continue
+				}
 				if _, ok := seenSubmissions[submissionID]; !ok {
 					seenSubmissions[submissionID] = struct{}{}
 					submissionCount++
@@ -383,6 +614,10 @@ Process:
 						return false
 					}
 					seenFiles[key] = struct{}{}
+					route := appdownloads.Route(layout.Routes, file)
+					if index.Excluded(d, file) || route.Action == appdownloads.RouteSkip || !config.SelectFiles.Selects(d, file) {
+						continue
+					}
 					fileCount++
 					gather.Title("Gathering files to download...\n[" + strconv.Itoa(pageCount) + " pages]\n[" + strconv.Itoa(submissionCount) + " submissions]\n[" + strconv.Itoa(fileCount) + " files]")
 
@@ -394,9 +629,10 @@ Process:
 						FileName:     filepath.Base(file.FileName),
 						FileMD5:      file.FullFileMD5,
 						IsPublic:     d.Public.Bool(),
-						Metadata:     appdownloads.NewSubmissionFileMetadata(d, file),
+						Metadata:     appdownloads.NewSubmissionFileMetadata(d, file, layout.TimeZone),
 						DownloadRoot: downloadDir,
-						Destinations: appdownloads.ResolveDestinations(downloadDir, downloadPath, d, file),
+						Destinations: appdownloads.ResolveLayoutDestinations(downloadDir, downloadPath, d, file, layout),
+						Convert:      route.Format(),
 						Spinner:      spinnerModel.New(spinnerModel.WithSpinner(spinnerModel.Dot)),
 						Status:       uitui.StatusQueued,
 					})
@@ -405,7 +641,8 @@ Process:
 					return false
 				}
 			}
-			return true
+			// Mirrors search newest first, so a page without new submissions ends it.
+			return kept > 0 || len(details.Submissions) == 0
 		}
 
 		detailsRequest := appdownloads.MetadataSubmissionDetailsRequest()
@@ -433,11 +670,32 @@ Process:
 			log.Warn("Session expired, please login again")
 			goto Login
 		}
-		log.Fatal("failed to gather submissions", "err", err)
+		fatal(ExitFailure, "failed to gather submissions", "err", err)
+	}
+	if blacklisted > 0 {
+		log.Info("Skipped submissions with blacklisted keywords", "count", blacklisted)
+	}
+	if aiFiltered > 0 {
+		log.Info("Skipped submissions by the AI filter", "count", aiFiltered, "filter", config.AIFilter)
+	}
+
+	if !config.NoTUI && len(items) > 0 {
+		items, err = approveArtists(items, config.ApproveArtists)
+		if errors.Is(err, huh.ErrUserAborted) {
+			log.Info("Download aborted by user")
+			setExitCode(ExitAborted)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to ask which artists to download from", "err", err)
+			goto Search
+		}
 	}
 
 	if len(items) == 0 {
 		log.Info("No files to download.")
+		setExitCode(ExitNothingMatched)
+		saveMirror(mirror, true)
 	} else {
 		maxActive := min(max(1, runtime.NumCPU()/6), 6)
 		if maxActiveStr != "" {
@@ -445,21 +703,81 @@ Process:
 				maxActive = parsed
 			}
 		}
-		downloadModel := uitui.NewDownloadModel(user, items, maxActive, toDownload, downloadCaption)
+		downloadModel := uitui.NewDownloadModel(user, items, maxActive, toDownload, sidecars)
+		fsync := storedState.Settings.FsyncPolicy
+		if config.Fsync != "" {
+			fsync = config.Fsync
+		}
+		if policy, err := appdownloads.ParseSyncPolicy(fsync); err != nil {
+			log.Warn("ignoring invalid fsync policy", "value", fsync, "err", err)
+		} else {
+			downloadModel.Syncer = appdownloads.NewFileSyncer(policy)
+		}
+		downloadModel.Index = index
+		downloadModel.Client = throttle.Client(tracer.Client(downloadModel.Client), "downloads")
+		downloadModel.Throttle = throttle
+		downloadModel.Connections = cmp.Or(config.Connections, storedState.Settings.ConnectionsPerFile)
+		status := currentRun.NewStatusWriter(downloadDir)
+		if status != nil {
+			downloadModel.StatusInterval = statusInterval
+			downloadModel.ReportStatus = func(progress appstorage.RunProgress) {
+				if err := status.Update(progress); err != nil {
+					log.Debug("failed to write run status", "err", err)
+				}
+			}
+		}
 		p := tea.NewProgram(downloadModel)
+		started := time.Now()
+		restoreLogs := utils.LogToFileAnd(downloadModel.Logs)
 		rawDownloadModel, runErr := p.Run()
+		restoreLogs()
+		for _, failure := range downloadModel.Failures() {
+			if !failure.Retried() {
+				log.Error("Failed to download", "submission", failure.Item.SubmissionID, "file", failure.Item.FileName, "attempts", failure.Attempts, "err", failure.Err)
+			}
+		}
+		if err := status.Finish(downloadModel.Progress()); err != nil {
+			log.Warn("failed to write run status", "err", err)
+		}
+		if err := downloadModel.Syncer.Flush(); err != nil {
+			log.Error("Failed to sync downloads", "err", err)
+		}
+		placeCollections(index, downloadDir, storedState.Settings.Collections)
+		if config.KeywordReport != "" {
+			writeKeywordReport(index, config.KeywordReport)
+		}
 		if errors.Is(runErr, tea.ErrInterrupted) {
 			log.Info("Download aborted by user")
+			setExitCode(ExitAborted)
 			return
 		}
 		if runErr != nil {
 			log.Error("Failed to run downloader TUI", "err", runErr)
+			notifyLongRun(started, appdownloads.Notification{
+				Title:   "Inkbunny Downloader failed",
+				Message: runErr.Error(),
+				Level:   appdownloads.NotifyError,
+			})
+			setExitCode(ExitFailure)
 			return
 		}
 		if finalDownloadModel, ok := rawDownloadModel.(*uitui.DownloadModel); ok && finalDownloadModel.Aborted {
 			log.Info("Download aborted by user")
+			setExitCode(ExitAborted)
 			return
 		}
+		progress := downloadModel.Progress()
+		setExitCode(ExitSuccess)
+		if progress.Failed > 0 {
+			setExitCode(ExitPartial)
+		}
+		notifyLongRun(started, runNotification(appdownloads.RunSummary{
+			Files:   int64(progress.Downloaded),
+			Failed:  int64(progress.Failed),
+			Bytes:   progress.Bytes,
+			Seconds: time.Since(started).Seconds(),
+		}))
+		saveMirror(mirror, progress.Failed == 0 && (toDownload <= 0 || len(items) < toDownload))
 	}
 
 	if config.NoTUI {
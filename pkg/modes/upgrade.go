@@ -0,0 +1,234 @@
+package modes
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/utils"
+)
+
+var errChecksumMismatch = errors.New("downloaded file does not match its md5")
+
+// runUpgrade replaces the screen, preview and thumbnail sized files recorded in
+// the library at root with their full size file, rewriting sidecars and history.
+func runUpgrade(config flags.Config, root string) {
+	index, err := library.Open(root)
+	if err != nil {
+		log.Warn("failed to load download history", "err", err)
+	}
+	entries := index.Upgradable()
+	if len(entries) == 0 {
+		log.Info("No smaller copies to upgrade", "library", root)
+		return
+	}
+	log.Info("Upgrading smaller copies to full size", "library", root, "files", len(entries))
+
+	sidecars := config.Sidecars
+	if !sidecars.Any() {
+		sidecars.Metadata = true
+	}
+	detailsRequest := inkbunny.SubmissionDetailsRequest{}
+	if appdownloads.SidecarsNeedDetails(sidecars) {
+		detailsRequest = appdownloads.MetadataSubmissionDetailsRequest()
+	}
+
+	bySubmission := make(map[string][]library.Entry)
+	for _, entry := range entries {
+		bySubmission[entry.SubmissionID] = append(bySubmission[entry.SubmissionID], entry)
+	}
+	ids := make([]string, 0, len(bySubmission))
+	for id := range bySubmission {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+
+	user, cleanup := commandLogin(config)
+	defer cleanup()
+
+	client := newThrottle().Client(&http.Client{Timeout: 5 * time.Minute}, "upgrades")
+	var upgraded, failed int
+	for batch := range slices.Chunk(ids, 100) {
+		request := detailsRequest
+		request.SID = user.SID
+		request.SubmissionIDSlice = batch
+		details, err := user.SubmissionDetails(request)
+		if err != nil {
+			log.Error("Failed to get submission details", "err", err)
+			failed += len(batch)
+			continue
+		}
+		for _, submission := range details.Submissions {
+			for _, entry := range bySubmission[submission.SubmissionID.String()] {
+				position := slices.IndexFunc(submission.Files, func(file inkbunny.File) bool {
+					return file.FileID.String() == entry.FileID
+				})
+				if position < 0 {
+					log.Warn("File is no longer part of its submission", "submission", entry.SubmissionID, "file", entry.FileID)
+					continue
+				}
+				if err := upgradeFile(client, user, index, submission, submission.Files[position], entry, sidecars, config.TimeZone); err != nil {
+					failed++
+					log.Error("failed to upgrade file", "file", entry.FileID, "err", err)
+					continue
+				}
+				upgraded++
+			}
+		}
+	}
+	log.Info("Upgraded files to full size", "library", root, "upgraded", upgraded, "failed", failed)
+}
+
+func upgradeFile(
+	client *http.Client,
+	user *inkbunny.User,
+	index *library.Index,
+	submission inkbunny.SubmissionDetails,
+	file inkbunny.File,
+	entry library.Entry,
+	sidecars apptypes.SidecarOptions,
+	timeZone string,
+) error {
+	paths := index.Files(entry)
+	if len(paths) == 0 {
+		return nil
+	}
+	// The smaller copy can be of another type than the full size file, such
+	// as a JPEG screen copy of a PNG, so the paths take the extension of the
+	// full size file.
+	targets := make([]string, len(paths))
+	moved := false
+	for n, path := range paths {
+		targets[n] = fullSizePath(path, file.FileName)
+		moved = moved || targets[n] != path
+	}
+	size, err := downloadReplacement(client, user, submission, file, targets[0])
+	if err != nil {
+		return err
+	}
+	// Every other recorded path still holds the smaller copy, so they are relinked to the new file.
+	if err := appdownloads.AdoptFile(targets[0], targets, file.FullFileMD5); err != nil {
+		return err
+	}
+	for n, path := range paths {
+		if targets[n] == path {
+			continue
+		}
+		moveSidecars(path, targets[n])
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Warn("failed to remove smaller copy", "file", path, "err", err)
+		}
+	}
+	if err := appdownloads.WriteSidecars(targets, appdownloads.NewSubmissionFileMetadata(submission, file, timeZone), sidecars); err != nil {
+		log.Warn("failed to write sidecars", "file", targets[0], "err", err)
+	}
+
+	log.Info("Upgraded file to full size", "file", targets[0], "from", entry.Variant, "submission", fmt.Sprintf("https://inkbunny.net/s/%s", entry.SubmissionID))
+	if moved {
+		// Recording merges paths, so the old ones are dropped first.
+		if err := index.Forget(entry.FileID); err != nil {
+			return err
+		}
+	}
+	entry.MD5 = file.FullFileMD5
+	entry.Variant = library.VariantFull
+	entry.Size = size
+	entry.Paths = targets
+	entry.Time = time.Time{}
+	return index.Record(entry)
+}
+
+// fullSizePath is path with the extension of the full size file named name.
+func fullSizePath(path, name string) string {
+	extension := filepath.Ext(name)
+	if extension == "" || strings.EqualFold(extension, filepath.Ext(path)) {
+		return path
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + extension
+}
+
+// moveSidecars renames the sidecars named after the file at from, such as the
+// Hydrus ones which keep its extension, to be named after to.
+func moveSidecars(from, to string) {
+	every := apptypes.SidecarOptions{Keywords: true, Metadata: true, Description: true, Comments: true, Hydrus: true}
+	previous, next := appdownloads.SidecarPaths(from, every), appdownloads.SidecarPaths(to, every)
+	if len(previous) != len(next) {
+		return
+	}
+	for n := range previous {
+		if previous[n] == next[n] {
+			continue
+		}
+		if err := os.Rename(previous[n], next[n]); err != nil && !os.IsNotExist(err) {
+			log.Warn("failed to move sidecar", "sidecar", previous[n], "err", err)
+		}
+	}
+}
+
+// downloadReplacement downloads the full size file beside destination and only
+// replaces it once the download matches the file's MD5.
+func downloadReplacement(client *http.Client, user *inkbunny.User, submission inkbunny.SubmissionDetails, file inkbunny.File, destination string) (int64, error) {
+	url := utils.ResourceURL(file.FileURLFull.String(), user.SID, submission.Public.Bool())
+	sidURL := utils.AppendSID(file.FileURLFull.String(), user.SID)
+
+	var resp *http.Response
+	for limited := 0; ; {
+		var err error
+		resp, err = client.Get(url)
+		if err != nil {
+			return 0, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			break
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusTooManyRequests {
+			// The client holds back the retry for as long as the server asked.
+			if limited++; limited > maxRateLimited {
+				return 0, errRateLimited
+			}
+			continue
+		}
+		if sidURL != "" && sidURL != url {
+			url = sidURL
+			continue
+		}
+		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	defer resp.Body.Close()
+
+	temp, err := os.CreateTemp(filepath.Dir(destination), ".upgrade-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(temp.Name())
+
+	hash := md5.New()
+	written, err := io.Copy(io.MultiWriter(temp, hash), resp.Body)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); !strings.EqualFold(sum, file.FullFileMD5) {
+		return 0, fmt.Errorf("%w: %q", errChecksumMismatch, destination)
+	}
+	return written, os.Rename(temp.Name(), destination)
+}
@@ -0,0 +1,91 @@
+package modes
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"sync/atomic"
+
+	"github.com/charmbracelet/log"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+)
+
+type usagePhase int
+
+const (
+	usageSearch usagePhase = iota
+	usageDetails
+	usageOtherAPI
+	usageFiles
+	usagePhases
+)
+
+var usagePhaseNames = [usagePhases]string{"search", "details", "other api", "files"}
+
+// runUsage counts the requests a run makes and the bytes it receives in each
+// phase, to keep an eye on how much a run asks of Inkbunny and to tell where
+// it was throttled.
+type runUsage struct {
+	phases [usagePhases]struct {
+		requests, throttled, bytes atomic.Int64
+	}
+}
+
+// Client returns an HTTP client whose requests are counted in u.
+func (u *runUsage) Client(client *http.Client) *http.Client {
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	counted := *client
+	counted.Transport = usageTransport{base: base, usage: u}
+	return &counted
+}
+
+// Report logs the usage of every phase the run went through.
+func (u *runUsage) Report() {
+	for phase := range usagePhases {
+		counts := &u.phases[phase]
+		requests := counts.requests.Load()
+		if requests == 0 {
+			continue
+		}
+		keyvals := []any{"phase", usagePhaseNames[phase], "requests", requests, "received", appdownloads.FormatSize(float64(counts.bytes.Load()))}
+		if throttled := counts.throttled.Load(); throttled > 0 {
+			keyvals = append(keyvals, "throttled", throttled)
+		}
+		log.Info("Usage", keyvals...)
+	}
+}
+
+func phaseOf(req *http.Request) usagePhase {
+	switch name := path.Base(req.URL.Path); {
+	case name == "api_search.php":
+		return usageSearch
+	case name == "api_submissions.php":
+		return usageDetails
+	case strings.HasPrefix(name, "api_"):
+		return usageOtherAPI
+	}
+	return usageFiles
+}
+
+type usageTransport struct {
+	base  http.RoundTripper
+	usage *runUsage
+}
+
+func (t usageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	counts := &t.usage.phases[phaseOf(req)]
+	counts.requests.Add(1)
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		counts.throttled.Add(1)
+	}
+	resp.Body = countingBody{ReadCloser: resp.Body, n: &counts.bytes}
+	return resp, nil
+}
@@ -0,0 +1,136 @@
+package modes
+
+import (
+	"errors"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+)
+
+// watchState carries what the cycles of a watch found into the next one.
+type watchState struct {
+	cycle int
+	// since is the newest submission of the cycles that downloaded everything
+	// they found, which later cycles only download what is newer than.
+	since int
+
+	downloaded int64
+	failed     bool
+
+	// tree is the download folder as hashed by the last cycle with --dedupe.
+	tree []library.HashedFile
+
+	// user is the session the cycles share, which keepAlive keeps from
+	// expiring while they wait, and source where it came from.
+	user   *inkbunny.User
+	source authSource
+}
+
+// watchKeepAlive is how often a watch checks its session between cycles.
+// Inkbunny ends sessions that make no request for a while, so without it the
+// first search after a long --interval would find it expired.
+const watchKeepAlive = 10 * time.Minute
+
+// runWatch runs the search of config every --interval until the process is
+// stopped, downloading only the submissions that are new since the last cycle.
+func runWatch(config flags.Config, root string) {
+	watch := &watchState{}
+	log.Info("Watching for new submissions", "interval", config.Interval)
+	for {
+		watch.cycle++
+		watch.downloaded, watch.failed = 0, false
+		started := time.Now()
+		downloadSearch(config, root, watch)
+
+		next := started.Add(config.Interval)
+		keyvals := []any{"cycle", watch.cycle, "downloaded", watch.downloaded, "took", time.Since(started).Round(time.Second), "next", next.Format(time.Kitchen)}
+		if watch.failed {
+			log.Warn("Watch cycle did not download everything, the next one tries again", keyvals...)
+		} else {
+			log.Info("Watch cycle done", keyvals...)
+		}
+		watch.keepAlive(&config, next)
+	}
+}
+
+// session returns the session of the previous cycle, and reports false when
+// there is none and the cycle has to log in.
+func (w *watchState) session() (*inkbunny.User, authSource, bool) {
+	if w == nil || w.user == nil {
+		return nil, "", false
+	}
+	return w.user, w.source, true
+}
+
+// keep sets the session the next cycles use, or forgets it when user is nil.
+func (w *watchState) keep(user *inkbunny.User, source authSource) {
+	if w == nil {
+		return
+	}
+	w.user, w.source = user, source
+}
+
+// keepAlive waits until next, making a cheap search every watchKeepAlive to
+// keep the session alive. When the session has expired anyway, it logs in
+// again right away rather than on the first search of the next cycle.
+func (w *watchState) keepAlive(config *flags.Config, next time.Time) {
+	for {
+		wait := time.Until(next)
+		if wait <= watchKeepAlive {
+			time.Sleep(wait)
+			return
+		}
+		time.Sleep(watchKeepAlive)
+		if w.user == nil || config.Simulate != "" {
+			continue
+		}
+		_, err := w.user.SearchSubmissions(inkbunny.SubmissionSearchRequest{NoSubmissions: inkbunny.Yes, SubmissionsPerPage: 1})
+		if err, ok := errors.AsType[inkbunny.ErrorResponse](err); ok && err.Code != nil && *err.Code == inkbunny.ErrInvalidSessionID {
+			log.Warn("Session expired while waiting for the next cycle, logging in again")
+			invalidateAuthSource(config, w.source)
+			w.relogin(*config)
+			continue
+		}
+		if err != nil {
+			log.Debug("failed to keep the session alive, the next cycle checks it again", "err", err)
+		}
+	}
+}
+
+// relogin logs in again the way the watch first did, leaving the next cycle
+// to try once more when that fails.
+func (w *watchState) relogin(config flags.Config) {
+	w.keep(nil, "")
+	user, source, persistSession, err := authenticateUser(config, false)
+	if err != nil {
+		log.Error("Failed to log in again, the next cycle tries once more", "err", err)
+		return
+	}
+	if persistSession {
+		if err := saveSession(user); err != nil {
+			log.Warn("failed to save session", "err", err)
+		}
+	}
+	logAuthenticatedUser(user, source)
+	prepareGuestSession(config, user, false)
+	w.keep(user, source)
+}
+
+// finish records the outcome of a cycle that kept submissions through mirror.
+// The next cycle starts from its newest submission only once everything it
+// found was downloaded.
+func (w *watchState) finish(mirror *library.Mirror, downloaded, failed int64, complete bool) {
+	if w == nil {
+		return
+	}
+	w.downloaded = downloaded
+	w.failed = failed > 0 || !complete
+	if !w.failed {
+		w.since = max(w.since, mirror.Newest())
+	}
+}
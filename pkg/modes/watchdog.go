@@ -0,0 +1,97 @@
+package modes
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+const (
+	// apiStepTimeout bounds a single search or details call made while
+	// gathering submissions to download.
+	apiStepTimeout = 2 * time.Minute
+	apiStepRetries = 3
+	// producerStallTimeout is how long gathering submissions may go without
+	// progress while no download is running before the run is given up on.
+	producerStallTimeout = apiStepTimeout*(apiStepRetries+1) + time.Minute
+)
+
+var errAPIStalled = errors.New("inkbunny did not answer in time")
+
+// retryAPIStep runs step with a deadline, trying again up to apiStepRetries
+// times when Inkbunny does not answer in time, or once it is back when offline
+// parks the run. Other errors are returned right away.
+func retryAPIStep[T any](offline *offlineGate, what string, step func(context.Context) (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), apiStepTimeout)
+		result, err := step(ctx)
+		timedOut := ctx.Err() != nil
+		cancel()
+		if err == nil {
+			return result, nil
+		}
+		if !timedOut {
+			if attempt > apiStepRetries || !offline.Wait(err) {
+				return result, err
+			}
+			continue
+		}
+		if attempt > apiStepRetries {
+			return result, errors.Join(errAPIStalled, err)
+		}
+		log.Warn("Inkbunny did not answer in time, retrying", "step", what, "attempt", attempt, "timeout", apiStepTimeout)
+	}
+}
+
+// producerWatchdog fails the run when gathering submissions stops making
+// progress while the downloads are idle, which would otherwise leave the run
+// waiting on its downloads forever.
+type producerWatchdog struct {
+	last atomic.Int64
+	step atomic.Pointer[string]
+	done chan struct{}
+}
+
+// watchProducer starts a watchdog that considers the downloads idle while idle
+// returns true.
+func watchProducer(idle func() bool) *producerWatchdog {
+	w := &producerWatchdog{done: make(chan struct{})}
+	w.Progress("starting")
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-w.done:
+				return
+			case <-ticker.C:
+			}
+			waited := time.Since(time.Unix(0, w.last.Load()))
+			if waited < producerStallTimeout || !idle() {
+				continue
+			}
+			fatal(ExitFailure, "Gathering submissions stalled while no downloads were running, giving up on the run",
+				"step", *w.step.Load(), "waited", waited.Round(time.Second))
+		}
+	}()
+	return w
+}
+
+// Progress records that gathering submissions moved on to step.
+func (w *producerWatchdog) Progress(step string) {
+	w.step.Store(&step)
+	w.last.Store(time.Now().UnixNano())
+}
+
+// Alive records progress made by the downloads, which keeps the watchdog from
+// acting while the producer waits for room in the queue.
+func (w *producerWatchdog) Alive() {
+	w.last.Store(time.Now().UnixNano())
+}
+
+func (w *producerWatchdog) Stop() {
+	close(w.done)
+}
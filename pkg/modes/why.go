@@ -0,0 +1,88 @@
+package modes
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flags"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/utils"
+)
+
+// skipRecorder counts a skip in the skip log and records it in the library,
+// so that --why can tell later why a file is not in it.
+type skipRecorder struct {
+	log    *utils.SkipLog
+	index  *library.Index
+	record bool
+	// files counts the files skipped, one for each skip of a single file and
+	// every file of a submission skipped whole.
+	files *atomic.Int64
+}
+
+// SkipSubmission is Skip for a submission skipped whole, counting each of its
+// files as skipped.
+func (s skipRecorder) SkipSubmission(skip library.Skip, files int, category string, msg string, keyvals ...any) {
+	if s.files != nil {
+		s.files.Add(int64(files))
+	}
+	s.logAndRecord(skip, category, msg, keyvals...)
+}
+
+func (s skipRecorder) Skip(skip library.Skip, category string, msg string, keyvals ...any) {
+	if skip.FileID != "" && s.files != nil {
+		s.files.Add(1)
+	}
+	s.logAndRecord(skip, category, msg, keyvals...)
+}
+
+func (s skipRecorder) logAndRecord(skip library.Skip, category string, msg string, keyvals ...any) {
+	s.log.Skip(category, msg, keyvals...)
+	// A file already downloaded is in the history, which --why reports it
+	// from, so recording it on every run would only grow the skips file.
+	if !s.record || skip.Reason == library.SkipExisting {
+		return
+	}
+	if err := s.index.RecordSkip(skip); err != nil {
+		log.Warn("failed to record skip", "submission", skip.SubmissionID, "err", err)
+	}
+}
+
+// runWhy prints what the library at root knows about each submission given
+// to --why: the files of it that were downloaded and why the others were not.
+func runWhy(config flags.Config, root string) {
+	index, err := library.Open(root)
+	if err != nil {
+		fatal(ExitFailure, "failed to load download history", "library", root, "err", err)
+	}
+
+	for _, id := range config.Why {
+		downloaded := index.Submission(id)
+		for _, entry := range downloaded {
+			log.Info("Downloaded", "submission", id, "file", entry.FileID, "time", entry.Time.Local().Format(time.DateTime), "paths", entry.Paths)
+		}
+		skips, err := index.Skips(id)
+		if err != nil {
+			fatal(ExitFailure, "failed to read skipped files", "library", root, "err", err)
+		}
+		for _, skip := range skips {
+			if skip.Reason == library.SkipExisting {
+				// Left by older runs; the file is listed as downloaded above.
+				continue
+			}
+			keyvals := []any{"submission", id, "reason", skip.Reason, "time", skip.Time.Local().Format(time.DateTime)}
+			if skip.FileID != "" {
+				keyvals = append(keyvals, "file", skip.FileID)
+			}
+			if skip.Detail != "" {
+				keyvals = append(keyvals, "detail", skip.Detail)
+			}
+			log.Warn("Skipped", keyvals...)
+		}
+		if len(downloaded) == 0 && len(skips) == 0 {
+			log.Warn("Submission was never found by a run into this library", "submission", id, "library", root)
+		}
+	}
+}
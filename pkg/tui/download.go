@@ -25,6 +25,10 @@ import (
 	"github.com/ellypaws/inkbunny"
 
 	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
+	appstorage "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/storage"
+	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+	apputils "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/utils"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/utils"
 )
 
@@ -49,6 +53,9 @@ type DownloadCanceledMsg struct {
 	RunID int64
 }
 
+// statusTickMsg asks the model to hand its progress to ReportStatus.
+type statusTickMsg struct{}
+
 type DownloadItem struct {
 	SubmissionID string
 	Title        string
@@ -60,6 +67,8 @@ type DownloadItem struct {
 	Metadata     appdownloads.SubmissionFileMetadata
 	DownloadRoot string
 	Destinations []string
+	// Convert is the format a convert route re-encodes the file in.
+	Convert string
 
 	Written   atomic.Int64
 	TotalSize atomic.Int64
@@ -69,6 +78,8 @@ type DownloadItem struct {
 	Status     DownloadStatus
 	Error      error
 	MD5Retries int
+	// SingleConnection is set once the server refuses ranged requests for the file.
+	SingleConnection bool
 }
 
 type DownloadStatus int
@@ -90,9 +101,20 @@ type DownloadModel struct {
 	Width  int
 	Height int
 
-	Downloaded      int
-	ToDownload      int
-	DownloadCaption bool
+	Downloaded int
+	ToDownload int
+	Sidecars   apptypes.SidecarOptions
+	Syncer     *appdownloads.FileSyncer
+	Index      *library.Index
+	// Connections splits large files across this many ranged requests.
+	Connections int
+	// Throttle holds back the requests of Client after a 429 response, which
+	// the view counts down.
+	Throttle *apputils.RateLimiter
+	// ReportStatus, when set, is handed the progress of the downloads every
+	// StatusInterval so that other processes can check on the run.
+	ReportStatus   func(appstorage.RunProgress)
+	StatusInterval time.Duration
 
 	Aborted     bool
 	Confirmed   bool
@@ -103,6 +125,15 @@ type DownloadModel struct {
 	HScrollOffset int
 	contentWidth  int
 
+	// Logs holds what is logged while the downloads run, shown below them
+	// scrolled back by LogScroll. ShowErrors opens the drawer of the files
+	// that failed in its place, scrolled back by ErrorScroll.
+	Logs        *LogPanel
+	LogScroll   int
+	ShowErrors  bool
+	ErrorScroll int
+	failures    []DownloadFailure
+
 	ZoneManager *zone.Manager
 	runs        map[*DownloadItem]downloadRun
 	nextRunID   int64
@@ -113,16 +144,17 @@ type downloadRun struct {
 	cancel context.CancelFunc
 }
 
-func NewDownloadModel(user *inkbunny.User, items []*DownloadItem, maxActive int, toDownload int, caption bool) *DownloadModel {
+func NewDownloadModel(user *inkbunny.User, items []*DownloadItem, maxActive int, toDownload int, sidecars apptypes.SidecarOptions) *DownloadModel {
 	m := &DownloadModel{
-		Items:           items,
-		User:            user,
-		Client:          &http.Client{Timeout: 5 * time.Minute},
-		MaxActive:       maxActive,
-		ToDownload:      toDownload,
-		DownloadCaption: caption,
-		ZoneManager:     zone.New(),
-		runs:            make(map[*DownloadItem]downloadRun),
+		Items:       items,
+		User:        user,
+		Client:      &http.Client{Timeout: 5 * time.Minute},
+		MaxActive:   maxActive,
+		ToDownload:  toDownload,
+		Sidecars:    sidecars,
+		ZoneManager: zone.New(),
+		Logs:        NewLogPanel(),
+		runs:        make(map[*DownloadItem]downloadRun),
 	}
 	if m.MaxActive <= 0 {
 		m.MaxActive = 4
@@ -137,7 +169,33 @@ func NewDownloadModel(user *inkbunny.User, items []*DownloadItem, maxActive int,
 }
 
 func (m *DownloadModel) Init() tea.Cmd {
-	return nil
+	return m.statusTick()
+}
+
+func (m *DownloadModel) statusTick() tea.Cmd {
+	if m.ReportStatus == nil || m.StatusInterval <= 0 {
+		return nil
+	}
+	return tea.Tick(m.StatusInterval, func(time.Time) tea.Msg { return statusTickMsg{} })
+}
+
+// Progress counts the items by their status. Paused items count as queued.
+func (m *DownloadModel) Progress() appstorage.RunProgress {
+	var progress appstorage.RunProgress
+	for _, item := range m.Items {
+		switch item.Status {
+		case StatusQueued, StatusPaused:
+			progress.Queued++
+		case StatusActive:
+			progress.Active++
+		case StatusCompleted:
+			progress.Downloaded++
+		case StatusFailed:
+			progress.Failed++
+		}
+		progress.Bytes += item.Written.Load()
+	}
+	return progress
 }
 
 func (m *DownloadModel) runFor(item *DownloadItem) (downloadRun, bool) {
@@ -169,7 +227,7 @@ func (m *DownloadModel) launchItem(item *DownloadItem) tea.Cmd {
 	item.Error = nil
 	item.Written.Store(0)
 	item.TotalSize.Store(0)
-	return startDownloadCmd(item, m.User, m.Client, m.DownloadCaption, ctx, runID)
+	return startDownloadCmd(item, m.User, m.Client, m.Sidecars, m.Syncer, m.Index, m.Connections, m.Throttle != nil, ctx, runID)
 }
 
 func (m *DownloadModel) activeCount() int {
@@ -278,6 +336,10 @@ func (m *DownloadModel) handleMouseRelease(v1msg teaV1.MouseMsg) (tea.Model, tea
 	if m.ZoneManager.Get("btn_stop_all").InBounds(v1msg) {
 		return m, m.stopAll()
 	}
+	if m.ZoneManager.Get("btn_errors").InBounds(v1msg) {
+		m.ShowErrors = !m.ShowErrors
+		m.ErrorScroll = 0
+	}
 	return m, nil
 }
 
@@ -306,6 +368,10 @@ func (m *DownloadModel) handleMouseMove(v1msg teaV1.MouseMsg) {
 	}
 	if inBounds("btn_stop_all") {
 		m.HoveredZone = "btn_stop_all"
+		return
+	}
+	if inBounds("btn_errors") {
+		m.HoveredZone = "btn_errors"
 	}
 }
 
@@ -317,6 +383,10 @@ func (m *DownloadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.Height = msg.Height
 		return m, nil
 
+	case statusTickMsg:
+		m.ReportStatus(m.Progress())
+		return m, m.statusTick()
+
 	case tea.KeyPressMsg:
 		switch msg.String() {
 		case "ctrl+c", "q", "esc":
@@ -345,12 +415,21 @@ func (m *DownloadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.Confirmed {
 				return m, m.stopAll()
 			}
+		case "e":
+			if m.Confirmed {
+				m.ShowErrors = !m.ShowErrors
+				m.ErrorScroll = 0
+			}
 		case "up", "k":
-			if !m.Confirmed && m.ScrollOffset > 0 {
+			if m.Confirmed {
+				m.scroll(1)
+			} else if m.ScrollOffset > 0 {
 				m.ScrollOffset--
 			}
 		case "down", "j":
-			if !m.Confirmed && m.ScrollOffset < len(m.Items)-1 {
+			if m.Confirmed {
+				m.scroll(-1)
+			} else if m.ScrollOffset < len(m.Items)-1 {
 				m.ScrollOffset++
 			}
 		case "left", "h":
@@ -361,14 +440,18 @@ func (m *DownloadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.HScrollOffset++
 			m.clampHScroll()
 		case "pgup":
-			if !m.Confirmed {
+			if m.Confirmed {
+				m.scroll(m.Height / 4)
+			} else {
 				m.ScrollOffset -= m.Height / 2
 				if m.ScrollOffset < 0 {
 					m.ScrollOffset = 0
 				}
 			}
 		case "pgdown":
-			if !m.Confirmed {
+			if m.Confirmed {
+				m.scroll(-m.Height / 4)
+			} else {
 				m.ScrollOffset += m.Height / 2
 				if m.ScrollOffset >= len(m.Items) {
 					m.ScrollOffset = len(m.Items) - 1
@@ -413,6 +496,7 @@ func (m *DownloadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		msg.Item.Status = StatusFailed
 		msg.Item.Error = msg.Err
+		m.recordFailure(msg.Item, msg.Err)
 		if !m.Paused {
 			cmds = append(cmds, m.startNextDownload())
 		}
@@ -444,11 +528,15 @@ func (m *DownloadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case tea.MouseWheelMsg:
 		if msg.Mouse().Button == tea.MouseWheelUp {
-			if !m.Confirmed && m.ScrollOffset > 0 {
+			if m.Confirmed {
+				m.scroll(1)
+			} else if m.ScrollOffset > 0 {
 				m.ScrollOffset--
 			}
 		} else if msg.Mouse().Button == tea.MouseWheelDown {
-			if !m.Confirmed && m.ScrollOffset < len(m.Items)-1 {
+			if m.Confirmed {
+				m.scroll(-1)
+			} else if m.ScrollOffset < len(m.Items)-1 {
 				m.ScrollOffset++
 			}
 		} else if msg.Mouse().Button == tea.MouseWheelLeft {
@@ -463,11 +551,15 @@ func (m *DownloadModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case teaV1.MouseMsg:
 		v1msg := msg
 		if v1msg.Type == teaV1.MouseWheelUp {
-			if !m.Confirmed && m.ScrollOffset > 0 {
+			if m.Confirmed {
+				m.scroll(1)
+			} else if m.ScrollOffset > 0 {
 				m.ScrollOffset--
 			}
 		} else if v1msg.Type == teaV1.MouseWheelDown {
-			if !m.Confirmed && m.ScrollOffset < len(m.Items)-1 {
+			if m.Confirmed {
+				m.scroll(-1)
+			} else if m.ScrollOffset < len(m.Items)-1 {
 				m.ScrollOffset++
 			}
 		} else if v1msg.Type == teaV1.MouseRelease && v1msg.Button == teaV1.MouseButtonLeft {
@@ -705,7 +797,6 @@ func (m *DownloadModel) View() tea.View {
 		case StatusCompleted:
 			completed = append(completed, fmt.Sprintf("✓ Downloaded: %s", item.FileName))
 		case StatusFailed:
-			completed = append(completed, fmt.Sprintf("✗ Failed: %s (%v)", item.FileName, item.Error))
 			failedCount++
 		}
 	}
@@ -716,6 +807,17 @@ func (m *DownloadModel) View() tea.View {
 		availableLines = 10
 	}
 
+	// The log panel, or the error drawer in its place, keeps a third of the
+	// screen below the downloads.
+	panelLines := max(availableLines/3, 3)
+	var panel []string
+	if m.ShowErrors {
+		panel = m.renderErrorDrawer(contentWidth, panelLines)
+	} else {
+		panel = m.renderLogPanel(contentWidth, panelLines)
+	}
+	availableLines -= len(panel) + 1
+
 	var out []string
 	pauseLabel := "Pause All"
 	if m.Paused {
@@ -724,12 +826,22 @@ func (m *DownloadModel) View() tea.View {
 	btnPauseResume := m.renderActionButton("btn_pause_resume", pauseLabel, lipgloss.Color("#FFFFFF"), lipgloss.Color("#7A4BFF"), lipgloss.Color("#E04080"))
 	btnRetryAll := m.renderActionButton("btn_retry_all", "Retry All", lipgloss.Color("#FFFFFF"), lipgloss.Color("#2F6F4F"), lipgloss.Color("#5F7FFF"))
 	btnStopAll := m.renderActionButton("btn_stop_all", "Stop All", lipgloss.Color("#FFFFFF"), lipgloss.Color("#A83A3A"), lipgloss.Color("#E04080"))
+	errorsLabel := fmt.Sprintf("▸ Errors (%d)", len(m.failures))
+	if m.ShowErrors {
+		errorsLabel = fmt.Sprintf("▾ Errors (%d)", len(m.failures))
+	}
+	btnErrors := m.renderActionButton("btn_errors", errorsLabel, lipgloss.Color("#FFFFFF"), lipgloss.Color("#6B6B6B"), lipgloss.Color("#5F7FFF"))
 	stateLabel := "Running"
 	if m.Paused {
 		stateLabel = "Paused"
 	}
-	out = append(out, lipgloss.JoinHorizontal(lipgloss.Top, btnPauseResume, "  ", btnRetryAll, "  ", btnStopAll))
+	out = append(out, lipgloss.JoinHorizontal(lipgloss.Top, btnPauseResume, "  ", btnRetryAll, "  ", btnStopAll, "  ", btnErrors))
 	out = append(out, fmt.Sprintf("State: %s | Completed: %d | Active: %d | Paused: %d | Queued: %d | Failed: %d", stateLabel, m.Downloaded, len(active), len(paused), len(queued), failedCount))
+	if wait := m.Throttle.Remaining(); wait > 0 {
+		throttled := lipgloss.NewStyle().Foreground(lipgloss.Color("#E0A040")).Bold(true)
+		out = append(out, throttled.Render(fmt.Sprintf("Rate limited by Inkbunny, resuming in %s", (wait+time.Second-1).Truncate(time.Second))))
+		availableLines--
+	}
 	out = append(out, "")
 	availableLines -= 3
 
@@ -765,14 +877,17 @@ func (m *DownloadModel) View() tea.View {
 		if len(queued) > showCount {
 			out[len(out)-1] = fmt.Sprintf("  ... and %d more", len(queued)-showCount+1)
 		}
+		out = append(out, "")
 	}
 
+	out = append(out, panel...)
+
 	rendered := borderStyle.Render(strings.Join(out, "\n"))
 	rendered = m.applyHorizontalViewport(rendered)
 	return newDownloadView(m.ZoneManager.Scan(rendered))
 }
 
-func startDownloadCmd(item *DownloadItem, user *inkbunny.User, client *http.Client, saveCaption bool, ctx context.Context, runID int64) tea.Cmd {
+func startDownloadCmd(item *DownloadItem, user *inkbunny.User, client *http.Client, sidecars apptypes.SidecarOptions, syncer *appdownloads.FileSyncer, index *library.Index, connections int, throttled bool, ctx context.Context, runID int64) tea.Cmd {
 	return func() tea.Msg {
 		destinations := uniqueNonEmptyPaths(item.Destinations)
 		if len(destinations) == 0 {
@@ -783,15 +898,33 @@ func startDownloadCmd(item *DownloadItem, user *inkbunny.User, client *http.Clie
 			destinations = []string{filepath.Join(root, item.Username, item.FileName)}
 		}
 		filename := destinations[0]
-		if fileExists(filename) {
+		entry := library.Entry{
+			SubmissionID: item.SubmissionID,
+			FileID:       item.Metadata.File.FileID.String(),
+			MD5:          item.FileMD5,
+			Artist:       item.Username,
+			Title:        item.Metadata.Title,
+			Uploaded:     appdownloads.UploadTime(item.Metadata.SubmissionDetails, item.Metadata.File, appdownloads.TimeZoneUTC),
+			Keywords:     library.SubmissionKeywords(item.Metadata.SubmissionDetails),
+			Paths:        destinations,
+		}
+		source, ok := index.Source(entry.FileID, entry.MD5, filename)
+		if !ok && fileExists(filename) {
+			source, ok = filename, true
+		}
+		if ok {
 			item.Written.Store(item.TotalSize.Load())
-			if err := ensureDownloadTargetsFromSource(filename, destinations); err != nil {
+			if err := ensureDownloadTargetsFromSource(source, destinations); err != nil {
 				return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
 			}
-			if saveCaption {
-				if err := appdownloads.WriteSubmissionMetadata(destinations, item.Metadata); err != nil {
-					return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
-				}
+			if err := appdownloads.ConvertFiles(destinations, item.Convert); err != nil {
+				return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+			}
+			if err := index.Record(entry); err != nil {
+				return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+			}
+			if err := appdownloads.WriteSidecars(destinations, item.Metadata, sidecars); err != nil {
+				return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
 			}
 			return DownloadCompleteMsg{Item: item, RunID: runID}
 		}
@@ -805,10 +938,17 @@ func startDownloadCmd(item *DownloadItem, user *inkbunny.User, client *http.Clie
 		url := utils.ResourceURL(item.URL, user.SID, item.IsPublic)
 		sidURL := utils.AppendSID(item.URL, user.SID)
 
+		f, offset, err := appdownloads.OpenPart(filename)
+		if err != nil {
+			return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+		}
+		defer f.Close()
+
 		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 		if err != nil {
 			return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
 		}
+		appdownloads.RequestFrom(req, offset)
 
 		resp, err = client.Do(req)
 		if err != nil {
@@ -820,16 +960,28 @@ func startDownloadCmd(item *DownloadItem, user *inkbunny.User, client *http.Clie
 
 		if resp.StatusCode == http.StatusTooManyRequests {
 			resp.Body.Close()
-			log.Warn("Rate limited, pausing 5s before retrying...", "file", item.FileName)
-			select {
-			case <-ctx.Done():
-				return DownloadCanceledMsg{Item: item, RunID: runID}
-			case <-time.After(5 * time.Second):
+			// A throttled client holds back the retry for as long as the server asked.
+			if !throttled {
+				log.Warn("Rate limited, pausing 5s before retrying...", "file", item.FileName)
+				select {
+				case <-ctx.Done():
+					return DownloadCanceledMsg{Item: item, RunID: runID}
+				case <-time.After(5 * time.Second):
+				}
 			}
 			return RetryDownloadMsg{Item: item, RunID: runID}
 		}
 
-		if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			// The part file is no shorter than the file, such as one left preallocated.
+			resp.Body.Close()
+			if err := f.Truncate(0); err != nil {
+				return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+			}
+			return RetryDownloadMsg{Item: item, RunID: runID}
+		}
+
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
 			resp.Body.Close()
 			if sidURL != "" && sidURL != url {
 				item.URL = sidURL
@@ -838,21 +990,57 @@ func startDownloadCmd(item *DownloadItem, user *inkbunny.User, client *http.Clie
 			return DownloadErrorMsg{Item: item, Err: fmt.Errorf("unexpected status: %d", resp.StatusCode), RunID: runID}
 		}
 
-		if resp.ContentLength > 0 {
+		if offset == 0 && !item.SingleConnection && appdownloads.CanSegment(resp, connections) {
+			resp.Body.Close()
 			item.TotalSize.Store(resp.ContentLength)
+			hashStr, err := appdownloads.DownloadSegments(ctx, client, url, f, resp.ContentLength, connections, item.Written.Store)
+			if err == nil {
+				err = syncer.Written(f, filename)
+			}
+			if err != nil {
+				// Parts are written all over the file, so what is there cannot be resumed.
+				_ = f.Truncate(0)
+				if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
+					return DownloadCanceledMsg{Item: item, RunID: runID}
+				}
+				if errors.Is(err, appdownloads.ErrSegmentsUnsupported) {
+					item.SingleConnection = true
+					return RetryDownloadMsg{Item: item, RunID: runID}
+				}
+				return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+			}
+			if err := finishPart(f, filename); err != nil {
+				return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+			}
+			return finishDownload(item, hashStr, filename, destinations, entry, index, sidecars, runID)
 		}
 
-		f, err := os.Create(filename)
+		hasher := md5.New()
+		written, err := appdownloads.ResumeFrom(f, resp, offset, hasher)
 		if err != nil {
 			resp.Body.Close()
 			return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
 		}
+		if resp.ContentLength > 0 {
+			item.TotalSize.Store(written + resp.ContentLength)
+		}
+		item.Written.Store(written)
 
-		hasher := md5.New()
-		writer := io.MultiWriter(f, hasher)
+		preallocated := false
+		if written == 0 {
+			if preallocated, err = appdownloads.PreallocateFile(f, resp.ContentLength); err != nil {
+				f.Close()
+				resp.Body.Close()
+				_ = os.Remove(appdownloads.PartPath(filename))
+				return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+			}
+		}
+
+		buffered := appdownloads.NewFileWriter(f)
+		writer := io.MultiWriter(buffered, hasher)
 
+		// An interrupted download keeps its part file so the next attempt resumes it.
 		buf := make([]byte, 32*1024)
-		var written int64
 		for {
 			n, err := resp.Body.Read(buf)
 			if n > 0 {
@@ -862,7 +1050,6 @@ func startDownloadCmd(item *DownloadItem, user *inkbunny.User, client *http.Clie
 					item.Written.Store(written)
 				}
 				if ew != nil {
-					f.Close()
 					resp.Body.Close()
 					return DownloadErrorMsg{Item: item, Err: ew, RunID: runID}
 				}
@@ -871,42 +1058,71 @@ func startDownloadCmd(item *DownloadItem, user *inkbunny.User, client *http.Clie
 				if err == io.EOF {
 					break
 				}
-				f.Close()
+				_ = buffered.Flush()
 				resp.Body.Close()
 				if errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled) {
-					_ = os.Remove(filename)
 					return DownloadCanceledMsg{Item: item, RunID: runID}
 				}
 				return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
 			}
 		}
 
-		f.Close()
 		resp.Body.Close()
-
-		hashStr := fmt.Sprintf("%x", hasher.Sum(nil))
-		if item.FileMD5 != "" && hashStr != item.FileMD5 {
-			if item.MD5Retries < 5 {
-				item.MD5Retries++
-				_ = os.Remove(filename)
-				log.Warn("MD5 mismatch, retrying...", "file", item.FileName, "attempt", item.MD5Retries)
-				return RetryDownloadMsg{Item: item, RunID: runID}
-			}
-			return DownloadErrorMsg{Item: item, Err: fmt.Errorf("MD5 mismatch: got %s, expected %s", hashStr, item.FileMD5), RunID: runID}
-		}
-
-		if err := ensureDownloadTargetsFromSource(filename, destinations); err != nil {
+		if err := buffered.Flush(); err != nil {
 			return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
 		}
-		if saveCaption {
-			err := appdownloads.WriteSubmissionMetadata(destinations, item.Metadata)
-			if err != nil {
+		if preallocated {
+			if err := appdownloads.TrimPreallocatedFile(f, written); err != nil {
 				return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
 			}
 		}
+		if err := syncer.Written(f, filename); err != nil {
+			return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+		}
+		if err := finishPart(f, filename); err != nil {
+			return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+		}
+
+		return finishDownload(item, fmt.Sprintf("%x", hasher.Sum(nil)), filename, destinations, entry, index, sidecars, runID)
+	}
+}
+
+// finishPart closes the part file of a finished download and gives it the name
+// of filename.
+func finishPart(f *os.File, filename string) error {
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return appdownloads.FinishPart(filename)
+}
+
+// finishDownload checks the hash of a finished download before linking it to its
+// other destinations, recording it and writing its sidecars.
+func finishDownload(item *DownloadItem, hashStr string, filename string, destinations []string, entry library.Entry, index *library.Index, sidecars apptypes.SidecarOptions, runID int64) tea.Msg {
+	if item.FileMD5 != "" && hashStr != item.FileMD5 {
+		if item.MD5Retries < 5 {
+			item.MD5Retries++
+			_ = os.Remove(filename)
+			log.Warn("MD5 mismatch, retrying...", "file", item.FileName, "attempt", item.MD5Retries)
+			return RetryDownloadMsg{Item: item, RunID: runID}
+		}
+		return DownloadErrorMsg{Item: item, Err: fmt.Errorf("MD5 mismatch: got %s, expected %s", hashStr, item.FileMD5), RunID: runID}
+	}
 
-		return DownloadCompleteMsg{Item: item, RunID: runID}
+	if err := ensureDownloadTargetsFromSource(filename, destinations); err != nil {
+		return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+	}
+	if err := appdownloads.ConvertFiles(destinations, item.Convert); err != nil {
+		return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
 	}
+	if err := index.Record(entry); err != nil {
+		return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+	}
+	if err := appdownloads.WriteSidecars(destinations, item.Metadata, sidecars); err != nil {
+		return DownloadErrorMsg{Item: item, Err: err, RunID: runID}
+	}
+
+	return DownloadCompleteMsg{Item: item, RunID: runID}
 }
 
 func uniqueNonEmptyPaths(paths []string) []string {
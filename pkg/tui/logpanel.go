@@ -0,0 +1,139 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// logPanelLines is how many of the newest lines the log panel keeps.
+const logPanelLines = 1000
+
+// LogPanel keeps the newest lines logged while the downloads are shown, so
+// that the dashboard can show them in a panel of its own instead of them
+// being written over it. It is an io.Writer for utils.LogToFileAnd.
+type LogPanel struct {
+	mu      sync.Mutex
+	lines   []string
+	partial string
+}
+
+func NewLogPanel() *LogPanel {
+	return &LogPanel{}
+}
+
+func (p *LogPanel) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	text := p.partial + ansi.Strip(string(b))
+	lines := strings.Split(text, "\n")
+	p.partial = lines[len(lines)-1]
+	for _, line := range lines[:len(lines)-1] {
+		p.lines = append(p.lines, strings.TrimRight(line, "\r"))
+	}
+	if over := len(p.lines) - logPanelLines; over > 0 {
+		p.lines = append(p.lines[:0], p.lines[over:]...)
+	}
+	return len(b), nil
+}
+
+// Lines returns the lines kept so far, oldest first.
+func (p *LogPanel) Lines() []string {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.lines...)
+}
+
+// DownloadFailure is a file that failed to download, kept for the error
+// drawer. Attempts counts how often it failed, and Err is the latest error.
+type DownloadFailure struct {
+	Item     *DownloadItem
+	Err      error
+	Time     time.Time
+	Attempts int
+}
+
+// Retried reports whether the file was downloaded after it failed.
+func (f DownloadFailure) Retried() bool {
+	return f.Item.Status == StatusCompleted
+}
+
+// recordFailure keeps the failure of item, in place of an earlier one.
+func (m *DownloadModel) recordFailure(item *DownloadItem, err error) {
+	for n := range m.failures {
+		if m.failures[n].Item == item {
+			m.failures[n].Err = err
+			m.failures[n].Time = time.Now()
+			m.failures[n].Attempts++
+			return
+		}
+	}
+	m.failures = append(m.failures, DownloadFailure{Item: item, Err: err, Time: time.Now(), Attempts: 1})
+}
+
+// Failures returns every file that failed to download during the run, in the
+// order they first failed.
+func (m *DownloadModel) Failures() []DownloadFailure {
+	return append([]DownloadFailure(nil), m.failures...)
+}
+
+// scroll moves the error drawer when it is open, or else the log panel, by
+// lines, where a positive number goes back to older lines.
+func (m *DownloadModel) scroll(lines int) {
+	if m.ShowErrors {
+		m.ErrorScroll = max(0, min(m.ErrorScroll+lines, len(m.failures)-1))
+		return
+	}
+	m.LogScroll = max(0, min(m.LogScroll+lines, len(m.Logs.Lines())-1))
+}
+
+var (
+	panelHeadingStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#5F7FFF")).Bold(true)
+	panelHintStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("#888888"))
+	failureStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("#E06060"))
+)
+
+// renderErrorDrawer lists the failures, newest last, in height lines below its
+// heading, scrolled back by m.ErrorScroll.
+func (m *DownloadModel) renderErrorDrawer(width int, height int) []string {
+	out := []string{panelHeadingStyle.Render(fmt.Sprintf("▾ Errors (%d)", len(m.failures))) + panelHintStyle.Render("  e to close · ↑/↓ to scroll · r to retry")}
+	if len(m.failures) == 0 {
+		return append(out, panelHintStyle.Render("  No failures so far."))
+	}
+	end := len(m.failures) - m.ErrorScroll
+	for _, failure := range m.failures[max(0, end-height):end] {
+		line := fmt.Sprintf("%s ✗ %s: %v", failure.Time.Format(time.TimeOnly), failure.Item.FileName, failure.Err)
+		if failure.Attempts > 1 {
+			line += fmt.Sprintf(" (%d attempts)", failure.Attempts)
+		}
+		if failure.Retried() {
+			out = append(out, panelHintStyle.Render(truncateToWidth(line+" · downloaded on retry", width)))
+			continue
+		}
+		out = append(out, failureStyle.Render(truncateToWidth(line, width)))
+	}
+	return out
+}
+
+// renderLogPanel shows height of the logged lines below its heading, the
+// newest ones unless scrolled back by m.LogScroll.
+func (m *DownloadModel) renderLogPanel(width int, height int) []string {
+	lines := m.Logs.Lines()
+	heading := "Log"
+	if m.LogScroll > 0 {
+		heading = fmt.Sprintf("Log (%d lines back)", m.LogScroll)
+	}
+	out := []string{panelHeadingStyle.Render(heading) + panelHintStyle.Render("  ↑/↓ to scroll · e for errors")}
+	end := max(0, len(lines)-m.LogScroll)
+	for _, line := range lines[max(0, end-height):end] {
+		out = append(out, panelHintStyle.Render(truncateToWidth(line, width)))
+	}
+	return out
+}
@@ -1,7 +1,11 @@
 package tui
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -10,6 +14,8 @@ import (
 	zone "github.com/lrstanley/bubblezone"
 
 	"github.com/ellypaws/inkbunny"
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/library"
 	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
 	"github.com/ellypaws/inkbunny/cmd/downloader/pkg/flight"
 )
@@ -26,6 +32,8 @@ const (
 	FieldMaxActive
 	FieldDownloadDirectory
 	FieldDownloadPattern
+	FieldCharacters
+	FieldKeywordBlacklist
 	FieldNone
 )
 
@@ -36,22 +44,27 @@ var FocusableZones = []string{
 	"search_words", "btn_search_top",
 	"rad_and", "rad_or", "rad_exact",
 	"chk_keywords", "chk_title", "chk_desc", "chk_md5",
-	"artist_name", "link_use_my_name_artist", "link_use_my_watches_artist",
+	"artist_name", "link_use_my_name_artist", "link_use_my_watches_artist", "link_mirror_artist",
 	"fav_by", "link_use_my_name_fav",
 	"cycle_time", "pool_id", "cycle_scraps",
 	"chk_rate_gen", "chk_rate_nudity", "chk_rate_mildv", "chk_rate_sex", "chk_rate_strongv",
 	"rad_type_any", "chk_type_pic", "chk_type_sketch", "chk_type_picseries", "chk_type_comic",
 	"chk_type_port", "chk_type_swfanim", "chk_type_swfint", "chk_type_vidfeat", "chk_type_vidanim",
 	"chk_type_musicsing", "chk_type_musicalb", "chk_type_writing", "chk_type_char", "chk_type_photo",
-	"cycle_order", "per_page", "max_dl", "max_active", "download_dir", "download_pattern", "chk_dl_caption",
+	"cycle_order", "per_page", "max_dl", "max_active", "download_dir", "download_pattern", "cycle_collabs", "characters", "keyword_blacklist", "cycle_ai",
+	"chk_sc_keywords", "chk_sc_metadata", "chk_sc_description", "chk_sc_comments", "chk_sc_submission", "chk_sc_hydrus", "cycle_caption_format",
 	"btn_search_bottom", "btn_unread", "btn_logout",
 }
 
+// SuggestKeywordMsg and SuggestUsernameMsg carry the ID of the fetch they
+// answer, so that those of a prefix typed over since are dropped.
 type SuggestKeywordMsg struct {
+	ID          int
 	Suggestions []string
 }
 
 type SuggestUsernameMsg struct {
+	ID          int
 	Field       activeField
 	Suggestions []string
 }
@@ -83,6 +96,8 @@ type Model struct {
 	MaxActive      textinput.Model
 	DownloadDir    textinput.Model
 	DownloadPath   textinput.Model
+	Characters     textinput.Model
+	Blacklist      textinput.Model
 
 	ActiveField activeField
 	HoveredZone string
@@ -90,12 +105,19 @@ type Model struct {
 
 	KeywordCache  *flight.Cache[string, []inkbunny.KeywordAutocomplete]
 	UsernameCache *flight.Cache[string, []inkbunny.Autocomplete]
+	// History ranks keyword suggestions by how often they appear in the library.
+	History *library.Index
 
 	Suggestions     []string
 	SuggestionField activeField
 	SuggestionIndex int
 	lastQuery       string
 
+	// cancelSuggestions gives up on the suggestions being fetched, which the
+	// next change to a field does before fetching its own.
+	cancelSuggestions context.CancelFunc
+	suggestionsID     int
+
 	// Options
 	StringJoinType inkbunny.JoinType
 
@@ -116,13 +138,22 @@ type Model struct {
 	OrderByLabels []string
 	OrderByValues []string
 
-	DownloadCaption   bool
+	CollabsIndex  int
+	CollabsLabels []string
+	CollabsValues []string
+
+	AIIndex  int
+	AILabels []string
+	AIValues []string
+
+	Sidecars          apptypes.SidecarOptions
 	UnreadMode        bool
 	UnreadCount       int
 	CanUseUnread      bool
 	CanUseWatching    bool
 	WatchingUsers     []string
 	UseWatchingArtist bool
+	MirrorMode        bool
 
 	// Ratings
 	RatingGeneral        bool
@@ -267,6 +298,16 @@ func NewModel(
 		downloadPattern.SetValue(value)
 	}
 
+	characters := textinput.New()
+	characters.Placeholder = "comma separated, e.g. Elly, Star"
+	characters.Prompt = ""
+	characters.SetValue(strings.Join(settings.Characters, ", "))
+
+	blacklist := textinput.New()
+	blacklist.Placeholder = "comma separated, e.g. sketch, work in progress"
+	blacklist.Prompt = ""
+	blacklist.SetValue(strings.Join(settings.KeywordBlacklist, ", "))
+
 	model := &Model{
 		ZoneManager:      zm,
 		User:             user,
@@ -283,6 +324,8 @@ func NewModel(
 		MaxActive:        maxActive,
 		DownloadDir:      downloadDir,
 		DownloadPath:     downloadPattern,
+		Characters:       characters,
+		Blacklist:        blacklist,
 		KeywordCache:     keywordCache,
 		UsernameCache:    usernameCache,
 
@@ -301,13 +344,18 @@ func NewModel(
 		OrderByLabels: []string{"Newest First", "Most Popular First (by Favs)", "Most Popular First (by Views)"},
 		OrderByValues: []string{inkbunny.OrderByCreateDatetime, inkbunny.OrderByFavs, inkbunny.OrderByViews},
 
-		DownloadCaption: false,
-		UnreadCount:     unreadCount,
-		CanUseUnread:    canUseUnread,
-		CanUseWatching:  canUseWatching,
-		WatchingUsers:   append([]string(nil), watchingUsers...),
-		ActiveField:     FieldSearchWords,
-		FocusIndex:      0,
+		CollabsLabels: []string{"Owner's folder", "collabs folder", "Every artist's folder"},
+		CollabsValues: []string{appdownloads.CollabsOff, appdownloads.CollabsFolder, appdownloads.CollabsLinks},
+		AILabels:      []string{"Keep all", "Exclude AI-generated", "Only AI-generated"},
+		AIValues:      []string{"", appdownloads.AIExclude, appdownloads.AIOnly},
+
+		Sidecars:       settings.Sidecars,
+		UnreadCount:    unreadCount,
+		CanUseUnread:   canUseUnread,
+		CanUseWatching: canUseWatching,
+		WatchingUsers:  append([]string(nil), watchingUsers...),
+		ActiveField:    FieldSearchWords,
+		FocusIndex:     0,
 
 		RatingGeneral:        userRatingsMask[0] == '1',
 		RatingNudity:         userRatingsMask[1] == '1',
@@ -318,20 +366,77 @@ func NewModel(
 		TypeAny: true,
 	}
 
+	model.SetCollabs(settings.Collabs)
+	if filter, err := appdownloads.ParseAIFilter(settings.AIFilter); err == nil {
+		model.SetAIFilter(filter)
+	}
 	model.SavedSettings = model.PersistentSettings()
 	return model
 }
 
+// stopSuggestions cancels the suggestions still being fetched, so that they
+// are not shown once they arrive.
+func (m *Model) stopSuggestions() {
+	if m.cancelSuggestions != nil {
+		m.cancelSuggestions()
+		m.cancelSuggestions = nil
+	}
+	m.suggestionsID++
+}
+
+// restartSuggestions stops the suggestions still being fetched, and returns
+// the context and ID of the fetch that replaces them.
+func (m *Model) restartSuggestions() (context.Context, int) {
+	m.stopSuggestions()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancelSuggestions = cancel
+	return ctx, m.suggestionsID
+}
+
+// lookupSuggestions waits for the suggestions for query until ctx is
+// cancelled. A lookup given up on still finishes in the background and is
+// cached, so typing the prefix again does not ask for it twice.
+func lookupSuggestions[V any](ctx context.Context, cache *flight.Cache[string, []V], query string) ([]V, error) {
+	type lookup struct {
+		results []V
+		err     error
+	}
+	done := make(chan lookup, 1)
+	go func() {
+		results, err := cache.GetWithContext(ctx, query)
+		done <- lookup{results, err}
+	}()
+	select {
+	case l := <-done:
+		if l.err == nil {
+			l.err = ctx.Err()
+		}
+		return l.results, l.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
 func (m *Model) fetchKeywordSuggestions(query string) tea.Cmd {
+	ctx, id := m.restartSuggestions()
 	if m.KeywordCache == nil || strings.TrimSpace(query) == "" {
 		return nil
 	}
 	cache := m.KeywordCache
+	history := m.History
 	return func() tea.Msg {
-		results, err := cache.Get(query)
+		results, err := lookupSuggestions(ctx, cache, query)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
 		if err != nil || len(results) == 0 {
-			return SuggestKeywordMsg{}
+			return SuggestKeywordMsg{ID: id}
 		}
+		// The cached results are shared, so they are ranked on a copy.
+		results = slices.Clone(results)
+		library.RankKeywords(history, results, func(result inkbunny.KeywordAutocomplete) string {
+			return result.Value
+		})
 		suggestions := make([]string, 0, len(results))
 		for _, r := range results {
 			if len(suggestions) >= 10 {
@@ -339,19 +444,23 @@ func (m *Model) fetchKeywordSuggestions(query string) tea.Cmd {
 			}
 			suggestions = append(suggestions, r.Value)
 		}
-		return SuggestKeywordMsg{Suggestions: suggestions}
+		return SuggestKeywordMsg{ID: id, Suggestions: suggestions}
 	}
 }
 
 func (m *Model) fetchUsernameSuggestions(field activeField, query string) tea.Cmd {
+	ctx, id := m.restartSuggestions()
 	if m.UsernameCache == nil || strings.TrimSpace(query) == "" {
 		return nil
 	}
 	cache := m.UsernameCache
 	return func() tea.Msg {
-		results, err := cache.Get(query)
+		results, err := lookupSuggestions(ctx, cache, query)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
 		if err != nil || len(results) == 0 {
-			return SuggestUsernameMsg{Field: field}
+			return SuggestUsernameMsg{ID: id, Field: field}
 		}
 		suggestions := make([]string, 0, len(results))
 		for _, r := range results {
@@ -360,7 +469,7 @@ func (m *Model) fetchUsernameSuggestions(field activeField, query string) tea.Cm
 			}
 			suggestions = append(suggestions, r.Value)
 		}
-		return SuggestUsernameMsg{Field: field, Suggestions: suggestions}
+		return SuggestUsernameMsg{ID: id, Field: field, Suggestions: suggestions}
 	}
 }
 
@@ -369,6 +478,44 @@ func (m *Model) TimeRange() inkbunny.IntString {
 	return m.TimeRangeValues[m.TimeRangeIndex]
 }
 
+func (m *Model) SetCollabs(mode string) {
+	mode = appdownloads.NormalizeCollabsMode(mode)
+	for i, value := range m.CollabsValues {
+		if value == mode {
+			m.CollabsIndex = i
+			return
+		}
+	}
+}
+
+func (m *Model) Collabs() string {
+	return m.CollabsValues[m.CollabsIndex]
+}
+
+func (m *Model) SetAIFilter(filter string) {
+	if i := slices.Index(m.AIValues, filter); i >= 0 {
+		m.AIIndex = i
+	}
+}
+
+func (m *Model) AIFilter() string {
+	return m.AIValues[m.AIIndex]
+}
+
+// CycleCaptionFormat moves the keywords sidecar on to the next caption format.
+// The terminal UI has no input for the template, so the template format is
+// only offered when --caption-template gave one.
+func (m *Model) CycleCaptionFormat() {
+	formats := appdownloads.CaptionFormats
+	if m.Sidecars.CaptionTemplate == "" {
+		formats = slices.DeleteFunc(slices.Clone(formats), func(format string) bool {
+			return format == appdownloads.CaptionTemplate
+		})
+	}
+	current := slices.Index(formats, cmp.Or(m.Sidecars.CaptionFormat, appdownloads.CaptionTags))
+	m.Sidecars.CaptionFormat = formats[(current+1)%len(formats)]
+}
+
 func (m *Model) OrderBy() string {
 	return m.OrderByValues[m.OrderByIndex]
 }
@@ -405,6 +552,14 @@ func (m *Model) DownloadPatternValue() string {
 	return strings.TrimSpace(m.DownloadPath.Placeholder)
 }
 
+func (m *Model) CharactersValue() []string {
+	return appdownloads.ParseCharacters(m.Characters.Value())
+}
+
+func (m *Model) BlacklistValue() []string {
+	return appdownloads.ParseKeywordBlacklist(m.Blacklist.Value())
+}
+
 func (m *Model) MaxActiveValue() int {
 	value := strings.TrimSpace(m.MaxActive.Value())
 	if value == "" {
@@ -475,13 +630,23 @@ func (m *Model) PersistentSettings() apptypes.AppSettings {
 		DownloadDirectory: m.DownloadDirectoryValue(),
 		DownloadPattern:   m.DownloadPatternValue(),
 		MaxActive:         m.MaxActiveValue(),
+		Sidecars:          m.Sidecars,
+		Collabs:           m.Collabs(),
+		Characters:        m.CharactersValue(),
+		KeywordBlacklist:  m.BlacklistValue(),
+		AIFilter:          m.AIFilter(),
 	}
 }
 
 func samePersistentSettings(a, b apptypes.AppSettings) bool {
 	return strings.TrimSpace(a.DownloadDirectory) == strings.TrimSpace(b.DownloadDirectory) &&
 		strings.TrimSpace(a.DownloadPattern) == strings.TrimSpace(b.DownloadPattern) &&
-		a.MaxActive == b.MaxActive
+		a.MaxActive == b.MaxActive &&
+		a.Sidecars == b.Sidecars &&
+		a.Collabs == b.Collabs &&
+		slices.Equal(a.Characters, b.Characters) &&
+		slices.Equal(a.KeywordBlacklist, b.KeywordBlacklist) &&
+		a.AIFilter == b.AIFilter
 }
 
 func (m *Model) ArtistFilters() []string {
@@ -0,0 +1,93 @@
+package tui
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ellypaws/inkbunny"
+
+	appdownloads "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/downloads"
+	apptypes "github.com/ellypaws/inkbunny/cmd/downloader/pkg/app/types"
+)
+
+// ApplyPreset fills the search form from a saved preset. Fields the preset
+// leaves empty keep what the form already had.
+func (m *Model) ApplyPreset(preset apptypes.SyncPreset) {
+	search := preset.Search
+	m.SearchWords.SetValue(search.Query)
+	if search.JoinType != "" {
+		m.StringJoinType = inkbunny.JoinType(strings.ToLower(search.JoinType))
+	}
+	if search.SearchInKeywords || search.SearchInTitle || search.SearchInDescription || search.SearchInMD5 {
+		m.SearchInKeywords = search.SearchInKeywords
+		m.SearchInTitle = search.SearchInTitle
+		m.SearchInDesc = search.SearchInDescription
+		m.SearchInMD5 = search.SearchInMD5
+	}
+	m.ArtistName.SetValue(strings.Join(search.ArtistNames, ", "))
+	m.UseWatchingArtist = search.UseWatchingArtists && m.CanUseWatching
+	m.FavBy.SetValue(search.FavoritesBy)
+	m.PoolID.SetValue("")
+	if search.PoolID > 0 {
+		m.PoolID.SetValue(strconv.Itoa(search.PoolID))
+	}
+	if i := slices.Index(m.TimeRangeValues, inkbunny.IntString(search.TimeRangeDays)); i >= 0 {
+		m.TimeRangeIndex = i
+	}
+	if i := slices.Index(m.ScrapsValues, inkbunny.Scraps(strings.ToLower(search.Scraps))); i >= 0 {
+		m.ScrapsIndex = i
+	}
+	if i := slices.Index(m.OrderByValues, search.OrderBy); i >= 0 {
+		m.OrderByIndex = i
+	}
+	m.setSubmissionTypes(search.SubmissionTypes)
+	m.MaxDownloads.SetValue("")
+	if search.MaxDownloads > 0 {
+		m.MaxDownloads.SetValue(strconv.Itoa(search.MaxDownloads))
+	}
+	if active := cmp.Or(preset.Options.MaxActive, search.MaxActive); active > 0 {
+		m.MaxActive.SetValue(strconv.Itoa(active))
+	}
+
+	options := preset.Options
+	if options.Sidecars != nil {
+		m.Sidecars = *options.Sidecars
+	} else if options.SaveKeywords || search.SaveKeywords {
+		m.Sidecars = appdownloads.LegacySidecars(true)
+	}
+	if value := strings.TrimSpace(options.DownloadDirectory); value != "" {
+		m.DownloadDir.SetValue(value)
+	}
+	if value := strings.TrimSpace(options.DownloadPattern); value != "" {
+		m.DownloadPath.SetValue(value)
+	}
+	if options.Collabs != "" {
+		m.SetCollabs(options.Collabs)
+	}
+	if len(options.Characters) > 0 {
+		m.Characters.SetValue(strings.Join(options.Characters, ", "))
+	}
+}
+
+func (m *Model) setSubmissionTypes(types []int) {
+	has := func(submissionType inkbunny.SubmissionType) bool {
+		return slices.Contains(types, int(submissionType))
+	}
+	m.TypeAny = len(types) == 0 || has(inkbunny.SubmissionTypeAny)
+	m.TypePicture = !m.TypeAny && has(inkbunny.SubmissionTypePicturePinup)
+	m.TypeSketch = !m.TypeAny && has(inkbunny.SubmissionTypeSketch)
+	m.TypePictureSeries = !m.TypeAny && has(inkbunny.SubmissionTypePictureSeries)
+	m.TypeComic = !m.TypeAny && has(inkbunny.SubmissionTypeComic)
+	m.TypePortfolio = !m.TypeAny && has(inkbunny.SubmissionTypePortfolio)
+	m.TypeSWFAnimation = !m.TypeAny && has(inkbunny.SubmissionTypeShockwaveFlashAnimation)
+	m.TypeSWFInteract = !m.TypeAny && has(inkbunny.SubmissionTypeShockwaveFlashInteractive)
+	m.TypeVideoFeature = !m.TypeAny && has(inkbunny.SubmissionTypeVideoFeatureLength)
+	m.TypeVideoAnim = !m.TypeAny && has(inkbunny.SubmissionTypeVideoAnimation3DCGI)
+	m.TypeMusicSingle = !m.TypeAny && has(inkbunny.SubmissionTypeMusicSingleTrack)
+	m.TypeMusicAlbum = !m.TypeAny && has(inkbunny.SubmissionTypeMusicAlbum)
+	m.TypeWriting = !m.TypeAny && has(inkbunny.SubmissionTypeWritingDocument)
+	m.TypeCharSheet = !m.TypeAny && has(inkbunny.SubmissionTypeCharacterSheet)
+	m.TypePhotography = !m.TypeAny && has(inkbunny.SubmissionTypePhotography)
+}
@@ -52,6 +52,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				// If no suggestion selected, fall through to default enter behavior
 			case "esc":
+				m.stopSuggestions()
 				m.Suggestions = nil
 				m.SuggestionIndex = -1
 				return m, nil
@@ -100,7 +101,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 	case SuggestKeywordMsg:
-		if m.ActiveField == FieldSearchWords {
+		if m.ActiveField == FieldSearchWords && msg.ID == m.suggestionsID {
 			m.Suggestions = msg.Suggestions
 			m.SuggestionField = FieldSearchWords
 			m.SuggestionIndex = -1
@@ -108,7 +109,7 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case SuggestUsernameMsg:
-		if m.ActiveField == msg.Field {
+		if m.ActiveField == msg.Field && msg.ID == m.suggestionsID {
 			m.Suggestions = msg.Suggestions
 			m.SuggestionField = msg.Field
 			m.SuggestionIndex = -1
@@ -164,6 +165,10 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	cmds = append(cmds, cmd)
 	m.DownloadPath, cmd = updateInput(m.DownloadPath, msg)
 	cmds = append(cmds, cmd)
+	m.Characters, cmd = updateInput(m.Characters, msg)
+	cmds = append(cmds, cmd)
+	m.Blacklist, cmd = updateInput(m.Blacklist, msg)
+	cmds = append(cmds, cmd)
 
 	if q := m.SearchWords.Value(); q != prevSearch && q != m.lastQuery {
 		m.lastQuery = q
@@ -274,14 +279,15 @@ func (m *Model) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
 
 	if m.HoveredZone == "" {
 		_ = hoverCheck("btn_update_open") || hoverCheck("btn_update_later") || hoverCheck("btn_update_skip") ||
-			hoverCheck("btn_logout") || hoverCheck("btn_unread") || hoverCheck("search_words") || hoverCheck("artist_name") || hoverCheck("fav_by") || hoverCheck("pool_id") || hoverCheck("per_page") || hoverCheck("max_dl") || hoverCheck("max_active") || hoverCheck("download_dir") || hoverCheck("download_pattern") ||
+			hoverCheck("btn_logout") || hoverCheck("btn_unread") || hoverCheck("search_words") || hoverCheck("artist_name") || hoverCheck("fav_by") || hoverCheck("pool_id") || hoverCheck("per_page") || hoverCheck("max_dl") || hoverCheck("max_active") || hoverCheck("download_dir") || hoverCheck("download_pattern") || hoverCheck("characters") || hoverCheck("keyword_blacklist") ||
 			hoverCheck("btn_search_top") || hoverCheck("btn_search_bottom") ||
-			hoverCheck("link_use_my_name_artist") || hoverCheck("link_use_my_watches_artist") || hoverCheck("link_use_my_name_fav") ||
+			hoverCheck("link_use_my_name_artist") || hoverCheck("link_use_my_watches_artist") || hoverCheck("link_mirror_artist") || hoverCheck("link_use_my_name_fav") ||
 			hoverCheck("rad_and") || hoverCheck("rad_or") || hoverCheck("rad_exact") ||
 			hoverCheck("chk_keywords") || hoverCheck("chk_title") || hoverCheck("chk_desc") || hoverCheck("chk_md5") ||
 			hoverCheck("chk_rate_gen") || hoverCheck("chk_rate_nudity") || hoverCheck("chk_rate_mildv") || hoverCheck("chk_rate_sex") || hoverCheck("chk_rate_strongv") ||
 			hoverCheck("rad_type_any") || hoverCheck("chk_type_pic") || hoverCheck("chk_type_sketch") || hoverCheck("chk_type_picseries") || hoverCheck("chk_type_comic") || hoverCheck("chk_type_port") || hoverCheck("chk_type_swfanim") || hoverCheck("chk_type_swfint") || hoverCheck("chk_type_vidfeat") || hoverCheck("chk_type_vidanim") || hoverCheck("chk_type_musicsing") || hoverCheck("chk_type_musicalb") || hoverCheck("chk_type_writing") || hoverCheck("chk_type_char") || hoverCheck("chk_type_photo") ||
-			hoverCheck("cycle_time") || hoverCheck("cycle_scraps") || hoverCheck("cycle_order") || hoverCheck("chk_dl_caption")
+			hoverCheck("cycle_time") || hoverCheck("cycle_scraps") || hoverCheck("cycle_order") || hoverCheck("cycle_collabs") || hoverCheck("cycle_ai") ||
+			hoverCheck("chk_sc_keywords") || hoverCheck("chk_sc_metadata") || hoverCheck("chk_sc_description") || hoverCheck("chk_sc_comments") || hoverCheck("chk_sc_submission") || hoverCheck("chk_sc_hydrus") || hoverCheck("cycle_caption_format")
 	}
 
 	return m, nil
@@ -316,6 +322,12 @@ func (m *Model) triggerZone(id string) (tea.Model, tea.Cmd) {
 	case "download_pattern":
 		m.ActiveField = FieldDownloadPattern
 		m.focusActiveField()
+	case "characters":
+		m.ActiveField = FieldCharacters
+		m.focusActiveField()
+	case "keyword_blacklist":
+		m.ActiveField = FieldKeywordBlacklist
+		m.focusActiveField()
 	case "btn_logout":
 		if m.User != nil {
 			_ = m.User.Logout()
@@ -356,6 +368,8 @@ func (m *Model) triggerZone(id string) (tea.Model, tea.Cmd) {
 			m.Suggestions = nil
 			m.SuggestionIndex = -1
 		}
+	case "link_mirror_artist":
+		m.MirrorMode = !m.MirrorMode
 	case "link_use_my_name_fav":
 		m.FavBy.SetValue(appendUniqueUserFilter(m.FavBy.Value(), m.Username))
 		m.FavBy.CursorEnd()
@@ -434,8 +448,24 @@ func (m *Model) triggerZone(id string) (tea.Model, tea.Cmd) {
 		m.ScrapsIndex = (m.ScrapsIndex + 1) % len(m.ScrapsLabels)
 	case "cycle_order":
 		m.OrderByIndex = (m.OrderByIndex + 1) % len(m.OrderByLabels)
-	case "chk_dl_caption":
-		m.DownloadCaption = !m.DownloadCaption
+	case "cycle_collabs":
+		m.CollabsIndex = (m.CollabsIndex + 1) % len(m.CollabsLabels)
+	case "cycle_ai":
+		m.AIIndex = (m.AIIndex + 1) % len(m.AILabels)
+	case "cycle_caption_format":
+		m.CycleCaptionFormat()
+	case "chk_sc_keywords":
+		m.Sidecars.Keywords = !m.Sidecars.Keywords
+	case "chk_sc_metadata":
+		m.Sidecars.Metadata = !m.Sidecars.Metadata
+	case "chk_sc_description":
+		m.Sidecars.Description = !m.Sidecars.Description
+	case "chk_sc_comments":
+		m.Sidecars.Comments = !m.Sidecars.Comments
+	case "chk_sc_submission":
+		m.Sidecars.Submission = !m.Sidecars.Submission
+	case "chk_sc_hydrus":
+		m.Sidecars.Hydrus = !m.Sidecars.Hydrus
 	}
 	return m, nil
 }
@@ -461,6 +491,10 @@ func (m *Model) updateActiveField() {
 		m.ActiveField = FieldDownloadDirectory
 	case "download_pattern":
 		m.ActiveField = FieldDownloadPattern
+	case "characters":
+		m.ActiveField = FieldCharacters
+	case "keyword_blacklist":
+		m.ActiveField = FieldKeywordBlacklist
 	default:
 		m.ActiveField = FieldNone
 	}
@@ -617,6 +651,8 @@ func (m *Model) focusActiveField() {
 	m.MaxActive.Blur()
 	m.DownloadDir.Blur()
 	m.DownloadPath.Blur()
+	m.Characters.Blur()
+	m.Blacklist.Blur()
 
 	switch m.ActiveField {
 	case FieldSearchWords:
@@ -637,5 +673,9 @@ func (m *Model) focusActiveField() {
 		m.DownloadDir.Focus()
 	case FieldDownloadPattern:
 		m.DownloadPath.Focus()
+	case FieldCharacters:
+		m.Characters.Focus()
+	case FieldKeywordBlacklist:
+		m.Blacklist.Focus()
 	}
 }
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"cmp"
 	"fmt"
 	"strings"
 
@@ -256,6 +257,7 @@ func (m *Model) renderMiddleSection() string {
 		artistInput = m.renderStaticInput("artist_name", label)
 	}
 	artistLink := m.renderLink("link_use_my_name_artist", "Use my name", "(Search my uploads only)")
+	artistMirrorLink := m.renderLink("link_mirror_artist", "Mirror gallery", "(Whole gallery with scraps, only new work on later runs)")
 	artistWatchLink := ""
 	if m.CanUseWatching {
 		artistWatchLink = m.renderLink("link_use_my_watches_artist", "My watches", fmt.Sprintf("(Use %d watched users)", len(m.WatchingUsers)))
@@ -273,9 +275,13 @@ func (m *Model) renderMiddleSection() string {
 	if artistWatchLink != "" {
 		artistParts = append(artistParts, lipgloss.JoinHorizontal(lipgloss.Top, subLabelStyle.Render(""), artistWatchLink))
 	}
+	artistParts = append(artistParts, lipgloss.JoinHorizontal(lipgloss.Top, subLabelStyle.Render(""), artistMirrorLink))
 	if m.UseWatchingArtist {
 		artistParts = append(artistParts, helperTextStyle.Render(fmt.Sprintf("Using watched artists only (%d users).", len(m.WatchingUsers))))
 	}
+	if m.MirrorMode {
+		artistParts = append(artistParts, helperTextStyle.Render("Mirroring one artist. The search words and filters are ignored."))
+	}
 	if artistSugBlock != "" {
 		artistParts = append(artistParts, artistSugBlock)
 	}
@@ -378,13 +384,36 @@ func (m *Model) renderFooterSection() string {
 	downloadPatternLabel := labelStyle.Render("Download pattern:")
 	downloadPatternInput := m.renderInput("download_pattern", m.DownloadPath, FieldDownloadPattern)
 
-	dlCaptionLabel := labelStyle.Render("Download metadata:")
-	dlCaptionCheckbox := m.renderCheckbox("chk_dl_caption", m.DownloadCaption, "Save as .json")
+	collabsLabel := labelStyle.Render("Collaborations:")
+	collabsCycle := m.renderCycle("cycle_collabs", m.CollabsLabels[m.CollabsIndex])
+
+	charactersLabel := labelStyle.Render("Characters:")
+	charactersInput := m.renderInput("characters", m.Characters, FieldCharacters)
+	charactersHint := helperTextStyle.Render("Matching keywords are also linked under characters/<name>/.")
+
+	blacklistLabel := labelStyle.Render("Blacklist:")
+	blacklistInput := m.renderInput("keyword_blacklist", m.Blacklist, FieldKeywordBlacklist)
+	blacklistHint := helperTextStyle.Render("Submissions with any of these keywords are skipped.")
+
+	aiLabel := labelStyle.Render("AI content:")
+	aiCycle := m.renderCycle("cycle_ai", m.AILabels[m.AIIndex])
+
+	sidecarsLabel := labelStyle.Render("Sidecars:")
+	sc1 := m.renderCheckbox("chk_sc_keywords", m.Sidecars.Keywords, "Keywords .txt")
+	sc2 := m.renderCheckbox("chk_sc_metadata", m.Sidecars.Metadata, "Metadata .json")
+	sc3 := m.renderCheckbox("chk_sc_description", m.Sidecars.Description, "Description .md")
+	sc4 := m.renderCheckbox("chk_sc_comments", m.Sidecars.Comments, "Comments .json")
+	sc5 := m.renderCheckbox("chk_sc_submission", m.Sidecars.Submission, "Submission .submission.json")
+	sc6 := m.renderCheckbox("chk_sc_hydrus", m.Sidecars.Hydrus, "Hydrus .tags/.urls.txt")
+
+	captionFormatLabel := labelStyle.Render("Caption format:")
+	captionFormatCycle := m.renderCycle("cycle_caption_format", cmp.Or(m.Sidecars.CaptionFormat, appdownloads.CaptionTags))
+	captionFormatHint := helperTextStyle.Render("How Keywords .txt is written: tags, booru, jsonl (metadata.jsonl), or template when --caption-template is given.")
 
 	patternHint := helperTextStyle.Render("Pattern tokens use {name}, e.g. {artist}, {submission_id}, {file_name_full}, {ext}.")
 	patternPreview := m.renderDownloadPatternPreview()
 
-	var orderBlock, poolBlock, perPageBlock, scrapsBlock, dlMaxBlock, activeMaxBlock, downloadDirBlock, downloadPatternBlock, dlCaptionBlock string
+	var orderBlock, poolBlock, perPageBlock, scrapsBlock, dlMaxBlock, activeMaxBlock, downloadDirBlock, downloadPatternBlock, collabsBlock, charactersBlock, blacklistBlock, aiBlock, sidecarsBlock, captionFormatBlock string
 
 	if m.Width > 0 && m.Width < 100 {
 		orderBlock = lipgloss.JoinVertical(lipgloss.Left, orderLabel, orderCycle)
@@ -395,7 +424,12 @@ func (m *Model) renderFooterSection() string {
 		activeMaxBlock = lipgloss.JoinVertical(lipgloss.Left, activeMaxLabel, activeMaxInput)
 		downloadDirBlock = lipgloss.JoinVertical(lipgloss.Left, downloadDirLabel, downloadDirInput)
 		downloadPatternBlock = lipgloss.JoinVertical(lipgloss.Left, downloadPatternLabel, downloadPatternInput, patternHint, patternPreview)
-		dlCaptionBlock = lipgloss.JoinVertical(lipgloss.Left, dlCaptionLabel, dlCaptionCheckbox)
+		collabsBlock = lipgloss.JoinVertical(lipgloss.Left, collabsLabel, collabsCycle)
+		charactersBlock = lipgloss.JoinVertical(lipgloss.Left, charactersLabel, charactersInput, charactersHint)
+		blacklistBlock = lipgloss.JoinVertical(lipgloss.Left, blacklistLabel, blacklistInput, blacklistHint)
+		aiBlock = lipgloss.JoinVertical(lipgloss.Left, aiLabel, aiCycle)
+		sidecarsBlock = lipgloss.JoinVertical(lipgloss.Left, sidecarsLabel, sc1, sc2, sc3, sc4, sc5, sc6)
+		captionFormatBlock = lipgloss.JoinVertical(lipgloss.Left, captionFormatLabel, captionFormatCycle, captionFormatHint)
 	} else {
 		orderBlock = lipgloss.JoinHorizontal(lipgloss.Center, orderLabel, orderCycle)
 		poolBlock = lipgloss.JoinHorizontal(lipgloss.Center, poolLabel, poolInput)
@@ -409,7 +443,21 @@ func (m *Model) renderFooterSection() string {
 			patternHint,
 			patternPreview,
 		)
-		dlCaptionBlock = lipgloss.JoinHorizontal(lipgloss.Top, dlCaptionLabel, dlCaptionCheckbox)
+		collabsBlock = lipgloss.JoinHorizontal(lipgloss.Center, collabsLabel, collabsCycle)
+		charactersBlock = lipgloss.JoinVertical(lipgloss.Left,
+			lipgloss.JoinHorizontal(lipgloss.Center, charactersLabel, charactersInput),
+			charactersHint,
+		)
+		blacklistBlock = lipgloss.JoinVertical(lipgloss.Left,
+			lipgloss.JoinHorizontal(lipgloss.Center, blacklistLabel, blacklistInput),
+			blacklistHint,
+		)
+		aiBlock = lipgloss.JoinHorizontal(lipgloss.Center, aiLabel, aiCycle)
+		sidecarsBlock = lipgloss.JoinHorizontal(lipgloss.Top, sidecarsLabel, sc1, "   ", sc2, "   ", sc3, "   ", sc4, "   ", sc5, "   ", sc6)
+		captionFormatBlock = lipgloss.JoinVertical(lipgloss.Left,
+			lipgloss.JoinHorizontal(lipgloss.Center, captionFormatLabel, captionFormatCycle),
+			captionFormatHint,
+		)
 	}
 
 	searchBtn := m.renderButton("btn_search_bottom", "Search")
@@ -423,7 +471,12 @@ func (m *Model) renderFooterSection() string {
 		activeMaxBlock, "",
 		downloadDirBlock, "",
 		downloadPatternBlock, "",
-		dlCaptionBlock, "",
+		collabsBlock, "",
+		charactersBlock, "",
+		blacklistBlock, "",
+		aiBlock, "",
+		sidecarsBlock, "",
+		captionFormatBlock, "",
 		searchBtn,
 	)
 }
@@ -618,7 +671,7 @@ func (m *Model) renderCycle(id string, label string) string {
 func (m *Model) renderLink(id string, text string, hint string) string {
 	currentFocus := m.currentFocusZone()
 	style := linkStyle
-	active := id == "link_use_my_watches_artist" && m.UseWatchingArtist
+	active := (id == "link_use_my_watches_artist" && m.UseWatchingArtist) || (id == "link_mirror_artist" && m.MirrorMode)
 	if active || m.HoveredZone == id || currentFocus == id {
 		style = linkHoverStyle
 	}
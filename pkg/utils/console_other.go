@@ -0,0 +1,9 @@
+//go:build !windows
+
+package utils
+
+// utf8Console does nothing outside of Windows, where terminals already take
+// UTF-8.
+func utf8Console() func() {
+	return func() {}
+}
@@ -0,0 +1,26 @@
+//go:build windows
+
+package utils
+
+import "golang.org/x/sys/windows"
+
+// utf8CodePage is CP_UTF8.
+const utf8CodePage = 65001
+
+// utf8Console switches the console to UTF-8, so that titles and keywords in
+// other scripts print as they are rather than in the code page of the system,
+// and returns a func that puts back the code pages it had. Output that is not
+// a console, such as a pipe, is left alone.
+func utf8Console() func() {
+	input, inputErr := windows.GetConsoleCP()
+	output, outputErr := windows.GetConsoleOutputCP()
+	if inputErr != nil || outputErr != nil {
+		return func() {}
+	}
+	_ = windows.SetConsoleCP(utf8CodePage)
+	_ = windows.SetConsoleOutputCP(utf8CodePage)
+	return func() {
+		_ = windows.SetConsoleCP(input)
+		_ = windows.SetConsoleOutputCP(output)
+	}
+}
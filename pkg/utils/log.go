@@ -7,12 +7,26 @@ import (
 	"github.com/charmbracelet/log"
 )
 
-func LogOutput(writer io.Writer) func() {
-	f, _ := os.OpenFile("log.txt", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+// logFile is the file LogOutput logs to, if any, and logOutput where it sends
+// logs altogether.
+var logFile, logOutput io.Writer
 
-	mw := io.MultiWriter(writer, f)
+// LogOutput sends logs to writer and to the file at path, or only to writer
+// when path is empty.
+func LogOutput(writer io.Writer, path string) func() {
+	mw := writer
+	var f *os.File
+	if path != "" {
+		f, _ = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+		mw = io.MultiWriter(writer, f)
+	}
+	logFile, logOutput = nil, mw
+	if f != nil {
+		logFile = f
+	}
 	r, w, _ := os.Pipe()
 
+	restoreConsole := utf8Console()
 	log.SetOutput(mw)
 
 	exit := make(chan bool)
@@ -26,6 +40,32 @@ func LogOutput(writer io.Writer) func() {
 	return func() {
 		_ = w.Close()
 		<-exit
-		_ = f.Close()
+		if f != nil {
+			_ = f.Close()
+		}
+		restoreConsole()
+	}
+}
+
+// LogToFileOnly stops logs from going to the writer of LogOutput while a view
+// takes over the terminal, keeping them in its file. The returned func sends
+// them to both again. It reports false and changes nothing when there is no
+// file to keep them in.
+func LogToFileOnly() (func(), bool) {
+	if logFile == nil {
+		return nil, false
+	}
+	log.SetOutput(logFile)
+	return func() { log.SetOutput(logOutput) }, true
+}
+
+// LogToFileAnd sends logs to writer in place of the writer of LogOutput while
+// a view takes over the terminal and shows them itself, still keeping them in
+// its file. The returned func sends them to both again.
+func LogToFileAnd(writer io.Writer) func() {
+	if logFile != nil {
+		writer = io.MultiWriter(logFile, writer)
 	}
+	log.SetOutput(writer)
+	return func() { log.SetOutput(logOutput) }
 }
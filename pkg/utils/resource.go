@@ -2,6 +2,7 @@ package utils
 
 import (
 	"net/url"
+	"slices"
 	"strings"
 )
 
@@ -95,3 +96,30 @@ func ResourceURL(raw string, sid string, isPublic bool) string {
 	}
 	return SetSID(raw, sid)
 }
+
+// FileHosts are the hosts Inkbunny serves the same files from.
+var FileHosts = []string{"us.ib.metapix.net", "tx.ib.metapix.net", "nl.ib.metapix.net", "ib.metapix.net"}
+
+// WithHost returns the URL with its host replaced by host.
+func WithHost(raw string, host string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return raw
+	}
+	parsed.Host = host
+	return parsed.String()
+}
+
+// HostOf returns the host of the URL, or an empty string if it has none.
+func HostOf(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// FileHost reports whether host is one of FileHosts, which serve the same files.
+func FileHost(host string) bool {
+	return slices.Contains(FileHosts, strings.ToLower(host))
+}
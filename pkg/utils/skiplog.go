@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/charmbracelet/log"
+)
+
+type SkipLogMode string
+
+const (
+	// SkipLogSummary counts skips and reports one line per category at the end.
+	SkipLogSummary SkipLogMode = "summary"
+	// SkipLogVerbose logs every skip as it happens.
+	SkipLogVerbose SkipLogMode = "verbose"
+	// SkipLogQuiet drops skip logs entirely.
+	SkipLogQuiet SkipLogMode = "quiet"
+)
+
+var ErrUnknownSkipLogMode = errors.New("unknown skip log mode")
+
+// ParseSkipLogMode parses a --skip-log value, defaulting to SkipLogSummary when empty.
+func ParseSkipLogMode(value string) (SkipLogMode, error) {
+	switch mode := SkipLogMode(strings.ToLower(strings.TrimSpace(value))); mode {
+	case "":
+		return SkipLogSummary, nil
+	case SkipLogSummary, SkipLogVerbose, SkipLogQuiet:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownSkipLogMode, value)
+	}
+}
+
+// SkipLog collects repetitive skip warnings per category so they can be
+// summarized instead of flooding the log.
+type SkipLog struct {
+	mode SkipLogMode
+
+	mu     sync.Mutex
+	order  []string
+	counts map[string]int
+}
+
+func NewSkipLog(mode SkipLogMode) *SkipLog {
+	return &SkipLog{
+		mode:   mode,
+		counts: make(map[string]int),
+	}
+}
+
+// Skip records a skip under category. In verbose mode msg is logged immediately
+// with keyvals; otherwise only the count is kept.
+func (s *SkipLog) Skip(category string, msg string, keyvals ...any) {
+	if s.mode == SkipLogVerbose {
+		log.Warn(msg, keyvals...)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.counts[category]; !ok {
+		s.order = append(s.order, category)
+	}
+	s.counts[category]++
+}
+
+// Summarize logs one line per category, e.g. "skipped 214 files already downloaded".
+// It does nothing outside of summary mode.
+func (s *SkipLog) Summarize() {
+	if s.mode != SkipLogSummary {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, category := range s.order {
+		log.Warnf("Skipped %d %s", s.counts[category], category)
+	}
+}
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"iter"
+	"runtime"
 	"sync"
 )
 
@@ -23,11 +24,15 @@ type jobRequest[J any, R any] struct {
 	promise chan R // nil if not a promise job
 }
 
-// NewWorkerPool creates a new worker pool with the given number of workers.
+// NewWorkerPool creates a new worker pool with the given number of workers,
+// or one per CPU when workers is not positive.
 // The job channel is buffered to the number of workers.
 // The work function should use the channel to receive jobs, and use the callback function to send responses.
 // Inside the work function, the callback function should only be called synchronously or the program might panic.
 func NewWorkerPool[J any, R any](workers int, work func(J) R) WorkerPool[J, R] {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
 	return WorkerPool[J, R]{
 		workers:   workers,
 		work:      work,